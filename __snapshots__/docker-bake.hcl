@@ -0,0 +1,31 @@
+group "default" {
+  targets = ["app", "app-debug"]
+}
+
+target "app" {
+  context    = "."
+  dockerfile = "Dockerfile"
+  contexts   = {
+    docs = "../docs"
+  }
+  platforms  = ["linux/amd64", "linux/arm64"]
+  args       = {
+    GOPROXY = "https://proxy.golang.org"
+    VERSION = ""
+  }
+  tags       = ["ghcr.io/org/app:$${VERSION}"]
+}
+
+target "app-debug" {
+  context    = "."
+  dockerfile = "Dockerfile.debug"
+  contexts   = {
+    docs = "../docs"
+  }
+  platforms  = ["linux/amd64", "linux/arm64"]
+  args       = {
+    GOPROXY = "https://proxy.golang.org"
+    VERSION = ""
+  }
+  tags       = ["ghcr.io/org/app:$${VERSION}"]
+}
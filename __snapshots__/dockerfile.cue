@@ -0,0 +1,124 @@
+package dockerfileyml
+
+#Dockerfile: {
+	add?: {[string]: #Scalar}
+	annotations?: {[string]: #Scalar}
+	arg?: {[string]: #Scalar}
+	artifacts?: [...#Artifact]
+	cmd?: [...#Scalar]
+	"cmd-form"?: "exec" | "shell"
+	comments?: {[string]: #Scalar}
+	contexts?: {[string]: #Scalar}
+	copy?: {[string]: #Scalar} | [...#CopyEntry]
+	directives?: {[string]: #Scalar}
+	entrypoint?: [...#Scalar]
+	"entrypoint-form"?: "exec" | "shell"
+	env?: {[string]: #Scalar}
+	expose?: [...#Scalar]
+	extends?: #Scalar
+	from?: #Scalar
+	healthcheck?: #Scalar
+	ignore?: [...#Scalar]
+	image?: #Scalar
+	include?: [...#Scalar]
+	label?: {[string]: #Scalar}
+	lint?: {[string]: #Scalar}
+	name?: #Scalar
+	needs?: [...#Scalar]
+	output?: #Scalar
+	"platform-overrides"?: {[string]: #Dockerfile}
+	platforms?: [...#Scalar]
+	profiles?: {[string]: #Dockerfile}
+	run?: [...(#Scalar | #Script)]
+	"run-join"?: #Scalar
+	"run-prelude"?: #Scalar
+	snippets?: {[string]: #Snippet}
+	stages?: {[string]: #Stage}
+	steps?: [...#Step]
+	stopsignal?: #Scalar
+	target?: #Scalar
+	user?: #Scalar
+	variants?: {[string]: #Dockerfile}
+	version?: int
+	volume?: [...#Scalar]
+	workdir?: #Scalar
+	[=~"^x-"]: _
+}
+
+#Artifact: {
+	as?: #Scalar
+	path?: #Scalar
+	[=~"^x-"]: _
+}
+
+#CopyEntry: {
+	chmod?: #Scalar
+	chown?: #Scalar
+	comment?: #Scalar
+	dst?: #Scalar
+	from?: #Scalar
+	src?: #Scalar | [...#Scalar]
+	[=~"^x-"]: _
+}
+
+#Script: {
+	cmd?: #Scalar
+	comment?: #Scalar
+	mount?: [...#Scalar]
+	network?: #Scalar
+	"no-join"?: bool
+	security?: #Scalar
+	[=~"^x-"]: _
+}
+
+#Snippet: {
+	params?: {[string]: #Scalar}
+	steps?: [...#Step]
+	[=~"^x-"]: _
+}
+
+#Stage: {
+	add?: {[string]: #Scalar}
+	arg?: {[string]: #Scalar}
+	artifacts?: [...#Artifact]
+	cmd?: [...#Scalar]
+	"cmd-form"?: "exec" | "shell"
+	comments?: {[string]: #Scalar}
+	copy?: {[string]: #Scalar} | [...#CopyEntry]
+	entrypoint?: [...#Scalar]
+	"entrypoint-form"?: "exec" | "shell"
+	env?: {[string]: #Scalar}
+	expose?: [...#Scalar]
+	extends?: #Scalar
+	from?: #Scalar
+	healthcheck?: #Scalar
+	label?: {[string]: #Scalar}
+	needs?: [...#Scalar]
+	run?: [...(#Scalar | #Script)]
+	"run-join"?: #Scalar
+	"run-prelude"?: #Scalar
+	steps?: [...#Step]
+	stopsignal?: #Scalar
+	user?: #Scalar
+	volume?: [...#Scalar]
+	workdir?: #Scalar
+	[=~"^x-"]: _
+}
+
+#Step: {
+	add?: {[string]: #Scalar}
+	arg?: {[string]: #Scalar}
+	comment?: #Scalar
+	copy?: {[string]: #Scalar} | [...#CopyEntry]
+	env?: {[string]: #Scalar}
+	expose?: [...#Scalar]
+	run?: [...(#Scalar | #Script)]
+	use?: #Scalar
+	user?: #Scalar
+	volume?: [...#Scalar]
+	with?: {[string]: #Scalar}
+	workdir?: #Scalar
+	[=~"^x-"]: _
+}
+
+#Scalar: string | number | bool
@@ -0,0 +1,28 @@
+#!/bin/sh
+set -eu
+
+image="${1:-ghcr.io/org/app}"
+
+BUILDPLATFORM="${BUILDPLATFORM:-}"
+ctr_builder=$(buildah from "--platform=${BUILDPLATFORM:-linux/amd64}" "busybox")
+buildah config --workingdir "/go/src" "$ctr_builder"
+COMMIT_SHA="${COMMIT_SHA:-}"
+PROJECT_NAME="${PROJECT_NAME:-}"
+TARGETPLATFORM="${TARGETPLATFORM:-}"
+buildah run "$ctr_builder" -- env COMMIT_SHA="$COMMIT_SHA" PROJECT_NAME="$PROJECT_NAME" TARGETPLATFORM="$TARGETPLATFORM" sh -c 'echo ${TARGETPLATFORM} > a.txt && touch b.txt'
+
+ctr_builder2=$(buildah from "busybox")
+buildah config --workingdir "/go/src" "$ctr_builder2"
+buildah run "$ctr_builder2" -- sh -c 'touch b.txt'
+
+ctr_main=$(buildah from "busybox")
+buildah config --workingdir "/todo" "$ctr_main"
+buildah copy --from "$ctr_builder2" "$ctr_main" "/go/src/b.txt" "./"
+buildah copy --from "$ctr_builder" "$ctr_main" "/go/src/a.txt" "./"
+
+if [ -n "${image}" ]; then
+  buildah commit "$ctr_main" "${image}"
+else
+  buildah commit "$ctr_main"
+fi
+buildah rm "$ctr_builder" "$ctr_builder2" "$ctr_main" >/dev/null
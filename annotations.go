@@ -0,0 +1,67 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"strings"
+)
+
+const annotationPrefix = "org.opencontainers.image."
+
+// pre-defined annotation keys of OCI image,
+// https://github.com/opencontainers/image-spec/blob/main/annotations.md#pre-defined-annotation-keys
+var annotationKeys = []string{
+	"created",
+	"authors",
+	"url",
+	"documentation",
+	"source",
+	"version",
+	"revision",
+	"vendor",
+	"licenses",
+	"ref.name",
+	"title",
+	"description",
+	"base.digest",
+	"base.name",
+}
+
+// annotationLabels converts annotations to LABEL keys,
+// keys could be short like title, or full like org.opencontainers.image.title.
+func annotationLabels(annotations Values) (Values, error) {
+	labels := Values{}
+
+	for key, value := range annotations {
+		name := strings.TrimPrefix(key, annotationPrefix)
+
+		if !stringIncludes(annotationKeys, name) {
+			return nil, fmt.Errorf("unknown annotation %s, should be one of %s", key, strings.Join(annotationKeys, ", "))
+		}
+
+		if _, ok := labels[annotationPrefix+name]; ok {
+			return nil, fmt.Errorf("duplicated annotation %s", key)
+		}
+
+		labels[annotationPrefix+name] = value
+	}
+
+	return labels, nil
+}
+
+// applyAnnotations returns a copy of final stage with annotations in labels,
+// labels of stage take precedence.
+func applyAnnotations(final *Stage, annotations Values) (*Stage, error) {
+	if len(annotations) == 0 {
+		return final, nil
+	}
+
+	labels, err := annotationLabels(annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	s := *final
+	s.Label = KeyValuesOf(labels).merge(final.Label)
+
+	return &s, nil
+}
@@ -0,0 +1,56 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestAnnotations(t *testing.T) {
+	t.Run("written as labels of final stage", func(t *testing.T) {
+		d := Dockerfile{
+			Annotations: Values{
+				"title":                             "app",
+				"org.opencontainers.image.licenses": "MIT",
+			},
+			Stage: Stage{
+				From:  "alpine",
+				Label: KeyValuesOf(Values{"org.opencontainers.image.title": "custom"}),
+			},
+		}
+
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal(`FROM alpine
+
+LABEL org.opencontainers.image.licenses=MIT
+
+LABEL org.opencontainers.image.title=custom
+
+`))
+		NewWithT(t).Expect(d.Stage.Label).To(HaveLen(1))
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		d := Dockerfile{
+			Annotations: Values{"name": "app"},
+			Stage:       Stage{From: "alpine"},
+		}
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("unknown annotation name"))
+	})
+
+	t.Run("duplicated key", func(t *testing.T) {
+		d := Dockerfile{
+			Annotations: Values{"title": "a", "org.opencontainers.image.title": "b"},
+			Stage:       Stage{From: "alpine"},
+		}
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
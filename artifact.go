@@ -0,0 +1,121 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Artifact is a file or directory of stage, which other stages copy by name
+// without knowing layout of the stage, like
+//
+//	stages:
+//	  builder:
+//	    workdir: /go/src
+//	    artifacts:
+//	      - {path: /go/bin/app, as: app}
+//	copy:
+//	  artifact://builder/app: /usr/local/bin/
+type Artifact struct {
+	// Path in the stage, relative ones are in workdir of the stage
+	Path string `yaml:"path"`
+	// As is name of the artifact, unique in the stage
+	As string `yaml:"as"`
+}
+
+const artifactScheme = "artifact://"
+
+// artifact returns artifact of name
+func (s *Stage) artifact(name string) (Artifact, bool) {
+	for _, a := range s.Artifacts {
+		if a.As == name {
+			return a, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// resolveArtifacts returns stage and key of copy with artifacts replaced to paths in the stage,
+// like --from=builder /go/bin/app for artifact://builder/app,
+// stage is empty when there is no artifact in key.
+func resolveArtifacts(key string, stages map[string]*Stage) (string, string, error) {
+	words := strings.Fields(key)
+	stageName := ""
+
+	for i, word := range words {
+		if !strings.HasPrefix(word, artifactScheme) {
+			continue
+		}
+
+		name, p, err := resolveArtifact(word, stages)
+		if err != nil {
+			return "", "", err
+		}
+
+		if stageName != "" && stageName != name {
+			return "", "", fmt.Errorf("%w %s, artifacts of one copy should be of same stage %s", ErrInvalidArtifact, word, stageName)
+		}
+		stageName = name
+
+		words[i] = p
+	}
+
+	if stageName == "" {
+		return "", key, nil
+	}
+
+	if stringSome(words, func(word string, i int) bool { return strings.HasPrefix(word, "--from=") }) {
+		return "", "", fmt.Errorf("%w, artifacts should not be copied with --from", ErrInvalidArtifact)
+	}
+
+	return stageName, strings.Join(append([]string{"--from=" + stageName}, words...), " "), nil
+}
+
+// resolveArtifact returns stage and path in the stage of artifact, like builder and /go/bin/app for artifact://builder/app
+func resolveArtifact(artifact string, stages map[string]*Stage) (string, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(artifact, artifactScheme), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%w %s, should be %s<stage>/<name>", ErrInvalidArtifact, artifact, artifactScheme)
+	}
+
+	stage := stages[parts[0]]
+	if stage == nil {
+		return "", "", fmt.Errorf("%w %s", ErrMissingStage, parts[0])
+	}
+
+	a, ok := stage.artifact(parts[1])
+	if !ok {
+		return "", "", fmt.Errorf("%w %s of stage %s", ErrMissingArtifact, parts[1], parts[0])
+	}
+
+	if stage.WorkingDir == "" && !strings.HasPrefix(a.Path, "/") {
+		return "", "", fmt.Errorf("%w of stage %s for artifact %s", ErrMissingWorkdir, parts[0], a.As)
+	}
+
+	return parts[0], joinIfNeed(stage.WorkingDir, a.Path), nil
+}
+
+// validateArtifacts validates artifacts have paths and unique names
+func validateArtifacts(path string, artifacts []Artifact) error {
+	errs := errorList{}
+	names := map[string]bool{}
+
+	for i, a := range artifacts {
+		p := path + "[" + strconv.Itoa(i) + "]"
+
+		if a.Path == "" {
+			errs.add(fieldError(p+".path", fmt.Errorf("%w, missing path", ErrInvalidArtifact)))
+		}
+
+		switch {
+		case a.As == "" || strings.ContainsAny(a.As, "/ "):
+			errs.add(fieldError(p+".as", fmt.Errorf("%w name %q", ErrInvalidArtifact, a.As)))
+		case names[a.As]:
+			errs.add(fieldError(p+".as", fmt.Errorf("%w, duplicated name %s", ErrInvalidArtifact, a.As)))
+		}
+
+		names[a.As] = true
+	}
+
+	return errs.err()
+}
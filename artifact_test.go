@@ -0,0 +1,62 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestArtifacts(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang
+    workdir: /go/src
+    artifacts:
+      - {path: /go/bin/app, as: app}
+      - {path: ./config, as: config}
+from: busybox
+copy:
+  artifact://builder/app: /usr/local/bin/
+  --chown=nobody artifact://builder/config: /etc/app/
+`), WithStrict())
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d)).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM golang AS builder
+
+WORKDIR /go/src
+
+FROM busybox
+
+COPY --from=builder --chown=nobody /go/src/config /etc/app/
+
+COPY --from=builder /go/bin/app /usr/local/bin/
+
+`))
+
+	t.Run("missing artifact", func(t *testing.T) {
+		d.Copy = CopyValues(Values{"artifact://builder/ap": "/usr/local/bin/"})
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), *d)
+		NewWithT(t).Expect(errors.Is(err, ErrMissingArtifact)).To(BeTrue())
+		NewWithT(t).Expect(err.Error()).To(Equal(`10:1: copy["artifact://builder/ap"]: missing artifact ap of stage builder`))
+	})
+
+	t.Run("invalid artifacts", func(t *testing.T) {
+		d.Copy = CopyValues(Values{"artifact://builder": "/"})
+		d.Stages["builder"].Artifacts = append(d.Stages["builder"].Artifacts, Artifact{Path: "/a", As: "app"}, Artifact{As: "a/b"})
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), *d)
+		NewWithT(t).Expect(errors.Is(err, ErrInvalidArtifact)).To(BeTrue())
+		NewWithT(t).Expect(err.Error()).To(Equal(`4 problems of spec:
+  - 6:5: stages.builder.artifacts[2].as: invalid artifact, duplicated name app
+  - 6:5: stages.builder.artifacts[3].path: invalid artifact, missing path
+  - 6:5: stages.builder.artifacts[3].as: invalid artifact name "a/b"
+  - 10:1: copy["artifact://builder"]: invalid artifact artifact://builder, should be artifact://<stage>/<name>`))
+	})
+}
@@ -0,0 +1,221 @@
+package dockerfileyml
+
+import (
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteToBakeFile writes docker-bake.hcl to build Dockerfile of d,
+// with one target for d and one for each of its variants.
+//
+// Targets are named by name of spec, default when not set,
+// or basename of output when in group default with variants, which could not be named as group,
+// args are ARG of all stages with their defaults, and tags are from image.
+func WriteToBakeFile(w io.Writer, d Dockerfile) error {
+	targets, err := bakeTargets(&d)
+	if err != nil {
+		return err
+	}
+
+	b := &hclWriter{}
+
+	if len(targets) > 1 {
+		names := make([]string, len(targets))
+		for i := range targets {
+			names[i] = targets[i].name
+		}
+
+		b.block("group", "default", []hclAttr{
+			{"targets", hclList(names)},
+		})
+	}
+
+	for i := range targets {
+		b.block("target", targets[i].name, targets[i].attrs)
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+type bakeTarget struct {
+	name  string
+	attrs []hclAttr
+}
+
+func bakeTargets(d *Dockerfile) ([]*bakeTarget, error) {
+	list, err := buildTargets(d)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]*bakeTarget, 0, len(list))
+
+	for _, d := range list {
+		t := &bakeTarget{name: d.targetName()}
+		if t.name == "default" && len(list) > 1 {
+			t.name = reTargetNameInvalid.ReplaceAllString(path.Base(d.dockerfile()), "_")
+		}
+		t.attrs = append(t.attrs, hclAttr{"context", hclString(".")})
+		t.attrs = append(t.attrs, hclAttr{"dockerfile", hclString(d.dockerfile())})
+
+		if len(d.Contexts) > 0 {
+			t.attrs = append(t.attrs, hclAttr{"contexts", hclMap(d.Contexts)})
+		}
+		if d.Target != "" {
+			t.attrs = append(t.attrs, hclAttr{"target", hclString(d.Target)})
+		}
+		if len(d.Platforms) > 0 {
+			t.attrs = append(t.attrs, hclAttr{"platforms", hclList(d.Platforms)})
+		}
+		if args := d.buildArgs(); len(args) > 0 {
+			t.attrs = append(t.attrs, hclAttr{"args", hclMap(args)})
+		}
+		if d.Image != "" {
+			t.attrs = append(t.attrs, hclAttr{"tags", hclList([]string{d.Image})})
+		}
+
+		targets = append(targets, t)
+	}
+
+	return targets, nil
+}
+
+// buildTargets returns d and its variants, each as a target to build
+func buildTargets(d *Dockerfile) ([]*Dockerfile, error) {
+	list := []*Dockerfile{d}
+
+	if len(d.Variants) > 0 {
+		variants, err := ExpandVariants(d)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, variants...)
+	}
+
+	return list, nil
+}
+
+// targetName returns name of d to build, default when not set
+func (d *Dockerfile) targetName() string {
+	name := reTargetNameInvalid.ReplaceAllString(d.Name, "_")
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
+// dockerfile returns output of d, Dockerfile when not set
+func (d *Dockerfile) dockerfile() string {
+	if d.Output == "" {
+		return "Dockerfile"
+	}
+	return d.Output
+}
+
+// buildArgs returns ARG of all stages with defaults
+func (d *Dockerfile) buildArgs() Values {
+	args := Values{}
+
+	stages := []*Stage{&d.Stage}
+	for name := range d.Stages {
+		stages = append(stages, d.Stages[name])
+	}
+
+	for _, s := range stages {
+		values := []KeyValues{s.Arg}
+		for i := range s.Steps {
+			values = append(values, s.Steps[i].Arg)
+		}
+
+		for _, v := range values {
+			for _, item := range v {
+				if _, ok := args[item.Key]; !ok || item.Value != "" {
+					args[item.Key] = item.Value
+				}
+			}
+		}
+	}
+
+	return args
+}
+
+var reTargetNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+type hclAttr struct {
+	key   string
+	value string
+}
+
+type hclWriter struct {
+	strings.Builder
+}
+
+func (b *hclWriter) block(kind string, name string, attrs []hclAttr) {
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+
+	b.WriteString(kind + " " + hclString(name) + " {\n")
+	b.attrs(attrs, "  ")
+	b.WriteString("}\n")
+}
+
+// attrs writes attrs with = aligned, like hclfmt
+func (b *hclWriter) attrs(attrs []hclAttr, indent string) {
+	width := 0
+	for _, attr := range attrs {
+		if len(attr.key) > width {
+			width = len(attr.key)
+		}
+	}
+
+	for _, attr := range attrs {
+		value := strings.Replace(attr.value, "\n", "\n"+indent, -1)
+		b.WriteString(indent + attr.key + strings.Repeat(" ", width-len(attr.key)) + " = " + value + "\n")
+	}
+}
+
+var reHCLIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// hclString quotes s, with template sequences escaped
+func hclString(s string) string {
+	s = strings.Replace(s, "${", "$${", -1)
+	s = strings.Replace(s, "%{", "%%{", -1)
+	return strconv.Quote(s)
+}
+
+func hclList(values []string) string {
+	quoted := make([]string, len(values))
+	for i := range values {
+		quoted[i] = hclString(values[i])
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func hclMap(values Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]hclAttr, len(keys))
+	for i, key := range keys {
+		k := key
+		if !reHCLIdentifier.MatchString(k) {
+			k = hclString(k)
+		}
+		attrs[i] = hclAttr{k, hclString(values[key])}
+	}
+
+	b := &hclWriter{}
+	b.WriteString("{\n")
+	b.attrs(attrs, "  ")
+	b.WriteString("}")
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+)
+
+func TestWriteToBakeFile(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+name: app
+image: ghcr.io/org/app:${VERSION}
+contexts:
+  docs: ../docs
+platforms: [linux/amd64, linux/arm64]
+stages:
+  builder:
+    from: golang:1.15
+    arg:
+      GOPROXY: https://proxy.golang.org
+    run: [go build]
+from: alpine
+arg:
+  VERSION: ""
+copy:
+  builder:/go/src/app: /usr/local/bin/
+variants:
+  debug:
+    from: busybox
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	err = WriteToBakeFile(buf, *d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(MatchSnapshot("docker-bake.hcl"))
+
+	buf.Reset()
+	err = WriteToBakeFile(buf, Dockerfile{Target: "builder"})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`target "default" {
+  context    = "."
+  dockerfile = "Dockerfile"
+  target     = "builder"
+}
+`))
+
+	buf.Reset()
+	err = WriteToBakeFile(buf, Dockerfile{Output: "docker/app.Dockerfile", Variants: map[string]*Dockerfile{"debug": {}}})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(HavePrefix(`group "default" {
+  targets = ["app_Dockerfile", "debug"]
+}
+`))
+}
@@ -0,0 +1,206 @@
+package dockerfileyml
+
+import (
+	"strconv"
+	"strings"
+)
+
+// WithSharedBases extracts instructions after FROM shared by stages into generated base stages,
+// which improves cache sharing of stages, like
+//
+//	FROM golang AS base
+//	WORKDIR /go/src
+//	RUN go mod download
+//
+//	FROM base AS test
+//	RUN go test ./...
+//
+//	FROM base AS builder
+//	RUN go build ./...
+//
+// stages are of same base when they are from same image with same flags,
+// ARG are not shared, since values of them are not inherited by stages from the base.
+func WithSharedBases() WriteOption {
+	return func(o *writeOptions) {
+		o.sharedBases = true
+	}
+}
+
+// sharedBase is instructions after FROM shared by stages
+type sharedBase struct {
+	// indexes of stages, in order of stages
+	stages []int
+	// count of instructions shared after FROM
+	n int
+}
+
+// sharedBasesOf returns shared bases of stages, each stage is in one base at most
+func sharedBasesOf(stages [][]instruction) []sharedBase {
+	bases := make([]sharedBase, 0)
+	grouped := map[int]bool{}
+
+	for a := range stages {
+		fa := fromIndex(stages[a])
+		if grouped[a] || fa < 0 {
+			continue
+		}
+
+		base := sharedBase{stages: []int{a}}
+
+		for b := a + 1; b < len(stages); b++ {
+			fb := fromIndex(stages[b])
+			if grouped[b] || fb < 0 || fromImageOf(stages[a][fa]) != fromImageOf(stages[b][fb]) {
+				continue
+			}
+
+			n := sharedCount(stages[a][fa+1:], stages[b][fb+1:])
+			if n == 0 {
+				continue
+			}
+
+			if len(base.stages) == 1 || n < base.n {
+				base.n = n
+			}
+			base.stages = append(base.stages, b)
+		}
+
+		if len(base.stages) > 1 {
+			for _, i := range base.stages {
+				grouped[i] = true
+			}
+			bases = append(bases, base)
+		}
+	}
+
+	return bases
+}
+
+// sharedCount returns count of same instructions from start of a and b, until ARG
+func sharedCount(a []instruction, b []instruction) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n].Key != "ARG" && a[n].String() == b[n].String() {
+		n++
+	}
+	return n
+}
+
+// fromIndex returns index of FROM of instructions of stage, after args used by it
+func fromIndex(list []instruction) int {
+	for i := range list {
+		if list[i].Key == "FROM" {
+			return i
+		}
+	}
+	return -1
+}
+
+// fromImageOf returns flags and image of FROM, without name of stage
+func fromImageOf(ins instruction) string {
+	return strings.Join(append(append([]string{}, ins.Flags...), strings.SplitN(ins.Value, " AS ", 2)[0]), " ")
+}
+
+// stageNameOf returns name of stage of FROM, empty for the main stage
+func stageNameOf(ins instruction) string {
+	if parts := strings.SplitN(ins.Value, " AS ", 2); len(parts) == 2 {
+		return parts[1]
+	}
+	return ""
+}
+
+// extractSharedBases extracts shared instructions of stages into base stages, see WithSharedBases
+func extractSharedBases(stages [][]instruction) [][]instruction {
+	bases := sharedBasesOf(stages)
+	if len(bases) == 0 {
+		return stages
+	}
+
+	names := map[string]bool{}
+	for _, list := range stages {
+		if i := fromIndex(list); i >= 0 {
+			names[stageNameOf(list[i])] = true
+		}
+	}
+
+	// base stages inserted before stage of index
+	inserted := map[int][]instruction{}
+
+	for _, base := range bases {
+		name := "base"
+		for i := 2; names[name]; i++ {
+			name = "base-" + strconv.Itoa(i)
+		}
+		names[name] = true
+
+		first := stages[base.stages[0]]
+		f := fromIndex(first)
+
+		list := make([]instruction, 0, f+1+base.n)
+		list = append(list, first[0:f]...)
+
+		from := first[f]
+		from.Value = strings.SplitN(from.Value, " AS ", 2)[0] + " AS " + name
+		list = append(list, from)
+		list = append(list, first[f+1:f+1+base.n]...)
+
+		for i := range list {
+			list[i].Stage = name
+		}
+
+		inserted[base.stages[0]] = list
+
+		for _, i := range base.stages {
+			stage := stages[i]
+			f := fromIndex(stage)
+
+			from := stage[f]
+			from.Flags = nil
+			from.Value = name
+			if stageName := stageNameOf(stage[f]); stageName != "" {
+				from.Value += " AS " + stageName
+			}
+
+			extracted := append([]instruction{}, stage[0:f]...)
+			extracted = append(extracted, from)
+			stages[i] = append(extracted, stage[f+1+base.n:]...)
+		}
+	}
+
+	result := make([][]instruction, 0, len(stages)+len(bases))
+	for i := range stages {
+		if base, ok := inserted[i]; ok {
+			result = append(result, base)
+		}
+		result = append(result, stages[i])
+	}
+
+	return result
+}
+
+// checkSharedBase checks stages sharing instructions after FROM, which could be extracted by WithSharedBases
+func checkSharedBase(d *Dockerfile) (findings []Finding) {
+	stages, err := renderStages(*d)
+	if err != nil {
+		return nil
+	}
+
+	for _, base := range sharedBasesOf(stages) {
+		first := stages[base.stages[0]]
+		firstName := stageNameOf(first[fromIndex(first)])
+
+		for _, i := range base.stages[1:] {
+			path := ""
+			if name := stageNameOf(stages[i][fromIndex(stages[i])]); name != "" {
+				path = "stages." + name
+			}
+
+			findings = append(findings, Finding{
+				Rule:     "shared-base",
+				Severity: SeverityInfo,
+				Path:     path,
+				Message:  "shares " + strconv.Itoa(base.n) + " instructions after FROM with stage " + firstName + ", which could be extracted into a base stage for cache sharing",
+			})
+		}
+	}
+
+	return
+}
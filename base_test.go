@@ -0,0 +1,66 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSharedBases(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  base:
+    from: alpine
+  builder:
+    from: golang
+    workdir: /go/src
+    run: [go mod download]
+    steps:
+      - run: [go build ./...]
+  test:
+    from: golang
+    workdir: /go/src
+    run: [go mod download]
+    steps:
+      - run: [go test ./...]
+from: base
+copy:
+  builder:/go/bin/app: /bin/app
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d, WithSharedBases())).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM alpine AS base
+
+FROM golang AS base-2
+
+WORKDIR /go/src
+
+RUN go mod download
+
+FROM base-2 AS builder
+
+RUN go build ./...
+
+FROM base-2 AS test
+
+RUN go test ./...
+
+FROM base
+
+COPY --from=builder /go/bin/app /bin/app
+
+`))
+
+	NewWithT(t).Expect(checkSharedBase(d)).To(Equal([]Finding{
+		{
+			Rule:     "shared-base",
+			Severity: SeverityInfo,
+			Path:     "stages.test",
+			Message:  "shares 2 instructions after FROM with stage builder, which could be extracted into a base stage for cache sharing",
+		},
+	}))
+}
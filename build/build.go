@@ -0,0 +1,151 @@
+// Package build builds dockerfile.yml by BuildKit, without writing Dockerfile.
+package build
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-courier/dockerfileyml"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"golang.org/x/sync/errgroup"
+)
+
+type BuildOptions struct {
+	// Context is dir of build context, defaults to current dir
+	Context string
+	Tags    []string
+	// Platform to build for, multiple platforms are separated by comma
+	Platform string
+	Args     map[string]string
+	// Secrets of id to path of file, for RUN --mount=type=secret,id=<id>
+	Secrets map[string]string
+	// Progress receives plain progress of build, discarded when nil
+	Progress io.Writer
+	// Addr of BuildKit, like unix:///run/buildkit/buildkitd.sock, defaults to BUILDKIT_HOST,
+	// when both empty, BuildKit of Docker at DOCKER_HOST (or unix:///var/run/docker.sock) is used.
+	Addr string
+}
+
+// Build renders Dockerfile of d in memory, and builds it with context dir by the Dockerfile frontend of BuildKit,
+// the image is loaded into Docker when using BuildKit of Docker.
+//
+// Contexts of d are passed as named contexts, like --build-context of docker buildx,
+// values with scheme are passed as they are, like docker-image://alpine,
+// others are local dirs, relative to current dir.
+func Build(ctx context.Context, d dockerfileyml.Dockerfile, opts BuildOptions) error {
+	buf := bytes.NewBuffer(nil)
+	if err := dockerfileyml.WriteToDockerfile(buf, d); err != nil {
+		return err
+	}
+
+	c, exporter, err := newClient(ctx, opts.Addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	solveOpt, err := solveOptOf(buf.Bytes(), d.Contexts, exporter, opts)
+	if err != nil {
+		return err
+	}
+
+	progress := opts.Progress
+	if progress == nil {
+		progress = ioutil.Discard
+	}
+
+	ch := make(chan *client.SolveStatus)
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		_, err := c.Solve(ctx, nil, *solveOpt, ch)
+		return err
+	})
+
+	eg.Go(func() error {
+		_, err := progressui.DisplaySolveStatus(context.Background(), nil, progress, ch)
+		return err
+	})
+
+	return eg.Wait()
+}
+
+func solveOptOf(dockerfile []byte, contexts dockerfileyml.Values, exporter string, opts BuildOptions) (*client.SolveOpt, error) {
+	contextDir := opts.Context
+	if contextDir == "" {
+		contextDir = "."
+	}
+
+	localDirs := map[string]string{
+		"context": contextDir,
+	}
+
+	attrs := map[string]string{
+		"filename": "Dockerfile",
+	}
+	if opts.Platform != "" {
+		attrs["platform"] = opts.Platform
+	}
+	for key, value := range opts.Args {
+		attrs["build-arg:"+key] = value
+	}
+	for name, value := range contexts {
+		if strings.Contains(value, "://") {
+			attrs["context:"+name] = value
+			continue
+		}
+		// prefixed to not collide with local dirs of context and dockerfile
+		localName := "context-" + name
+		if _, err := os.Stat(value); err != nil {
+			return nil, err
+		}
+		attrs["context:"+name] = "local:" + localName
+		localDirs[localName] = value
+	}
+
+	ids := make([]string, 0, len(opts.Secrets))
+	for id := range opts.Secrets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	sources := make([]secretsprovider.Source, 0, len(ids))
+	for _, id := range ids {
+		if _, err := os.Stat(opts.Secrets[id]); err != nil {
+			return nil, err
+		}
+		sources = append(sources, secretsprovider.Source{ID: id, FilePath: opts.Secrets[id]})
+	}
+
+	store, err := secretsprovider.NewStore(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client.SolveOpt{
+		Frontend:      "dockerfile.v0",
+		FrontendAttrs: attrs,
+		FrontendInputs: map[string]llb.State{
+			"dockerfile": llb.Scratch().File(llb.Mkfile("Dockerfile", 0644, dockerfile)),
+		},
+		LocalDirs: localDirs,
+		Session: []session.Attachable{
+			secretsprovider.NewSecretProvider(store),
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type:  exporter,
+				Attrs: map[string]string{"name": strings.Join(opts.Tags, ",")},
+			},
+		},
+	}, nil
+}
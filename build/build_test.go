@@ -0,0 +1,49 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/go-courier/dockerfileyml"
+	. "github.com/onsi/gomega"
+)
+
+func TestSolveOptOf(t *testing.T) {
+	opt, err := solveOptOf([]byte("FROM busybox\n"), nil, exporterMoby, BuildOptions{
+		Tags:     []string{"app:latest", "app:v1"},
+		Platform: "linux/amd64,linux/arm64",
+		Args:     map[string]string{"VERSION": "v1"},
+		Secrets:  map[string]string{"npmrc": "build_test.go"},
+	})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(opt.FrontendAttrs).To(Equal(map[string]string{
+		"filename":          "Dockerfile",
+		"platform":          "linux/amd64,linux/arm64",
+		"build-arg:VERSION": "v1",
+	}))
+	NewWithT(t).Expect(opt.LocalDirs).To(Equal(map[string]string{"context": "."}))
+	NewWithT(t).Expect(opt.FrontendInputs).To(HaveKey("dockerfile"))
+	NewWithT(t).Expect(opt.Exports[0].Type).To(Equal("moby"))
+	NewWithT(t).Expect(opt.Exports[0].Attrs["name"]).To(Equal("app:latest,app:v1"))
+
+	_, err = solveOptOf([]byte("FROM busybox\n"), nil, exporterMoby, BuildOptions{
+		Secrets: map[string]string{"npmrc": "missing"},
+	})
+	NewWithT(t).Expect(err).NotTo(BeNil())
+
+	t.Run("contexts", func(t *testing.T) {
+		opt, err := solveOptOf([]byte("FROM busybox\n"), dockerfileyml.Values{
+			"docs":   "../frontend",
+			"alpine": "docker-image://alpine:3.18",
+		}, exporterMoby, BuildOptions{Context: ".."})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(opt.FrontendAttrs).To(Equal(map[string]string{
+			"filename":       "Dockerfile",
+			"context:docs":   "local:context-docs",
+			"context:alpine": "docker-image://alpine:3.18",
+		}))
+		NewWithT(t).Expect(opt.LocalDirs).To(Equal(map[string]string{"context": "..", "context-docs": "../frontend"}))
+
+		_, err = solveOptOf([]byte("FROM busybox\n"), dockerfileyml.Values{"docs": "missing"}, exporterMoby, BuildOptions{})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
@@ -0,0 +1,117 @@
+package build
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/moby/buildkit/client"
+)
+
+// exporter of BuildKit in Docker, which loads image into Docker
+const exporterMoby = "moby"
+
+// newClient connects to BuildKit, and returns type of image exporter
+func newClient(ctx context.Context, addr string) (*client.Client, string, error) {
+	if addr == "" {
+		addr = os.Getenv("BUILDKIT_HOST")
+	}
+
+	if addr != "" {
+		c, err := client.New(ctx, addr)
+		return c, client.ExporterImage, err
+	}
+
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+
+	network, address, err := parseDockerHost(host)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, err := client.New(ctx, "",
+		client.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return dialHijack(ctx, network, address, "/grpc", "h2c", nil)
+		}),
+		client.WithSessionDialer(func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+			return dialHijack(ctx, network, address, "/session", proto, meta)
+		}),
+	)
+
+	return c, exporterMoby, err
+}
+
+func parseDockerHost(host string) (network string, address string, err error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch u.Scheme {
+	case "unix":
+		return "unix", u.Path, nil
+	case "tcp":
+		return "tcp", u.Host, nil
+	}
+
+	return "", "", fmt.Errorf("unsupported DOCKER_HOST %s", host)
+}
+
+// dialHijack upgrades connection of endpoint of Docker to proto
+func dialHijack(ctx context.Context, network string, address string, endpoint string, proto string, meta map[string][]string) (net.Conn, error) {
+	d := &net.Dialer{}
+
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "http://docker"+endpoint, nil)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	for key, values := range meta {
+		req.Header[key] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", proto)
+
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		_ = conn.Close()
+		return nil, fmt.Errorf("upgrade %s of docker failed: %s", endpoint, resp.Status)
+	}
+
+	return &hijackedConn{Conn: conn, r: br}, nil
+}
+
+// hijackedConn reads buffered data first
+type hijackedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *hijackedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
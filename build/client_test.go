@@ -0,0 +1,76 @@
+package build
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseDockerHost(t *testing.T) {
+	network, address, err := parseDockerHost("unix:///var/run/docker.sock")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect([]string{network, address}).To(Equal([]string{"unix", "/var/run/docker.sock"}))
+
+	network, address, err = parseDockerHost("tcp://127.0.0.1:2375")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect([]string{network, address}).To(Equal([]string{"tcp", "127.0.0.1:2375"}))
+
+	_, _, err = parseDockerHost("ssh://user@host")
+	NewWithT(t).Expect(err).NotTo(BeNil())
+}
+
+func TestDialHijack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	sock := filepath.Join(dir, "docker.sock")
+
+	l, err := net.Listen("unix", sock)
+	NewWithT(t).Expect(err).To(BeNil())
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err != nil {
+					return
+				}
+
+				if req.URL.Path != "/grpc" || req.Header.Get("Upgrade") != "h2c" {
+					_, _ = conn.Write([]byte("HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n"))
+					return
+				}
+
+				_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\nhello"))
+			}()
+		}
+	}()
+
+	conn, err := dialHijack(context.Background(), "unix", sock, "/grpc", "h2c", nil)
+	NewWithT(t).Expect(err).To(BeNil())
+	defer conn.Close()
+
+	data, err := ioutil.ReadAll(conn)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(string(data)).To(Equal("hello"))
+
+	_, err = dialHijack(context.Background(), "unix", sock, "/session", "h2c", nil)
+	NewWithT(t).Expect(err).NotTo(BeNil())
+}
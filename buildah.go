@@ -0,0 +1,281 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// WriteToBuildahScript writes a shell script building d by buildah commands, without Dockerfile parser.
+//
+// Image to commit is the first arg of script, defaults to image of d.
+// ARG becomes shell variable, which could be overridden by environment variable of same name.
+func WriteToBuildahScript(w io.Writer, d Dockerfile) error {
+	stages, err := renderStages(d)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(stages))
+	for i := range stages {
+		names[i] = stages[i][0].Stage
+	}
+
+	b := &buildahScript{stages: names}
+
+	b.line("#!/bin/sh")
+	b.line("set -eu")
+	b.line("")
+	b.line("image=" + shellDoubleQuote("${1:-"+d.Image+"}"))
+
+	for i := range stages {
+		b.line("")
+		if err := b.stage(stages[i], i == len(stages)-1); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+type buildahScript struct {
+	strings.Builder
+	// names of stages, empty for main stage
+	stages []string
+	// names of args declared
+	args []string
+}
+
+func (b *buildahScript) line(s string) {
+	b.WriteString(s + "\n")
+}
+
+func (b *buildahScript) stage(list []instruction, final bool) error {
+	ctr := ""
+
+	for _, ins := range list {
+		for _, comment := range ins.Comments {
+			b.line("# " + comment)
+		}
+
+		if ctr == "" && ins.Key == "FROM" {
+			ctr = containerVar(ins.Stage)
+		}
+
+		if err := b.instruction(ins, ctr); err != nil {
+			return err
+		}
+	}
+
+	if final {
+		b.line("")
+		b.line(`if [ -n "${image}" ]; then`)
+		b.line(`  buildah commit "$` + ctr + `" "${image}"`)
+		b.line("else")
+		b.line(`  buildah commit "$` + ctr + `"`)
+		b.line("fi")
+
+		containers := make([]string, 0, len(b.stages))
+		for _, name := range b.stages {
+			containers = append(containers, `"$`+containerVar(name)+`"`)
+		}
+		b.line("buildah rm " + strings.Join(containers, " ") + " >/dev/null")
+	}
+
+	return nil
+}
+
+func (b *buildahScript) instruction(ins instruction, ctr string) error {
+	ref := `"$` + ctr + `"`
+
+	switch ins.Key {
+	case "ARG":
+		parts := strings.SplitN(ins.Value, "=", 2)
+		name := parts[0]
+
+		value := ""
+		if len(parts) == 2 {
+			value = unquote(parts[1])
+		}
+
+		if !stringIncludes(b.args, name) {
+			b.args = append(b.args, name)
+		}
+
+		b.line(name + "=" + shellDoubleQuote("${"+name+":-"+value+"}"))
+	case "FROM":
+		// args of previous stage are out of scope
+		b.args = nil
+
+		words := strings.Fields(strings.SplitN(ins.Value, " AS ", 2)[0])
+		args := []string{"buildah", "from"}
+
+		for _, flag := range ins.Flags {
+			args = append(args, shellDoubleQuote(flag))
+		}
+
+		image := words[0]
+		if stringIncludes(b.stages, image) {
+			// stage is committed as image to build from
+			b.line(containerVar(image) + "_image=$(buildah commit \"$" + containerVar(image) + "\")")
+			args = append(args, `"$`+containerVar(image)+`_image"`)
+		} else {
+			args = append(args, shellDoubleQuote(image))
+		}
+
+		b.line(ctr + "=$(" + strings.Join(args, " ") + ")")
+	case "RUN":
+		args := []string{"buildah", "run"}
+
+		for _, flag := range ins.Flags {
+			if strings.HasPrefix(flag, "--security=") {
+				b.line("# WARNING: RUN " + flag + " is not supported by buildah run, dropped")
+				continue
+			}
+			args = append(args, shellDoubleQuote(flag))
+		}
+
+		args = append(args, ref, "--")
+
+		// args are passed as env, like in RUN of Dockerfile
+		if len(b.args) > 0 {
+			args = append(args, "env")
+			for _, name := range b.args {
+				args = append(args, name+`="$`+name+`"`)
+			}
+		}
+
+		if values, ok := ins.jsonArray(); ok {
+			for _, v := range values {
+				args = append(args, shellQuote(v))
+			}
+		} else {
+			args = append(args, "sh", "-c", shellQuote(ins.Value))
+		}
+
+		b.line(strings.Join(args, " "))
+	case "COPY", "ADD":
+		args := []string{"buildah", strings.ToLower(ins.Key)}
+
+		for _, flag := range ins.Flags {
+			if strings.HasPrefix(flag, "--from=") {
+				from := strings.TrimPrefix(flag, "--from=")
+				if stringIncludes(b.stages, from) {
+					args = append(args, "--from", `"$`+containerVar(from)+`"`)
+				} else {
+					args = append(args, "--from", shellDoubleQuote(from))
+				}
+				continue
+			}
+			args = append(args, shellDoubleQuote(flag))
+		}
+
+		args = append(args, ref)
+
+		values, ok := ins.jsonArray()
+		if !ok {
+			values = strings.Fields(ins.Value)
+		}
+		for _, v := range values {
+			args = append(args, shellDoubleQuote(v))
+		}
+
+		b.line(strings.Join(args, " "))
+	case "ENV", "LABEL":
+		keyValues, err := parseKeyValues(ins)
+		if err != nil {
+			return err
+		}
+		values := keyValues.Values()
+
+		option := "--env"
+		if ins.Key == "LABEL" {
+			option = "--label"
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		args := []string{"buildah", "config"}
+		for _, key := range keys {
+			args = append(args, option, shellDoubleQuote(key+"="+values[key]))
+		}
+
+		b.line(strings.Join(append(args, ref), " "))
+	case "EXPOSE":
+		args := []string{"buildah", "config"}
+		for _, port := range strings.Fields(ins.Value) {
+			args = append(args, "--port", shellDoubleQuote(port))
+		}
+		b.line(strings.Join(append(args, ref), " "))
+	case "VOLUME":
+		values, ok := ins.jsonArray()
+		if !ok {
+			values = strings.Fields(ins.Value)
+		}
+		args := []string{"buildah", "config"}
+		for _, v := range values {
+			args = append(args, "--volume", shellDoubleQuote(v))
+		}
+		b.line(strings.Join(append(args, ref), " "))
+	case "HEALTHCHECK":
+		args := []string{"buildah", "config"}
+
+		words := strings.Fields(ins.Value)
+		for len(words) > 0 && strings.HasPrefix(words[0], "--") {
+			// like --interval=30s to --healthcheck-interval=30s
+			args = append(args, shellDoubleQuote("--healthcheck-"+strings.TrimPrefix(words[0], "--")))
+			words = words[1:]
+		}
+
+		args = append(args, "--healthcheck", shellQuote(strings.Join(words, " ")))
+		b.line(strings.Join(append(args, ref), " "))
+	case "WORKDIR", "USER", "STOPSIGNAL", "ENTRYPOINT", "CMD":
+		option := map[string]string{
+			"WORKDIR":    "--workingdir",
+			"USER":       "--user",
+			"STOPSIGNAL": "--stop-signal",
+			"ENTRYPOINT": "--entrypoint",
+			"CMD":        "--cmd",
+		}[ins.Key]
+
+		value := shellDoubleQuote(ins.Value)
+		if _, ok := ins.jsonArray(); ok || ins.Key == "ENTRYPOINT" || ins.Key == "CMD" {
+			// no expansion for commands, which are expanded at runtime
+			value = shellQuote(ins.Value)
+		}
+
+		b.line("buildah config " + option + " " + value + " " + ref)
+	default:
+		return fmt.Errorf("%s is not supported by buildah script", ins.Key)
+	}
+
+	return nil
+}
+
+var reShellVarInvalid = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// containerVar returns shell variable of container of stage, ctr_main for the main stage
+func containerVar(stage string) string {
+	if stage == "" {
+		return "ctr_main"
+	}
+	return "ctr_" + reShellVarInvalid.ReplaceAllString(stage, "_")
+}
+
+// shellQuote quotes s in single quotes, without expansion
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// shellDoubleQuote quotes s in double quotes, with $ kept for expanding variables of args
+func shellDoubleQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,21 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+)
+
+func TestWriteToBuildahScript(t *testing.T) {
+	d, err := ParseFile("testdata/multistage.yml")
+	NewWithT(t).Expect(err).To(BeNil())
+
+	d.Image = "ghcr.io/org/app"
+
+	buf := bytes.NewBuffer(nil)
+	err = WriteToBuildahScript(buf, *d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(MatchSnapshot("multistage.buildah.sh"))
+}
@@ -0,0 +1,228 @@
+package dockerfileyml
+
+import (
+	"path"
+	"strings"
+)
+
+// dependencyManifests returns manifests of dependencies installed by command in words,
+// like package*.json for npm ci, nil when it is not installing dependencies from manifests.
+func dependencyManifests(words []string) []string {
+	flagsOnly := func(words []string) bool {
+		return !stringSome(words, func(word string, i int) bool { return !strings.HasPrefix(word, "-") })
+	}
+
+	for i, word := range words {
+		rest := words[i+1:]
+
+		switch word {
+		case "npm":
+			if len(rest) > 0 && (rest[0] == "ci" || rest[0] == "install") && flagsOnly(rest[1:]) {
+				return []string{"package*.json"}
+			}
+		case "yarn":
+			if flagsOnly(rest) || (rest[0] == "install" && flagsOnly(rest[1:])) {
+				return []string{"package.json", "yarn.lock"}
+			}
+		case "pnpm":
+			if len(rest) > 0 && rest[0] == "install" && flagsOnly(rest[1:]) {
+				return []string{"package.json", "pnpm-lock.yaml"}
+			}
+		case "pip", "pip3":
+			if len(rest) > 0 && rest[0] == "install" {
+				for j, w := range rest {
+					if (w == "-r" || w == "--requirement") && j+1 < len(rest) {
+						return []string{rest[j+1]}
+					}
+					if strings.HasPrefix(w, "--requirement=") {
+						return []string{strings.TrimPrefix(w, "--requirement=")}
+					}
+				}
+			}
+		case "go":
+			if len(rest) > 1 && rest[0] == "mod" && rest[1] == "download" {
+				return []string{"go.mod", "go.sum"}
+			}
+		case "bundle":
+			if len(rest) > 0 && rest[0] == "install" {
+				return []string{"Gemfile", "Gemfile.lock"}
+			}
+		case "composer":
+			if len(rest) > 0 && rest[0] == "install" {
+				return []string{"composer.json", "composer.lock"}
+			}
+		case "cargo":
+			if len(rest) > 0 && rest[0] == "fetch" {
+				return []string{"Cargo.toml", "Cargo.lock"}
+			}
+		default:
+			continue
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// scriptManifests returns manifests of dependencies installed by script
+func scriptManifests(script Script) []string {
+	for _, words := range commandsOf(script) {
+		if manifests := dependencyManifests(words); manifests != nil {
+			return manifests
+		}
+	}
+	return nil
+}
+
+// contextCopyOf returns pair of copy of whole build context, like . or ./
+func contextCopyOf(c Copies, path string, stages map[string]*Stage) (copyPair, bool) {
+	for _, pair := range c.pairs(path) {
+		for _, src := range copySourcesOf(pair.key, stages) {
+			if src == "." || src == "./" {
+				return pair, true
+			}
+		}
+	}
+	return copyPair{}, false
+}
+
+// stepsOf returns copy and run of fields and steps of s as steps in order of writing,
+// with yaml paths of them, which are path of stage for fields.
+func stepsOf(s *Stage, path string) (steps []Step, paths []string) {
+	if s.Copy.Len() > 0 {
+		steps = append(steps, Step{Copy: s.Copy})
+		paths = append(paths, path)
+	}
+	if len(s.Run) > 0 {
+		steps = append(steps, Step{Run: s.Run})
+		paths = append(paths, path)
+	}
+	for i := range s.Steps {
+		steps = append(steps, s.Steps[i])
+		paths = append(paths, fieldPath(path, "steps")+indexPath(i))
+	}
+	return
+}
+
+// checkCacheOrder checks copy of whole build context before installing dependencies,
+// which invalidates cache of dependencies on any change of sources.
+func checkCacheOrder(d *Dockerfile) (findings []Finding) {
+	eachStage(d, func(p string, s *Stage) {
+		steps, paths := stepsOf(s, p)
+
+		for i := range steps {
+			pair, ok := contextCopyOf(steps[i].Copy, fieldPath(paths[i], "copy"), d.Stages)
+			if !ok {
+				continue
+			}
+
+			for j := i + 1; j < len(steps); j++ {
+				for k, script := range steps[j].Run {
+					if manifests := scriptManifests(script); manifests != nil {
+						findings = append(findings, Finding{
+							Rule:     "cache-order",
+							Severity: SeverityWarning,
+							Path:     pair.path,
+							Message: "copy of build context before installing dependencies by " + fieldPath(paths[j], "run") + indexPath(k) +
+								" invalidates cache of dependencies on any change, copy " + strings.Join(manifests, " ") + " first, install, then copy sources",
+						})
+						return
+					}
+				}
+			}
+			return
+		}
+	})
+	return
+}
+
+// WithCacheOrder reorders stages to copy manifests of dependencies, install, then copy sources, like
+//
+//	COPY package*.json ./
+//	RUN npm ci
+//	COPY . ./
+//
+// only when installing is the first script after copy of build context,
+// and there are only copies between them, since scripts before may need the sources.
+func WithCacheOrder() WriteOption {
+	return func(o *writeOptions) {
+		o.cacheOrder = true
+	}
+}
+
+// reorderForCache reorders copy and run of s to install dependencies before copy of build context, see WithCacheOrder
+func reorderForCache(s *Stage, stages map[string]*Stage) {
+	steps, _ := stepsOf(s, "")
+
+	for i := range steps {
+		pair, ok := contextCopyOf(steps[i].Copy, "", stages)
+		if !ok {
+			continue
+		}
+
+		j := i + 1
+		for j < len(steps) && steps[j].Copy.Len() > 0 && steps[j].instructionCount() == 1 {
+			j++
+		}
+
+		if j == len(steps) || len(steps[j].Run) == 0 || steps[j].Use != "" {
+			return
+		}
+
+		install := steps[j].Run[0]
+		manifests := scriptManifests(install)
+		if manifests == nil {
+			return
+		}
+
+		// manifests in dirs are copied into same dirs of destination, as installing reads them,
+		// ones out of build context are not reordered.
+		dirs := make([]string, 0)
+		manifestsOfDirs := map[string][]string{}
+		for _, manifest := range manifests {
+			dir := path.Dir(path.Clean(manifest))
+			if path.IsAbs(manifest) || dir == ".." || strings.HasPrefix(dir, "../") {
+				return
+			}
+			if _, ok := manifestsOfDirs[dir]; !ok {
+				dirs = append(dirs, dir)
+			}
+			manifestsOfDirs[dir] = append(manifestsOfDirs[dir], manifest)
+		}
+
+		dst := pair.dst
+		if !strings.HasSuffix(dst, "/") {
+			dst += "/"
+		}
+
+		// flags like --chown of the copy
+		words := make([]string, 0)
+		for _, word := range strings.Fields(pair.key) {
+			if strings.HasPrefix(word, "--") {
+				words = append(words, word)
+			}
+		}
+
+		reordered := append([]Step{}, steps[0:i]...)
+		for _, dir := range dirs {
+			to := dst
+			if dir != "." {
+				to += dir + "/"
+			}
+			reordered = append(reordered, Step{Copy: CopyValues(Values{strings.Join(append(append([]string{}, words...), manifestsOfDirs[dir]...), " "): to})})
+		}
+		reordered = append(reordered, Step{Run: []Script{install}})
+		reordered = append(reordered, steps[i:j]...)
+		if rest := steps[j].Run[1:]; len(rest) > 0 {
+			step := steps[j]
+			step.Run = rest
+			reordered = append(reordered, step)
+		}
+		reordered = append(reordered, steps[j+1:]...)
+
+		s.Copy = Copies{}
+		s.Run = nil
+		s.Steps = reordered
+		return
+	}
+}
@@ -0,0 +1,141 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDependencyManifests(t *testing.T) {
+	cases := map[string][]string{
+		"npm ci":                             {"package*.json"},
+		"npm install --production":           {"package*.json"},
+		"npm install express":                nil,
+		"yarn":                               {"package.json", "yarn.lock"},
+		"yarn install --frozen-lockfile":     {"package.json", "yarn.lock"},
+		"python -m pip install -r reqs.txt":  {"reqs.txt"},
+		"pip install --requirement=reqs.txt": {"reqs.txt"},
+		"pip install flask":                  nil,
+		"go mod download":                    {"go.mod", "go.sum"},
+		"go build ./...":                     nil,
+		"bundle install":                     {"Gemfile", "Gemfile.lock"},
+		"make":                               nil,
+	}
+
+	for command, manifests := range cases {
+		NewWithT(t).Expect(dependencyManifests(strings.Fields(command))).To(Equal(manifests), command)
+	}
+}
+
+func TestCacheOrder(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  web:
+    from: node
+    workdir: /app
+    copy: {.: ./}
+    run: [npm ci, npm run build]
+from: golang
+workdir: /go/src
+steps:
+  - copy: {--chown=nobody .: .}
+  - workdir: /go/src/cmd
+  - run: [go mod download]
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	NewWithT(t).Expect(checkCacheOrder(d)).To(Equal([]Finding{
+		{
+			Rule:     "cache-order",
+			Severity: SeverityWarning,
+			Path:     `steps[0].copy["--chown=nobody ."]`,
+			Message:  "copy of build context before installing dependencies by steps[2].run[0] invalidates cache of dependencies on any change, copy go.mod go.sum first, install, then copy sources",
+		},
+		{
+			Rule:     "cache-order",
+			Severity: SeverityWarning,
+			Path:     `stages.web.copy["."]`,
+			Message:  "copy of build context before installing dependencies by stages.web.run[0] invalidates cache of dependencies on any change, copy package*.json first, install, then copy sources",
+		},
+	}))
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d, WithCacheOrder())).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM node AS web
+
+WORKDIR /app
+
+COPY package*.json ./
+
+RUN npm ci
+
+COPY . ./
+
+RUN npm run build
+
+FROM golang
+
+WORKDIR /go/src
+
+COPY --chown=nobody . .
+
+WORKDIR /go/src/cmd
+
+RUN go mod download
+
+`))
+
+	t.Run("steps", func(t *testing.T) {
+		d.Steps = []Step{
+			{Copy: CopyValues(Values{"--chown=nobody .": "."})},
+			{Copy: CopyValues(Values{"./config": "/etc/app/"})},
+			{Run: Scripts("go mod download", "go build ./...")},
+		}
+
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, *d, WithCacheOrder())).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`WORKDIR /go/src
+
+COPY --chown=nobody go.mod go.sum ./
+
+RUN go mod download
+
+COPY --chown=nobody . .
+
+COPY ./config /etc/app/
+
+RUN go build ./...
+`))
+	})
+
+	t.Run("manifests in dirs", func(t *testing.T) {
+		d.Steps = []Step{
+			{Copy: CopyValues(Values{".": "./"})},
+			{Run: Scripts("pip install -r requirements/dev.txt")},
+		}
+
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, *d, WithCacheOrder())).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`WORKDIR /go/src
+
+COPY requirements/dev.txt ./requirements/
+
+RUN pip install -r requirements/dev.txt
+
+COPY . ./
+`))
+
+		d.Steps[1] = Step{Run: Scripts("pip install -r ../dev.txt")}
+
+		buf = bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, *d, WithCacheOrder())).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`WORKDIR /go/src
+
+COPY . ./
+
+RUN pip install -r ../dev.txt
+`))
+	})
+}
@@ -0,0 +1,186 @@
+package dockerfileyml
+
+// Clone returns deep copy of s, maps and slices of it are not shared with s,
+// which could be changed to derive variants of the stage.
+func (s *Stage) Clone() *Stage {
+	if s == nil {
+		return nil
+	}
+
+	c := *s
+
+	c.Needs = cloneStrings(s.Needs)
+	c.Artifacts = append([]Artifact(nil), s.Artifacts...)
+	c.Comments = cloneValues(s.Comments)
+	c.Label = cloneKeyValues(s.Label)
+	c.Arg = cloneKeyValues(s.Arg)
+	c.Env = cloneKeyValues(s.Env)
+	c.Add = cloneValues(s.Add)
+	c.Copy = s.Copy.clone()
+	c.Run = cloneScripts(s.Run)
+	c.Steps = cloneSteps(s.Steps)
+	c.Expose = cloneStrings(s.Expose)
+	c.Volume = cloneStrings(s.Volume)
+	c.Entrypoint = cloneStrings(s.Entrypoint)
+	c.Command = cloneStrings(s.Command)
+
+	if s.deps != nil {
+		c.deps = make(map[string]bool, len(s.deps))
+		for name, ok := range s.deps {
+			c.deps[name] = ok
+		}
+	}
+	c.copyReplaces = cloneValues(s.copyReplaces)
+	if s.copyEntries != nil {
+		c.copyEntries = make(map[string]CopyEntry, len(s.copyEntries))
+		for path, e := range s.copyEntries {
+			e.Src = append(Paths{}, e.Src...)
+			c.copyEntries[path] = e
+		}
+	}
+
+	return &c
+}
+
+// Clone returns deep copy of d, including stages, snippets, profiles, variants and platform overrides,
+// maps and slices of it are not shared with d.
+func (d *Dockerfile) Clone() *Dockerfile {
+	if d == nil {
+		return nil
+	}
+
+	c := *d
+
+	c.Include = cloneStrings(d.Include)
+	c.Directives = cloneValues(d.Directives)
+	c.Contexts = cloneValues(d.Contexts)
+	c.Ignore = cloneStrings(d.Ignore)
+	c.Platforms = cloneStrings(d.Platforms)
+	c.Annotations = cloneValues(d.Annotations)
+
+	if d.Stages != nil {
+		c.Stages = make(map[string]*Stage, len(d.Stages))
+		for name, s := range d.Stages {
+			c.Stages[name] = s.Clone()
+		}
+	}
+
+	if d.Snippets != nil {
+		c.Snippets = make(map[string]*Snippet, len(d.Snippets))
+		for name, snippet := range d.Snippets {
+			if snippet == nil {
+				c.Snippets[name] = nil
+				continue
+			}
+			c.Snippets[name] = &Snippet{Params: cloneValues(snippet.Params), Steps: cloneSteps(snippet.Steps)}
+		}
+	}
+
+	c.Profiles = cloneDockerfiles(d.Profiles)
+	c.Variants = cloneDockerfiles(d.Variants)
+	c.PlatformOverrides = cloneDockerfiles(d.PlatformOverrides)
+
+	if d.Lint != nil {
+		c.Lint = make(map[string]Severity, len(d.Lint))
+		for rule, severity := range d.Lint {
+			c.Lint[rule] = severity
+		}
+	}
+
+	c.Stage = *d.Stage.Clone()
+
+	c.positions = d.positions.clone()
+	c.comments = cloneValues(d.comments)
+
+	return &c
+}
+
+func (c Copies) clone() Copies {
+	entries := []CopyEntry(nil)
+	if c.Entries != nil {
+		entries = make([]CopyEntry, len(c.Entries))
+		for i, e := range c.Entries {
+			e.Src = Paths(cloneStrings(e.Src))
+			entries[i] = e
+		}
+	}
+	return Copies{Values: cloneValues(c.Values), Entries: entries}
+}
+
+func (p Positions) clone() Positions {
+	if p == nil {
+		return nil
+	}
+	c := make(Positions, len(p))
+	for path, pos := range p {
+		c[path] = pos
+	}
+	return c
+}
+
+func cloneValues(values Values) Values {
+	if values == nil {
+		return nil
+	}
+	c := make(Values, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
+
+func cloneKeyValues(kv KeyValues) KeyValues {
+	if kv == nil {
+		return nil
+	}
+	return append(make(KeyValues, 0, len(kv)), kv...)
+}
+
+func cloneStrings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(list)), list...)
+}
+
+func cloneScripts(scripts []Script) []Script {
+	if scripts == nil {
+		return nil
+	}
+	c := make([]Script, len(scripts))
+	for i, script := range scripts {
+		script.Mount = cloneStrings(script.Mount)
+		c[i] = script
+	}
+	return c
+}
+
+func cloneSteps(steps []Step) []Step {
+	if steps == nil {
+		return nil
+	}
+	c := make([]Step, len(steps))
+	for i, step := range steps {
+		step.With = cloneValues(step.With)
+		step.Arg = cloneKeyValues(step.Arg)
+		step.Env = cloneKeyValues(step.Env)
+		step.Add = cloneValues(step.Add)
+		step.Copy = step.Copy.clone()
+		step.Run = cloneScripts(step.Run)
+		step.Expose = cloneStrings(step.Expose)
+		step.Volume = cloneStrings(step.Volume)
+		c[i] = step
+	}
+	return c
+}
+
+func cloneDockerfiles(m map[string]*Dockerfile) map[string]*Dockerfile {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]*Dockerfile, len(m))
+	for name, d := range m {
+		c[name] = d.Clone()
+	}
+	return c
+}
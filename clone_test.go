@@ -0,0 +1,59 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestClone(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang
+    workdir: /go/src
+    copy:
+      - {src: [./go.mod, ./go.sum], dst: ./}
+    run:
+      - {cmd: go build, mount: ["type=cache,target=/root/.cache"]}
+    steps:
+      - {env: {CGO_ENABLED: "0"}}
+      - {run: [go test]}
+variants:
+  debug:
+    env: {DEBUG: "1"}
+from: alpine
+env: {MODE: production}
+copy:
+  builder:/go/bin/app: /bin/app
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	before := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(before, *d)).To(BeNil())
+
+	c := d.Clone()
+	NewWithT(t).Expect(c).To(Equal(d))
+
+	c.Env.Set("MODE", "development")
+	c.Copy.Values["builder:/go/bin/app"] = "/usr/bin/app"
+	c.Variants["debug"].Env.Set("DEBUG", "0")
+
+	builder := c.Stages["builder"]
+	builder.WorkingDir = "/src"
+	builder.Copy.Entries[0].Src[0] = "./go.work"
+	builder.Run[0].Mount[0] = "type=tmpfs,target=/tmp"
+	builder.Steps[0].Env.Set("CGO_ENABLED", "1")
+
+	after := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(after, *d)).To(BeNil())
+	NewWithT(t).Expect(after.String()).To(Equal(before.String()))
+	NewWithT(t).Expect(d.Variants["debug"].Env.Values()).To(Equal(Values{"DEBUG": "1"}))
+
+	t.Run("nil", func(t *testing.T) {
+		NewWithT(t).Expect((*Stage)(nil).Clone()).To(BeNil())
+		NewWithT(t).Expect((*Dockerfile)(nil).Clone()).To(BeNil())
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var diffCommand = &command{
+	name:    "diff",
+	usage:   "<old.yml> <new.yml>",
+	summary: "print instruction level diff of Dockerfile generated from two specs",
+}
+
+func init() {
+	diffCommand.run = runDiff
+}
+
+func runDiff(args []string) error {
+	fs := newFlagSet(diffCommand)
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	oldFiles, err := generateFiles(positional[0], generateOptions{})
+	if err != nil {
+		return err
+	}
+
+	newFiles, err := generateFiles(positional[1], generateOptions{})
+	if err != nil {
+		return err
+	}
+
+	n := len(oldFiles)
+	if len(newFiles) > n {
+		n = len(newFiles)
+	}
+
+	for i := 0; i < n; i++ {
+		a, b := &generatedFile{path: "/dev/null"}, &generatedFile{path: "/dev/null"}
+		if i < len(oldFiles) {
+			a = oldFiles[i]
+		}
+		if i < len(newFiles) {
+			b = newFiles[i]
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        instructionLines(string(a.data)),
+			B:        instructionLines(string(b.data)),
+			FromFile: a.path,
+			ToFile:   b.path,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(stdout, diff); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// instructionLines splits Dockerfile into one line per instruction,
+// continuations are joined, and blank lines between instructions are dropped.
+func instructionLines(dockerfile string) []string {
+	lines := make([]string, 0)
+	current := ""
+
+	for _, line := range strings.Split(dockerfile, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSpace(strings.TrimSuffix(line, "\\")) + " "
+			continue
+		}
+
+		lines = append(lines, current+strings.TrimSpace(line)+"\n")
+		current = ""
+	}
+
+	if current != "" {
+		lines = append(lines, current+"\n")
+	}
+
+	return lines
+}
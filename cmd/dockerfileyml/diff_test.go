@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerfileyml")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	buf := bytes.NewBuffer(nil)
+	stdout = buf
+	defer func() {
+		stdout = os.Stdout
+	}()
+
+	oldSpec := filepath.Join(dir, "old.yml")
+	newSpec := filepath.Join(dir, "new.yml")
+
+	_ = ioutil.WriteFile(oldSpec, []byte("from: busybox\nworkdir: /app\nenv:\n  A: \"1\"\ncmd: [sh]\n"), 0644)
+	_ = ioutil.WriteFile(newSpec, []byte("from: busybox\nworkdir: /app\nenv:\n  A: \"2\"\ncmd: [sh]\n"), 0644)
+
+	err = runDiff([]string{oldSpec, newSpec})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring("-ENV A=1\n+ENV A=2\n CMD [\"sh\"]\n"))
+
+	buf.Reset()
+
+	err = runDiff([]string{oldSpec, oldSpec})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(BeEmpty())
+}
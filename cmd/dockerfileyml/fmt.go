@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-courier/dockerfileyml"
+)
+
+var fmtCommand = &command{
+	name:    "fmt",
+	usage:   "<spec.yml|-> ... [-w] [-l]",
+	summary: "format specs in canonical form, see dockerfileyml.Format",
+}
+
+func init() {
+	fmtCommand.run = runFmt
+}
+
+func runFmt(args []string) error {
+	fs := newFlagSet(fmtCommand)
+	write := fs.Bool("w", false, "write result to spec instead of stdout")
+	list := fs.Bool("l", false, "list specs of which formatting differs, fail when any")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	unformatted := 0
+
+	for _, spec := range positional {
+		var data []byte
+
+		if spec == "-" {
+			if *write {
+				return fmt.Errorf("could not write result to stdin")
+			}
+			data, err = ioutil.ReadAll(stdin)
+		} else {
+			data, err = ioutil.ReadFile(spec)
+		}
+		if err != nil {
+			return err
+		}
+
+		formatted, err := dockerfileyml.Format(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", spec, err)
+		}
+
+		if *list {
+			if !bytes.Equal(data, formatted) {
+				unformatted++
+				fmt.Fprintln(stdout, spec)
+			}
+			continue
+		}
+
+		if *write {
+			if bytes.Equal(data, formatted) {
+				continue
+			}
+			info, err := os.Stat(spec)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(spec, formatted, info.Mode()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := stdout.Write(formatted); err != nil {
+			return err
+		}
+	}
+
+	if unformatted > 0 {
+		return fmt.Errorf("%d specs are not formatted", unformatted)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFmt(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	stdin = strings.NewReader("cmd: [app]\nfrom: 'busybox'\n")
+	stdout = buf
+	defer func() {
+		stdin = os.Stdin
+		stdout = os.Stdout
+	}()
+
+	NewWithT(t).Expect(runFmt([]string{"-"})).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal("from: busybox\ncmd:\n  - app\n"))
+
+	t.Run("write", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "fmt")
+		defer os.RemoveAll(dir)
+
+		spec := filepath.Join(dir, "dockerfile.yml")
+		_ = ioutil.WriteFile(spec, []byte("cmd: [app]\nfrom: busybox\n"), 0644)
+
+		buf.Reset()
+		err := runFmt([]string{spec, "-l"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal(spec + "\n"))
+
+		NewWithT(t).Expect(runFmt([]string{"-w", spec})).To(BeNil())
+		data, _ := ioutil.ReadFile(spec)
+		NewWithT(t).Expect(string(data)).To(Equal("from: busybox\ncmd:\n  - app\n"))
+
+		buf.Reset()
+		NewWithT(t).Expect(runFmt([]string{spec, "-l"})).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(BeEmpty())
+	})
+}
@@ -0,0 +1,829 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-courier/dockerfileyml"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var generateCommand = &command{
+	name:    "generate",
+	usage:   "<spec.yml|-> [-o Dockerfile|-] [--profile name,...] [--variant name|--all-variants] [--per-platform] [--dialect docker|podman] [--line-ending lf|crlf] [--stage-separators] [--comments] [--group] [--merge-runs] [--cache-order] [--shared-bases] [--dedupe-stages] [--multiline-run] [--run-join &&|;] [--run-prelude script] [--indent n|--align] [--wrap-arrays width] [--fold n] [--vcs-labels] [--header] [--pin] [--pin-comments] [--normalize-images] [--mirror registry=mirror ...] [--lint] [--non-root] [--tagged-images] [--absolute-workdir] [--escape-dollars [--escape-dollars-in instruction ...]] [--require-digests] [--policy path ...] [--scan trivy|grype|--scan-report file ...] [--vuln-threshold severity=count ...] [--context dir [--expand-globs]] [--source-map] [--dockerignore [--ignore-pattern pattern ...]] [--check]",
+	summary: "generate Dockerfile from spec, with digests of dockerfile.lock next to spec when exists",
+}
+
+func init() {
+	generateCommand.run = runGenerate
+}
+
+func runGenerate(args []string) error {
+	fs := newFlagSet(generateCommand)
+	check := fs.Bool("check", false, "only check generated Dockerfile is up to date, print diff and fail if not")
+	options := generateFlags(fs)
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	o, err := options()
+	if err != nil {
+		return err
+	}
+
+	files, err := generateFiles(positional[0], o)
+	if err != nil {
+		return err
+	}
+
+	if *check {
+		return checkFiles(files)
+	}
+
+	return writeFiles(files)
+}
+
+// generateFlags registers flags of generate into fs,
+// options of parsed flags are returned by the func.
+func generateFlags(fs *flag.FlagSet) func() (generateOptions, error) {
+	output := fs.String("o", "", "output file, - for stdout, defaults to output of spec or Dockerfile next to spec (stdout when spec from stdin)")
+	profiles := fs.String("profile", "", "profiles to apply in order, separated by comma")
+	variant := fs.String("variant", "", "render variant of name instead of spec")
+	all := fs.Bool("all-variants", false, "render spec and all its variants into separated Dockerfile")
+	perPlatform := fs.Bool("per-platform", false, "render Dockerfile for each platform instead of one by TARGETARCH")
+	dialect := fs.String("dialect", string(dockerfileyml.DialectDocker), "dialect of output, podman writes Containerfile")
+	stageSeparators := fs.Bool("stage-separators", false, "write comment like # --- stage: builder --- before instructions of each stage")
+	comments := fs.Bool("comments", false, "write comments of spec as # lines before instructions of commented fields")
+	group := fs.Bool("group", false, "write adjacent instructions of same key, like ARG, ENV, LABEL and COPY, without blank lines between")
+	mergeRuns := fs.Bool("merge-runs", false, "merge adjacent RUN of each stage into one, scripts with no-join opt out")
+	cacheOrder := fs.Bool("cache-order", false, "copy manifests of dependencies, install, then copy sources, when build context is copied before installing")
+	sharedBases := fs.Bool("shared-bases", false, "extract instructions after FROM shared by stages into base stages")
+	dedupeStages := fs.Bool("dedupe-stages", false, "collapse stages of same instructions into one, with references to them rewritten")
+	multilineRun := fs.Bool("multiline-run", false, "write each script joined into RUN in its own line, continued by \\")
+	runJoin := fs.String("run-join", "", "join adjacent scripts into one RUN by && or ;, for stages without run-join")
+	runPrelude := fs.String("run-prelude", "", "script before scripts of each RUN, like set -euxo pipefail, for stages without run-prelude")
+	indent := fs.Int("indent", 4, "indent continuation lines by n spaces")
+	align := fs.Bool("align", false, "align continuation lines with value after key of instruction, instead of --indent")
+	wrapArrays := fs.Int("wrap-arrays", 0, "write each value of exec form in its own line, when instruction is longer than width")
+	fold := fs.Int("fold", 0, "write pairs of env, and labels of stage, in one instruction with one pair per line, when there are at least n pairs")
+	lineEnding := fs.String("line-ending", string(dockerfileyml.LineEndingLF), "line ending of output, lf or crlf")
+	header := fs.Bool("header", false, "write header with source, version and spec hash, to stop hand-editing generated Dockerfile")
+	pin := fs.Bool("pin", false, "pin images of from by digests resolved from registries, with credentials of docker config")
+	pinTimeout := fs.Duration("pin-timeout", 30*time.Second, "timeout of resolving digest of each image")
+	pinComments := fs.Bool("pin-comments", false, "write renovate comments before FROM of pinned images for dependency update bots")
+	normalizeImages := fs.Bool("normalize-images", false, "write images with full names, like docker.io/library/busybox for busybox")
+	mirrors := dockerfileyml.Values{}
+	fs.Var(valuesFlag(mirrors), "mirror", "rewrite images of registry or repository prefix to mirror, like docker.io=mirror.corp.example, could be repeated")
+	lint := fs.Bool("lint", false, "check spec by lint rules, and fail on errors")
+	nonRoot := fs.Bool("non-root", false, "fail when final stage runs as root, or as user of base image without user set")
+	taggedImages := fs.Bool("tagged-images", false, "fail when images of from have no tag or latest tag")
+	absoluteWorkdir := fs.Bool("absolute-workdir", false, "fail when first workdir of stages is relative")
+	escapeDollars := fs.Bool("escape-dollars", false, "escape $ in values of env, label, cmd and entrypoint as \\$, to keep literal dollar signs")
+	escapeDollarsIn := listFlag{}
+	fs.Var(&escapeDollarsIn, "escape-dollars-in", "escape $ in values of instruction, like env, instead of defaults of --escape-dollars, could be repeated")
+	requireDigests := fs.Bool("require-digests", false, "fail when images of from, copy --from and mount from are not pinned by digests, or pin them with --pin")
+	policies := listFlag{}
+	fs.Var(&policies, "policy", "file or dir of Rego policies evaluated by opa, fail on deny results, could be repeated")
+	scan := fs.String("scan", "", "scan base images for vulnerabilities by trivy or grype, digests resolved with --pin are scanned")
+	scanReports := listFlag{}
+	fs.Var(&scanReports, "scan-report", "JSON report of trivy or grype scanned before, instead of --scan, could be repeated")
+	vulnThresholds := dockerfileyml.Values{}
+	fs.Var(valuesFlag(vulnThresholds), "vuln-threshold", "max count of vulnerabilities of severity, like critical=0, fail when over, could be repeated")
+	contextDir := fs.String("context", "", "build context dir, sources of copy, add and bind mounts should exist in it")
+	expandGlobs := fs.Bool("expand-globs", false, "expand glob patterns of sources of copy and add into files matched in --context dir")
+	dockerignore := fs.Bool("dockerignore", false, "write .dockerignore next to output, which ignores everything but sources of copy, add and bind mounts, also for <Dockerfile>.dockerignore of ignore of spec")
+	ignorePatterns := listFlag{}
+	fs.Var(&ignorePatterns, "ignore-pattern", "extra pattern of .dockerignore, like **/*_test.go, could be repeated")
+	sourceMap := fs.Bool("source-map", false, "write source map of lines of Dockerfile to fields of spec, as <output>.map.json")
+	vcsLabels := fs.Bool("vcs-labels", false, "add revision, source and created labels resolved from git repository of spec")
+	vcs := dockerfileyml.Values{}
+	for _, key := range []string{"revision", "source", "created"} {
+		fs.Var(vcsValue{values: vcs, key: key}, "vcs-"+key, key+" label instead of resolved from git, implies --vcs-labels")
+	}
+
+	return func() (generateOptions, error) {
+		if *all {
+			if *variant != "" {
+				return generateOptions{}, fmt.Errorf("--variant could not be used with --all-variants")
+			}
+			*variant = allVariants
+		}
+
+		thresholds := dockerfileyml.VulnCounts{}
+		for severity, count := range vulnThresholds {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return generateOptions{}, fmt.Errorf("invalid threshold of %s, should be count of vulnerabilities, but got %s", severity, count)
+			}
+			thresholds[strings.ToLower(severity)] = n
+		}
+
+		switch dockerfileyml.Dialect(*dialect) {
+		case dockerfileyml.DialectDocker, dockerfileyml.DialectPodman:
+		default:
+			return generateOptions{}, fmt.Errorf("unsupported dialect %s", *dialect)
+		}
+
+		switch dockerfileyml.LineEnding(*lineEnding) {
+		case dockerfileyml.LineEndingLF, dockerfileyml.LineEndingCRLF:
+		default:
+			return generateOptions{}, fmt.Errorf("unsupported line ending %s, should be lf or crlf", *lineEnding)
+		}
+
+		return generateOptions{
+			output:          *output,
+			variant:         *variant,
+			perPlatform:     *perPlatform,
+			dialect:         dockerfileyml.Dialect(*dialect),
+			lineEnding:      dockerfileyml.LineEnding(*lineEnding),
+			stageSeparators: *stageSeparators,
+			comments:        *comments,
+			group:           *group,
+			mergeRuns:       *mergeRuns,
+			cacheOrder:      *cacheOrder,
+			sharedBases:     *sharedBases,
+			dedupeStages:    *dedupeStages,
+			multilineRun:    *multilineRun,
+			runJoin:         *runJoin,
+			runPrelude:      *runPrelude,
+			indent:          *indent,
+			align:           *align,
+			wrapArrays:      *wrapArrays,
+			fold:            *fold,
+			header:          *header,
+			pin:             *pin,
+			pinTimeout:      *pinTimeout,
+			pinComments:     *pinComments,
+			normalizeImages: *normalizeImages,
+			mirrors:         mirrors,
+			lint:            *lint,
+			nonRoot:         *nonRoot,
+			taggedImages:    *taggedImages,
+			absoluteWorkdir: *absoluteWorkdir,
+			escapeDollars:   *escapeDollars || len(escapeDollarsIn) > 0,
+			escapeDollarsIn: escapeDollarsIn,
+			requireDigests:  *requireDigests,
+			policies:        policies,
+			scan:            *scan,
+			scanReports:     scanReports,
+			vulnThresholds:  thresholds,
+			sourceMap:       *sourceMap,
+			contextDir:      *contextDir,
+			expandGlobs:     *expandGlobs,
+			dockerignore:    *dockerignore,
+			ignorePatterns:  ignorePatterns,
+			diagnostics:     true,
+			readOptions:     readOptions(*profiles, *vcsLabels, vcs),
+		}, nil
+	}
+}
+
+// writeFiles writes files atomically, unchanged ones are skipped to keep mtime for build tools and watchers
+func writeFiles(files []*generatedFile) error {
+	for _, f := range files {
+		if f.path == "-" {
+			if _, err := stdout.Write(f.data); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := dockerfileyml.WriteFileIfChanged(f.path, f.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFiles prints diff of each out of date file
+func checkFiles(files []*generatedFile) error {
+	outdated := 0
+
+	for _, f := range files {
+		if f.path == "-" {
+			return fmt.Errorf("--check could not be used with output to stdout")
+		}
+
+		current, err := ioutil.ReadFile(f.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if bytes.Equal(current, f.data) {
+			continue
+		}
+
+		outdated++
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(current)),
+			B:        difflib.SplitLines(string(f.data)),
+			FromFile: f.path,
+			ToFile:   f.path + " (generated)",
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, _ = io.WriteString(stdout, diff)
+	}
+
+	if outdated > 0 {
+		return fmt.Errorf("%d Dockerfile out of date, run dockerfileyml generate to update", outdated)
+	}
+
+	return nil
+}
+
+type generatedFile struct {
+	path string
+	data []byte
+}
+
+func readOptions(profiles string, vcsLabels bool, vcs dockerfileyml.Values) (opts []dockerfileyml.ReadOption) {
+	if profiles != "" {
+		opts = append(opts, dockerfileyml.WithProfiles(strings.Split(profiles, ",")...))
+	}
+	if vcsLabels || len(vcs) > 0 {
+		opts = append(opts, dockerfileyml.WithVCS(vcs))
+	}
+	return
+}
+
+// vcsValue sets provided value of vcs label as flag
+type vcsValue struct {
+	values dockerfileyml.Values
+	key    string
+}
+
+func (v vcsValue) String() string {
+	if v.values == nil {
+		return ""
+	}
+	return v.values[v.key]
+}
+
+func (v vcsValue) Set(s string) error {
+	v.values[v.key] = s
+	return nil
+}
+
+// valuesFlag sets key=value into values, could be repeated
+type valuesFlag dockerfileyml.Values
+
+func (v valuesFlag) String() string {
+	pairs := make([]string, 0, len(v))
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v valuesFlag) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("should be key=value, but got %s", s)
+	}
+	v[parts[0]] = parts[1]
+	return nil
+}
+
+// vulnScanner returns scanner of name, or of reports
+func vulnScanner(name string, reports []string) (dockerfileyml.VulnScanner, error) {
+	if len(reports) > 0 {
+		if name != "" {
+			return nil, fmt.Errorf("--scan could not be used with --scan-report")
+		}
+		return dockerfileyml.ReportScanner(reports...)
+	}
+
+	switch name {
+	case "trivy":
+		return dockerfileyml.TrivyScanner(""), nil
+	case "grype":
+		return dockerfileyml.GrypeScanner(""), nil
+	}
+
+	return nil, fmt.Errorf("unsupported scanner %s, should be trivy or grype", name)
+}
+
+// listFlag appends each value, could be repeated
+type listFlag []string
+
+func (v *listFlag) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *listFlag) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+// allVariants selects spec and all its variants
+const allVariants = "*"
+
+type generateOptions struct {
+	// output file, only allowed for single Dockerfile
+	output string
+	// variant of name is rendered instead of spec when set
+	variant string
+	// render Dockerfile for each platform
+	perPlatform bool
+	dialect     dockerfileyml.Dialect
+	lineEnding  dockerfileyml.LineEnding
+	// comment before instructions of each stage
+	stageSeparators bool
+	// comments of spec before instructions
+	comments bool
+	// adjacent instructions of same key without blank lines between
+	group bool
+	// adjacent RUN merged into one
+	mergeRuns bool
+	// dependencies installed before copy of build context
+	cacheOrder bool
+	// shared instructions of stages extracted into base stages
+	sharedBases bool
+	// stages of same instructions collapsed into one
+	dedupeStages bool
+	// scripts of RUN in lines
+	multilineRun bool
+	// join and prelude of scripts of RUN, for stages without them
+	runJoin    string
+	runPrelude string
+	// indent of continuation lines when not 0, or aligned with value after key
+	indent int
+	align  bool
+	// wrap values of exec form when instruction is longer than width
+	wrapArrays int
+	// fold pairs of env and labels when at least fold pairs
+	fold int
+	// write header on top
+	header bool
+	// pin images by digests
+	pin        bool
+	pinTimeout time.Duration
+	// renovate comments before FROM of pinned images
+	pinComments bool
+	// write images with full names
+	normalizeImages bool
+	// mirrors of registries or repository prefixes
+	mirrors dockerfileyml.Values
+	// fail on lint errors
+	lint bool
+	// fail when final stage runs as root
+	nonRoot bool
+	// fail when images of from have no tag or latest tag
+	taggedImages bool
+	// fail when first workdir of stages is relative
+	absoluteWorkdir bool
+	// escape $ in values of instructions of escapeDollarsIn, or defaults
+	escapeDollars   bool
+	escapeDollarsIn []string
+	// fail when images are not pinned by digests, or pin them with pin
+	requireDigests bool
+	// files or dirs of Rego policies, fail on deny results
+	policies []string
+	// scanner of base images, trivy or grype
+	scan string
+	// reports of trivy or grype instead of scan
+	scanReports []string
+	// max counts of vulnerabilities by severity
+	vulnThresholds dockerfileyml.VulnCounts
+	// write source map next to output
+	sourceMap bool
+	// check sources exist in build context dir
+	contextDir string
+	// expand glob patterns of sources in contextDir
+	expandGlobs bool
+	// write .dockerignore next to output, with extra patterns
+	dockerignore   bool
+	ignorePatterns []string
+	// print warnings of reading and writing
+	diagnostics bool
+	readOptions []dockerfileyml.ReadOption
+}
+
+// generateFiles renders all documents of spec, - for stdin
+func generateFiles(spec string, o generateOptions) ([]*generatedFile, error) {
+	var data []byte
+	var err error
+
+	if spec == "-" {
+		data, err = ioutil.ReadAll(stdin)
+	} else {
+		data, err = ioutil.ReadFile(spec)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	readOptions := append([]dockerfileyml.ReadOption{dockerfileyml.WithFilename(spec)}, o.readOptions...)
+	if spec != "-" {
+		readOptions = append([]dockerfileyml.ReadOption{dockerfileyml.WithDir(filepath.Dir(spec))}, readOptions...)
+	}
+
+	printDiagnostic := func(f dockerfileyml.Finding) {
+		fmt.Fprintln(stderr, formatFinding(spec, f))
+	}
+
+	if o.diagnostics {
+		readOptions = append(readOptions, dockerfileyml.WithReadDiagnostics(printDiagnostic))
+	}
+
+	list, err := dockerfileyml.ReadAllFromYAML(bytes.NewReader(data), readOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	var r *dockerfileyml.Resolver
+	if o.pin {
+		r = &dockerfileyml.Resolver{Timeout: o.pinTimeout}
+	}
+
+	writeOptions := []dockerfileyml.WriteOption{dockerfileyml.WithDialect(o.dialect), dockerfileyml.WithLineEnding(o.lineEnding)}
+
+	if o.diagnostics {
+		writeOptions = append(writeOptions, dockerfileyml.WithWriteDiagnostics(printDiagnostic))
+	}
+
+	if o.pinComments {
+		writeOptions = append(writeOptions, dockerfileyml.WithPinComments())
+	}
+
+	if o.normalizeImages {
+		writeOptions = append(writeOptions, dockerfileyml.WithNormalizedImages())
+	}
+
+	if len(o.mirrors) > 0 {
+		writeOptions = append(writeOptions, dockerfileyml.WithMirrors(o.mirrors))
+	}
+
+	if o.lint {
+		writeOptions = append(writeOptions, dockerfileyml.WithLint(nil))
+	}
+
+	if o.nonRoot {
+		writeOptions = append(writeOptions, dockerfileyml.WithNonRootUser())
+	}
+
+	if o.taggedImages {
+		writeOptions = append(writeOptions, dockerfileyml.WithTaggedImages())
+	}
+
+	if o.absoluteWorkdir {
+		writeOptions = append(writeOptions, dockerfileyml.WithAbsoluteWorkdir())
+	}
+
+	if o.stageSeparators {
+		writeOptions = append(writeOptions, dockerfileyml.WithStageSeparators())
+	}
+
+	if o.multilineRun {
+		writeOptions = append(writeOptions, dockerfileyml.WithMultilineRun())
+	}
+
+	if o.runJoin != "" || o.runPrelude != "" {
+		writeOptions = append(writeOptions, dockerfileyml.WithRunJoin(o.runJoin, o.runPrelude))
+	}
+
+	if o.align {
+		writeOptions = append(writeOptions, dockerfileyml.WithAlignedContinuations())
+	} else if o.indent > 0 {
+		writeOptions = append(writeOptions, dockerfileyml.WithIndent(o.indent))
+	}
+
+	if o.fold > 0 {
+		writeOptions = append(writeOptions, dockerfileyml.WithFoldedValues(o.fold))
+	}
+
+	if o.wrapArrays > 0 {
+		writeOptions = append(writeOptions, dockerfileyml.WithWrappedArrays(o.wrapArrays))
+	}
+
+	if o.group {
+		writeOptions = append(writeOptions, dockerfileyml.WithGroupedInstructions())
+	}
+
+	if o.comments {
+		writeOptions = append(writeOptions, dockerfileyml.WithSourceComments())
+	}
+
+	if o.mergeRuns {
+		writeOptions = append(writeOptions, dockerfileyml.WithMergedRuns())
+	}
+
+	if o.cacheOrder {
+		writeOptions = append(writeOptions, dockerfileyml.WithCacheOrder())
+	}
+
+	if o.sharedBases {
+		writeOptions = append(writeOptions, dockerfileyml.WithSharedBases())
+	}
+
+	if o.dedupeStages {
+		writeOptions = append(writeOptions, dockerfileyml.WithDedupedStages())
+	}
+
+	if o.escapeDollars {
+		writeOptions = append(writeOptions, dockerfileyml.WithEscapedDollars(o.escapeDollarsIn...))
+	}
+
+	if o.requireDigests {
+		writeOptions = append(writeOptions, dockerfileyml.WithRequiredDigests(r))
+	}
+
+	if len(o.policies) > 0 {
+		writeOptions = append(writeOptions, dockerfileyml.WithPolicy(&dockerfileyml.PolicyRule{Policies: o.policies}))
+	}
+
+	if o.expandGlobs {
+		if o.contextDir == "" {
+			return nil, fmt.Errorf("--expand-globs requires --context")
+		}
+		writeOptions = append(writeOptions, dockerfileyml.WithExpandedGlobs(o.contextDir))
+	} else if o.contextDir != "" {
+		writeOptions = append(writeOptions, dockerfileyml.WithContextDir(o.contextDir))
+	}
+
+	if o.scan != "" || len(o.scanReports) > 0 {
+		scanner, err := vulnScanner(o.scan, o.scanReports)
+		if err != nil {
+			return nil, err
+		}
+		writeOptions = append(writeOptions, dockerfileyml.WithVulnScan(&dockerfileyml.VulnRule{Scanner: scanner, Thresholds: o.vulnThresholds, Resolver: r}))
+	}
+
+	if o.header {
+		h := dockerfileyml.Header{Version: version(), Spec: data}
+		if spec != "-" {
+			h.Source = filepath.ToSlash(spec)
+		}
+		writeOptions = append(writeOptions, dockerfileyml.WithHeader(h))
+	}
+
+	if spec != "-" {
+		list, err = applyLockfile(list, filepath.Join(filepath.Dir(spec), dockerfileyml.LockfileName))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	list, err = selectVariants(list, o.variant)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.perPlatform {
+		list, err = expandPlatforms(list)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.pin {
+		for i := range list {
+			list[i], err = dockerfileyml.PinDigests(context.Background(), list[i], r)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	output := o.output
+
+	if output != "" && len(list) > 1 {
+		return nil, fmt.Errorf("-o could not be used with multiple Dockerfile of spec %s", spec)
+	}
+
+	files := make([]*generatedFile, 0, len(list))
+
+	for _, d := range list {
+		buf := bytes.NewBuffer(nil)
+		path := outputOf(spec, d, output, o.dialect.Filename())
+
+		sourceMap := dockerfileyml.SourceMap{}
+		opts := writeOptions
+
+		if o.sourceMap {
+			if path == "-" {
+				return nil, fmt.Errorf("--source-map could not be used with output to stdout")
+			}
+			opts = append(append([]dockerfileyml.WriteOption{}, writeOptions...), dockerfileyml.WithSourceMap(&sourceMap))
+		}
+
+		if err := dockerfileyml.WriteToDockerfile(buf, *d, opts...); err != nil {
+			if located(err) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+
+		files = append(files, &generatedFile{
+			path: path,
+			data: buf.Bytes(),
+		})
+
+		if o.sourceMap {
+			buf := bytes.NewBuffer(nil)
+			if err := dockerfileyml.WriteSourceMap(buf, sourceMap); err != nil {
+				return nil, err
+			}
+			files = append(files, &generatedFile{
+				path: path + ".map.json",
+				data: buf.Bytes(),
+			})
+		}
+
+		// no context to filter when output to stdout
+		if len(d.Ignore) > 0 && path != "-" {
+			// the whole context, unless allow-list of sources is required
+			sources := []string{"."}
+			if o.dockerignore {
+				sources = dockerfileyml.ContextSources(*d)
+			}
+
+			buf := bytes.NewBuffer(nil)
+			if err := dockerfileyml.WriteDockerignore(buf, sources, append(append([]string{}, d.Ignore...), o.ignorePatterns...)...); err != nil {
+				return nil, err
+			}
+			files = append(files, &generatedFile{
+				path: dockerfileyml.IgnoreFilename(path),
+				data: buf.Bytes(),
+			})
+		}
+	}
+
+	// nothing generated from spec of comments only
+	if o.dockerignore && len(files) > 0 {
+		f, err := dockerignoreFile(list, files[0].path, o.ignorePatterns)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// dockerignoreFile returns .dockerignore next to output, allowing sources of all Dockerfile
+func dockerignoreFile(list []*dockerfileyml.Dockerfile, output string, patterns []string) (*generatedFile, error) {
+	if output == "-" {
+		return nil, fmt.Errorf("--dockerignore could not be used with output to stdout")
+	}
+
+	seen := map[string]bool{}
+	sources := make([]string, 0)
+
+	for _, d := range list {
+		for _, src := range dockerfileyml.ContextSources(*d) {
+			if !seen[src] {
+				seen[src] = true
+				sources = append(sources, src)
+			}
+		}
+	}
+	sort.Strings(sources)
+
+	buf := bytes.NewBuffer(nil)
+	if err := dockerfileyml.WriteDockerignore(buf, sources, patterns...); err != nil {
+		return nil, err
+	}
+
+	return &generatedFile{
+		path: filepath.Join(filepath.Dir(output), ".dockerignore"),
+		data: buf.Bytes(),
+	}, nil
+}
+
+// applyLockfile pins documents by lockfile when exists
+func applyLockfile(list []*dockerfileyml.Dockerfile, path string) ([]*dockerfileyml.Dockerfile, error) {
+	l, err := dockerfileyml.ReadLockfile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return list, nil
+		}
+		return nil, err
+	}
+
+	locked := make([]*dockerfileyml.Dockerfile, 0, len(list))
+
+	for _, d := range list {
+		applied, err := l.Apply(d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		locked = append(locked, applied)
+	}
+
+	return locked, nil
+}
+
+func selectVariants(list []*dockerfileyml.Dockerfile, variant string) ([]*dockerfileyml.Dockerfile, error) {
+	if variant == "" {
+		return list, nil
+	}
+
+	selected := make([]*dockerfileyml.Dockerfile, 0, len(list))
+
+	for _, d := range list {
+		if variant != allVariants {
+			v, err := dockerfileyml.ApplyVariant(d, variant)
+			if err != nil {
+				return nil, err
+			}
+			selected = append(selected, v)
+			continue
+		}
+
+		selected = append(selected, d)
+
+		if len(d.Variants) == 0 {
+			continue
+		}
+
+		variants, err := dockerfileyml.ExpandVariants(d)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, variants...)
+	}
+
+	return selected, nil
+}
+
+func expandPlatforms(list []*dockerfileyml.Dockerfile) ([]*dockerfileyml.Dockerfile, error) {
+	expanded := make([]*dockerfileyml.Dockerfile, 0, len(list))
+
+	for _, d := range list {
+		if len(d.Platforms) == 0 {
+			expanded = append(expanded, d)
+			continue
+		}
+
+		platforms, err := dockerfileyml.ExpandPlatforms(d)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, platforms...)
+	}
+
+	return expanded, nil
+}
+
+// outputOf resolves output of document, relative to spec,
+// defaults to filename next to spec
+func outputOf(spec string, d *dockerfileyml.Dockerfile, output string, filename string) string {
+	if output != "" {
+		return output
+	}
+
+	dir := filepath.Dir(spec)
+	if spec == "-" {
+		dir = "."
+	}
+
+	if d.Output != "" {
+		if filepath.IsAbs(d.Output) {
+			return d.Output
+		}
+		return filepath.Join(dir, d.Output)
+	}
+
+	if spec == "-" {
+		return "-"
+	}
+	return filepath.Join(dir, filename)
+}
+
+// formatFinding formats finding of spec, position of finding is prefixed as file:line:col when known
+func formatFinding(spec string, f dockerfileyml.Finding) string {
+	if f.Position.IsValid() {
+		return f.String()
+	}
+	return spec + ": " + f.String()
+}
+
+// located tells err is prefixed with position of spec
+func located(err error) bool {
+	fe := &dockerfileyml.FieldError{}
+	return errors.As(err, &fe) && fe.Position.IsValid()
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerfileyml")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	t.Run("single", func(t *testing.T) {
+		output := filepath.Join(dir, "Dockerfile.simple")
+
+		err := runGenerate([]string{"../../testdata/multistage.yml", "-o", output})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ := ioutil.ReadFile(output)
+		expected, _ := ioutil.ReadFile("../../__snapshots__/multistage.Dockerfile")
+		NewWithT(t).Expect(string(data)).To(Equal(string(expected)))
+
+		// unchanged output is not written again
+		past := time.Now().Add(-time.Hour).Truncate(time.Second)
+		_ = os.Chtimes(output, past, past)
+
+		err = runGenerate([]string{"../../testdata/multistage.yml", "-o", output})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		info, _ := os.Stat(output)
+		NewWithT(t).Expect(info.ModTime().Equal(past)).To(BeTrue())
+	})
+
+	t.Run("multi documents", func(t *testing.T) {
+		spec := filepath.Join(dir, "dockerfile.yml")
+
+		_ = ioutil.WriteFile(spec, []byte(`
+output: api/Dockerfile
+from: busybox
+cmd: [api]
+---
+output: worker/Dockerfile
+from: busybox
+cmd: [worker]
+`), 0644)
+
+		err := runGenerate([]string{spec})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "worker/Dockerfile"))
+		NewWithT(t).Expect(string(data)).To(ContainSubstring(`CMD ["worker"]`))
+
+		err = runGenerate([]string{spec, "-o", filepath.Join(dir, "Dockerfile")})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("check", func(t *testing.T) {
+		output := filepath.Join(dir, "Dockerfile.check")
+
+		buf := bytes.NewBuffer(nil)
+		stdout = buf
+		defer func() {
+			stdout = os.Stdout
+		}()
+
+		err := runGenerate([]string{"../../testdata/multistage.yml", "-o", output, "--check"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+
+		err = runGenerate([]string{"../../testdata/multistage.yml", "-o", output})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		err = runGenerate([]string{"../../testdata/multistage.yml", "-o", output, "--check"})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		_ = ioutil.WriteFile(output, []byte("FROM alpine\n"), 0644)
+		buf.Reset()
+
+		err = runGenerate([]string{"../../testdata/multistage.yml", "-o", output, "--check"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("-FROM alpine\n"))
+	})
+
+	t.Run("pipe", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		stdin = strings.NewReader("from: busybox\ncmd: [sh]\n")
+		stdout = buf
+		defer func() {
+			stdin = os.Stdin
+			stdout = os.Stdout
+		}()
+
+		err := runGenerate([]string{"-"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("FROM busybox\n\nCMD [\"sh\"]\n\n"))
+
+		buf.Reset()
+
+		err = runGenerate([]string{"../../testdata/multistage.yml", "-o", "-"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(HavePrefix("ARG BUILDPLATFORM\n"))
+	})
+	t.Run("profile", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		stdin = strings.NewReader("from: busybox\ncmd: [sh]\nprofiles:\n  debug:\n    cmd: [sh, -x]\n")
+		stdout = buf
+		defer func() {
+			stdin = os.Stdin
+			stdout = os.Stdout
+		}()
+
+		err := runGenerate([]string{"-", "--profile", "debug"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("FROM busybox\n\nCMD [\"sh\",\"-x\"]\n\n"))
+	})
+	t.Run("header", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		stdin = strings.NewReader("from: busybox\n")
+		stdout = buf
+		defer func() {
+			stdin = os.Stdin
+			stdout = os.Stdout
+		}()
+
+		err := runGenerate([]string{"-", "--header"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(HavePrefix("# Code generated by dockerfileyml. DO NOT EDIT.\n"))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("# spec sha256: "))
+	})
+	t.Run("mirror", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		stdin = strings.NewReader("from: busybox\n")
+		stdout = buf
+		defer func() {
+			stdin = os.Stdin
+			stdout = os.Stdout
+		}()
+
+		err := runGenerate([]string{"-", "--mirror", "docker.io=mirror.corp.example"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("FROM mirror.corp.example/library/busybox\n\n"))
+
+		err = runGenerate([]string{"-", "--mirror", "docker.io"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+	t.Run("vcs labels", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		stdin = strings.NewReader("from: busybox\n")
+		stdout = buf
+		defer func() {
+			stdin = os.Stdin
+			stdout = os.Stdout
+		}()
+
+		err := runGenerate([]string{"-", "--vcs-revision", "abc", "--vcs-source", "https://github.com/org/app", "--vcs-created", "2021-01-02T03:04:05Z"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("LABEL org.opencontainers.image.revision=abc\n"))
+	})
+	t.Run("variants", func(t *testing.T) {
+		spec := filepath.Join(dir, "variants/dockerfile.yml")
+		_ = os.MkdirAll(filepath.Dir(spec), os.ModePerm)
+
+		_ = ioutil.WriteFile(spec, []byte(`
+from: python:3.9
+variants:
+  slim:
+    from: python:3.9-slim
+`), 0644)
+
+		err := runGenerate([]string{spec, "--all-variants"})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "variants/Dockerfile"))
+		NewWithT(t).Expect(string(data)).To(Equal("FROM python:3.9\n\n"))
+
+		data, _ = ioutil.ReadFile(filepath.Join(dir, "variants/Dockerfile.slim"))
+		NewWithT(t).Expect(string(data)).To(Equal("FROM python:3.9-slim\n\n"))
+
+		output := filepath.Join(dir, "variants/Dockerfile.one")
+
+		err = runGenerate([]string{spec, "--variant", "slim", "-o", output})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ = ioutil.ReadFile(output)
+		NewWithT(t).Expect(string(data)).To(Equal("FROM python:3.9-slim\n\n"))
+
+		err = runGenerate([]string{spec, "--variant", "cuda"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+	t.Run("per platform", func(t *testing.T) {
+		spec := filepath.Join(dir, "platforms/dockerfile.yml")
+		_ = os.MkdirAll(filepath.Dir(spec), os.ModePerm)
+
+		_ = ioutil.WriteFile(spec, []byte(`
+platforms: [linux/amd64, linux/arm64]
+platform-overrides:
+  linux/arm64:
+    from: arm64v8/alpine
+from: alpine
+`), 0644)
+
+		err := runGenerate([]string{spec, "--per-platform"})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "platforms/Dockerfile.linux-arm64"))
+		NewWithT(t).Expect(string(data)).To(Equal("FROM arm64v8/alpine\n\n"))
+
+		err = runGenerate([]string{spec})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ = ioutil.ReadFile(filepath.Join(dir, "platforms/Dockerfile"))
+		NewWithT(t).Expect(string(data)).To(HaveSuffix("FROM main-${TARGETARCH}\n\n"))
+	})
+	t.Run("source map", func(t *testing.T) {
+		spec := filepath.Join(dir, "sourcemap/dockerfile.yml")
+		_ = os.MkdirAll(filepath.Dir(spec), os.ModePerm)
+
+		_ = ioutil.WriteFile(spec, []byte("from: alpine\nrun: [make]\n"), 0644)
+
+		err := runGenerate([]string{spec, "--source-map"})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "sourcemap/Dockerfile.map.json"))
+		NewWithT(t).Expect(string(data)).To(ContainSubstring(`"path": "run[0]"`))
+		NewWithT(t).Expect(string(data)).To(ContainSubstring(`"line": 3,`))
+
+		err = runGenerate([]string{spec, "--source-map", "-o", "-"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+	t.Run("dockerignore", func(t *testing.T) {
+		spec := filepath.Join(dir, "dockerignore/dockerfile.yml")
+		_ = os.MkdirAll(filepath.Dir(spec), os.ModePerm)
+
+		_ = ioutil.WriteFile(spec, []byte("from: alpine\ncopy:\n  ./bin/app: /usr/bin/app\n"), 0644)
+
+		err := runGenerate([]string{spec, "--dockerignore", "--ignore-pattern", "**/*.md"})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "dockerignore/.dockerignore"))
+		NewWithT(t).Expect(string(data)).To(Equal("# Code generated by dockerfileyml. DO NOT EDIT.\n*\n!bin/app\n**/*.md\n"))
+
+		empty := filepath.Join(dir, "dockerignore/empty.yml")
+		_ = ioutil.WriteFile(empty, []byte("# nothing yet\n"), 0644)
+
+		err = runGenerate([]string{empty, "--dockerignore"})
+		NewWithT(t).Expect(err).To(BeNil())
+	})
+
+	t.Run("ignore of spec", func(t *testing.T) {
+		spec := filepath.Join(dir, "ignore/dockerfile.yml")
+		_ = os.MkdirAll(filepath.Dir(spec), os.ModePerm)
+
+		_ = ioutil.WriteFile(spec, []byte("---\nname: app\noutput: app.Dockerfile\nfrom: alpine\nignore: [node_modules, \"**/*.log\"]\ncopy:\n  ./app: /app\n---\nname: web\noutput: web.Dockerfile\nfrom: nginx\n"), 0644)
+
+		err := runGenerate([]string{spec})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "ignore/app.Dockerfile.dockerignore"))
+		NewWithT(t).Expect(string(data)).To(Equal("# Code generated by dockerfileyml. DO NOT EDIT.\nnode_modules\n**/*.log\n"))
+
+		_, err = os.Stat(filepath.Join(dir, "ignore/web.Dockerfile.dockerignore"))
+		NewWithT(t).Expect(os.IsNotExist(err)).To(BeTrue())
+
+		err = runGenerate([]string{spec, "--dockerignore"})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ = ioutil.ReadFile(filepath.Join(dir, "ignore/app.Dockerfile.dockerignore"))
+		NewWithT(t).Expect(string(data)).To(Equal("# Code generated by dockerfileyml. DO NOT EDIT.\n*\n!app\nnode_modules\n**/*.log\n"))
+	})
+
+	t.Run("dialect", func(t *testing.T) {
+		spec := filepath.Join(dir, "podman/dockerfile.yml")
+		_ = os.MkdirAll(filepath.Dir(spec), os.ModePerm)
+
+		_ = ioutil.WriteFile(spec, []byte(`
+from: alpine
+copy:
+  --link ./: /src
+`), 0644)
+
+		err := runGenerate([]string{spec, "--dialect", "podman"})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, _ := ioutil.ReadFile(filepath.Join(dir, "podman/Containerfile"))
+		NewWithT(t).Expect(string(data)).To(Equal("FROM alpine\n\nCOPY ./ /src\n\n"))
+
+		err = runGenerate([]string{spec, "--dialect", "kaniko"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+	t.Run("diagnostics", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		errBuf := bytes.NewBuffer(nil)
+		stdin = strings.NewReader("from: busybox\nwokrdir: /src\n")
+		stdout = buf
+		stderr = errBuf
+		defer func() {
+			stdin = os.Stdin
+			stdout = os.Stdout
+			stderr = os.Stderr
+		}()
+
+		err := runGenerate([]string{"-"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("FROM busybox\n\n"))
+		NewWithT(t).Expect(errBuf.String()).To(Equal("-:2:1: warning[ignored-field] wokrdir: unknown field\n"))
+	})
+
+	t.Run("scan report", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "scan")
+		defer os.RemoveAll(dir)
+
+		report := filepath.Join(dir, "busybox.json")
+		_ = ioutil.WriteFile(report, []byte(`{"ArtifactName": "busybox:1.36", "Results": [{"Vulnerabilities": [{"Severity": "HIGH"}]}]}`), 0644)
+
+		buf := bytes.NewBuffer(nil)
+		errBuf := bytes.NewBuffer(nil)
+		stdout = buf
+		stderr = errBuf
+		defer func() {
+			stdin = os.Stdin
+			stdout = os.Stdout
+			stderr = os.Stderr
+		}()
+
+		stdin = strings.NewReader("from: busybox:1.36\n")
+		err := runGenerate([]string{"-", "--scan-report", report})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(errBuf.String()).To(Equal("-:1:1: info[vulnerabilities] from: image busybox:1.36 has 1 high vulnerabilities\n"))
+
+		stdin = strings.NewReader("from: busybox:1.36\n")
+		err = runGenerate([]string{"-", "--scan-report", report, "--vuln-threshold", "HIGH=0"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("over thresholds of high > 0"))
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+var initCommand = &command{
+	name:    "init",
+	usage:   "--lang <lang> [-o dockerfile.yml] [--force]",
+	summary: "write a starter spec",
+}
+
+func init() {
+	initCommand.run = runInit
+}
+
+// starters of spec by lang
+var starters = map[string]string{
+	"go": `stages:
+  builder:
+    from: golang:1.15
+    workdir: /go/src
+    env:
+      CGO_ENABLED: "0"
+    steps:
+      - copy:
+          go.mod: ./
+          go.sum: ./
+      - run:
+          - go mod download
+      - copy:
+          .: ./
+      - run:
+          - go build -o /go/bin/app ./
+
+from: gcr.io/distroless/static
+workdir: /app
+copy:
+  builder:/go/bin/app: ./app
+user: nonroot
+entrypoint: [./app]
+`,
+	"node": `stages:
+  builder:
+    from: node:14
+    workdir: /app
+    steps:
+      - copy:
+          package.json: ./
+          package-lock.json: ./
+      - run:
+          - npm ci
+      - copy:
+          .: ./
+      - run:
+          - npm run build
+
+from: node:14-slim
+workdir: /app
+env:
+  NODE_ENV: production
+copy:
+  builder:/app: ./
+user: node
+cmd: [node, index.js]
+`,
+	"python": `from: python:3.8-slim
+workdir: /app
+steps:
+  - copy:
+      requirements.txt: ./
+  - run:
+      - pip install --no-cache-dir -r requirements.txt
+  - copy:
+      .: ./
+user: nobody
+cmd: [python, main.py]
+`,
+}
+
+func starterLangs() []string {
+	langs := make([]string, 0, len(starters))
+	for lang := range starters {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+func runInit(args []string) error {
+	fs := newFlagSet(initCommand)
+	lang := fs.String("lang", "", "language of project, one of "+strings.Join(starterLangs(), ", "))
+	output := fs.String("o", "dockerfile.yml", "output file, - for stdout")
+	force := fs.Bool("force", false, "overwrite existing file")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 0 || *lang == "" {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	starter, ok := starters[*lang]
+	if !ok {
+		return fmt.Errorf("unsupported lang %s, should be one of %s", *lang, strings.Join(starterLangs(), ", "))
+	}
+
+	if *output == "-" {
+		_, err := io.WriteString(stdout, starter)
+		return err
+	}
+
+	if !*force {
+		if _, err := os.Stat(*output); err == nil {
+			return fmt.Errorf("%s already exists, use --force to overwrite", *output)
+		}
+	}
+
+	if err := ioutil.WriteFile(*output, []byte(starter), 0644); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "written", *output)
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-courier/dockerfileyml"
+	. "github.com/onsi/gomega"
+)
+
+func TestInit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerfileyml")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	stdout = bytes.NewBuffer(nil)
+	defer func() {
+		stdout = os.Stdout
+	}()
+
+	for _, lang := range starterLangs() {
+		lang := lang
+
+		t.Run(lang, func(t *testing.T) {
+			spec := filepath.Join(dir, lang+".yml")
+
+			err := runInit([]string{"--lang", lang, "-o", spec})
+			NewWithT(t).Expect(err).To(BeNil())
+
+			d, err := dockerfileyml.ParseFile(spec, dockerfileyml.WithStrict())
+			NewWithT(t).Expect(err).To(BeNil())
+			NewWithT(t).Expect(dockerfileyml.WriteToDockerfile(bytes.NewBuffer(nil), *d)).To(BeNil())
+
+			err = runInit([]string{"--lang", lang, "-o", spec})
+			NewWithT(t).Expect(err).NotTo(BeNil())
+		})
+	}
+}
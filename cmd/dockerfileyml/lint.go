@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/go-courier/dockerfileyml"
+)
+
+var lintCommand = &command{
+	name:    "lint",
+	usage:   "<spec.yml|-> [--rules dir] [--policy path] [--max-layers n] [--format text|json|sarif]",
+	summary: "check spec by lint rules, fail when any error found",
+}
+
+func init() {
+	lintCommand.run = runLint
+}
+
+func runLint(args []string) error {
+	fs := newFlagSet(lintCommand)
+	rulesDir := fs.String("rules", "", "dir of executable rules to check with built-in rules, see ExecRule for protocol")
+	maxLayers := fs.Int("max-layers", dockerfileyml.DefaultMaxLayers, "max depth of layers of RUN, COPY and ADD of stages, warned by layer-count")
+	format := fs.String("format", "text", "format of findings, json or sarif for CI and code scanning")
+	policies := listFlag{}
+	fs.Var(&policies, "policy", "file or dir of Rego policies evaluated by opa, see PolicyRule for input and results, could be repeated")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	switch *format {
+	case "text", "json", "sarif":
+	default:
+		return fmt.Errorf("unsupported format %s, should be text, json or sarif", *format)
+	}
+
+	spec := positional[0]
+
+	var list []*dockerfileyml.Dockerfile
+
+	if spec == "-" {
+		list, err = dockerfileyml.ReadAllFromYAML(stdin, dockerfileyml.WithFilename(spec))
+	} else {
+		list, err = dockerfileyml.ParseFileAll(spec)
+	}
+	if err != nil {
+		return err
+	}
+
+	rules := dockerfileyml.DefaultRules
+
+	if *rulesDir != "" {
+		plugins, err := dockerfileyml.LoadRulePlugins(*rulesDir)
+		if err != nil {
+			return err
+		}
+		rules = append(append([]dockerfileyml.Rule{}, rules...), plugins...)
+	}
+
+	if *maxLayers != dockerfileyml.DefaultMaxLayers {
+		rules = append([]dockerfileyml.Rule{}, rules...)
+		for i := range rules {
+			if _, ok := rules[i].(*dockerfileyml.LayerRule); ok {
+				rules[i] = &dockerfileyml.LayerRule{Max: *maxLayers}
+			}
+		}
+	}
+
+	if len(policies) > 0 {
+		rules = append(append([]dockerfileyml.Rule{}, rules...), &dockerfileyml.PolicyRule{Policies: policies})
+	}
+
+	errors := 0
+	findings := make([]dockerfileyml.Finding, 0)
+
+	for _, d := range list {
+		for _, f := range dockerfileyml.Lint(d, rules...) {
+			if f.Severity == dockerfileyml.SeverityError {
+				errors++
+			}
+			if f.Position.Filename == "" && spec != "-" {
+				f.Position.Filename = spec
+			}
+			findings = append(findings, f)
+		}
+	}
+
+	switch *format {
+	case "json":
+		err = dockerfileyml.WriteFindingsJSON(stdout, findings)
+	case "sarif":
+		err = dockerfileyml.WriteFindingsSARIF(stdout, findings, version())
+	default:
+		for _, f := range findings {
+			if _, err = io.WriteString(stdout, formatFinding(spec, f)+"\n"); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if errors > 0 {
+		return fmt.Errorf("%d errors found", errors)
+	}
+
+	return nil
+}
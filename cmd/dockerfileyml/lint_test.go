@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLint(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	stdin = strings.NewReader("from: busybox\nentrypoint: [app]\nentrypoint-form: shell\ncmd: [serve]\n")
+	stdout = buf
+	defer func() {
+		stdin = os.Stdin
+		stdout = os.Stdout
+	}()
+
+	err := runLint([]string{"-"})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal("-:2:1: warning[shell-form-entrypoint] entrypoint: shell form ENTRYPOINT ignores CMD and arguments of docker run\n" +
+		"-:2:1: warning[shell-form-signals] entrypoint: shell form ENTRYPOINT runs under /bin/sh -c, which does not forward signals, use exec form or prefix with exec\n"))
+
+	t.Run("rules", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "rules")
+		defer os.RemoveAll(dir)
+
+		_ = ioutil.WriteFile(filepath.Join(dir, "no-busybox"), []byte("#!/bin/sh\necho '[{\"severity\": \"error\", \"path\": \"from\", \"message\": \"busybox is not allowed\"}]'\n"), 0755)
+
+		buf.Reset()
+		stdin = strings.NewReader("from: busybox\n")
+
+		err := runLint([]string{"-", "--rules", dir})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("-:1:1: error[no-busybox] from: busybox is not allowed\n"))
+	})
+
+	t.Run("max layers", func(t *testing.T) {
+		buf.Reset()
+		stdin = strings.NewReader("from: busybox\nsteps:\n  - run: [a]\n  - run: [b]\n")
+
+		err := runLint([]string{"-", "--max-layers", "1"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("-: warning[layer-count]: 2 layers of RUN, COPY and ADD, more than 1, merge RUN or copy fewer times\n"))
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "opa")
+		defer os.RemoveAll(dir)
+
+		// fake opa in PATH
+		_ = ioutil.WriteFile(filepath.Join(dir, "opa"), []byte("#!/bin/sh\necho '{\"result\": [{\"expressions\": [{\"value\": {\"deny\": [{\"path\": \"from\", \"msg\": \"busybox is not allowed\"}]}}]}]}'\n"), 0755)
+
+		path := os.Getenv("PATH")
+		_ = os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+		defer os.Setenv("PATH", path)
+
+		buf.Reset()
+		stdin = strings.NewReader("from: busybox\n")
+
+		err := runLint([]string{"-", "--policy", "org.rego"})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("-:1:1: error[policy] from: busybox is not allowed\n"))
+	})
+
+	t.Run("sarif", func(t *testing.T) {
+		buf.Reset()
+		stdin = strings.NewReader("from: busybox\nentrypoint: [app]\nentrypoint-form: shell\ncmd: [serve]\n")
+
+		err := runLint([]string{"-", "--format", "sarif"})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`"ruleId": "shell-form-entrypoint"`))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`"uri": "-"`))
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-courier/dockerfileyml"
+)
+
+var lockCommand = &command{
+	name:    "lock",
+	usage:   "<spec.yml> [--update image,...|--update-all] [--check]",
+	summary: "lock digests of base images and remote sources into dockerfile.lock",
+}
+
+func init() {
+	lockCommand.run = runLock
+}
+
+func runLock(args []string) error {
+	fs := newFlagSet(lockCommand)
+	update := fs.String("update", "", "images or sources to update, separated by comma, only missing are resolved by default")
+	all := fs.Bool("update-all", false, "update all images and sources")
+	check := fs.Bool("check", false, "only check all images and sources are locked, without resolving")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of resolving each image")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 || positional[0] == "-" {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	spec := positional[0]
+	path := filepath.Join(filepath.Dir(spec), dockerfileyml.LockfileName)
+
+	list, err := dockerfileyml.ParseFileAll(spec)
+	if err != nil {
+		return err
+	}
+
+	current, err := dockerfileyml.ReadLockfile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if *check {
+		if current == nil {
+			return fmt.Errorf("missing %s, run dockerfileyml lock to create", path)
+		}
+		return current.Verify(list...)
+	}
+
+	names := make([]string, 0)
+	if *update != "" {
+		names = strings.Split(*update, ",")
+	}
+
+	if *all {
+		current = nil
+	}
+
+	l, err := dockerfileyml.UpdateLockfile(context.Background(), current, list, &dockerfileyml.Resolver{Timeout: *timeout}, names...)
+	if err != nil {
+		return err
+	}
+
+	return dockerfileyml.WriteLockfile(path, l)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLock(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("content"))
+	}))
+	defer files.Close()
+
+	dir, err := ioutil.TempDir("", "dockerfileyml")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	spec := filepath.Join(dir, "dockerfile.yml")
+	_ = ioutil.WriteFile(spec, []byte("from: scratch\nadd:\n  "+files.URL+"/app.tar.gz: /app/\n"), 0644)
+
+	err = runLock([]string{spec, "--check"})
+	NewWithT(t).Expect(err).NotTo(BeNil())
+
+	err = runLock([]string{spec})
+	NewWithT(t).Expect(err).To(BeNil())
+
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "dockerfile.lock"))
+	NewWithT(t).Expect(string(data)).To(ContainSubstring(files.URL + "/app.tar.gz: sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"))
+
+	err = runLock([]string{spec, "--check"})
+	NewWithT(t).Expect(err).To(BeNil())
+
+	err = runGenerate([]string{spec})
+	NewWithT(t).Expect(err).To(BeNil())
+
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "Dockerfile"))
+	NewWithT(t).Expect(strings.Contains(string(data), "ADD --checksum=sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73 ")).To(BeTrue())
+}
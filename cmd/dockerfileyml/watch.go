@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/go-courier/dockerfileyml"
+)
+
+var watchCommand = &command{
+	name:    "watch",
+	usage:   "<spec.yml>... [-interval 500ms] [flags of generate, except --check]",
+	summary: "regenerate Dockerfile when spec or local files included by it change",
+}
+
+func init() {
+	watchCommand.run = runWatch
+}
+
+func runWatch(args []string) error {
+	fs := newFlagSet(watchCommand)
+	interval := fs.Duration("interval", 500*time.Millisecond, "interval of checking changes")
+	options := generateFlags(fs)
+
+	specs, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(specs) == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	o, err := options()
+	if err != nil {
+		return err
+	}
+
+	if o.output != "" && len(specs) > 1 {
+		return fmt.Errorf("-o could not be used with multiple specs")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		cancel()
+	}()
+
+	watchSpecs(ctx, specs, *interval, o)
+	return nil
+}
+
+// watchSpecs polls modification time of specs, and of local files included by them,
+// and regenerates changed ones by o until ctx done.
+// errors are printed, so fixing spec continues the loop.
+func watchSpecs(ctx context.Context, specs []string, interval time.Duration, o generateOptions) {
+	// of files by spec, same file could be included by multiple specs
+	modTimes := map[string]map[string]time.Time{}
+	// spec and files included by it
+	files := map[string][]string{}
+	failures := map[string]string{}
+
+	report := func(spec string, err error) {
+		// only once for same failure
+		if failures[spec] != err.Error() {
+			failures[spec] = err.Error()
+			fmt.Fprintln(stderr, "dockerfileyml:", err)
+		}
+	}
+
+	changed := func(spec string) (bool, error) {
+		if modTimes[spec] == nil {
+			modTimes[spec] = map[string]time.Time{}
+			files[spec] = []string{spec}
+		}
+
+		changed := false
+
+		for _, file := range files[spec] {
+			modTime := time.Time{}
+
+			info, err := os.Stat(file)
+			if err == nil {
+				modTime = info.ModTime()
+			} else if file == spec || !os.IsNotExist(err) {
+				return false, err
+			}
+
+			// missing included files are changed when created, or dropped from spec
+			if last, ok := modTimes[spec][file]; !ok || !last.Equal(modTime) {
+				modTimes[spec][file] = modTime
+				changed = true
+			}
+		}
+
+		return changed, nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, spec := range specs {
+			ok, err := changed(spec)
+			if err != nil {
+				report(spec, err)
+				continue
+			}
+
+			if !ok {
+				continue
+			}
+
+			included, err := regenerate(spec, o)
+			// included files are watched even on failure, so fixing them regenerates spec
+			files[spec] = append([]string{spec}, included...)
+			if err != nil {
+				report(spec, err)
+				continue
+			}
+
+			delete(failures, spec)
+			fmt.Fprintln(stdout, "generated from", spec)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// regenerate writes files of spec, returns local files included by spec
+func regenerate(spec string, o generateOptions) ([]string, error) {
+	included := make([]string, 0)
+
+	o.readOptions = append(append([]dockerfileyml.ReadOption{}, o.readOptions...), dockerfileyml.WithIncludedFiles(func(filename string) {
+		included = append(included, filename)
+	}))
+
+	files, err := generateFiles(spec, o)
+	if err != nil {
+		return included, err
+	}
+	return included, writeFiles(files)
+}
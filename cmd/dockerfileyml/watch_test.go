@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerfileyml")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	stdout = bytes.NewBuffer(nil)
+	defer func() {
+		stdout = os.Stdout
+	}()
+
+	spec := filepath.Join(dir, "dockerfile.yml")
+	output := filepath.Join(dir, "Dockerfile")
+
+	_ = ioutil.WriteFile(spec, []byte("from: busybox\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		watchSpecs(ctx, []string{spec}, 10*time.Millisecond, generateOptions{})
+		close(done)
+	}()
+
+	readOutput := func() string {
+		data, _ := ioutil.ReadFile(output)
+		return string(data)
+	}
+
+	NewWithT(t).Eventually(readOutput).Should(Equal("FROM busybox\n\n"))
+
+	_ = ioutil.WriteFile(spec, []byte("from: alpine\n"), 0644)
+	_ = os.Chtimes(spec, time.Now().Add(time.Second), time.Now().Add(time.Second))
+
+	NewWithT(t).Eventually(readOutput).Should(Equal("FROM alpine\n\n"))
+
+	cancel()
+	<-done
+}
+
+func TestWatchIncluded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerfileyml")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	stdout = bytes.NewBuffer(nil)
+	defer func() {
+		stdout = os.Stdout
+	}()
+
+	spec := filepath.Join(dir, "dockerfile.yml")
+	base := filepath.Join(dir, "base.yml")
+	output := filepath.Join(dir, "Containerfile")
+
+	_ = ioutil.WriteFile(base, []byte("stages:\n  base:\n    from: busybox\n"), 0644)
+	_ = ioutil.WriteFile(spec, []byte("include: [base.yml]\nfrom: base\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		watchSpecs(ctx, []string{spec}, 10*time.Millisecond, generateOptions{dialect: "podman"})
+		close(done)
+	}()
+
+	readOutput := func() string {
+		data, _ := ioutil.ReadFile(output)
+		return string(data)
+	}
+
+	NewWithT(t).Eventually(readOutput).Should(ContainSubstring("FROM busybox AS base"))
+
+	_ = ioutil.WriteFile(base, []byte("stages:\n  base:\n    from: alpine\n"), 0644)
+	_ = os.Chtimes(base, time.Now().Add(time.Second), time.Now().Add(time.Second))
+
+	NewWithT(t).Eventually(readOutput).Should(ContainSubstring("FROM alpine AS base"))
+
+	cancel()
+	<-done
+}
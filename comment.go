@@ -0,0 +1,173 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// writeInstruction writes instruction of docker key with values, for field of yaml path,
+// returns the instruction written, or nil when skipped
+type writeInstruction func(path string, dockerKey string, values ...string) *instruction
+
+// withComment returns write which attaches comment to the first instruction written by it
+func withComment(write writeInstruction, comment string) writeInstruction {
+	if comment == "" {
+		return write
+	}
+
+	return func(path string, dockerKey string, values ...string) *instruction {
+		ins := write(path, dockerKey, values...)
+		if ins != nil && comment != "" {
+			ins.Comments = append(ins.Comments, commentLines(comment)...)
+			comment = ""
+		}
+		return ins
+	}
+}
+
+// commentLines returns lines of comment, each one is written as # line
+func commentLines(comment string) []string {
+	lines := strings.Split(strings.TrimRight(comment, "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(strings.TrimPrefix(strings.TrimPrefix(lines[i], "#"), " "), " \t\r")
+	}
+	return lines
+}
+
+// validateComments validates comments are of fields which are written as instructions
+func validateComments(path string, comments Values) error {
+	errs := errorList{}
+
+	fields := map[string]bool{}
+	for i := 0; i < typeStage.NumField(); i++ {
+		if field := typeStage.Field(i); strings.Split(field.Tag.Get("docker"), ",")[0] != "" {
+			fields[yamlFieldName(field)] = true
+		}
+	}
+
+	keys := make([]string, 0, len(comments))
+	for key := range comments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !fields[key] {
+			errs.add(fieldError(yamlPath(path, key), fmt.Errorf("%w %s, should be field written as instruction", ErrInvalidKey, key)))
+		}
+	}
+
+	return errs.err()
+}
+
+var typeStage = reflect.TypeOf(Stage{})
+
+// WithSourceComments writes comments of source yaml as # lines before instructions of fields,
+// like comments of stages before FROM, and comments of keys of env before ENV.
+func WithSourceComments() WriteOption {
+	return func(o *writeOptions) {
+		o.sourceComments = true
+	}
+}
+
+// commentsOf returns comments of fields in each document of yaml stream by yaml path,
+// head comments and line comments of keys and items are kept, foot comments are dropped.
+func commentsOf(data []byte) ([]Values, error) {
+	decoder := yamlv3.NewDecoder(bytes.NewReader(data))
+
+	list := make([]Values, 0)
+
+	for {
+		node := &yamlv3.Node{}
+		if err := decoder.Decode(node); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		comments := Values{}
+		walkNodes("", node, func(path string, key *yamlv3.Node, value *yamlv3.Node) {
+			lines := make([]string, 0)
+			for _, c := range []string{key.HeadComment, key.LineComment, value.LineComment} {
+				if c != "" && !stringIncludes(lines, c) {
+					lines = append(lines, c)
+				}
+			}
+			if len(lines) > 0 {
+				comments[path] = strings.Join(lines, "\n")
+			}
+		})
+		list = append(list, comments)
+	}
+
+	return list, nil
+}
+
+// addSourceComments adds comments of fields before the first instruction of each field in order of source,
+// comments of fields merged into instruction of parent, like keys of inline env, are added before the instruction,
+// and comments of fields not written as instructions are dropped.
+func addSourceComments(list []instruction, comments Values, positions Positions) []instruction {
+	paths := make([]string, 0, len(comments))
+	for p := range comments {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	sort.SliceStable(paths, func(i, j int) bool {
+		a, b := positions[paths[i]], positions[paths[j]]
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+
+	// count of comments added to each instruction, before comments of rendering
+	added := make([]int, len(list))
+
+	for _, p := range paths {
+		i := indexOfField(list, p)
+		if i < 0 {
+			i = indexOfField(list, pathParentOf(p))
+		}
+		if i < 0 {
+			continue
+		}
+
+		lines := commentLines(comments[p])
+		ins := &list[i]
+		ins.Comments = append(append(append([]string{}, ins.Comments[0:added[i]]...), lines...), ins.Comments[added[i]:]...)
+		added[i] += len(lines)
+	}
+
+	return list
+}
+
+// indexOfField returns index of the first instruction of field of path, or -1
+func indexOfField(list []instruction, path string) int {
+	if path == "" {
+		return -1
+	}
+	for i := range list {
+		if p := list[i].Path; p == path || (strings.HasPrefix(p, path) && (p[len(path)] == '.' || p[len(path)] == '[')) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pathParentOf returns yaml path of parent, like env for env.A, run for run[0] and copy for copy["./a"]
+func pathParentOf(path string) string {
+	if strings.HasSuffix(path, "]") {
+		if strings.HasSuffix(path, "\"]") {
+			return path[0:strings.LastIndex(path, "[\"")]
+		}
+		return path[0:strings.LastIndex(path, "[")]
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[0:i]
+	}
+	return ""
+}
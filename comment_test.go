@@ -0,0 +1,105 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestComments(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+from: alpine
+comments:
+  workdir: sources are built here
+  cmd: |
+    runs as
+    nobody
+workdir: /src
+run:
+  - {cmd: "apk add curl", comment: needed for healthcheck}
+  - apk add git
+  - {cmd: "apk add make", comment: needed for build}
+copy:
+  - {src: ./go.mod, dst: ./, comment: "# cached before sources"}
+steps:
+  - {run: [make], comment: build}
+cmd: [app]
+`), WithStrict())
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d)).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM alpine
+
+# sources are built here
+WORKDIR /src
+
+# cached before sources
+COPY ./go.mod ./
+
+# needed for healthcheck
+# needed for build
+RUN apk add curl && apk add git && apk add make
+
+# build
+RUN make
+
+# runs as
+# nobody
+CMD ["app"]
+
+`))
+
+	t.Run("invalid", func(t *testing.T) {
+		d.Comments = Values{"steps": "x"}
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), *d)
+		NewWithT(t).Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+	})
+}
+
+func TestSourceComments(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  # builds the app
+  builder:
+    from: golang # pinned by renovate
+    workdir: /go/src
+    run:
+      - go mod download
+      # compiles
+      - go build ./...
+# runtime
+from: alpine
+env:
+  # mode of app
+  MODE: production
+copy:
+  builder:/go/bin/app: /bin/app
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d, WithSourceComments())).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`# builds the app
+# pinned by renovate
+FROM golang AS builder
+
+WORKDIR /go/src
+
+# compiles
+RUN go mod download && go build ./...
+
+# runtime
+FROM alpine
+
+# mode of app
+ENV MODE=production
+
+COPY --from=builder /go/bin/app /bin/app
+
+`))
+}
@@ -0,0 +1,82 @@
+package dockerfileyml
+
+import (
+	"io"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// WriteToComposeFile writes a docker-compose fragment to build Dockerfile of d,
+// with one service for d and one for each of its variants, named as targets of WriteToBakeFile.
+//
+//	services:
+//	  app:
+//	    image: ghcr.io/org/app
+//	    build:
+//	      context: .
+//	      dockerfile: Dockerfile
+//	      args:
+//	        VERSION: ""
+//	      cache_from: [ghcr.io/org/app]
+func WriteToComposeFile(w io.Writer, d Dockerfile) error {
+	list, err := buildTargets(&d)
+	if err != nil {
+		return err
+	}
+
+	services := yaml.MapSlice{}
+
+	for _, d := range list {
+		build := yaml.MapSlice{
+			{Key: "context", Value: "."},
+			{Key: "dockerfile", Value: d.dockerfile()},
+		}
+
+		if d.Target != "" {
+			build = append(build, yaml.MapItem{Key: "target", Value: d.Target})
+		}
+		if args := d.buildArgs(); len(args) > 0 {
+			build = append(build, yaml.MapItem{Key: "args", Value: sortedMapSlice(args)})
+		}
+		if len(d.Contexts) > 0 {
+			build = append(build, yaml.MapItem{Key: "additional_contexts", Value: sortedMapSlice(d.Contexts)})
+		}
+		if len(d.Platforms) > 0 {
+			build = append(build, yaml.MapItem{Key: "platforms", Value: d.Platforms})
+		}
+		if d.Image != "" {
+			build = append(build, yaml.MapItem{Key: "cache_from", Value: []string{d.Image}})
+		}
+
+		service := yaml.MapSlice{}
+		if d.Image != "" {
+			service = append(service, yaml.MapItem{Key: "image", Value: d.Image})
+		}
+		service = append(service, yaml.MapItem{Key: "build", Value: build})
+
+		services = append(services, yaml.MapItem{Key: d.targetName(), Value: service})
+	}
+
+	data, err := yaml.Marshal(yaml.MapSlice{{Key: "services", Value: services}})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func sortedMapSlice(values Values) yaml.MapSlice {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	m := make(yaml.MapSlice, len(keys))
+	for i, key := range keys {
+		m[i] = yaml.MapItem{Key: key, Value: values[key]}
+	}
+	return m
+}
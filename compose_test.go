@@ -0,0 +1,45 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+)
+
+func TestWriteToComposeFile(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+name: app
+output: build/Dockerfile
+image: ghcr.io/org/app
+target: runtime
+contexts:
+  docs: ../docs
+stages:
+  runtime:
+    from: alpine
+    arg:
+      VERSION: ""
+variants:
+  debug:
+    from: busybox
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	err = WriteToComposeFile(buf, *d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(MatchSnapshot("docker-compose.yml"))
+
+	buf.Reset()
+	err = WriteToComposeFile(buf, Dockerfile{})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`services:
+  default:
+    build:
+      context: .
+      dockerfile: Dockerfile
+`))
+}
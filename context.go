@@ -0,0 +1,302 @@
+package dockerfileyml
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithContextDir checks sources of copy and add, and bind mounts of run exist in build context dir before writing,
+// to catch typos before a slow docker build does. Glob patterns should match at least one file.
+func WithContextDir(dir string) WriteOption {
+	return func(o *writeOptions) {
+		o.contextDir = dir
+	}
+}
+
+// missingSourceErrors returns errors of sources not in context dir
+func missingSourceErrors(d *Dockerfile, dir string) error {
+	errs := errorList{}
+
+	eachContextSource(d, func(p string, src string) {
+		if src == "." {
+			return
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(src))
+
+		if isGlob(src) {
+			matches, err := filepath.Glob(file)
+			if err != nil {
+				errs.add(fieldError(p, fmt.Errorf("invalid pattern %s: %w", src, err)))
+				return
+			}
+			if len(matches) == 0 {
+				errs.add(fieldError(p, fmt.Errorf("%w: no files of %s matched in %s", ErrMissingSource, src, dir)))
+			}
+			return
+		}
+
+		if _, err := os.Stat(file); err != nil {
+			if os.IsNotExist(err) {
+				errs.add(fieldError(p, fmt.Errorf("%w: %s not found in %s", ErrMissingSource, src, dir)))
+				return
+			}
+			errs.add(fieldError(p, err))
+		}
+	})
+
+	return errs.err()
+}
+
+// isGlob tells src is glob pattern of COPY
+func isGlob(src string) bool {
+	return strings.ContainsAny(src, "*?[")
+}
+
+// WithExpandedGlobs expands glob patterns of sources of copy and add into files matched in build context dir,
+// and fails when no files matched, instead of a confusing error of docker build.
+// Sources are checked like WithContextDir.
+func WithExpandedGlobs(dir string) WriteOption {
+	return func(o *writeOptions) {
+		o.contextDir = dir
+		o.expandGlobs = true
+	}
+}
+
+// expandGlobs returns a copy of d with glob patterns of copy and add sources expanded into files of dir
+func expandGlobs(d *Dockerfile, dir string) (*Dockerfile, error) {
+	expand := func(values Values) (Values, error) {
+		if values == nil {
+			return nil, nil
+		}
+
+		expanded := make(Values, len(values))
+
+		for from, dest := range values {
+			key, err := expandCopyKey(from, d.Stages, dir)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = dest
+		}
+
+		return expanded, nil
+	}
+
+	expandKey := func(key string) (string, error) {
+		return expandCopyKey(key, d.Stages, dir)
+	}
+
+	expandEntry := func(e CopyEntry) (CopyEntry, error) {
+		return expandCopyEntry(e, dir)
+	}
+
+	return mapStages(d, func(s *Stage, stages []string) (err error) {
+		if s.Copy, err = s.Copy.mapKeys("copy", expandKey, expandEntry); err != nil {
+			return err
+		}
+		if s.Add, err = expand(s.Add); err != nil {
+			return err
+		}
+
+		for i := range s.Steps {
+			if s.Steps[i].Copy, err = s.Steps[i].Copy.mapKeys("steps"+indexPath(i)+".copy", expandKey, expandEntry); err != nil {
+				return err
+			}
+			if s.Steps[i].Add, err = expand(s.Steps[i].Add); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// expandCopyKey expands glob patterns in key of copy, like ./cmd/*/main.go to ./cmd/a/main.go ./cmd/b/main.go
+func expandCopyKey(from string, stages map[string]*Stage, dir string) (string, error) {
+	if len(copySourcesOf(from, stages)) == 0 {
+		// sources of stages or images
+		return from, nil
+	}
+
+	words := strings.Fields(from)
+	expanded := make([]string, 0, len(words))
+
+	for i, word := range words {
+		if strings.HasPrefix(word, "--") {
+			expanded = append(expanded, word)
+			continue
+		}
+
+		if rest := strings.Join(words[i:], " "); strings.HasPrefix(rest, "[") && json.Valid([]byte(rest)) {
+			// sources in JSON array are kept
+			return from, nil
+		}
+
+		matches, err := expandGlob(word, dir)
+		if err != nil {
+			return "", err
+		}
+		expanded = append(expanded, matches...)
+	}
+
+	return strings.Join(expanded, " "), nil
+}
+
+// expandCopyEntry expands glob patterns in sources of entry in build context, sources of stages or images are kept
+func expandCopyEntry(e CopyEntry, dir string) (CopyEntry, error) {
+	if e.From != "" {
+		return e, nil
+	}
+
+	expanded := make(Paths, 0, len(e.Src))
+
+	for _, src := range e.Src {
+		if strings.HasPrefix(src, artifactScheme) {
+			expanded = append(expanded, src)
+			continue
+		}
+
+		matches, err := expandGlob(src, dir)
+		if err != nil {
+			return CopyEntry{}, err
+		}
+		expanded = append(expanded, matches...)
+	}
+
+	e.Src = expanded
+	return e, nil
+}
+
+// expandGlob returns files matched by pattern in dir, relative to dir, or pattern itself when it is not a glob
+func expandGlob(pattern string, dir string) ([]string, error) {
+	if !isGlob(pattern) || strings.Contains(pattern, "$") {
+		return []string{pattern}, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(pattern, "/"))))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: no files of %s matched in %s", ErrMissingSource, pattern, dir)
+	}
+
+	prefix := ""
+	if strings.HasPrefix(pattern, "./") {
+		prefix = "./"
+	}
+
+	expanded := make([]string, 0, len(matches))
+
+	for _, m := range matches {
+		rel, err := filepath.Rel(dir, m)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, prefix+filepath.ToSlash(rel))
+	}
+
+	return expanded, nil
+}
+
+// eachCopy calls fn for each key and dest of copy and add, including ones of steps, with yaml path of key
+func eachCopy(d *Dockerfile, fn func(p string, from string, dest string)) {
+	visit := func(p string, c Copies) {
+		for _, pair := range c.pairs(p) {
+			fn(pair.path, pair.key, pair.dst)
+		}
+	}
+
+	eachStage(d, func(p string, s *Stage) {
+		visit(fieldPath(p, "copy"), s.Copy)
+		visit(fieldPath(p, "add"), CopyValues(s.Add))
+
+		for i, step := range s.Steps {
+			stepPath := fieldPath(p, "steps") + indexPath(i)
+			visit(stepPath+".copy", step.Copy)
+			visit(stepPath+".add", CopyValues(step.Add))
+		}
+	})
+}
+
+// checkCopyDirectory checks destinations of copy are directories ending with /,
+// when sources are directories or multiple files.
+func checkCopyDirectory(d *Dockerfile) []Finding {
+	return copyDirectoryFindings(d, "")
+}
+
+// copyDirectoryFindings checks destinations of copy with sources in context dir when dir is not empty,
+// since COPY copies contents of directories instead of directories themselves,
+// and fails when multiple sources are copied to destination without trailing slash.
+func copyDirectoryFindings(d *Dockerfile, dir string) (findings []Finding) {
+	eachCopy(d, func(p string, from string, dest string) {
+		if strings.Contains(dest, "$") {
+			return
+		}
+
+		destIsDir := strings.HasSuffix(dest, "/") || dest == "." || strings.HasSuffix(dest, "/.")
+
+		// sources in build context, or of stages
+		sources := copySourcesOf(from, d.Stages)
+		inContext := len(sources) > 0
+		if !inContext {
+			if parts := strings.Split(from, ":"); len(parts) == 2 && d.Stages[parts[0]] != nil {
+				sources = parts[1:]
+			} else if words := strings.Fields(from); len(words) > 0 {
+				sources = words[len(words)-1:]
+			}
+		}
+
+		if !destIsDir && len(sources) > 1 {
+			findings = append(findings, Finding{
+				Rule:     "copy-directory",
+				Severity: SeverityError,
+				Path:     p,
+				Message:  "destination " + dest + " of multiple sources should end with /",
+			})
+			return
+		}
+
+		for _, src := range sources {
+			isDir := strings.HasSuffix(src, "/") || src == "." || strings.HasSuffix(src, "/.")
+
+			if dir != "" && inContext && !isGlob(src) && !strings.Contains(src, "$") {
+				if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(src, "/")))); err == nil {
+					if isDir && !info.IsDir() {
+						findings = append(findings, Finding{
+							Rule:     "copy-directory",
+							Severity: SeverityWarning,
+							Path:     p,
+							Message:  "source " + src + " ends with / but is a file",
+						})
+						continue
+					}
+					isDir = info.IsDir()
+				}
+			}
+
+			switch {
+			case isGlob(src) && !destIsDir:
+				findings = append(findings, Finding{
+					Rule:     "copy-directory",
+					Severity: SeverityWarning,
+					Path:     p,
+					Message:  "destination " + dest + " of pattern " + src + " should end with /, which fails when multiple files matched",
+				})
+			case isDir && !destIsDir:
+				findings = append(findings, Finding{
+					Rule:     "copy-directory",
+					Severity: SeverityWarning,
+					Path:     p,
+					Message:  "contents of directory " + src + " are copied into " + dest + ", end it with / to make clear it is a directory",
+				})
+			}
+		}
+	})
+
+	return
+}
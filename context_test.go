@@ -0,0 +1,155 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWithContextDir(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "context")
+	defer os.RemoveAll(dir)
+
+	_ = os.MkdirAll(filepath.Join(dir, "cmd/app"), os.ModePerm)
+	_ = ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module app\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "cmd/app/main.go"), []byte("package main\n"), 0644)
+
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang:1.21
+    workdir: /src
+    copy:
+      ./go.mod: ./
+      ./go.sum: ./
+      ./cmd/*/*.go: ./cmd/
+      ./docs/*.md: ./docs/
+    run:
+      - cmd: go build ./cmd/app
+        mount: ["type=bind,source=internal,target=internal"]
+from: alpine:3.18
+copy:
+  builder:/src/app: /usr/bin/app
+  ./$CONFIG: /etc/config
+`), WithFilename("dockerfile.yml"))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	err = WriteToDockerfile(ioutil.Discard, *d, WithContextDir(dir))
+	NewWithT(t).Expect(errors.Is(err, ErrMissingSource)).To(BeTrue())
+
+	messages := make([]string, 0)
+	for _, e := range err.(*ValidationError).Errors {
+		messages = append(messages, strings.Replace(e.Error(), dir, "<dir>", 1))
+	}
+
+	NewWithT(t).Expect(messages).To(Equal([]string{
+		`dockerfile.yml:10:7: stages.builder.copy["./docs/*.md"]: missing source: no files of docs/*.md matched in <dir>`,
+		`dockerfile.yml:8:7: stages.builder.copy["./go.sum"]: missing source: go.sum not found in <dir>`,
+		`dockerfile.yml:13:17: stages.builder.run[0].mount[0]: missing source: internal not found in <dir>`,
+	}))
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "go.sum"), nil, 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "internal"), nil, 0644)
+	delete(d.Stages["builder"].Copy.Values, "./docs/*.md")
+
+	NewWithT(t).Expect(WriteToDockerfile(ioutil.Discard, *d, WithContextDir(dir))).To(BeNil())
+}
+
+func TestWithExpandedGlobs(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "context")
+	defer os.RemoveAll(dir)
+
+	_ = os.MkdirAll(filepath.Join(dir, "cmd/a"), os.ModePerm)
+	_ = os.MkdirAll(filepath.Join(dir, "cmd/b"), os.ModePerm)
+	_ = ioutil.WriteFile(filepath.Join(dir, "cmd/a/main.go"), nil, 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "cmd/b/main.go"), nil, 0644)
+
+	d := Dockerfile{
+		Stage: Stage{
+			From: "golang:1.21",
+			Copy: CopyValues(Values{
+				"--chown=app ./cmd/*/main.go": "/src/",
+				`["cmd/a/main.go"]`:           "/a.go",
+			}),
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d, WithExpandedGlobs(dir))).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring("COPY --chown=app ./cmd/a/main.go ./cmd/b/main.go /src/\n"))
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring(`COPY ["cmd/a/main.go"] /a.go`))
+
+	NewWithT(t).Expect(d.Copy.Values).To(HaveKey("--chown=app ./cmd/*/main.go"))
+
+	entries := d
+	entries.Copy = CopyEntries(CopyEntry{Src: Paths{"./cmd/*/main.go"}, Dst: "/src/", Chown: "app"})
+
+	buf.Reset()
+	NewWithT(t).Expect(WriteToDockerfile(buf, entries, WithExpandedGlobs(dir))).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring("COPY --chown=app ./cmd/a/main.go ./cmd/b/main.go /src/\n"))
+
+	d.Steps = []Step{{Copy: CopyValues(Values{"./docs/*.md": "/docs/"})}}
+
+	err := WriteToDockerfile(ioutil.Discard, d, WithExpandedGlobs(dir))
+	NewWithT(t).Expect(errors.Is(err, ErrMissingSource)).To(BeTrue())
+	NewWithT(t).Expect(err.Error()).To(ContainSubstring(`steps[0].copy["./docs/*.md"]: missing source: no files of docs/*.md matched`))
+}
+
+func TestCopyDirectory(t *testing.T) {
+	d := &Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {From: "golang:1.21", WorkingDir: "/src"},
+		},
+		Stage: Stage{
+			From:       "alpine",
+			WorkingDir: "/app",
+			Copy: CopyValues(Values{
+				"./bin/":            "/usr/bin",
+				"./a ./b":           "/opt",
+				"./conf/*.yml":      "/etc/app",
+				"builder:/src/out/": "/out",
+				"./static":          "/srv/",
+				"./app":             "/usr/bin/app",
+			}),
+		},
+	}
+
+	messages := func(findings []Finding) []string {
+		list := make([]string, 0)
+		for _, f := range findings {
+			list = append(list, f.String())
+		}
+		return list
+	}
+
+	NewWithT(t).Expect(messages(Lint(d, RuleFunc(checkCopyDirectory)))).To(Equal([]string{
+		`error[copy-directory] copy["./a ./b"]: destination /opt of multiple sources should end with /`,
+		`warning[copy-directory] copy["./bin/"]: contents of directory ./bin/ are copied into /usr/bin, end it with / to make clear it is a directory`,
+		`warning[copy-directory] copy["./conf/*.yml"]: destination /etc/app of pattern ./conf/*.yml should end with /, which fails when multiple files matched`,
+		`warning[copy-directory] copy["builder:/src/out/"]: contents of directory /src/out/ are copied into /out, end it with / to make clear it is a directory`,
+	}))
+
+	t.Run("with context dir", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "context")
+		defer os.RemoveAll(dir)
+
+		_ = os.MkdirAll(filepath.Join(dir, "app"), os.ModePerm)
+		_ = ioutil.WriteFile(filepath.Join(dir, "bin"), nil, 0644)
+
+		NewWithT(t).Expect(messages(Lint(d, RuleFunc(func(d *Dockerfile) []Finding {
+			return copyDirectoryFindings(d, dir)
+		})))).To(Equal([]string{
+			`error[copy-directory] copy["./a ./b"]: destination /opt of multiple sources should end with /`,
+			`warning[copy-directory] copy["./app"]: contents of directory ./app are copied into /usr/bin/app, end it with / to make clear it is a directory`,
+			`warning[copy-directory] copy["./bin/"]: source ./bin/ ends with / but is a file`,
+			`warning[copy-directory] copy["./conf/*.yml"]: destination /etc/app of pattern ./conf/*.yml should end with /, which fails when multiple files matched`,
+			`warning[copy-directory] copy["builder:/src/out/"]: contents of directory /src/out/ are copied into /out, end it with / to make clear it is a directory`,
+		}))
+	})
+}
@@ -0,0 +1,350 @@
+package dockerfileyml
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Copies are sources of COPY to destinations.
+//
+// In yaml, it is a mapping of sources to destination, like
+//
+//	copy:
+//	  ./bin/app: /usr/local/bin/
+//	  builder:/go/bin/app: /usr/local/bin/
+//
+// or a list of CopyEntry, which keeps order, duplicated sources and multiple sources to one destination, like
+//
+//	copy:
+//	  - {src: [./go.mod, ./go.sum], dst: ./}
+//	  - {src: ./config.yaml, dst: /etc/app/, chown: nobody, chmod: "644"}
+//	  - {src: ./config.yaml, dst: /etc/app/backup/}
+//	  - {from: builder, src: /go/bin/app, dst: /usr/local/bin/}
+//
+// Copy of Stage and Step is Copies since v1.0.0, Go callers assigning Values wrap them by CopyValues.
+type Copies struct {
+	// Values of mapping form, written in order of sources
+	Values Values
+	// Entries of list form, written in order after Values
+	Entries []CopyEntry
+}
+
+var typeCopies = reflect.TypeOf(Copies{})
+
+// CopyValues returns Copies of mapping form
+func CopyValues(values Values) Copies {
+	return Copies{Values: values}
+}
+
+// CopyEntries returns Copies of list form
+func CopyEntries(entries ...CopyEntry) Copies {
+	return Copies{Entries: entries}
+}
+
+func (c *Copies) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	values := Values{}
+	if err := unmarshal(&values); err == nil {
+		*c = Copies{Values: values}
+		return nil
+	}
+
+	entries := make([]CopyEntry, 0)
+	if err := unmarshal(&entries); err != nil {
+		return err
+	}
+	*c = Copies{Entries: entries}
+	return nil
+}
+
+func (c Copies) MarshalYAML() (interface{}, error) {
+	if len(c.Entries) > 0 {
+		if len(c.Values) == 0 {
+			return c.Entries, nil
+		}
+		// both are set in code, mapping is converted to entries to keep all of them
+		return append(entriesOfValues(c.Values), c.Entries...), nil
+	}
+	return c.Values, nil
+}
+
+// IsZero tells no sources, for omitempty of yaml
+func (c Copies) IsZero() bool {
+	return c.Len() == 0
+}
+
+// Len returns count of sources to destinations
+func (c Copies) Len() int {
+	return len(c.Values) + len(c.Entries)
+}
+
+// CopyEntry is one COPY of list form of Copies
+type CopyEntry struct {
+	// Src are sources, a path or a list of paths in yaml
+	Src Paths `yaml:"src"`
+	// Dst is destination
+	Dst string `yaml:"dst"`
+	// From is stage, image or named context copied from, like --from of COPY.
+	// when it is stage, relative sources are in workdir of the stage
+	From  string `yaml:"from,omitempty"`
+	Chown string `yaml:"chown,omitempty"`
+	Chmod string `yaml:"chmod,omitempty"`
+	// Comment is written as # lines before COPY of the entry
+	Comment string `yaml:"comment,omitempty"`
+}
+
+// key returns sources with flags, in form of keys of mapping form, like --from=builder --chown=nobody ./a ./b,
+// sources are in JSON array when any of them has whitespace, like ["my file.txt"]
+func (e CopyEntry) key() string {
+	if hasWhitespace(e.Src...) {
+		return strings.Join(append(e.flags(), jsonArrayOf(e.Src)), " ")
+	}
+	return strings.Join(append(e.flags(), e.Src...), " ")
+}
+
+// args returns flags, sources and destination of COPY of e,
+// sources and destination are in JSON array when any of them has whitespace, like ["my file.txt", "/app/"]
+func (e CopyEntry) args() string {
+	paths := append(append([]string{}, e.Src...), e.Dst)
+	if hasWhitespace(paths...) {
+		return strings.Join(append(e.flags(), jsonArrayOf(paths)), " ")
+	}
+	return strings.Join(append(append(e.flags(), e.Src...), quoteWord(e.Dst)), " ")
+}
+
+// flags returns flags of e, like --from=builder
+func (e CopyEntry) flags() []string {
+	words := make([]string, 0, 3)
+
+	for _, flag := range []struct {
+		name  string
+		value string
+	}{
+		{"--from=", e.From},
+		{"--chown=", e.Chown},
+		{"--chmod=", e.Chmod},
+	} {
+		if flag.value != "" {
+			words = append(words, flag.name+flag.value)
+		}
+	}
+
+	return words
+}
+
+func hasWhitespace(paths ...string) bool {
+	return stringSome(paths, func(p string, i int) bool {
+		return strings.ContainsAny(p, " \t")
+	})
+}
+
+// copyEntryOf returns entry of key of mapping form, like --from=builder ./a, or --from=builder ["my file.txt"]
+func copyEntryOf(key string, dst string) CopyEntry {
+	e := CopyEntry{Dst: dst}
+
+	words := strings.Fields(key)
+
+	for i, word := range words {
+		if rest := strings.Join(words[i:], " "); strings.HasPrefix(rest, "[") {
+			if err := json.Unmarshal([]byte(rest), &e.Src); err == nil {
+				break
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(word, "--from="):
+			e.From = strings.TrimPrefix(word, "--from=")
+		case strings.HasPrefix(word, "--chown="):
+			e.Chown = strings.TrimPrefix(word, "--chown=")
+		case strings.HasPrefix(word, "--chmod="):
+			e.Chmod = strings.TrimPrefix(word, "--chmod=")
+		default:
+			e.Src = append(e.Src, word)
+		}
+	}
+
+	return e
+}
+
+// entriesOfValues returns entries of mapping form in order of sources
+func entriesOfValues(values Values) []CopyEntry {
+	entries := make([]CopyEntry, 0, len(values))
+	for _, key := range sortedValueKeys(values) {
+		entries = append(entries, copyEntryOf(key, values[key]))
+	}
+	return entries
+}
+
+// Paths are paths, a path or a list of paths in yaml
+type Paths []string
+
+func (p *Paths) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	path := ""
+	if err := unmarshal(&path); err == nil {
+		*p = Paths{path}
+		return nil
+	}
+
+	paths := make([]string, 0)
+	if err := unmarshal(&paths); err != nil {
+		return err
+	}
+	*p = paths
+	return nil
+}
+
+func (p Paths) MarshalYAML() (interface{}, error) {
+	if len(p) == 1 {
+		return p[0], nil
+	}
+	return []string(p), nil
+}
+
+var typePaths = reflect.TypeOf(Paths{})
+
+// isDrivePath tells path with drive letter of windows, like C:\app,
+// which is not copy from stage of mapping form, like builder:/go/bin/app
+func isDrivePath(path string) bool {
+	if len(path) < 3 || path[1] != ':' || (path[2] != '\\' && path[2] != '/') {
+		return false
+	}
+	c := path[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// copyPair is source to destination of copy, in form of mapping form
+type copyPair struct {
+	// key is sources with flags, in form of keys of mapping form
+	key string
+	dst string
+	// path is yaml path of the source
+	path string
+	// comment of entry
+	comment string
+	// entry of list form, sources of which are paths as they are, without stage prefix like builder:
+	entry *CopyEntry
+}
+
+// pairs returns sources to destinations of c in order of writing, with yaml path of each under path
+func (c Copies) pairs(path string) []copyPair {
+	pairs := make([]copyPair, 0, c.Len())
+
+	for _, key := range sortedValueKeys(c.Values) {
+		pairs = append(pairs, copyPair{key: key, dst: c.Values[key], path: yamlPath(path, key)})
+	}
+
+	for i := range c.Entries {
+		e := &c.Entries[i]
+		pairs = append(pairs, copyPair{key: e.key(), dst: e.Dst, path: path + indexPath(i), entry: e, comment: e.Comment})
+	}
+
+	return pairs
+}
+
+// mapKeys returns a copy of c with keys of mapping form mapped by fn, and entries mapped by mapEntry,
+// keys mapped into same one are invalid, since one of them would be dropped.
+func (c Copies) mapKeys(path string, fn func(key string) (string, error), mapEntry func(e CopyEntry) (CopyEntry, error)) (Copies, error) {
+	mapped := Copies{}
+
+	if c.Values != nil {
+		mapped.Values = make(Values, len(c.Values))
+		sources := map[string]string{}
+
+		for _, key := range sortedValueKeys(c.Values) {
+			k, err := fn(key)
+			if err != nil {
+				return Copies{}, err
+			}
+			if source, ok := sources[k]; ok {
+				return Copies{}, fieldError(yamlPath(path, key), fmt.Errorf("%w, same as %s after mapped to %s", ErrInvalidKey, source, k))
+			}
+			sources[k] = key
+			mapped.Values[k] = c.Values[key]
+		}
+	}
+
+	if c.Entries != nil {
+		mapped.Entries = make([]CopyEntry, len(c.Entries))
+
+		for i, e := range c.Entries {
+			e.Src = append(Paths{}, e.Src...)
+			m, err := mapEntry(e)
+			if err != nil {
+				return Copies{}, err
+			}
+			mapped.Entries[i] = m
+		}
+	}
+
+	return mapped, nil
+}
+
+// resolveCopyEntry returns e with artifacts of sources replaced to paths in the stage of them,
+// or relative sources of stage of from joined with workdir of the stage,
+// and name of the stage, empty when e is not copied from stage.
+func resolveCopyEntry(e CopyEntry, stages map[string]*Stage) (CopyEntry, string, error) {
+	e.Src = append(Paths{}, e.Src...)
+	stageName := ""
+
+	for i, src := range e.Src {
+		if !strings.HasPrefix(src, artifactScheme) {
+			continue
+		}
+
+		if e.From != "" {
+			return CopyEntry{}, "", fmt.Errorf("%w, artifacts should not be copied with from", ErrInvalidArtifact)
+		}
+
+		name, p, err := resolveArtifact(src, stages)
+		if err != nil {
+			return CopyEntry{}, "", err
+		}
+
+		if stageName != "" && stageName != name {
+			return CopyEntry{}, "", fmt.Errorf("%w %s, artifacts of one copy should be of same stage %s", ErrInvalidArtifact, src, stageName)
+		}
+		stageName = name
+
+		e.Src[i] = p
+	}
+
+	if stageName != "" {
+		e.From = stageName
+		return e, stageName, nil
+	}
+
+	stage, ok := stages[e.From]
+	if !ok {
+		return e, "", nil
+	}
+
+	if stage.WorkingDir == "" && stringSome(e.Src, func(src string, i int) bool { return !strings.HasPrefix(src, "/") }) {
+		return CopyEntry{}, "", fmt.Errorf("%w of stage %s for copy file", ErrMissingWorkdir, e.From)
+	}
+
+	for i := range e.Src {
+		e.Src[i] = joinIfNeed(stage.WorkingDir, e.Src[i])
+	}
+
+	return e, e.From, nil
+}
+
+// validateCopyEntries validates entries of list form have sources and destination
+func validateCopyEntries(path string, c Copies) error {
+	errs := errorList{}
+
+	for i, e := range c.Entries {
+		p := path + "[" + strconv.Itoa(i) + "]"
+
+		if len(e.Src) == 0 {
+			errs.add(fieldError(p+".src", ErrMissingSource))
+		}
+		if e.Dst == "" {
+			errs.add(fieldError(p+".dst", ErrMissingDestination))
+		}
+	}
+
+	return errs.err()
+}
@@ -0,0 +1,158 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCopyEntries(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+from: busybox
+workdir: /app
+copy:
+  - {src: [./go.mod, ./go.sum], dst: ./}
+  - {src: ./config.yaml, dst: /etc/app/, chown: nobody, chmod: "644"}
+  - {src: ./config.yaml, dst: /etc/app/backup/}
+  - {src: /etc/passwd, dst: /etc/, from: alpine}
+  - {src: 'C:\app', dst: /app/}
+`), WithStrict())
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(d.Copy.Entries).To(HaveLen(5))
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d)).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM busybox
+
+WORKDIR /app
+
+COPY ./go.mod ./go.sum ./
+
+COPY --chown=nobody --chmod=644 ./config.yaml /etc/app/
+
+COPY ./config.yaml /etc/app/backup/
+
+COPY --from=alpine /etc/passwd /etc/
+
+COPY C:\app /app/
+
+`))
+
+	t.Run("yaml", func(t *testing.T) {
+		data, err := yaml.Marshal(d.Copy)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		copies := Copies{}
+		NewWithT(t).Expect(yaml.Unmarshal(data, &copies)).To(BeNil())
+		NewWithT(t).Expect(copies).To(Equal(d.Copy))
+	})
+
+	t.Run("mapping", func(t *testing.T) {
+		copies := Copies{}
+		NewWithT(t).Expect(yaml.Unmarshal([]byte(`{./b: /b, ./a: /a}`), &copies)).To(BeNil())
+		NewWithT(t).Expect(copies).To(Equal(CopyValues(Values{"./a": "/a", "./b": "/b"})))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		d.Copy = CopyEntries(CopyEntry{Dst: "/"}, CopyEntry{Src: Paths{"./a"}})
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), *d)
+		NewWithT(t).Expect(errors.Is(err, ErrMissingSource)).To(BeTrue())
+		NewWithT(t).Expect(errors.Is(err, ErrMissingDestination)).To(BeTrue())
+	})
+}
+
+func TestCopyFromStage(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang
+    workdir: /go/src
+from: busybox
+copy:
+  - {from: builder, src: [./app, /etc/ssl/certs/], dst: /usr/local/bin/}
+  - {from: alpine, src: ./etc/passwd, dst: /etc/}
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d)).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM golang AS builder
+
+WORKDIR /go/src
+
+FROM busybox
+
+COPY --from=builder /go/src/app /etc/ssl/certs/ /usr/local/bin/
+
+COPY --from=alpine ./etc/passwd /etc/
+
+`))
+
+	t.Run("missing workdir", func(t *testing.T) {
+		d.Stages["builder"].WorkingDir = ""
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), *d)
+		NewWithT(t).Expect(errors.Is(err, ErrMissingWorkdir)).To(BeTrue())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("copy[0].src"))
+	})
+
+	t.Run("whitespace", func(t *testing.T) {
+		d.Stages["builder"].WorkingDir = "/src"
+		d.Copy = CopyEntries(
+			CopyEntry{From: "builder", Src: Paths{"my file.txt"}, Dst: "/app/"},
+			CopyEntry{Src: Paths{"./a b", "./c"}, Dst: "/app/"},
+		)
+
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, *d)).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(HaveSuffix(`FROM busybox
+
+COPY --from=builder ["/src/my file.txt","/app/"]
+
+COPY ["./a b","./c","/app/"]
+
+`))
+	})
+
+	t.Run("drive path", func(t *testing.T) {
+		d := Dockerfile{Stage: Stage{From: "busybox", Copy: CopyValues(Values{`C:\app`: "/app/"})}}
+		NewWithT(t).Expect(WriteToDockerfile(bytes.NewBuffer(nil), d)).To(BeNil())
+	})
+}
+
+func TestCopiesMapKeys(t *testing.T) {
+	c := Copies{
+		Values:  Values{"--from=a.io/tools /bin/sh": "/bin/", "--from=b.io/tools /bin/sh": "/usr/bin/"},
+		Entries: []CopyEntry{{From: "a.io/tools", Src: Paths{"my file.txt"}, Dst: "/"}},
+	}
+
+	toMirror := func(s string) string {
+		return strings.NewReplacer("a.io", "mirror.io", "b.io", "mirror.io").Replace(s)
+	}
+
+	_, err := c.mapKeys("copy", func(key string) (string, error) {
+		return toMirror(key), nil
+	}, func(e CopyEntry) (CopyEntry, error) {
+		e.From = toMirror(e.From)
+		return e, nil
+	})
+	NewWithT(t).Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+	NewWithT(t).Expect(err.Error()).To(Equal(`copy["--from=b.io/tools /bin/sh"]: invalid key, same as --from=a.io/tools /bin/sh after mapped to --from=mirror.io/tools /bin/sh`))
+
+	delete(c.Values, "--from=b.io/tools /bin/sh")
+
+	mapped, err := c.mapKeys("copy", func(key string) (string, error) {
+		return toMirror(key), nil
+	}, func(e CopyEntry) (CopyEntry, error) {
+		e.From = toMirror(e.From)
+		return e, nil
+	})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(mapped.Entries).To(Equal([]CopyEntry{{From: "mirror.io/tools", Src: Paths{"my file.txt"}, Dst: "/"}}))
+	NewWithT(t).Expect(c.Entries[0].From).To(Equal("a.io/tools"))
+}
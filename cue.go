@@ -0,0 +1,123 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CUESchema returns CUE definitions of dockerfile.yml as package dockerfileyml,
+// specs could be vetted by
+//
+//	cue vet dockerfile.cue dockerfile.yml -d '#Dockerfile'
+func CUESchema() []byte {
+	g := &cueGenerator{definitions: map[string]string{}}
+	g.definition(reflect.TypeOf(Dockerfile{}))
+
+	names := make([]string, 0, len(g.definitions))
+	for name := range g.definitions {
+		if name != "Dockerfile" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	buf := bytes.NewBufferString("package dockerfileyml\n\n")
+
+	for _, name := range append([]string{"Dockerfile"}, names...) {
+		buf.WriteString("#" + name + ": " + g.definitions[name] + "\n\n")
+	}
+
+	buf.WriteString("#Scalar: string | number | bool\n")
+
+	return buf.Bytes()
+}
+
+type cueGenerator struct {
+	definitions map[string]string
+}
+
+func (g *cueGenerator) definition(t reflect.Type) string {
+	if _, ok := g.definitions[t.Name()]; !ok {
+		// placeholder for recursive types
+		g.definitions[t.Name()] = ""
+
+		fields := yamlFields(t)
+
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		buf := bytes.NewBufferString("{\n")
+
+		for _, name := range names {
+			buf.WriteString("\t" + cueLabel(name) + "?: " + g.typeOf(fields[name]) + "\n")
+		}
+
+		// x- prefixed keys are for holding anchors
+		buf.WriteString("\t[=~\"^x-\"]: _\n")
+		buf.WriteString("}")
+
+		g.definitions[t.Name()] = buf.String()
+	}
+
+	return "#" + t.Name()
+}
+
+func (g *cueGenerator) typeOf(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == typeForm {
+		return strconv.Quote(string(FormExec)) + " | " + strconv.Quote(string(FormShell))
+	}
+
+	switch t {
+	case typeCopies:
+		return g.typeOf(reflect.TypeOf(Values{})) + " | " + g.typeOf(reflect.TypeOf([]CopyEntry{}))
+	case typePaths:
+		return "#Scalar | " + g.typeOf(reflect.TypeOf([]string{}))
+	case typeKeyValues:
+		return g.typeOf(reflect.TypeOf(Values{}))
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		ref := g.definition(t)
+		if reflect.PtrTo(t).Implements(typeYAMLUnmarshaler) {
+			// custom types accept scalar short form
+			return "#Scalar | " + ref
+		}
+		return ref
+	case reflect.Map:
+		return "{[string]: " + g.typeOf(t.Elem()) + "}"
+	case reflect.Slice:
+		elem := g.typeOf(t.Elem())
+		if strings.Contains(elem, "|") {
+			elem = "(" + elem + ")"
+		}
+		return "[..." + elem + "]"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	}
+
+	return "#Scalar"
+}
+
+var reCUEIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func cueLabel(name string) string {
+	if reCUEIdentifier.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
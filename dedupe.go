@@ -0,0 +1,161 @@
+package dockerfileyml
+
+import (
+	"strings"
+)
+
+// WithDedupedStages collapses stages rendered to same instructions into the first of them,
+// and rewrites references to the others, like FROM, COPY --from and RUN --mount=from,
+// stages becoming same after rewriting are collapsed too, the main stage is always kept.
+func WithDedupedStages() WriteOption {
+	return func(o *writeOptions) {
+		o.dedupedStages = true
+	}
+}
+
+// duplicateStagesOf returns names of stages rendered to same instructions as earlier ones, with names of the earlier ones,
+// and stages with references to collapsed stages rewritten
+func duplicateStagesOf(stages [][]instruction) (map[string]string, [][]instruction) {
+	duplicates := map[string]string{}
+
+	for {
+		seen := map[string]string{}
+		found := false
+
+		// the main stage is the last one
+		for i := 0; i < len(stages)-1; i++ {
+			f := fromIndex(stages[i])
+			if f < 0 {
+				continue
+			}
+
+			name := stageNameOf(stages[i][f])
+			if name == "" || duplicates[name] != "" {
+				continue
+			}
+
+			content := stageContentOf(stages[i])
+			if first, ok := seen[content]; ok {
+				duplicates[name] = first
+				found = true
+				continue
+			}
+			seen[content] = name
+		}
+
+		if !found {
+			return duplicates, stages
+		}
+
+		stages = rewriteStageRefs(stages, duplicates)
+	}
+}
+
+// stageContentOf returns instructions of stage without name of stage and comments, for comparing
+func stageContentOf(list []instruction) string {
+	lines := make([]string, len(list))
+	for i := range list {
+		ins := list[i]
+		if ins.Key == "FROM" {
+			ins.Value = strings.SplitN(ins.Value, " AS ", 2)[0]
+		}
+		lines[i] = ins.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rewriteStageRefs rewrites references to stages by names, in FROM, COPY --from and RUN --mount=from
+func rewriteStageRefs(stages [][]instruction, names map[string]string) [][]instruction {
+	rename := func(name string) string {
+		if to, ok := names[name]; ok {
+			return to
+		}
+		return name
+	}
+
+	rewritten := make([][]instruction, len(stages))
+
+	for i := range stages {
+		list := make([]instruction, len(stages[i]))
+
+		for j := range stages[i] {
+			ins := stages[i][j]
+
+			if ins.Key == "FROM" {
+				parts := strings.SplitN(ins.Value, " AS ", 2)
+				parts[0] = rename(parts[0])
+				ins.Value = strings.Join(parts, " AS ")
+			}
+
+			flags := make([]string, len(ins.Flags))
+			for k, flag := range ins.Flags {
+				switch {
+				case strings.HasPrefix(flag, "--from="):
+					flag = "--from=" + rename(strings.TrimPrefix(flag, "--from="))
+				case strings.HasPrefix(flag, "--mount="):
+					options := strings.Split(strings.TrimPrefix(flag, "--mount="), ",")
+					for m, option := range options {
+						if strings.HasPrefix(option, "from=") {
+							options[m] = "from=" + rename(strings.TrimPrefix(option, "from="))
+						}
+					}
+					flag = "--mount=" + strings.Join(options, ",")
+				}
+				flags[k] = flag
+			}
+			ins.Flags = flags
+
+			list[j] = ins
+		}
+
+		rewritten[i] = list
+	}
+
+	return rewritten
+}
+
+// dedupeStages collapses duplicate stages, see WithDedupedStages
+func dedupeStages(stages [][]instruction) [][]instruction {
+	duplicates, stages := duplicateStagesOf(stages)
+	if len(duplicates) == 0 {
+		return stages
+	}
+
+	result := make([][]instruction, 0, len(stages)-len(duplicates))
+	for i := range stages {
+		if f := fromIndex(stages[i]); f >= 0 && duplicates[stageNameOf(stages[i][f])] != "" {
+			continue
+		}
+		result = append(result, stages[i])
+	}
+	return result
+}
+
+// checkDuplicateStage checks stages rendered to same instructions as earlier ones, which could be collapsed by WithDedupedStages
+func checkDuplicateStage(d *Dockerfile) (findings []Finding) {
+	stages, err := renderStages(*d)
+	if err != nil {
+		return nil
+	}
+
+	duplicates, _ := duplicateStagesOf(stages)
+
+	for i := range stages {
+		f := fromIndex(stages[i])
+		if f < 0 {
+			continue
+		}
+
+		name := stageNameOf(stages[i][f])
+		if first, ok := duplicates[name]; ok {
+			findings = append(findings, Finding{
+				Rule:     "duplicate-stage",
+				Severity: SeverityWarning,
+				Path:     "stages." + name,
+				Message:  "renders same instructions as stage " + first + ", which could be collapsed into it",
+			})
+		}
+	}
+
+	return
+}
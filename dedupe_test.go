@@ -0,0 +1,63 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDedupedStages(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  a:
+    from: golang
+    run: [make]
+  b:
+    from: golang
+    run: [make]
+  c:
+    from: alpine
+    copy: {a:/out: /out}
+  d:
+    from: alpine
+    copy: {b:/out: /out}
+from: d
+run:
+  - cmd: ls /mnt
+    mount: ["type=bind,from=b,target=/mnt"]
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d, WithDedupedStages())).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM golang AS a
+
+RUN make
+
+FROM alpine AS c
+
+COPY --from=a /out /out
+
+FROM c
+
+RUN --mount=type=bind,from=a,target=/mnt ls /mnt
+
+`))
+
+	NewWithT(t).Expect(checkDuplicateStage(d)).To(Equal([]Finding{
+		{
+			Rule:     "duplicate-stage",
+			Severity: SeverityWarning,
+			Path:     "stages.b",
+			Message:  "renders same instructions as stage a, which could be collapsed into it",
+		},
+		{
+			Rule:     "duplicate-stage",
+			Severity: SeverityWarning,
+			Path:     "stages.d",
+			Message:  "renders same instructions as stage c, which could be collapsed into it",
+		},
+	}))
+}
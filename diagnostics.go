@@ -0,0 +1,53 @@
+package dockerfileyml
+
+import (
+	"strings"
+)
+
+// WithReadDiagnostics reports non-fatal problems of reading to fn, as findings of warning,
+// like unknown fields, which are ignored when not in strict mode.
+func WithReadDiagnostics(fn func(f Finding)) ReadOption {
+	return func(o *readOptions) {
+		o.diagnostics = fn
+	}
+}
+
+// WithWriteDiagnostics reports non-fatal problems of writing to fn, as findings of warning,
+// like entrypoint in shell form or flags dropped for dialect,
+// which are written as WARNING comments in Dockerfile too.
+func WithWriteDiagnostics(fn func(f Finding)) WriteOption {
+	return func(o *writeOptions) {
+		o.diagnostics = fn
+	}
+}
+
+const warningPrefix = "WARNING: "
+
+// reportWarnings reports WARNING comments of instructions
+func reportWarnings(list []instruction, report func(f Finding)) {
+	for _, ins := range list {
+		for _, comment := range ins.Comments {
+			if !strings.HasPrefix(comment, warningPrefix) {
+				continue
+			}
+
+			rule := "dialect"
+			if ins.Key == "ENTRYPOINT" {
+				// dialect drops no flags of entrypoint
+				rule = "shell-form-entrypoint"
+			}
+
+			p := ""
+			if ins.Stage != "" {
+				p = yamlPath("stages", ins.Stage)
+			}
+
+			report(Finding{
+				Rule:     rule,
+				Severity: SeverityWarning,
+				Path:     fieldPath(p, strings.ToLower(ins.Key)),
+				Message:  strings.TrimPrefix(comment, warningPrefix),
+			})
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDiagnostics(t *testing.T) {
+	t.Run("read", func(t *testing.T) {
+		findings := make([]Finding, 0)
+
+		d, err := ReadFromYAML(strings.NewReader(`
+from: alpine
+cmd: [sh]
+stages:
+  builder:
+    from: golang
+    wokrdir: /go/src
+`), WithReadDiagnostics(func(f Finding) {
+			findings = append(findings, f)
+		}))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.From).To(Equal("alpine"))
+		NewWithT(t).Expect(findings).To(HaveLen(1))
+		NewWithT(t).Expect(findings[0].String()).To(Equal("7:5: warning[ignored-field] stages.builder.wokrdir: unknown field"))
+	})
+
+	t.Run("read in strict mode", func(t *testing.T) {
+		findings := make([]Finding, 0)
+
+		_, err := ReadFromYAML(strings.NewReader("from: alpine\nwokrdir: /src\n"), WithStrict(), WithReadDiagnostics(func(f Finding) {
+			findings = append(findings, f)
+		}))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(findings).To(HaveLen(0))
+	})
+
+	t.Run("write", func(t *testing.T) {
+		d := Dockerfile{
+			Stages: map[string]*Stage{
+				"builder": {
+					From: "golang",
+					Copy: CopyValues(Values{"--parents ./src": "/go/src"}),
+				},
+			},
+			Stage: Stage{
+				From:           "alpine",
+				Entrypoint:     []string{"app"},
+				EntrypointForm: FormShell,
+				StopSignal:     "SIGQUIT",
+			},
+		}
+
+		findings := make([]Finding, 0)
+
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, d, WithDialect(DialectPodman), WithWriteDiagnostics(func(f Finding) {
+			findings = append(findings, f)
+		}))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("# WARNING: "))
+		NewWithT(t).Expect(findings).To(HaveLen(2))
+		NewWithT(t).Expect(findings[0].String()).To(Equal("warning[dialect] stages.builder.copy: COPY --parents is not supported by podman, --parents is dropped"))
+		NewWithT(t).Expect(findings[1].Rule).To(Equal("shell-form-entrypoint"))
+		NewWithT(t).Expect(findings[1].Path).To(Equal("entrypoint"))
+	})
+}
@@ -0,0 +1,159 @@
+package dockerfileyml
+
+import (
+	"strings"
+)
+
+type WriteOption func(o *writeOptions)
+
+type writeOptions struct {
+	dialect   Dialect
+	validator func(dockerfile []byte) error
+	header    *Header
+	// comment for dependency update bots before FROM of pinned images
+	pinComments bool
+	// mappers of images, applied in order before rendering
+	imageMappers []func(image string) (string, error)
+	// lint before writing, with severities overriding lint of spec
+	lint       bool
+	severities map[string]Severity
+	// reports non-fatal problems
+	diagnostics func(f Finding)
+	// filled with source map when not nil
+	sourceMap *SourceMap
+	// fail when final stage runs as root
+	nonRootUser bool
+	// fail when images of from have no tag or latest tag
+	taggedImages bool
+	// fail when images are not pinned by digests, or pin them by digestResolver when not nil
+	requiredDigests bool
+	digestResolver  *Resolver
+	// rules of policies, fail on findings of error
+	policies []Rule
+	// scan base images for vulnerabilities
+	vulnScan *VulnRule
+	// check sources exist in build context dir when not empty
+	contextDir string
+	// expand glob patterns of sources into files of contextDir
+	expandGlobs bool
+	// fail when first workdir of stages is relative
+	absoluteWorkdir bool
+	// instructions of which $ in values are escaped
+	escapedDollars map[string]bool
+	// line ending of written Dockerfile
+	lineEnding LineEnding
+	// comment before instructions of each stage
+	stageSeparators bool
+	// adjacent instructions of same key without blank lines between
+	groupedInstructions bool
+	// scripts of RUN in lines
+	multilineRun bool
+	// join and prelude of scripts of RUN, for stages without them
+	runJoin    string
+	runPrelude string
+	// indent of continuation lines, or aligned with value after key of instruction
+	indent               string
+	alignedContinuations bool
+	// values of exec form are wrapped in lines when instruction is longer than wrapWidth
+	wrapWidth int
+	// pairs of ENV and LABEL are folded in lines when at least foldedValues
+	foldedValues int
+	// comments of source yaml before instructions of fields
+	sourceComments bool
+	// adjacent RUN of stages merged into one
+	mergedRuns bool
+	// dependencies installed before copy of build context
+	cacheOrder bool
+	// shared instructions of stages extracted into base stages
+	sharedBases bool
+	// stages of same instructions collapsed into one
+	dedupedStages bool
+}
+
+func newWriteOptions(opts []WriteOption) *writeOptions {
+	o := &writeOptions{dialect: DialectDocker, indent: defaultIndent}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Dialect of output, for builders other than BuildKit
+type Dialect string
+
+const (
+	DialectDocker Dialect = "docker"
+	// DialectPodman writes Containerfile for Podman/Buildah,
+	// flags only supported by BuildKit are dropped, with warnings when not equivalent.
+	DialectPodman Dialect = "podman"
+)
+
+// Filename returns conventional filename of dialect
+func (d Dialect) Filename() string {
+	if d == DialectPodman {
+		return "Containerfile"
+	}
+	return "Dockerfile"
+}
+
+// WithDialect sets dialect of output, DialectDocker by default
+func WithDialect(dialect Dialect) WriteOption {
+	return func(o *writeOptions) {
+		o.dialect = dialect
+	}
+}
+
+// BuildKit only flags of instructions for podman,
+// warning is empty when dropping the flag makes no difference of result.
+var podmanUnsupportedFlags = map[string]map[string]string{
+	"RUN": {
+		"--security": "RUN --security is not supported by podman",
+	},
+	"COPY": {
+		"--link":    "",
+		"--exclude": "COPY --exclude is not supported by podman",
+		"--parents": "COPY --parents is not supported by podman",
+	},
+	"ADD": {
+		"--link":         "",
+		"--checksum":     "ADD --checksum is not supported by podman, source is not verified",
+		"--keep-git-dir": "ADD --keep-git-dir is not supported by podman",
+	},
+}
+
+// applyDialect adjusts instructions for dialect
+func applyDialect(list []instruction, dialect Dialect) []instruction {
+	if dialect != DialectPodman {
+		return list
+	}
+
+	adjusted := make([]instruction, len(list))
+
+	for i := range list {
+		ins := list[i]
+
+		if unsupported, ok := podmanUnsupportedFlags[ins.Key]; ok {
+			flags := make([]string, 0, len(ins.Flags))
+
+			for _, flag := range ins.Flags {
+				name := strings.SplitN(flag, "=", 2)[0]
+
+				warning, ok := unsupported[name]
+				if !ok {
+					flags = append(flags, flag)
+					continue
+				}
+
+				if warning != "" {
+					ins.Comments = append(append([]string{}, ins.Comments...), warningPrefix+warning+", "+flag+" is dropped")
+				}
+			}
+
+			ins.Flags = flags
+		}
+
+		adjusted[i] = ins
+	}
+
+	return adjusted
+}
@@ -0,0 +1,33 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+)
+
+func TestDialect(t *testing.T) {
+	d := Dockerfile{}
+	d.From = "alpine"
+	d.Add = Values{"--checksum=sha256:24454f830cdb571e2c4ad15481119c43b3cafd48dd869a9b2945d1036d1dc68d https://example.com/app.tar.gz": "/"}
+	d.Copy = CopyValues(Values{"--link ./bin": "/usr/local/bin"})
+	d.Run = []Script{
+		{Command: "apk add curl", Mount: []string{"type=cache,target=/var/cache/apk"}},
+		{Command: "./test.sh", Security: "insecure"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := WriteToDockerfile(buf, d, WithDialect(DialectPodman))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(MatchSnapshot("podman.Containerfile"))
+
+	docker := bytes.NewBuffer(nil)
+	err = WriteToDockerfile(docker, d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(docker.String()).To(ContainSubstring("--security=insecure"))
+
+	NewWithT(t).Expect(DialectPodman.Filename()).To(Equal("Containerfile"))
+	NewWithT(t).Expect(DialectDocker.Filename()).To(Equal("Dockerfile"))
+}
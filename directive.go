@@ -0,0 +1,45 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// reDirective matches parser directive, like # syntax=docker/dockerfile:1
+var reDirective = regexp.MustCompile(`^#\s*([a-zA-Z][a-zA-Z0-9]*)\s*=\s*(.*?)\s*$`)
+
+// parseDirective returns key and value of parser directive of line, key is in lower case
+func parseDirective(line string) (string, string, bool) {
+	matched := reDirective.FindStringSubmatch(line)
+	if matched == nil {
+		return "", "", false
+	}
+	return strings.ToLower(matched[1]), matched[2], true
+}
+
+// validateDirectives checks directives could be written,
+// escape other than \ is invalid, since lines are continued by \ in written Dockerfile.
+func validateDirectives(directives Values) error {
+	for _, key := range sortedValueKeys(directives) {
+		if !reDirective.MatchString("# " + key + "=") {
+			return fieldError(yamlPath("directives", key), ErrInvalidKey)
+		}
+		if strings.ToLower(key) == "escape" && directives[key] != "\\" {
+			return fieldError(yamlPath("directives", key), fmt.Errorf("%w %s, only \\ is supported", ErrInvalidValue, directives[key]))
+		}
+	}
+	return nil
+}
+
+// writeDirectives writes directives in lines of # key=value, which are followed by a blank line
+func writeDirectives(w io.Writer, directives Values) error {
+	for _, key := range sortedValueKeys(directives) {
+		if _, err := io.WriteString(w, "# "+key+"="+directives[key]+"\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
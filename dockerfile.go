@@ -1,24 +1,78 @@
 package dockerfileyml
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 )
 
 type Dockerfile struct {
-	Image  string            `yaml:"image,omitempty"`
+	// Version of format, documents of older version are migrated when reading
+	Version int `yaml:"version,omitempty"`
+
+	// Name and Output identify Dockerfile in multi-document yaml,
+	// Output is the path to write Dockerfile to.
+	Name   string `yaml:"name,omitempty"`
+	Output string `yaml:"output,omitempty"`
+
+	// Include loads stages and snippets from other yaml files,
+	// relative paths are resolved from dir of the spec.
+	// Remote sources should be pinned, like
+	// git::https://github.com/org/repo.git//stages.yml?ref=<commit>,
+	// git::https://github.com/org/repo.git//stages.yml?ref=v1.2.3&checksum=sha256:<hex>
+	// or https://example.com/stages.yml?checksum=sha256:<hex>
+	Include []string `yaml:"include,omitempty"`
+
+	// Directives are parser directives written on top of Dockerfile, like syntax: docker/dockerfile:1,
+	// escape could only be \, since lines of written Dockerfile are continued by \.
+	Directives Values `yaml:"directives,omitempty"`
+
+	Image string `yaml:"image,omitempty"`
+	// Contexts are named build contexts, like docs: ../docs,
+	// which could be used by COPY --from=docs
+	Contexts Values `yaml:"contexts,omitempty"`
+	// Ignore are patterns of .dockerignore written to <Dockerfile>.dockerignore,
+	// which BuildKit uses instead of .dockerignore of context, for context filtering of each Dockerfile.
+	Ignore []string `yaml:"ignore,omitempty"`
+
 	Stages map[string]*Stage `yaml:"stages,omitempty"`
-	Stage  `yaml:",inline"`
+	// Target selects a stage of stages as the final stage,
+	// only stages it depends on are written, and the main stage is skipped.
+	Target string `yaml:"target,omitempty"`
+	// Snippets are reusable groups of steps, see Snippet
+	Snippets map[string]*Snippet `yaml:"snippets,omitempty"`
+	// Profiles are overlays to merge onto the spec by name, see ApplyProfiles
+	Profiles map[string]*Dockerfile `yaml:"profiles,omitempty"`
+	// Variants are patches to render into separated Dockerfile, see ApplyVariant
+	Variants map[string]*Dockerfile `yaml:"variants,omitempty"`
+	// Platforms to build for, like linux/amd64
+	Platforms []string `yaml:"platforms,omitempty"`
+	// PlatformOverrides are patches of each platform,
+	// written into one Dockerfile by TARGETARCH, or per platform by ExpandPlatforms.
+	PlatformOverrides map[string]*Dockerfile `yaml:"platform-overrides,omitempty"`
+	// Annotations are written as org.opencontainers.image.* labels of the final stage,
+	// like title, description, source, licenses and version.
+	Annotations Values `yaml:"annotations,omitempty"`
+	// Lint sets severity of lint rules by id, like apt-cleanup: error, or off to disable.
+	Lint  map[string]Severity `yaml:"lint,omitempty"`
+	Stage `yaml:",inline"`
+
+	// positions of fields in source yaml
+	positions Positions
+	// comments of fields in source yaml
+	comments Values
 }
 
-func Scripts(args ...string) []string {
-	return args
+func (d *Dockerfile) documentName() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.Output
 }
 
 func Args(args ...string) []string {
@@ -27,57 +81,471 @@ func Args(args ...string) []string {
 
 type Values = map[string]string
 
+// Form selects how ENTRYPOINT and CMD are written
+type Form string
+
+const (
+	// FormExec writes JSON array, the default
+	FormExec Form = "exec"
+	// FormShell writes plain command line, which runs under /bin/sh -c
+	FormShell Form = "shell"
+)
+
 type Stage struct {
-	From       string            `yaml:"from,omitempty" docker:"FROM" `
-	Label      map[string]string `yaml:"label,omitempty" docker:"LABEL,multi" `
-	WorkingDir string            `yaml:"workdir" docker:"WORKDIR" `
+	From string `yaml:"from,omitempty" docker:"FROM" `
+	// Extends inherits fields of another stage,
+	// maps are merged, lists are appended unless entrypoint and cmd, others are overridden.
+	Extends string `yaml:"extends,omitempty"`
+	// Needs declares stages depended on, which are not expressed by copy or from,
+	// like bind mounts in run scripts
+	Needs []string `yaml:"needs,omitempty"`
+	// Artifacts are files of the stage, which other stages copy by name,
+	// like artifact://builder/app of copy, see Artifact
+	Artifacts []Artifact `yaml:"artifacts,omitempty"`
+	// Comments are written as # lines before instructions of fields by yaml name,
+	// like workdir: sources are built here
+	Comments Values `yaml:"comments,omitempty"`
+
+	Label      KeyValues `yaml:"label,omitempty" docker:"LABEL,multi" `
+	WorkingDir string    `yaml:"workdir" docker:"WORKDIR" `
+
+	Arg  KeyValues `yaml:"arg,omitempty" docker:"ARG,multi"`
+	Env  KeyValues `yaml:"env,omitempty" docker:"ENV,multi,inline"`
+	Add  Values    `yaml:"add,omitempty" docker:"ADD,join"`
+	Copy Copies    `yaml:"copy,omitempty" docker:"COPY"`
+	Run  []Script  `yaml:"run,omitempty" docker:"RUN,script"`
+	// RunJoin joins adjacent scripts into one RUN, && or ;, && by default
+	RunJoin string `yaml:"run-join,omitempty"`
+	// RunPrelude is written before scripts of each RUN, like set -euxo pipefail
+	RunPrelude string `yaml:"run-prelude,omitempty"`
 
-	Arg  Values   `yaml:"arg,omitempty" docker:"ARG,multi"`
-	Env  Values   `yaml:"env,omitempty" docker:"ENV,multi,inline"`
-	Add  Values   `yaml:"add,omitempty" docker:"ADD,join"`
-	Copy Values   `yaml:"copy,omitempty" docker:"COPY"`
-	Run  []string `yaml:"run,omitempty" docker:"RUN,script"`
+	// Steps are written in declaration order after fields above,
+	// for builds which need to interleave COPY and RUN
+	Steps []Step `yaml:"steps,omitempty" docker:",steps"`
+
+	// User is switched after all RUN of stage,
+	// use steps to switch users in between.
+	User string `yaml:"user,omitempty" docker:"USER"`
 
 	Expose []string `yaml:"expose,omitempty" docker:"EXPOSE"`
 	Volume []string `yaml:"volume,omitempty" docker:"VOLUME,array"`
 
-	Entrypoint []string `yaml:"entrypoint,omitempty" docker:"ENTRYPOINT,array"`
-	Command    []string `yaml:"cmd,omitempty" docker:"CMD,array"`
+	StopSignal string `yaml:"stopsignal,omitempty" docker:"STOPSIGNAL"`
+	// Healthcheck is written as it is, like --interval=30s CMD curl -f http://localhost/, or NONE
+	Healthcheck string `yaml:"healthcheck,omitempty" docker:"HEALTHCHECK"`
+
+	Entrypoint     []string `yaml:"entrypoint,omitempty" docker:"ENTRYPOINT,array" merge:"replace"`
+	EntrypointForm Form     `yaml:"entrypoint-form,omitempty"`
+	Command        []string `yaml:"cmd,omitempty" docker:"CMD,array" merge:"replace"`
+	CommandForm    Form     `yaml:"cmd-form,omitempty"`
 
-	usedBy       map[string]bool
+	deps         map[string]bool
 	name         string
 	copyReplaces map[string]string
+	// entries of list form resolved by yaml path, like copy[0]
+	copyEntries map[string]CopyEntry
+}
+
+// Step is one instruction of Stage.Steps, exactly one field should be set.
+// WORKDIR, USER and ENV could be repeated in steps at any point.
+// A step could also use a snippet instead, see Snippet.
+type Step struct {
+	// Use expands steps of snippet with params of With
+	Use  string `yaml:"use,omitempty"`
+	With Values `yaml:"with,omitempty"`
+	// Comment is written as # lines before instruction of the step
+	Comment string `yaml:"comment,omitempty"`
+
+	WorkingDir string `yaml:"workdir,omitempty" docker:"WORKDIR"`
+	User       string `yaml:"user,omitempty" docker:"USER"`
+
+	Arg  KeyValues `yaml:"arg,omitempty" docker:"ARG,multi"`
+	Env  KeyValues `yaml:"env,omitempty" docker:"ENV,multi,inline"`
+	Add  Values    `yaml:"add,omitempty" docker:"ADD,join"`
+	Copy Copies    `yaml:"copy,omitempty" docker:"COPY"`
+	Run  []Script  `yaml:"run,omitempty" docker:"RUN,script"`
+
+	Expose []string `yaml:"expose,omitempty" docker:"EXPOSE"`
+	Volume []string `yaml:"volume,omitempty" docker:"VOLUME,array"`
+}
+
+func (step *Step) instructionCount() int {
+	rv := reflect.ValueOf(step).Elem()
+	tpe := rv.Type()
+	n := 0
+
+	for i := 0; i < tpe.NumField(); i++ {
+		if tpe.Field(i).Tag.Get("docker") == "" {
+			continue
+		}
+		if c, ok := rv.Field(i).Interface().(Copies); ok {
+			if c.Len() > 0 {
+				n++
+			}
+			continue
+		}
+		if v := rv.Field(i); v.Len() > 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// copySource is source of copy, with yaml path of it in stage
+type copySource struct {
+	from string
+	path string
+	// entry of list form, sources of which are without stage prefix like builder:
+	entry *CopyEntry
+}
+
+// copySources returns sources of copy, including ones of steps
+func (s *Stage) copySources() []copySource {
+	sources := make([]copySource, 0, s.Copy.Len())
+
+	for _, pair := range s.Copy.pairs("copy") {
+		sources = append(sources, copySource{from: pair.key, path: pair.path, entry: pair.entry})
+	}
+
+	for i := range s.Steps {
+		for _, pair := range s.Steps[i].Copy.pairs("steps[" + strconv.Itoa(i) + "].copy") {
+			sources = append(sources, copySource{from: pair.key, path: pair.path, entry: pair.entry})
+		}
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].from != sources[j].from {
+			return sources[i].from < sources[j].from
+		}
+		return sources[i].path < sources[j].path
+	})
+
+	return sources
+}
+
+func (s *Stage) formOf(dockerKey string) Form {
+	switch dockerKey {
+	case "ENTRYPOINT":
+		return s.EntrypointForm
+	case "CMD":
+		return s.CommandForm
+	}
+	return FormExec
+}
+
+func (s *Stage) shellFormWarnings() (warnings []string) {
+	if s.EntrypointForm != FormShell || len(s.Entrypoint) == 0 {
+		return
+	}
+	if s.StopSignal != "" {
+		warnings = append(warnings, "shell form ENTRYPOINT runs under /bin/sh -c, STOPSIGNAL "+s.StopSignal+" will not reach the process")
+	}
+	if len(s.Command) > 0 {
+		warnings = append(warnings, "shell form ENTRYPOINT ignores CMD and arguments of docker run")
+	}
+	return
+}
+
+// reStageName is pattern of stage names allowed by BuildKit
+var reStageName = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+
+// validateStageName validates name of stage could be written as FROM ... AS name
+func validateStageName(name string) error {
+	if !reStageName.MatchString(name) {
+		return fmt.Errorf("%w %q, should be lowercase letters, digits, -, _ and ., and start with a letter", ErrInvalidStageName, name)
+	}
+	if name == "scratch" {
+		return fmt.Errorf("%w %q, which is reserved for empty image", ErrInvalidStageName, name)
+	}
+	return nil
+}
+
+// validatePorts validates ports of expose, like 80, 53/udp or 8000-8010/tcp,
+// ports with args are not validated.
+func validatePorts(path string, ports []string) error {
+	errs := errorList{}
+
+	for i, port := range ports {
+		if strings.Contains(port, "$") {
+			continue
+		}
+		if _, err := normalizePort(port); err != nil {
+			errs.add(fieldError(path+"["+strconv.Itoa(i)+"]", err))
+		}
+	}
+
+	return errs.err()
+}
+
+// normalizePort returns port with protocol, like 80/tcp for 80
+func normalizePort(port string) (string, error) {
+	parts := strings.SplitN(port, "/", 2)
+
+	protocol := "tcp"
+	if len(parts) == 2 {
+		protocol = strings.ToLower(parts[1])
+		switch protocol {
+		case "tcp", "udp", "sctp":
+		default:
+			return "", fmt.Errorf("%w %s, protocol should be tcp, udp or sctp", ErrInvalidPort, port)
+		}
+	}
+
+	numbers := strings.SplitN(parts[0], "-", 2)
+	for _, number := range numbers {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("%w %s, should be number in range 1-65535, with protocol optionally, like 80 or 53/udp", ErrInvalidPort, port)
+		}
+	}
+
+	return parts[0] + "/" + protocol, nil
 }
 
+// uniquePorts returns ports without duplicates, like 80 and 80/tcp
+func uniquePorts(ports []string) []string {
+	seen := map[string]bool{}
+	unique := make([]string, 0, len(ports))
+
+	for _, port := range ports {
+		key := port
+		if normalized, err := normalizePort(port); err == nil {
+			key = normalized
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, port)
+	}
+
+	return unique
+}
+
+var reIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateKeys validates keys of env or arg are shell identifiers,
+// which could be referred as $KEY by instructions and scripts.
+func validateKeys(path string, values KeyValues) error {
+	errs := errorList{}
+
+	for _, key := range values.Keys() {
+		if !reIdentifier.MatchString(key) {
+			errs.add(fieldError(yamlPath(path, key), fmt.Errorf("%w %q, should be letters, digits and _, and not start with a digit", ErrInvalidKey, key)))
+		}
+	}
+
+	return errs.err()
+}
+
+// validateValues validates values of env, arg or label could be written as words of Dockerfile,
+// newlines end instructions, even in quotes, and keys are not duplicated, which could be by Go callers.
+func validateValues(path string, values KeyValues) error {
+	errs := errorList{}
+	seen := map[string]bool{}
+
+	for _, item := range values {
+		if seen[item.Key] {
+			errs.add(fieldError(yamlPath(path, item.Key), fmt.Errorf("%w %q, duplicated", ErrInvalidKey, item.Key)))
+		}
+		seen[item.Key] = true
+
+		if strings.ContainsAny(item.Value, "\r\n") {
+			errs.add(fieldError(yamlPath(path, item.Key), fmt.Errorf("%w of %s, newlines are not allowed", ErrInvalidValue, item.Key)))
+		}
+	}
+
+	return errs.err()
+}
+
+func validateRunJoin(join string) error {
+	switch join {
+	case "", RunJoinAnd, RunJoinSemicolon:
+		return nil
+	}
+	return fmt.Errorf("%w %s, should be %s or %s", ErrInvalidJoin, join, RunJoinAnd, RunJoinSemicolon)
+}
+
+func validateForm(f Form) error {
+	switch f {
+	case "", FormExec, FormShell:
+		return nil
+	}
+	return fmt.Errorf("%w %s, should be %s or %s", ErrInvalidForm, f, FormExec, FormShell)
+}
+
+// scanAndValidate resolves dependencies of stage, and returns all problems found
 func scanAndValidate(s *Stage, stages map[string]*Stage) error {
-	for from := range s.Copy {
+	errs := errorList{}
+
+	// resolved again from current fields
+	s.deps = nil
+	s.copyReplaces = nil
+	s.copyEntries = nil
+
+	path := ""
+	if s.name != "" {
+		path = yamlPath("stages", s.name)
+	}
+
+	field := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+
+	if s.name != "" {
+		errs.add(fieldError(path, validateStageName(s.name)))
+	}
+
+	errs.add(fieldError(field("entrypoint-form"), validateForm(s.EntrypointForm)))
+	errs.add(fieldError(field("cmd-form"), validateForm(s.CommandForm)))
+	errs.add(fieldError(field("run-join"), validateRunJoin(s.RunJoin)))
+
+	errs.add(validateKeys(field("env"), s.Env))
+	errs.add(validateKeys(field("arg"), s.Arg))
+	errs.add(validateValues(field("env"), s.Env))
+	errs.add(validateValues(field("arg"), s.Arg))
+	errs.add(validateValues(field("label"), s.Label))
+	errs.add(validatePorts(field("expose"), s.Expose))
+	errs.add(validateArtifacts(field("artifacts"), s.Artifacts))
+	errs.add(validateComments(field("comments"), s.Comments))
+	errs.add(validateCopyEntries(field("copy"), s.Copy))
+
+	for i := range s.Steps {
+		if n := s.Steps[i].instructionCount(); n != 1 {
+			errs.add(fieldError(field("steps["+strconv.Itoa(i)+"]"), fmt.Errorf("%w: must define exactly one instruction, but got %d", ErrInvalidStep, n)))
+		}
+
+		errs.add(validateKeys(field("steps["+strconv.Itoa(i)+"].env"), s.Steps[i].Env))
+		errs.add(validateKeys(field("steps["+strconv.Itoa(i)+"].arg"), s.Steps[i].Arg))
+		errs.add(validateValues(field("steps["+strconv.Itoa(i)+"].env"), s.Steps[i].Env))
+		errs.add(validateValues(field("steps["+strconv.Itoa(i)+"].arg"), s.Steps[i].Arg))
+		errs.add(validatePorts(field("steps["+strconv.Itoa(i)+"].expose"), s.Steps[i].Expose))
+		errs.add(validateCopyEntries(field("steps["+strconv.Itoa(i)+"].copy"), s.Steps[i].Copy))
+	}
+
+	for i, name := range s.Needs {
+		if stages[name] == nil {
+			errs.add(fieldError(field("needs["+strconv.Itoa(i)+"]"), fmt.Errorf("%w %s", ErrMissingStage, name)))
+			continue
+		}
+		s.dependOn(name)
+	}
+
+	for _, source := range s.copySources() {
+		from := source.from
+
+		// copy of list form, like {from: builder, src: ./app, dst: ./}
+		if source.entry != nil {
+			entry, stageName, err := resolveCopyEntry(*source.entry, stages)
+			if err != nil {
+				errs.add(fieldError(field(source.path+".src"), err))
+				continue
+			}
+
+			if stageName != "" {
+				s.dependOn(stageName)
+
+				if s.copyEntries == nil {
+					s.copyEntries = map[string]CopyEntry{}
+				}
+
+				s.copyEntries[source.path] = entry
+			}
+			continue
+		}
+
+		// copy of artifacts, like artifact://builder/app
+		if stageName, resolved, err := resolveArtifacts(from, stages); err != nil {
+			errs.add(fieldError(field(source.path), err))
+			continue
+		} else if stageName != "" {
+			s.dependOn(stageName)
+
+			if s.copyReplaces == nil {
+				s.copyReplaces = map[string]string{}
+			}
+
+			s.copyReplaces[from] = resolved
+			continue
+		}
+
+		// copy with flags, like --from=builder --chown=nobody /go/bin/app
+		if strings.HasPrefix(from, "--") {
+			for _, word := range strings.Fields(from) {
+				if strings.HasPrefix(word, "--from=") {
+					if name := strings.TrimPrefix(word, "--from="); stages[name] != nil {
+						s.dependOn(name)
+					}
+				}
+			}
+			continue
+		}
+
 		parts := strings.Split(from, ":")
 
 		if len(parts) == 2 {
 			stageName := parts[0]
 
 			if stage, ok := stages[stageName]; ok {
-				if stage.WorkingDir == "" {
-					return fmt.Errorf("stage %s must define workdir for copy file", stageName)
+				if stage.WorkingDir == "" && !strings.HasPrefix(parts[1], "/") {
+					errs.add(fieldError(field(source.path), fmt.Errorf("%w of stage %s for copy file", ErrMissingWorkdir, stageName)))
+					continue
 				}
 
-				if stage.usedBy == nil {
-					stage.usedBy = map[string]bool{}
-				}
-
-				stage.usedBy[s.name] = true
+				s.dependOn(stageName)
 
 				if s.copyReplaces == nil {
 					s.copyReplaces = map[string]string{}
 				}
 
 				s.copyReplaces[from] = "--from=" + stageName + " " + joinIfNeed(stage.WorkingDir, parts[1])
-			} else {
-				return fmt.Errorf("missing stage %s", stageName)
+			} else if !isDrivePath(from) {
+				errs.add(fieldError(field(source.path), fmt.Errorf("%w %s", ErrMissingStage, stageName)))
 			}
 		}
 	}
-	return nil
+
+	if words := strings.Fields(s.From); len(words) > 0 {
+		if image := words[len(words)-1]; stages[image] != nil {
+			s.dependOn(image)
+		}
+	}
+
+	for _, script := range s.scripts() {
+		for _, mount := range script.Mount {
+			for _, option := range strings.Split(mount, ",") {
+				if strings.HasPrefix(option, "from=") {
+					if name := strings.TrimPrefix(option, "from="); stages[name] != nil {
+						s.dependOn(name)
+					}
+				}
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+func (s *Stage) dependOn(name string) {
+	if s.deps == nil {
+		s.deps = map[string]bool{}
+	}
+	s.deps[name] = true
+}
+
+// scripts returns all scripts of run, including ones of steps
+func (s *Stage) scripts() []Script {
+	scripts := append([]Script{}, s.Run...)
+
+	for i := range s.Steps {
+		scripts = append(scripts, s.Steps[i].Run...)
+	}
+
+	return scripts
 }
 
 func joinIfNeed(src string, to string) string {
@@ -87,64 +555,405 @@ func joinIfNeed(src string, to string) string {
 	return filepath.Join(src, to)
 }
 
-func WriteToDockerfile(w io.Writer, d Dockerfile) error {
-	stages := make([]*Stage, 0)
+func WriteToDockerfile(w io.Writer, d Dockerfile, opts ...WriteOption) error {
+	o := newWriteOptions(opts)
+
+	if o.lint {
+		if err := lintErrors(d, o.severities); err != nil {
+			return err
+		}
+	}
+
+	if o.nonRootUser {
+		if err := nonRootUserError(&d); err != nil {
+			return locateError(err, d.positions)
+		}
+	}
+
+	if o.taggedImages {
+		if err := untaggedImageErrors(&d); err != nil {
+			return locateError(err, d.positions)
+		}
+	}
+
+	if o.absoluteWorkdir {
+		if err := relativeWorkdirErrors(&d); err != nil {
+			return locateError(err, d.positions)
+		}
+	}
+
+	if o.contextDir != "" {
+		if err := missingSourceErrors(&d, o.contextDir); err != nil {
+			return locateError(err, d.positions)
+		}
+	}
+
+	if o.expandGlobs {
+		expanded, err := expandGlobs(&d, o.contextDir)
+		if err != nil {
+			return err
+		}
+		d = *expanded
+	}
+
+	if o.contextDir != "" && o.diagnostics != nil {
+		// directories are known with context dir, which are not found by lint
+		for _, f := range Lint(&d, RuleFunc(func(d *Dockerfile) []Finding {
+			return copyDirectoryFindings(d, o.contextDir)
+		})) {
+			o.diagnostics(f)
+		}
+	}
+
+	if len(o.policies) > 0 {
+		if err := policyErrors(&d, o.policies); err != nil {
+			return locateError(err, d.positions)
+		}
+	}
+
+	if o.vulnScan != nil {
+		if err := vulnErrors(&d, o.vulnScan, o.diagnostics); err != nil {
+			return locateError(err, d.positions)
+		}
+	}
+
+	for _, fn := range o.imageMappers {
+		mapped, err := mapImages(&d, fn)
+		if err != nil {
+			return err
+		}
+		d = *mapped
+	}
 
+	if o.cacheOrder {
+		reordered, err := mapStages(&d, func(s *Stage, stages []string) error {
+			reorderForCache(s, d.Stages)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		d = *reordered
+	}
+
+	if o.runJoin != "" || o.runPrelude != "" {
+		styled, err := mapStages(&d, func(s *Stage, stages []string) error {
+			if s.RunJoin == "" {
+				s.RunJoin = o.runJoin
+			}
+			if s.RunPrelude == "" {
+				s.RunPrelude = o.runPrelude
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		d = *styled
+	}
+
+	if o.requiredDigests {
+		pinned, err := requireDigests(&d, o.digestResolver)
+		if err != nil {
+			return locateError(err, d.positions)
+		}
+		d = *pinned
+	}
+
+	stages, err := renderStages(d)
+	if err != nil {
+		return locateError(err, d.positions)
+	}
+
+	if o.dedupedStages {
+		stages = dedupeStages(stages)
+	}
+
+	if o.sharedBases {
+		stages = extractSharedBases(stages)
+	}
+
+	list := joinStages(stages)
+
+	if o.sourceComments {
+		list = addSourceComments(list, d.comments, d.positions)
+	}
+
+	if o.mergedRuns {
+		list = mergeRuns(list)
+	}
+
+	if o.multilineRun {
+		list = foldRuns(list)
+	}
+
+	if o.foldedValues > 0 {
+		list = foldValues(list, o.foldedValues)
+	}
+
+	if len(o.escapedDollars) > 0 {
+		list = escapeDollars(list, o.escapedDollars)
+	}
+
+	list = applyDialect(list, o.dialect)
+
+	if o.diagnostics != nil {
+		reportWarnings(list, o.diagnostics)
+	}
+
+	if o.pinComments {
+		list = addPinComments(list)
+	}
+
+	if o.groupedInstructions {
+		list = groupInstructions(list)
+	}
+
+	if o.stageSeparators {
+		list = addStageSeparators(list)
+	}
+
+	list = formatContinuations(list, o)
+
+	if o.validator != nil {
+		if err := validateInstructions(list, o.validator); err != nil {
+			return err
+		}
+	}
+
+	if o.sourceMap != nil {
+		offset := 0
+		if len(d.Directives) > 0 {
+			offset = len(d.Directives) + 1
+		}
+		if o.header != nil {
+			offset += len(o.header.comments()) + 1
+		}
+		*o.sourceMap = sourceMapOf(list, offset, d.positions)
+	}
+
+	lw := newLineEndingWriter(w, o.lineEnding)
+
+	// parser directives are only recognized before any comment
+	if len(d.Directives) > 0 {
+		if err := writeDirectives(lw, d.Directives); err != nil {
+			return err
+		}
+	}
+
+	if o.header != nil {
+		if err := writeHeader(lw, o.header); err != nil {
+			return err
+		}
+	}
+
+	if err := writeInstructions(lw, list); err != nil {
+		return err
+	}
+
+	return lw.end()
+}
+
+// renderDockerfile validates Dockerfile and renders all instructions of it
+func renderDockerfile(d Dockerfile) ([]instruction, error) {
+	stages, err := renderStages(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return joinStages(stages), nil
+}
+
+// joinStages joins instructions of stages, with args used by FROM hoisted before the first stage
+func joinStages(stages [][]instruction) []instruction {
+	list := make([]instruction, 0)
+
+	for i := range stages {
+		list = append(list, stages[i]...)
+	}
+
+	return hoistFromArgs(list)
+}
+
+// renderStages validates Dockerfile and renders instructions of each stage in order,
+// the final stage is the last one.
+// stages are resolved on a clone, stages of d are not changed.
+func renderStages(d Dockerfile) ([][]instruction, error) {
+	d = *d.Clone()
+
+	if err := resolvePlatforms(&d); err != nil {
+		return nil, err
+	}
+
+	if err := resolveExtends(&d); err != nil {
+		return nil, err
+	}
+
+	if err := expandSnippets(&d); err != nil {
+		return nil, err
+	}
+
+	errs := errorList{}
+
+	errs.add(validateImages(&d))
+	errs.add(validateDirectives(d.Directives))
+
+	names := make([]string, 0, len(d.Stages))
 	for name := range d.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stages := make([]*Stage, 0)
+
+	for _, name := range names {
 		s := d.Stages[name]
 		s.name = name
 
-		if err := scanAndValidate(s, d.Stages); err != nil {
-			return err
-		}
+		errs.add(scanAndValidate(s, d.Stages))
 
 		stages = append(stages, s)
 	}
 
-	if err := scanAndValidate(&d.Stage, d.Stages); err != nil {
-		return err
+	errs.add(scanAndValidate(&d.Stage, d.Stages))
+
+	final := &d.Stage
+
+	if d.Target != "" {
+		if target, ok := d.Stages[d.Target]; ok {
+			final = target
+			stages = reachableStages(target, d.Stages)
+		} else {
+			errs.add(fieldError("target", fmt.Errorf("%w %s", ErrMissingStage, d.Target)))
+		}
 	}
 
-	sort.Slice(stages, func(i, j int) bool {
-		return len(stages[i].usedBy) > len(stages[j].usedBy) || stages[i].name < stages[j].name
-	})
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	final, err := applyAnnotations(final, d.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	stages, err = sortStages(stages)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([][]instruction, 0, len(stages)+1)
 
 	for i := range stages {
-		if err := writeState(w, stages[i]); err != nil {
-			return err
+		list = append(list, renderStage(stages[i]))
+	}
+
+	list = append(list, renderStage(final))
+
+	return list, nil
+}
+
+// hoistFromArgs moves args used by FROM to the top,
+// args before FROM of later stages belong to the previous stage.
+func hoistFromArgs(list []instruction) []instruction {
+	args := make([]instruction, 0)
+	rest := make([]instruction, 0, len(list))
+
+	for i := range list {
+		ins := list[i]
+
+		if ins.Attached && ins.Key == "ARG" {
+			j := i
+			for j < len(list) && list[j].Attached {
+				j++
+			}
+
+			if j < len(list) && list[j].Key == "FROM" {
+				if !instructionIncludes(args, ins) {
+					args = append(args, ins)
+				}
+				continue
+			}
 		}
+
+		rest = append(rest, ins)
 	}
 
-	if err := writeState(w, &d.Stage); err != nil {
-		return err
+	return append(args, rest...)
+}
+
+func instructionIncludes(list []instruction, ins instruction) bool {
+	for i := range list {
+		if list[i].String() == ins.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func writeInstructions(w io.Writer, list []instruction) error {
+	for i := range list {
+		ins := list[i]
+
+		for _, comment := range ins.Comments {
+			if comment != "" {
+				comment = " " + comment
+			}
+			if _, err := io.WriteString(w, "#"+comment+"\n"); err != nil {
+				return err
+			}
+		}
+
+		line := ins.String() + "\n"
+		if !ins.Attached {
+			line += "\n"
+		}
+
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func writeState(w io.Writer, stage *Stage) error {
+func renderStage(stage *Stage) []instruction {
 	if stage == nil {
 		return nil
 	}
 
-	write := func(dockerKey string, values ...string) {
+	list := make([]instruction, 0)
+
+	stagePath := ""
+	if stage.name != "" {
+		stagePath = yamlPath("stages", stage.name)
+	}
+
+	write := func(path string, dockerKey string, values ...string) *instruction {
 		if len(values) == 0 {
-			return
+			return nil
 		}
 
 		for _, v := range values {
 			if args := containsGlobalArgs(v); len(args) > 0 {
 				for _, arg := range args {
-					_, _ = io.WriteString(w, "ARG "+arg+"\n")
+					list = append(list, instruction{Key: "ARG", Value: arg, Attached: true})
+				}
+			}
+		}
+
+		// args of stage used by FROM are written before it too, to have values in FROM
+		if dockerKey == "FROM" {
+			for _, item := range stage.Arg {
+				if refersAny(values[0], map[string]bool{item.Key: true}) {
+					list = append(list, instruction{Key: "ARG", Value: quoteWord(item.Key) + "=" + quoteWord(item.Value), Attached: true})
 				}
 			}
 		}
 
-		_, _ = io.WriteString(w, dockerKey)
+		parts := []string{dockerKey}
 
 		for i := range values {
-			_, _ = io.WriteString(w, " ")
-
 			v := values[i]
 
 			switch dockerKey {
@@ -158,17 +967,97 @@ func writeState(w io.Writer, stage *Stage) error {
 						v = replaced
 					}
 				}
-			case "ENV":
-				v = mayQuote(v)
+			case "RUN", "CMD", "ENTRYPOINT", "HEALTHCHECK":
+				// bodies of heredocs are written as they are
+				if !strings.HasPrefix(v, "[") && len(heredocsOf(v)) == 0 {
+					v = shellLines(v)
+				}
+			case "WORKDIR":
+				v = normalizeWorkdir(v)
+			case "EXPOSE":
+				v = strings.Join(uniquePorts(strings.Fields(v)), " ")
 			}
 
-			_, _ = io.WriteString(w, v)
+			parts = append(parts, v)
+		}
+
+		ins := parseInstruction(strings.Join(parts, " "), 0)
+		ins.Path = fieldPath(stagePath, path)
+
+		if dockerKey == "ENTRYPOINT" {
+			for _, warning := range stage.shellFormWarnings() {
+				ins.Comments = append(ins.Comments, warningPrefix+warning)
+			}
 		}
 
-		_, _ = io.WriteString(w, "\n\n")
+		list = append(list, ins)
+		return &list[len(list)-1]
+	}
+
+	walkInstructions(reflect.Indirect(reflect.ValueOf(stage)), "", stage, write)
+
+	for i := range list {
+		list[i].Stage = stage.name
 	}
 
-	rv := reflect.Indirect(reflect.ValueOf(stage))
+	return list
+}
+
+// writeKeyValues writes key values of env, arg or label in order, as one instruction for each key,
+// or as inline ones of keys, which are split before values referring keys before in same instruction,
+// since they get values before the instruction.
+func writeKeyValues(path string, dockerKey string, keyValues KeyValues, inline bool, write writeInstruction) {
+	if !inline {
+		for _, item := range keyValues {
+			write(yamlPath(path, item.Key), dockerKey, quoteWord(item.Key)+"="+quoteWord(item.Value))
+		}
+		return
+	}
+
+	groupPath := path
+	pairs := make([]string, 0)
+	written := map[string]bool{}
+
+	flush := func() {
+		if len(pairs) > 0 {
+			if ins := write(groupPath, dockerKey, pairs...); ins != nil {
+				ins.Pairs = pairs
+			}
+		}
+		pairs = make([]string, 0)
+		written = map[string]bool{}
+	}
+
+	for _, item := range keyValues {
+		if refersAny(item.Value, written) {
+			flush()
+			groupPath = yamlPath(path, item.Key)
+		}
+		pairs = append(pairs, quoteWord(item.Key)+"="+quoteWord(item.Value))
+		written[item.Key] = true
+	}
+
+	flush()
+}
+
+var reVariable = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)`)
+
+// refersAny tells s refers any of keys as variables, like $KEY or ${KEY:-default}, escaped \$KEY is not
+func refersAny(s string, keys map[string]bool) bool {
+	for _, m := range reVariable.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] > 0 && s[m[0]-1] == '\\' {
+			continue
+		}
+		if keys[s[m[2]:m[3]]] {
+			return true
+		}
+	}
+	return false
+}
+
+// walkInstructions calls write for each docker tagged field of struct rv in field order,
+// with yaml path of field under path.
+func walkInstructions(rv reflect.Value, path string, stage *Stage, write writeInstruction) {
 	tpe := rv.Type()
 
 	for i := 0; i < tpe.NumField(); i++ {
@@ -177,17 +1066,73 @@ func writeState(w io.Writer, stage *Stage) error {
 		dockerKeys := strings.Split(dockerTag, ",")
 		dockerKey := dockerKeys[0]
 		dockerFlags := dockerKeys[1:]
+		name := fieldPath(path, yamlFieldName(field))
+
+		if stringIncludes(dockerFlags, "steps") {
+			value := rv.Field(i)
+
+			for j := 0; j < value.Len(); j++ {
+				step := value.Index(j)
+				walkInstructions(step, name+"["+strconv.Itoa(j)+"]", stage, withComment(write, step.FieldByName("Comment").String()))
+			}
+
+			continue
+		}
 
 		if len(dockerKey) > 0 {
-			value := rv.FieldByName(field.Name)
+			value := rv.Field(i)
+			write := write
+
+			if rv.Type() == typeStage {
+				write = withComment(write, stage.Comments[yamlFieldName(field)])
+			}
+
+			if copies, ok := value.Interface().(Copies); ok {
+				for _, pair := range copies.pairs(name) {
+					write := withComment(write, pair.comment)
+
+					if pair.entry != nil {
+						e := *pair.entry
+						if resolved, ok := stage.copyEntries[pair.path]; ok {
+							e = resolved
+						}
+						write(pair.path, dockerKey, e.args())
+						continue
+					}
+
+					write(pair.path, dockerKey, pair.key, quoteWord(pair.dst))
+				}
+				continue
+			}
+
+			if keyValues, ok := value.Interface().(KeyValues); ok {
+				writeKeyValues(name, dockerKey, keyValues, stringIncludes(dockerFlags, "inline"), write)
+				continue
+			}
 
 			switch field.Type.Kind() {
 			case reflect.String:
 				if len(value.String()) > 0 {
-					write(dockerKey, value.String())
+					write(name, dockerKey, value.String())
 				}
 			case reflect.Slice:
-				jsonArray := stringIncludes(dockerFlags, "array")
+				if stringIncludes(dockerFlags, "script") {
+					j := 0
+					for _, group := range scriptGroups(value.Interface().([]Script)) {
+						// path of the first script of group
+						write := withComment(write, strings.Join(group.comments, "\n"))
+						if ins := write(name+"["+strconv.Itoa(j)+"]", dockerKey, group.values(stage.RunJoin, stage.RunPrelude)...); ins != nil {
+							ins.Commands = group.commands
+							ins.Join = stage.RunJoin
+							ins.Prelude = stage.RunPrelude
+							ins.NoJoin = group.noJoin
+						}
+						j += len(group.commands)
+					}
+					continue
+				}
+
+				jsonArray := stringIncludes(dockerFlags, "array") && stage.formOf(dockerKey) != FormShell
 				slice := make([]string, 0)
 
 				for i := 0; i < value.Len(); i++ {
@@ -196,32 +1141,21 @@ func writeState(w io.Writer, stage *Stage) error {
 
 				if len(slice) > 0 {
 					if jsonArray {
-						jsonString, err := json.Marshal(slice)
-						if err != nil {
-							panic(err)
-						}
 						write(
+							name,
 							dockerKey,
-							string(jsonString),
+							jsonArrayOf(slice),
 						)
 					} else {
-						if stringIncludes(dockerFlags, "script") {
-							write(
-								dockerKey,
-								strings.Join(slice, " && "),
-							)
-						} else {
-							write(
-								dockerKey,
-								strings.Join(slice, ""),
-							)
-						}
+						write(
+							name,
+							dockerKey,
+							strings.Join(slice, " "),
+						)
 					}
 				}
 
 			case reflect.Map:
-				multi := stringIncludes(dockerFlags, "multi")
-				inline := stringIncludes(dockerFlags, "inline")
 				join := stringIncludes(dockerFlags, "join")
 
 				if join {
@@ -241,6 +1175,7 @@ func writeState(w io.Writer, stage *Stage) error {
 						sort.Strings(src)
 
 						write(
+							yamlPath(name, src[0]),
 							dockerKey,
 							append(src, dest)...,
 						)
@@ -257,40 +1192,21 @@ func writeState(w io.Writer, stage *Stage) error {
 
 					sort.Strings(keys)
 
-					if multi {
-						if inline {
-							keyValues := make([]string, 0)
-
-							for _, key := range keys {
-								keyValues = append(keyValues, key+"="+mayQuote(values[key]))
-							}
-
-							if len(keyValues) > 0 {
-								write(dockerKey, keyValues...)
-							}
-						} else {
-							for _, key := range keys {
-								write(dockerKey, key+"="+mayQuote(values[key]))
-							}
-						}
-					} else {
-						for _, key := range keys {
-							write(dockerKey, key, mayQuote(values[key]))
-						}
+					for _, key := range keys {
+						write(yamlPath(name, key), dockerKey, key, quoteWord(values[key]))
 					}
 				}
 			}
 		}
 	}
-
-	return nil
 }
 
-func mayQuote(s string) string {
-	if s == "" || strings.Contains(s, " ") {
-		return strconv.Quote(s)
+// yamlFieldName returns name of field in yaml
+func yamlFieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; name != "" {
+		return name
 	}
-	return s
+	return strings.ToLower(field.Name)
 }
 
 func stringIncludes(list []string, target string) bool {
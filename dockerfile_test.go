@@ -2,10 +2,12 @@ package dockerfileyml
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	. "github.com/go-courier/snapshotmacther"
 	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
 )
 
 func TestDockerfile(t *testing.T) {
@@ -16,13 +18,13 @@ func TestDockerfile(t *testing.T) {
 		d.From = "busybox:latest"
 		d.WorkingDir = "/todo"
 
-		d.Env = Values{
+		d.Env = KeyValuesOf(Values{
 			key: "hello",
-		}
+		})
 
-		d.Copy = Values{
+		d.Copy = CopyValues(Values{
 			"x": "./",
-		}
+		})
 
 		d.Entrypoint = Args("sh")
 		d.Command = Args("-c", "echo", EnvVar(key))
@@ -38,10 +40,10 @@ func TestDockerfile(t *testing.T) {
 
 		d.Stages = map[string]*Stage{
 			"builder": {
-				Arg: Values{
+				Arg: KeyValuesOf(Values{
 					"COMMIT_SHA":   "",
 					"PROJECT_NAME": "",
-				},
+				}),
 				From:       "--platform=${BUILDPLATFORM:-linux/amd64} busybox",
 				WorkingDir: "/go/src",
 				Run:        Scripts("echo ${TARGETPLATFORM} > a.txt", "touch b.txt"),
@@ -57,14 +59,231 @@ func TestDockerfile(t *testing.T) {
 
 		d.From = "busybox"
 		d.WorkingDir = "/todo"
-		d.Copy = Values{
+		d.Copy = CopyValues(Values{
 			"builder:./a.txt":        "./",
 			"builder2:/go/src/b.txt": "./",
-		}
+		})
 
 		buf := bytes.NewBuffer(nil)
 		err := WriteToDockerfile(buf, d)
 		NewWithT(t).Expect(err).To(BeNil())
 		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("multistage.Dockerfile"))
 	})
+
+	t.Run("shell form entrypoint", func(t *testing.T) {
+		d := Dockerfile{}
+		d.From = "busybox"
+		d.WorkingDir = "/todo"
+		d.StopSignal = "SIGTERM"
+		d.Entrypoint = Args("./app", "--port", EnvVar("PORT"))
+		d.EntrypointForm = FormShell
+		d.Command = Args("serve")
+
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("shell-form.Dockerfile"))
+	})
+
+	t.Run("invalid form", func(t *testing.T) {
+		d := Dockerfile{}
+		d.From = "busybox"
+		d.CommandForm = "json"
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("steps", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Stages = map[string]*Stage{
+			"builder": {
+				From:       "golang:1.15",
+				WorkingDir: "/go/src",
+				Steps: []Step{
+					{Copy: CopyValues(Values{"go.mod": "./"})},
+					{Run: Scripts("go mod download")},
+					{Copy: CopyValues(Values{".": "./"})},
+					{Run: Scripts("go build -o app")},
+				},
+			},
+		}
+
+		d.From = "busybox"
+		d.Steps = []Step{
+			{WorkingDir: "/todo"},
+			{Copy: CopyValues(Values{"builder:./app": "./"})},
+		}
+
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("steps.Dockerfile"))
+	})
+
+	t.Run("step with multi instructions", func(t *testing.T) {
+		d := Dockerfile{}
+		d.From = "busybox"
+		d.Steps = []Step{
+			{WorkingDir: "/todo", Run: Scripts("touch a.txt")},
+		}
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("structured run", func(t *testing.T) {
+		d := Dockerfile{}
+
+		err := yaml.Unmarshal([]byte(`
+from: golang:1.15
+workdir: /go/src
+run:
+  - go env
+  - cmd: go mod download
+    mount: ["type=cache,target=/go/pkg/mod"]
+  - cmd: go build -o app
+    mount: ["type=cache,target=/go/pkg/mod"]
+  - cmd: go test ./...
+    network: none
+  - cmd: echo done
+    no-join: true
+  - ls
+`), &d)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("structured-run.Dockerfile"))
+	})
+
+	t.Run("repeated instructions", func(t *testing.T) {
+		d := Dockerfile{}
+		d.From = "node:14"
+		d.WorkingDir = "/app"
+		d.User = "node"
+		d.Steps = []Step{
+			{User: "root"},
+			{Run: Scripts("apt-get update", "apt-get install -y curl")},
+			{User: "node"},
+			{Env: KeyValuesOf(Values{"NODE_ENV": "production"})},
+			{WorkingDir: "/app/web"},
+			{Copy: CopyValues(Values{"package.json": "./"})},
+			{Run: Scripts("npm install")},
+			{Env: KeyValuesOf(Values{"PATH": "/app/web/node_modules/.bin:${PATH}"})},
+		}
+
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("repeated.Dockerfile"))
+	})
+
+	t.Run("extends", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Stages = map[string]*Stage{
+			"base": {
+				From:       "golang:1.15",
+				WorkingDir: "/go/src",
+				Env:        KeyValuesOf(Values{"CGO_ENABLED": "0", "GOOS": "linux"}),
+				Run:        Scripts("go mod download"),
+				Command:    Args("sh"),
+			},
+			"builder": {
+				Extends: "base",
+				Env:     KeyValuesOf(Values{"GOOS": "darwin"}),
+				Run:     Scripts("go build -o app"),
+			},
+			"tester": {
+				Extends: "builder",
+				Run:     Scripts("go test ./..."),
+				Command: Args("go", "test"),
+			},
+		}
+		d.Extends = "base"
+		d.From = "alpine"
+
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("extends.Dockerfile"))
+		NewWithT(t).Expect(d.Stages["base"].Env.Values()).To(Equal(Values{"CGO_ENABLED": "0", "GOOS": "linux"}))
+
+		d.Stages["base"].Extends = "tester"
+		err = WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("snippets", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Snippets = map[string]*Snippet{
+			"install-node": {
+				Params: Values{"version": "14"},
+				Steps: []Step{
+					{Env: KeyValuesOf(Values{"NODE_VERSION": "{{ version }}"})},
+					{Run: Scripts("curl -fsSL https://deb.nodesource.com/setup_{{version}}.x | bash -", "apt-get install -y nodejs")},
+				},
+			},
+			"install": {
+				Params: Values{"pkg": ""},
+				Steps: []Step{
+					{Run: Scripts("apt-get install -y {{ pkg }}")},
+				},
+			},
+		}
+		d.From = "debian"
+		d.Steps = []Step{
+			{Use: "install-node", With: Values{"version": "15"}},
+			{Use: "install", With: Values{"pkg": "git"}},
+			{Use: "install-node"},
+		}
+
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("snippets.Dockerfile"))
+		NewWithT(t).Expect(d.Snippets["install-node"].Steps[0].Env.Values()).To(Equal(Values{"NODE_VERSION": "{{ version }}"}))
+
+		for _, step := range []Step{
+			{Use: "install"},
+			{Use: "install", With: Values{"pkg": "git", "version": "1"}},
+			{Use: "missing"},
+			{Use: "install", Run: Scripts("ls")},
+		} {
+			d.Steps = []Step{step}
+			err = WriteToDockerfile(bytes.NewBuffer(nil), d)
+			NewWithT(t).Expect(err).NotTo(BeNil())
+		}
+
+		d.Snippets["install"].Steps = []Step{{Use: "install-node", With: Values{"version": "{{ pkg }}"}}, {Use: "install"}}
+		d.Steps = []Step{{Use: "install", With: Values{"pkg": "16"}}}
+		err = WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).To(MatchError("circular use of snippets install -> install"))
+	})
+
+	t.Run("write failed", func(t *testing.T) {
+		d := Dockerfile{}
+		d.From = "busybox"
+		d.WorkingDir = "/todo"
+		d.Command = Args("sh")
+
+		err := WriteToDockerfile(&failingWriter{n: 2}, d)
+		NewWithT(t).Expect(err).To(Equal(errWriteFailed))
+	})
+}
+
+var errWriteFailed = errors.New("write failed")
+
+// failingWriter fails after n writes
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n <= 0 {
+		return 0, errWriteFailed
+	}
+	w.n--
+	return len(p), nil
 }
@@ -0,0 +1,153 @@
+package dockerfileyml
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ContextSources returns paths in build context used by copy and add, and bind mounts of run,
+// cleaned and sorted, . when the whole context is used.
+//
+// Sources of stages, images and named contexts, urls, heredocs and sources with args are skipped,
+// patterns for sources with args should be added to .dockerignore as extra patterns.
+func ContextSources(d Dockerfile) []string {
+	sources := map[string]bool{}
+
+	eachContextSource(&d, func(p string, src string) {
+		sources[src] = true
+	})
+
+	list := make([]string, 0, len(sources))
+	for src := range sources {
+		list = append(list, src)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// eachContextSource calls fn for each source in build context, cleaned, with yaml path of field of it,
+// see ContextSources for sources skipped.
+func eachContextSource(d *Dockerfile, fn func(p string, src string)) {
+	visit := func(p string, src string) {
+		if src == "" || strings.Contains(src, "$") || strings.HasPrefix(src, "<<") || strings.Contains(src, "://") || strings.HasPrefix(src, "git@") {
+			return
+		}
+		fn(p, path.Clean(strings.TrimPrefix(src, "/")))
+	}
+
+	visitCopy := func(p string, c Copies) {
+		for _, pair := range c.pairs(p) {
+			for _, src := range copySourcesOf(pair.key, d.Stages) {
+				visit(pair.path, src)
+			}
+		}
+	}
+
+	visitRun := func(p string, scripts []Script) {
+		for i, script := range scripts {
+			for j, mount := range script.Mount {
+				if src, ok := bindSourceOf(mount); ok {
+					visit(p+indexPath(i)+".mount"+indexPath(j), src)
+				}
+			}
+		}
+	}
+
+	eachStage(d, func(p string, s *Stage) {
+		visitCopy(fieldPath(p, "copy"), s.Copy)
+		visitCopy(fieldPath(p, "add"), CopyValues(s.Add))
+		visitRun(fieldPath(p, "run"), s.Run)
+
+		for i, step := range s.Steps {
+			stepPath := fieldPath(p, "steps") + indexPath(i)
+			visitCopy(stepPath+".copy", step.Copy)
+			visitCopy(stepPath+".add", CopyValues(step.Add))
+			visitRun(stepPath+".run", step.Run)
+		}
+	})
+}
+
+// copySourcesOf returns sources in build context of key of copy,
+// like ./a for --chown=app ./a, none for builder:/out, --from=builder /out or artifact://builder/app
+func copySourcesOf(from string, stages map[string]*Stage) []string {
+	words := strings.Fields(from)
+
+	for len(words) > 0 && strings.HasPrefix(words[0], "--") {
+		if strings.HasPrefix(words[0], "--from=") {
+			return nil
+		}
+		words = words[1:]
+	}
+
+	rest := strings.Join(words, " ")
+
+	if strings.HasPrefix(rest, "[") {
+		values := make([]string, 0)
+		if err := json.Unmarshal([]byte(rest), &values); err == nil {
+			return values
+		}
+	}
+
+	if parts := strings.Split(rest, ":"); len(parts) == 2 && stages[parts[0]] != nil {
+		return nil
+	}
+
+	if stringSome(words, func(word string, i int) bool { return strings.HasPrefix(word, artifactScheme) }) {
+		return nil
+	}
+
+	return words
+}
+
+// bindSourceOf returns source in build context of mount of run, when it is bind mount of context
+func bindSourceOf(mount string) (string, bool) {
+	options := map[string]string{}
+	for _, option := range strings.Split(mount, ",") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) == 2 {
+			options[kv[0]] = kv[1]
+		}
+	}
+
+	if t, ok := options["type"]; ok && t != "bind" {
+		return "", false
+	}
+	if _, ok := options["from"]; ok {
+		return "", false
+	}
+	if src, ok := options["source"]; ok {
+		return src, true
+	}
+	if src, ok := options["src"]; ok {
+		return src, true
+	}
+	return ".", true
+}
+
+// WriteDockerignore writes .dockerignore which ignores everything but sources, see ContextSources,
+// extra patterns are written after, like **/*_test.go to ignore files of sources again.
+func WriteDockerignore(w io.Writer, sources []string, extra ...string) error {
+	lines := []string{"# Code generated by dockerfileyml. DO NOT EDIT."}
+
+	if !stringIncludes(sources, ".") {
+		lines = append(lines, "*")
+		for _, src := range sources {
+			lines = append(lines, "!"+src)
+		}
+	}
+
+	lines = append(lines, extra...)
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// IgnoreFilename returns filename of ignore file of Dockerfile, like Dockerfile.dockerignore,
+// which BuildKit uses instead of .dockerignore of context.
+func IgnoreFilename(dockerfile string) string {
+	return dockerfile + ".dockerignore"
+}
@@ -0,0 +1,62 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestContextSources(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang:1.21
+    workdir: /src
+    copy:
+      ./go.mod: ./
+      "--chown=app ./cmd/": ./cmd/
+    run:
+      - cmd: go build ./cmd/app
+        mount:
+          - type=bind,source=internal,target=internal
+          - type=cache,target=/root/.cache
+          - type=bind,from=tools,source=/bin,target=/tools
+from: alpine:3.18
+add:
+  https://example.com/ca.pem: /etc/ca.pem
+copy:
+  builder:/go/bin/app: /usr/bin/app
+  --from=busybox /bin/sh: /bin/sh
+  '["config/app.yml", "/config/log.yml"]': /etc/app/
+  ./$CONFIG: /etc/config
+steps:
+  - copy:
+      ./go.mod: /go.mod
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	sources := ContextSources(*d)
+	NewWithT(t).Expect(sources).To(Equal([]string{"cmd", "config/app.yml", "config/log.yml", "go.mod", "internal"}))
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteDockerignore(buf, sources, "**/*_test.go")).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`# Code generated by dockerfileyml. DO NOT EDIT.
+*
+!cmd
+!config/app.yml
+!config/log.yml
+!go.mod
+!internal
+**/*_test.go
+`))
+
+	t.Run("whole context", func(t *testing.T) {
+		d := Dockerfile{Stage: Stage{From: "alpine", Copy: CopyValues(Values{".": "/src"})}}
+
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteDockerignore(buf, ContextSources(d), ".git")).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("# Code generated by dockerfileyml. DO NOT EDIT.\n.git\n"))
+	})
+}
@@ -0,0 +1,174 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// EarthlyVersion is version of Earthfile written by WriteToEarthfile
+const EarthlyVersion = "0.7"
+
+// WriteToEarthfile writes Earthfile of d, each stage becomes a target of same name,
+// and the main stage becomes target image, which saves image of d when set.
+//
+// COPY --from=<stage> is written as COPY +<stage>/<path>, with SAVE ARTIFACT in target of stage,
+// instructions not supported by Earthly are converted with warnings.
+func WriteToEarthfile(w io.Writer, d Dockerfile) error {
+	stages, err := renderStages(d)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(stages))
+
+	for i := range stages {
+		names[i] = "image"
+		if from, ok := findInstruction(stages[i], "FROM"); ok {
+			if parts := strings.SplitN(from.Value, " AS ", 2); len(parts) == 2 {
+				names[i] = parts[1]
+			}
+		}
+		if names[i] == "image" && i != len(stages)-1 {
+			return fmt.Errorf("stage image conflicts with target of main stage")
+		}
+	}
+
+	// paths of artifacts to save of each target
+	artifacts := map[string][]string{}
+
+	for i := range stages {
+		for _, ins := range stages[i] {
+			if from, sources, _, ok := copyFromStage(ins, names); ok {
+				for _, src := range sources {
+					if !stringIncludes(artifacts[from], src) {
+						artifacts[from] = append(artifacts[from], src)
+					}
+				}
+			}
+		}
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("VERSION " + EarthlyVersion + "\n")
+
+	for i := range stages {
+		name := names[i]
+
+		b.WriteString("\n" + name + ":\n")
+
+		for _, ins := range stages[i] {
+			ins, warnings := earthlyInstruction(ins, names)
+
+			for _, comment := range append(ins.Comments, warnings...) {
+				b.WriteString("    # " + comment + "\n")
+			}
+			b.WriteString("    " + ins.String() + "\n")
+		}
+
+		for _, artifact := range artifacts[name] {
+			b.WriteString("    SAVE ARTIFACT " + artifact + " " + artifact + "\n")
+		}
+
+		if name == "image" && d.Image != "" {
+			b.WriteString("    SAVE IMAGE " + d.Image + "\n")
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func findInstruction(list []instruction, key string) (instruction, bool) {
+	for i := range list {
+		if list[i].Key == key {
+			return list[i], true
+		}
+	}
+	return instruction{}, false
+}
+
+// copyFromStage returns stage, absolute sources and dest of COPY --from=<stage>
+func copyFromStage(ins instruction, stages []string) (from string, sources []string, dest string, ok bool) {
+	if ins.Key != "COPY" {
+		return "", nil, "", false
+	}
+
+	for _, flag := range ins.Flags {
+		if strings.HasPrefix(flag, "--from=") {
+			from = strings.TrimPrefix(flag, "--from=")
+		}
+	}
+
+	if !stringIncludes(stages, from) {
+		return "", nil, "", false
+	}
+
+	args, isArray := ins.jsonArray()
+	if !isArray {
+		args = strings.Fields(ins.Value)
+	}
+
+	if len(args) < 2 {
+		return "", nil, "", false
+	}
+
+	for _, src := range args[0 : len(args)-1] {
+		// paths of COPY --from are relative to root of stage
+		sources = append(sources, path.Join("/", src))
+	}
+
+	return from, sources, args[len(args)-1], true
+}
+
+// earthlyInstruction converts instruction for Earthly, with warnings of differences
+func earthlyInstruction(ins instruction, stages []string) (instruction, []string) {
+	warnings := make([]string, 0)
+
+	switch ins.Key {
+	case "FROM":
+		words := strings.Fields(strings.SplitN(ins.Value, " AS ", 2)[0])
+		if len(words) == 1 && stringIncludes(stages, words[0]) {
+			words[0] = "+" + words[0]
+		}
+		ins.Value = strings.Join(words, " ")
+	case "COPY":
+		if from, sources, dest, ok := copyFromStage(ins, stages); ok {
+			flags := make([]string, 0, len(ins.Flags))
+			for _, flag := range ins.Flags {
+				if !strings.HasPrefix(flag, "--from=") {
+					flags = append(flags, flag)
+				}
+			}
+
+			for i := range sources {
+				sources[i] = "+" + from + sources[i]
+			}
+
+			ins.Flags = flags
+			ins.Value = strings.Join(append(sources, dest), " ")
+			break
+		}
+
+		for _, flag := range ins.Flags {
+			if strings.HasPrefix(flag, "--from=") {
+				warnings = append(warnings, "WARNING: COPY "+flag+" of image is not supported by Earthly, use a target of FROM the image instead")
+			}
+		}
+	case "ADD":
+		ins.Key = "COPY"
+		warnings = append(warnings, "WARNING: ADD is not supported by Earthly, written as COPY, so urls are not fetched and archives are not extracted")
+	case "RUN":
+		flags := make([]string, len(ins.Flags))
+		for i, flag := range ins.Flags {
+			if flag == "--security=insecure" {
+				flag = "--privileged"
+			}
+			flags[i] = flag
+		}
+		ins.Flags = flags
+	}
+
+	return ins, warnings
+}
@@ -0,0 +1,30 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+)
+
+func TestWriteToEarthfile(t *testing.T) {
+	d, err := ParseFile("testdata/multistage.yml")
+	NewWithT(t).Expect(err).To(BeNil())
+
+	d.Image = "ghcr.io/org/app"
+	d.Add = Values{"https://example.com/config.tar.gz": "/etc/app/"}
+	d.Stages["tester"] = &Stage{
+		From: "builder",
+		Run:  []Script{{Command: "make test", Security: "insecure"}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = WriteToEarthfile(buf, *d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(MatchSnapshot("multistage.Earthfile"))
+
+	d.Stages["image"] = &Stage{From: "alpine"}
+	err = WriteToEarthfile(bytes.NewBuffer(nil), *d)
+	NewWithT(t).Expect(err).NotTo(BeNil())
+}
@@ -0,0 +1,130 @@
+package dockerfileyml
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// errors of validation, could be checked by errors.Is
+var (
+	ErrMissingStage       = errors.New("missing stage")
+	ErrMissingWorkdir     = errors.New("missing workdir")
+	ErrInvalidForm        = errors.New("invalid form")
+	ErrInvalidStep        = errors.New("invalid step")
+	ErrInvalidImage       = errors.New("invalid image reference")
+	ErrRootUser           = errors.New("root user")
+	ErrUntaggedImage      = errors.New("untagged image")
+	ErrMissingDigest      = errors.New("missing digest")
+	ErrPolicyDenied       = errors.New("denied by policy")
+	ErrVulnerableImage    = errors.New("vulnerable image")
+	ErrMissingSource      = errors.New("missing source")
+	ErrRelativeWorkdir    = errors.New("relative workdir")
+	ErrInvalidKey         = errors.New("invalid key")
+	ErrInvalidStageName   = errors.New("invalid stage name")
+	ErrInvalidPort        = errors.New("invalid port")
+	ErrInvalidValue       = errors.New("invalid value")
+	ErrInvalidJoin        = errors.New("invalid join")
+	ErrMissingDestination = errors.New("missing destination")
+	ErrMissingArtifact    = errors.New("missing artifact")
+	ErrInvalidArtifact    = errors.New("invalid artifact")
+)
+
+// FieldError is error of field of spec, with yaml path to map it back to source document
+type FieldError struct {
+	// Path is yaml path of field, like stages.final.copy["builder2:./b.txt"]
+	Path string
+	Err  error
+	// Position of field in source yaml, when read from yaml
+	Position Position
+}
+
+func (e *FieldError) Error() string {
+	if e.Position.IsValid() {
+		return e.Position.String() + ": " + e.Path + ": " + e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError returns FieldError of path for err, nil when err is nil
+func fieldError(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FieldError{Path: path, Err: err}
+}
+
+// ValidationError lists all problems found in spec,
+// so they could be fixed in one pass.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	b := strings.Builder{}
+	b.WriteString(strconv.Itoa(len(e.Errors)) + " problems of spec:")
+	for _, err := range e.Errors {
+		b.WriteString("\n  - " + err.Error())
+	}
+	return b.String()
+}
+
+// Is tells any of errors is target, for errors.Is
+func (e *ValidationError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of errors matching target, for errors.As
+func (e *ValidationError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorList collects errors, duplicated messages are dropped
+type errorList []error
+
+func (list *errorList) add(err error) {
+	if err == nil {
+		return
+	}
+
+	// errors of nested validation are flattened
+	if v, ok := err.(*ValidationError); ok {
+		for _, e := range v.Errors {
+			list.add(e)
+		}
+		return
+	}
+
+	for _, e := range *list {
+		if e.Error() == err.Error() {
+			return
+		}
+	}
+
+	*list = append(*list, err)
+}
+
+func (list errorList) err() error {
+	if len(list) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: list}
+}
@@ -0,0 +1,144 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestValidationError(t *testing.T) {
+	d := Dockerfile{
+		Target: "missing",
+		Stages: map[string]*Stage{
+			"builder": {
+				From:  "Golang",
+				Needs: []string{"tools"},
+			},
+		},
+		Stage: Stage{
+			From: "busybox",
+			Copy: CopyValues(Values{
+				"builder:./a.txt":  "./",
+				"builder2:./b.txt": "./",
+			}),
+			EntrypointForm: "bash",
+		},
+	}
+
+	err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+	NewWithT(t).Expect(err).NotTo(BeNil())
+
+	v := &ValidationError{}
+	NewWithT(t).Expect(errors.As(err, &v)).To(BeTrue())
+	NewWithT(t).Expect(err.Error()).To(Equal(`6 problems of spec:
+  - stages.builder.from: invalid image reference Golang: repository name must be lowercase
+  - stages.builder.needs[0]: missing stage tools
+  - entrypoint-form: invalid form bash, should be exec or shell
+  - copy["builder2:./b.txt"]: missing stage builder2
+  - copy["builder:./a.txt"]: missing workdir of stage builder for copy file
+  - target: missing stage missing`))
+
+	NewWithT(t).Expect(errors.Is(err, ErrMissingStage)).To(BeTrue())
+	NewWithT(t).Expect(errors.Is(err, ErrInvalidImage)).To(BeTrue())
+	NewWithT(t).Expect(errors.Is(err, ErrInvalidStep)).To(BeFalse())
+
+	NewWithT(t).Expect(errors.Is(fmt.Errorf("generate: %w", err), ErrMissingWorkdir)).To(BeTrue())
+
+	fe := &FieldError{}
+	NewWithT(t).Expect(errors.As(err, &fe)).To(BeTrue())
+	NewWithT(t).Expect(fe.Path).To(Equal("stages.builder.from"))
+
+	NewWithT(t).Expect(errors.As(v.Errors[3], &fe)).To(BeTrue())
+	NewWithT(t).Expect(fe.Path).To(Equal(`copy["builder2:./b.txt"]`))
+	NewWithT(t).Expect(errors.Is(fe, ErrMissingStage)).To(BeTrue())
+}
+
+func TestFieldError(t *testing.T) {
+	d := Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {
+				From:  "golang",
+				Steps: []Step{{}},
+			},
+			"final": {
+				From:    "busybox",
+				Extends: "base",
+			},
+		},
+	}
+
+	err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+	NewWithT(t).Expect(err).NotTo(BeNil())
+	NewWithT(t).Expect(err.Error()).To(Equal("stages.final.extends: missing stage base to extend"))
+	NewWithT(t).Expect(errors.Is(err, ErrMissingStage)).To(BeTrue())
+
+	d.Stages["final"].Extends = ""
+
+	err = WriteToDockerfile(bytes.NewBuffer(nil), d)
+	NewWithT(t).Expect(err).NotTo(BeNil())
+	NewWithT(t).Expect(err.Error()).To(Equal("stages.builder.steps[0]: invalid step: must define exactly one instruction, but got 0"))
+	NewWithT(t).Expect(errors.Is(err, ErrInvalidStep)).To(BeTrue())
+}
+
+func TestInvalidKeys(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From:  "busybox",
+			Env:   KeyValuesOf(Values{"APP_ENV": "prod", "1ST": "a", "MY VAR": "b"}),
+			Arg:   KeyValuesOf(Values{"VERSION": "1.0", "A=B": "c"}),
+			Steps: []Step{{Env: KeyValuesOf(Values{"app.name": "x"})}},
+		},
+	}
+
+	err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+	NewWithT(t).Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+	NewWithT(t).Expect(err.Error()).To(Equal(`4 problems of spec:
+  - env.1ST: invalid key "1ST", should be letters, digits and _, and not start with a digit
+  - env["MY VAR"]: invalid key "MY VAR", should be letters, digits and _, and not start with a digit
+  - arg["A=B"]: invalid key "A=B", should be letters, digits and _, and not start with a digit
+  - steps[0].env["app.name"]: invalid key "app.name", should be letters, digits and _, and not start with a digit`))
+}
+
+func TestInvalidStageNames(t *testing.T) {
+	d := Dockerfile{
+		Stages: map[string]*Stage{
+			"build-1.0_x": {From: "golang"},
+			"1bad name":   {From: "golang"},
+			"Builder":     {From: "golang"},
+			"scratch":     {From: "busybox"},
+		},
+		Stage: Stage{From: "busybox"},
+	}
+
+	err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+	NewWithT(t).Expect(errors.Is(err, ErrInvalidStageName)).To(BeTrue())
+	NewWithT(t).Expect(err.Error()).To(Equal(`3 problems of spec:
+  - stages["1bad name"]: invalid stage name "1bad name", should be lowercase letters, digits, -, _ and ., and start with a letter
+  - stages.Builder: invalid stage name "Builder", should be lowercase letters, digits, -, _ and ., and start with a letter
+  - stages.scratch: invalid stage name "scratch", which is reserved for empty image`))
+}
+
+func TestExpose(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From:   "busybox",
+			Expose: []string{"80", "0", "53/udp", "8080/http", "70000", "8000-8010", "$PORT"},
+		},
+	}
+
+	err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+	NewWithT(t).Expect(errors.Is(err, ErrInvalidPort)).To(BeTrue())
+	NewWithT(t).Expect(err.Error()).To(Equal(`3 problems of spec:
+  - expose[1]: invalid port 0, should be number in range 1-65535, with protocol optionally, like 80 or 53/udp
+  - expose[3]: invalid port 8080/http, protocol should be tcp, udp or sctp
+  - expose[4]: invalid port 70000, should be number in range 1-65535, with protocol optionally, like 80 or 53/udp`))
+
+	d.Expose = []string{"80", "53/udp", "80/tcp", "53/UDP", "53", "$PORT"}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d)).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal("FROM busybox\n\nEXPOSE 80 53/udp 53 $PORT\n\n"))
+}
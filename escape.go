@@ -0,0 +1,55 @@
+package dockerfileyml
+
+import (
+	"strings"
+)
+
+// instructions of which values are escaped by WithEscapedDollars by default
+var defaultEscapedInstructions = []string{"ENV", "LABEL", "CMD", "ENTRYPOINT"}
+
+// WithEscapedDollars escapes $ in values of instructions as \$,
+// so literal dollar signs are not expanded as args or variables by docker or shell,
+// instructions are like env or cmd, ENV, LABEL, CMD and ENTRYPOINT when none given.
+//
+// Values in exec form are kept, since they are not expanded.
+func WithEscapedDollars(instructions ...string) WriteOption {
+	return func(o *writeOptions) {
+		if len(instructions) == 0 {
+			instructions = defaultEscapedInstructions
+		}
+
+		if o.escapedDollars == nil {
+			o.escapedDollars = map[string]bool{}
+		}
+		for _, key := range instructions {
+			o.escapedDollars[strings.ToUpper(key)] = true
+		}
+	}
+}
+
+func escapeDollars(list []instruction, keys map[string]bool) []instruction {
+	for i := range list {
+		if !keys[list[i].Key] {
+			continue
+		}
+		if _, ok := list[i].jsonArray(); ok {
+			continue
+		}
+		list[i].Value = escapeDollar(list[i].Value)
+	}
+	return list
+}
+
+// escapeDollar escapes $ not escaped yet
+func escapeDollar(s string) string {
+	b := strings.Builder{}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '$' && (i == 0 || s[i-1] != '\\') {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
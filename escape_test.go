@@ -0,0 +1,46 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWithEscapedDollars(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From:           "busybox",
+			Env:            KeyValuesOf(Values{"PRICE": "$5", "ESCAPED": `\$HOME`}),
+			Label:          KeyValuesOf(Values{"cost": "$$$"}),
+			User:           "$USER",
+			Entrypoint:     []string{"echo", "$PATH"},
+			Command:        []string{"echo $HOME"},
+			CommandForm:    FormShell,
+			EntrypointForm: FormExec,
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d, WithEscapedDollars())).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM busybox
+
+LABEL cost=\$\$\$
+
+ENV ESCAPED=\$HOME PRICE=\$5
+
+USER $USER
+
+ENTRYPOINT ["echo","$PATH"]
+
+CMD echo \$HOME
+
+`))
+
+	t.Run("per instruction", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, d, WithEscapedDollars("cmd"))).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("ENV ESCAPED=\\$HOME PRICE=$5\n"))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("CMD echo \\$HOME\n"))
+	})
+}
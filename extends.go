@@ -0,0 +1,69 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// resolveExtends replaces stages with extends by new ones merged onto stages they extend
+func resolveExtends(d *Dockerfile) error {
+	resolved := map[string]*Stage{}
+
+	var resolve func(s *Stage, chain []string) (*Stage, error)
+
+	resolve = func(s *Stage, chain []string) (*Stage, error) {
+		if s.Extends == "" {
+			return s, nil
+		}
+
+		if stringIncludes(chain, s.Extends) {
+			return nil, fmt.Errorf("circular extends of stages %s", strings.Join(append(chain, s.Extends), " -> "))
+		}
+
+		base, ok := d.Stages[s.Extends]
+		if !ok {
+			path := "extends"
+			if len(chain) > 0 {
+				path = yamlPath("stages", chain[len(chain)-1]) + ".extends"
+			}
+			return nil, fieldError(path, fmt.Errorf("%w %s to extend", ErrMissingStage, s.Extends))
+		}
+
+		if r, ok := resolved[s.Extends]; ok {
+			base = r
+		} else {
+			r, err := resolve(base, append(chain, s.Extends))
+			if err != nil {
+				return nil, err
+			}
+			resolved[s.Extends] = r
+			base = r
+		}
+
+		merged := mergeValue(reflect.ValueOf(*base), reflect.ValueOf(*s)).Interface().(Stage)
+		merged.Extends = ""
+
+		return &merged, nil
+	}
+
+	stages := make(map[string]*Stage, len(d.Stages))
+
+	for name, s := range d.Stages {
+		r, err := resolve(s, []string{name})
+		if err != nil {
+			return err
+		}
+		stages[name] = r
+	}
+
+	main, err := resolve(&d.Stage, nil)
+	if err != nil {
+		return err
+	}
+
+	d.Stages = stages
+	d.Stage = *main
+
+	return nil
+}
@@ -0,0 +1,292 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Format returns spec in canonical form without changing semantics, for consistent diffs:
+//
+// fields are in order of declaration, stages, snippets, profiles and variants are sorted by name;
+// mappings and sequences are in block style indented by 2 spaces;
+// strings are plain unless quotes are required, and multiline ones are literal.
+//
+// Comments, anchors, extension keys like x-base, and order of env, arg and label are kept,
+// keys are not reordered in documents with aliases, which must follow their anchors.
+func Format(in []byte) ([]byte, error) {
+	decoder := yamlv3.NewDecoder(bytes.NewReader(in))
+
+	buf := bytes.NewBuffer(nil)
+	encoder := yamlv3.NewEncoder(buf)
+	encoder.SetIndent(2)
+
+	for {
+		node := &yamlv3.Node{}
+		if err := decoder.Decode(node); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		f := &formatter{sortKeys: !hasAliases(node)}
+		f.format(node, reflect.TypeOf(Dockerfile{}))
+
+		if err := encoder.Encode(node); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	out := buf.Bytes()
+
+	if err := sameDocuments(in, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+type formatter struct {
+	// sort keys of fields and names
+	sortKeys bool
+}
+
+func (f *formatter) format(node *yamlv3.Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, n := range node.Content {
+			f.format(n, t)
+		}
+		return
+	case yamlv3.AliasNode:
+		// formatted at anchor, not followed since anchor could contain alias of itself
+		return
+	case yamlv3.ScalarNode:
+		formatScalar(node)
+		return
+	}
+
+	node.Style &^= yamlv3.FlowStyle
+
+	if node.Kind == yamlv3.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			// implicit merge keys, which are written as !!merge << when tagged
+			if key := node.Content[i]; key.Value == "<<" && key.ShortTag() == "!!merge" {
+				key.Tag = ""
+			}
+		}
+	}
+
+	if t == typeKeyValues {
+		t = reflect.TypeOf(Values{})
+	}
+
+	if t == typeCopies {
+		// list form or mapping form
+		if node.Kind == yamlv3.SequenceNode {
+			t = reflect.TypeOf([]CopyEntry{})
+		} else {
+			t = reflect.TypeOf(Values{})
+		}
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yamlv3.MappingNode:
+		names := yamlFieldNames(t)
+		fields := yamlFields(t)
+
+		if f.sortKeys {
+			rank := map[string]int{}
+			for i, name := range names {
+				rank[name] = i
+			}
+
+			sortPairs(node, func(key string) int {
+				if key == "<<" || isExtensionKey(key) {
+					return -1
+				}
+				if i, ok := rank[key]; ok {
+					return i
+				}
+				// unknown keys last
+				return len(names)
+			})
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if ft, ok := fields[node.Content[i].Value]; ok {
+				f.format(node.Content[i+1], ft)
+			} else {
+				f.format(node.Content[i+1], typeAny)
+			}
+		}
+	case t.Kind() == reflect.Map && node.Kind == yamlv3.MappingNode:
+		elem := t.Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+
+		// order of values matters, like env referring keys before
+		if f.sortKeys && elem.Kind() == reflect.Struct {
+			keys := make([]string, 0, len(node.Content)/2)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				keys = append(keys, node.Content[i].Value)
+			}
+			sort.Strings(keys)
+
+			rank := map[string]int{}
+			for i, key := range keys {
+				rank[key] = i
+			}
+
+			sortPairs(node, func(key string) int {
+				if key == "<<" || isExtensionKey(key) {
+					return -1
+				}
+				return rank[key]
+			})
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			f.format(node.Content[i+1], t.Elem())
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yamlv3.SequenceNode:
+		for _, n := range node.Content {
+			f.format(n, t.Elem())
+		}
+	default:
+		for _, n := range node.Content {
+			f.format(n, typeAny)
+		}
+	}
+}
+
+var typeAny = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// sortPairs sorts pairs of mapping node by rank of keys, stable for same rank
+func sortPairs(node *yamlv3.Node, rank func(key string) int) {
+	pairs := make([][2]*yamlv3.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, [2]*yamlv3.Node{node.Content[i], node.Content[i+1]})
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return rank(pairs[i][0].Value) < rank(pairs[j][0].Value)
+	})
+
+	for i := range pairs {
+		node.Content[2*i], node.Content[2*i+1] = pairs[i][0], pairs[i][1]
+	}
+}
+
+// formatScalar sets style of strings, plain unless quotes are required by yaml, literal for multiline ones
+func formatScalar(node *yamlv3.Node) {
+	if node.ShortTag() != "!!str" {
+		return
+	}
+
+	if strings.Contains(node.Value, "\n") {
+		if !strings.Contains(node.Value, " \n") && !strings.Contains(node.Value, "\t") {
+			node.Style = yamlv3.LiteralStyle
+		}
+		return
+	}
+
+	// quoted for readers of yaml 1.1 too, which take plain yes, on and 0755 as others than strings
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(node.Value), &v); err == nil {
+		if s, ok := v.(string); ok && s == node.Value {
+			node.Style = 0
+		}
+	}
+}
+
+// yamlFieldNames returns yaml names of fields of struct in order of declaration, including inline ones
+func yamlFieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+
+		if stringIncludes(parts[1:], "inline") {
+			names = append(names, yamlFieldNames(f.Type)...)
+			continue
+		}
+
+		names = append(names, yamlFieldName(f))
+	}
+
+	return names
+}
+
+// hasAliases tells node has aliases in it, anchors of aliases are not followed
+func hasAliases(node *yamlv3.Node) bool {
+	if node.Kind == yamlv3.AliasNode {
+		return true
+	}
+	for _, n := range node.Content {
+		if hasAliases(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDocuments checks formatted yaml has same values as the source
+func sameDocuments(in []byte, out []byte) error {
+	decode := func(data []byte) ([]interface{}, error) {
+		list := make([]interface{}, 0)
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var v interface{}
+			if err := decoder.Decode(&v); err != nil {
+				if err == io.EOF {
+					return list, nil
+				}
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	}
+
+	a, err := decode(in)
+	if err != nil {
+		return err
+	}
+
+	b, err := decode(out)
+	if err != nil {
+		return fmt.Errorf("formatted yaml is invalid: %w", err)
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		return fmt.Errorf("formatted yaml changes values of spec")
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package dockerfileyml
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFormat(t *testing.T) {
+	out, err := Format([]byte(`# spec of app
+stages:
+  z: {from: 'alpine'}
+  builder:
+    run: ["go build", {cmd: make, no-join: true}]
+    from: golang
+    workdir: "/src"
+env: {B: "${A}", A: "yes"}
+from: "busybox" # base
+copy:
+  - {src: [./a, ./b], dst: ./}
+cmd: ["sh", "-c", "echo a\necho b\n"]
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(string(out)).To(Equal(`# spec of app
+stages:
+  builder:
+    from: golang
+    workdir: /src
+    run:
+      - go build
+      - cmd: make
+        no-join: true
+  z:
+    from: alpine
+from: busybox # base
+env:
+  B: ${A}
+  A: "yes"
+copy:
+  - src:
+      - ./a
+      - ./b
+    dst: ./
+cmd:
+  - sh
+  - -c
+  - |
+    echo a
+    echo b
+`))
+
+	again, err := Format(out)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(string(again)).To(Equal(string(out)))
+
+	t.Run("aliases", func(t *testing.T) {
+		out, err := Format([]byte(`x-base: &base {workdir: /src}
+stages:
+  z: {from: alpine}
+  b: {<<: *base, from: golang}
+`))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(string(out)).To(Equal(`x-base: &base
+  workdir: /src
+stages:
+  z:
+    from: alpine
+  b:
+    <<: *base
+    from: golang
+`))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Format([]byte("run: {"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
@@ -0,0 +1,283 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// defaultIndent of continuation lines
+const defaultIndent = "    "
+
+// LineEnding of written Dockerfile
+type LineEnding string
+
+const (
+	LineEndingLF   LineEnding = "lf"
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+// WithLineEnding writes lines of Dockerfile ended by e, LineEndingLF by default,
+// for checkouts with core.autocrlf of git on Windows.
+// The last line is always ended, even with a value ended without newline.
+func WithLineEnding(e LineEnding) WriteOption {
+	return func(o *writeOptions) {
+		o.lineEnding = e
+	}
+}
+
+// lineEndingWriter rewrites \n to line ending, and tracks whether the last line is ended
+type lineEndingWriter struct {
+	w     io.Writer
+	crlf  bool
+	ended bool
+}
+
+func newLineEndingWriter(w io.Writer, e LineEnding) *lineEndingWriter {
+	return &lineEndingWriter{w: w, crlf: e == LineEndingCRLF, ended: true}
+}
+
+func (w *lineEndingWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	data := p
+	if w.crlf {
+		data = bytes.Replace(bytes.Replace(p, []byte("\r\n"), []byte("\n"), -1), []byte("\n"), []byte("\r\n"), -1)
+	}
+
+	if _, err := w.w.Write(data); err != nil {
+		return 0, err
+	}
+
+	w.ended = p[len(p)-1] == '\n'
+	return len(p), nil
+}
+
+// end ends the last line when not ended
+func (w *lineEndingWriter) end() error {
+	if w.ended {
+		return nil
+	}
+	_, err := w.Write([]byte("\n"))
+	return err
+}
+
+// WithStageSeparators writes comment before instructions of each stage,
+// like # --- stage: builder ---, for readers of Dockerfile with many stages.
+func WithStageSeparators() WriteOption {
+	return func(o *writeOptions) {
+		o.stageSeparators = true
+	}
+}
+
+func addStageSeparators(list []instruction) []instruction {
+	for i := range list {
+		if i > 0 && list[i].Stage == list[i-1].Stage {
+			continue
+		}
+
+		separator := "--- main stage ---"
+		if list[i].Stage != "" {
+			separator = "--- stage: " + list[i].Stage + " ---"
+		}
+
+		list[i].Comments = append([]string{separator}, list[i].Comments...)
+	}
+
+	return list
+}
+
+// instructions grouped by WithGroupedInstructions
+var groupedKeys = map[string]bool{
+	"ARG":    true,
+	"ENV":    true,
+	"LABEL":  true,
+	"COPY":   true,
+	"ADD":    true,
+	"EXPOSE": true,
+	"VOLUME": true,
+}
+
+// WithGroupedInstructions writes adjacent instructions of same key in stage without blank lines between,
+// like ARG, ENV, LABEL and COPY, while stages are still separated by blank lines.
+func WithGroupedInstructions() WriteOption {
+	return func(o *writeOptions) {
+		o.groupedInstructions = true
+	}
+}
+
+func groupInstructions(list []instruction) []instruction {
+	for i := 0; i < len(list)-1; i++ {
+		next := list[i+1]
+
+		if groupedKeys[list[i].Key] && next.Key == list[i].Key && next.Stage == list[i].Stage && len(next.Comments) == 0 {
+			list[i].Attached = true
+		}
+	}
+
+	return list
+}
+
+// WithRunJoin joins adjacent scripts into one RUN by join, && or ;,
+// with prelude before them when not empty, like set -euxo pipefail,
+// for stages without run-join or run-prelude of their own.
+func WithRunJoin(join string, prelude string) WriteOption {
+	return func(o *writeOptions) {
+		o.runJoin = join
+		o.runPrelude = prelude
+	}
+}
+
+// WithMultilineRun writes each script joined into RUN in its own line, continued by \, like
+//
+//	RUN apk add curl && \
+//	    curl -fsSL https://example.com
+func WithMultilineRun() WriteOption {
+	return func(o *writeOptions) {
+		o.multilineRun = true
+	}
+}
+
+func foldRuns(list []instruction) []instruction {
+	for i := range list {
+		if len(list[i].Commands) < 2 && list[i].Prelude == "" {
+			continue
+		}
+
+		lines := make([]string, len(list[i].Commands))
+		for j, command := range list[i].Commands {
+			lines[j] = shellLines(command)
+		}
+
+		list[i].Value = joinScripts(lines, list[i].Join, list[i].Prelude, " \\\n"+defaultIndent)
+	}
+
+	return list
+}
+
+// WithIndent indents continuation lines by n spaces, 4 by default, negative n is taken as default
+func WithIndent(n int) WriteOption {
+	return func(o *writeOptions) {
+		if n < 0 {
+			o.indent = defaultIndent
+			return
+		}
+		o.indent = strings.Repeat(" ", n)
+	}
+}
+
+// WithAlignedContinuations aligns continuation lines with value after key of instruction, like
+//
+//	ENTRYPOINT [ \
+//	           "/bin/app", \
+//	           "serve" \
+//	]
+func WithAlignedContinuations() WriteOption {
+	return func(o *writeOptions) {
+		o.alignedContinuations = true
+	}
+}
+
+// WithWrappedArrays writes each value of exec form in its own line, when instruction is longer than width, like
+//
+//	CMD [ \
+//	    "serve", \
+//	    "--config=/etc/app/config.yaml" \
+//	]
+func WithWrappedArrays(width int) WriteOption {
+	return func(o *writeOptions) {
+		o.wrapWidth = width
+	}
+}
+
+// continuationIndent returns indent of continuation lines of ins
+func (o *writeOptions) continuationIndent(ins *instruction) string {
+	if o.alignedContinuations {
+		return strings.Repeat(" ", len(ins.Key)+1)
+	}
+	return o.indent
+}
+
+// formatContinuations indents continuation lines, which are rendered with defaultIndent,
+// and wraps values of exec form longer than wrapWidth.
+func formatContinuations(list []instruction, o *writeOptions) []instruction {
+	for i := range list {
+		indent := o.continuationIndent(&list[i])
+
+		if o.wrapWidth > 0 && len(list[i].String()) > o.wrapWidth {
+			if values, ok := list[i].jsonArray(); ok && len(values) > 1 {
+				lines := make([]string, len(values))
+				for j := range values {
+					lines[j] = indent + jsonStringOf(values[j])
+				}
+				list[i].Value = "[ \\\n" + strings.Join(lines, ", \\\n") + " \\\n]"
+				continue
+			}
+		}
+
+		if indent != defaultIndent {
+			list[i].Value = strings.Replace(list[i].Value, "\n"+defaultIndent, "\n"+indent, -1)
+		}
+	}
+
+	return list
+}
+
+// WithFoldedValues writes pairs of ENV, and adjacent LABEL of stage, in one instruction with one pair per line,
+// when there are at least min pairs, for fewer instructions while diffs are still line per key.
+func WithFoldedValues(min int) WriteOption {
+	return func(o *writeOptions) {
+		o.foldedValues = min
+	}
+}
+
+func foldValues(list []instruction, min int) []instruction {
+	folded := make([]instruction, 0, len(list))
+
+	for i := 0; i < len(list); i++ {
+		ins := list[i]
+
+		switch ins.Key {
+		case "ENV":
+			if len(ins.Pairs) >= min {
+				ins.Value = strings.Join(ins.Pairs, " \\\n"+defaultIndent)
+			}
+		case "LABEL":
+			parent := parentPath(ins.Path)
+
+			j := i + 1
+			for j < len(list) && list[j].Key == "LABEL" && list[j].Stage == ins.Stage && len(list[j].Comments) == 0 && parentPath(list[j].Path) == parent {
+				j++
+			}
+
+			if j-i >= min {
+				pairs := make([]string, 0, j-i)
+				for _, label := range list[i:j] {
+					pairs = append(pairs, label.Value)
+				}
+
+				ins.Value = strings.Join(pairs, " \\\n"+defaultIndent)
+				ins.Path = parent
+				ins.Pairs = pairs
+				i = j - 1
+			}
+		}
+
+		folded = append(folded, ins)
+	}
+
+	return folded
+}
+
+// parentPath returns path of parent of field or key of yaml path, like label of label.a or label["a.b"]
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "\"]") {
+		return path[0:strings.LastIndex(path, "[\"")]
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[0:i]
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWithLineEnding(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From: "busybox",
+			Run:  Scripts("echo a\necho b"),
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d, WithLineEnding(LineEndingCRLF), WithHeader(Header{}))).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal("# Code generated by dockerfileyml. DO NOT EDIT.\r\n\r\nFROM busybox\r\n\r\nRUN echo a; \\\r\n    echo b\r\n\r\n"))
+
+	t.Run("final newline", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		w := newLineEndingWriter(buf, LineEndingLF)
+		_, _ = w.Write([]byte("FROM busybox"))
+		NewWithT(t).Expect(w.end()).To(BeNil())
+		NewWithT(t).Expect(w.end()).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("FROM busybox\n"))
+	})
+}
+
+func TestWithStageSeparators(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From:  "busybox",
+			Label: KeyValuesOf(Values{"a": "1", "b": "2"}),
+			Copy:  CopyValues(Values{"builder:/go/bin/app": "/bin/app", "./etc": "/etc/app"}),
+		},
+		Stages: map[string]*Stage{
+			"builder": {
+				From: "golang",
+				Arg:  KeyValuesOf(Values{"GOOS": "linux", "GOARCH": "amd64"}),
+				Run:  Scripts("go build"),
+			},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d, WithStageSeparators(), WithGroupedInstructions())).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`# --- stage: builder ---
+FROM golang AS builder
+
+ARG GOARCH=amd64
+ARG GOOS=linux
+
+RUN go build
+
+# --- main stage ---
+FROM busybox
+
+LABEL a=1
+LABEL b=2
+
+COPY ./etc /etc/app
+COPY --from=builder /go/bin/app /bin/app
+
+`))
+}
+
+func TestWithMultilineRun(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From: "alpine",
+			Run: append(
+				Scripts("apk add curl", "if [ -f a ]; then\n  cat a\nfi", "curl -fsSL https://example.com"),
+				Script{Command: "echo done", NoJoin: true},
+			),
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d, WithMultilineRun())).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM alpine
+
+RUN apk add curl && \
+    if [ -f a ]; then \
+    cat a; \
+    fi && \
+    curl -fsSL https://example.com
+
+RUN echo done
+
+`))
+
+	list, err := parseInstructions(bytes.NewReader(buf.Bytes()))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(list[1].Value).To(Equal("apk add curl && if [ -f a ]; then cat a; fi && curl -fsSL https://example.com"))
+}
+
+func TestWithRunJoin(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From: "alpine",
+			Run:  Scripts("apk add curl", "curl -fsSL https://example.com"),
+		},
+		Stages: map[string]*Stage{
+			"builder": {
+				From:    "golang",
+				RunJoin: RunJoinSemicolon,
+				Run:     Scripts("go mod download", "go build"),
+			},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d, WithRunJoin("", "set -euxo pipefail"))).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM golang AS builder
+
+RUN set -euxo pipefail; go mod download; go build
+
+FROM alpine
+
+RUN set -euxo pipefail; apk add curl && curl -fsSL https://example.com
+
+`))
+
+	t.Run("multiline", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, d, WithRunJoin("", "set -eux;"), WithMultilineRun())).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`RUN set -eux; \
+    go mod download; \
+    go build
+`))
+	})
+
+	t.Run("invalid join", func(t *testing.T) {
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d, WithRunJoin("||", ""))
+		NewWithT(t).Expect(errors.Is(err, ErrInvalidJoin)).To(BeTrue())
+		NewWithT(t).Expect(err.Error()).To(Equal("run-join: invalid join ||, should be && or ;"))
+	})
+}
+
+func TestFormatContinuations(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From:       "alpine",
+			Run:        Scripts("apk add curl", "curl -fsSL https://example.com"),
+			Entrypoint: []string{"/bin/app", "serve"},
+			Command:    []string{"--config=/etc/app/config.yaml"},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d, WithMultilineRun(), WithIndent(2), WithWrappedArrays(24))).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM alpine
+
+RUN apk add curl && \
+  curl -fsSL https://example.com
+
+ENTRYPOINT [ \
+  "/bin/app", \
+  "serve" \
+]
+
+CMD ["--config=/etc/app/config.yaml"]
+
+`))
+
+	list, err := parseInstructions(bytes.NewReader(buf.Bytes()))
+	NewWithT(t).Expect(err).To(BeNil())
+	values, ok := list[2].jsonArray()
+	NewWithT(t).Expect(ok).To(BeTrue())
+	NewWithT(t).Expect(values).To(Equal(d.Entrypoint))
+
+	t.Run("aligned", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, d, WithAlignedContinuations(), WithWrappedArrays(24))).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`ENTRYPOINT [ \
+           "/bin/app", \
+           "serve" \
+]
+`))
+	})
+
+	t.Run("negative indent", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, d, WithMultilineRun(), WithIndent(-1))).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("RUN apk add curl && \\\n    curl -fsSL https://example.com\n"))
+	})
+}
+
+func TestWithFoldedValues(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From:  "alpine",
+			Label: KeyValuesOf(Values{"org.opencontainers.image.title": "app", "org.opencontainers.image.source": "https://example.com/app"}),
+			Env:   KeyValuesOf(Values{"A": "1", "B": "b c"}),
+		},
+		Stages: map[string]*Stage{
+			"builder": {
+				From: "golang",
+				Env:  KeyValuesOf(Values{"CGO_ENABLED": "0"}),
+			},
+		},
+	}
+
+	m := SourceMap{}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d, WithFoldedValues(2), WithAlignedContinuations(), WithSourceMap(&m))).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM golang AS builder
+
+ENV CGO_ENABLED=0
+
+FROM alpine
+
+LABEL org.opencontainers.image.source=https://example.com/app \
+      org.opencontainers.image.title=app
+
+ENV A=1 \
+    B="b c"
+
+`))
+	NewWithT(t).Expect(m.LinesOf("label")).To(Equal([]int{7}))
+
+	list, err := parseInstructions(bytes.NewReader(buf.Bytes()))
+	NewWithT(t).Expect(err).To(BeNil())
+	values, err := parseKeyValues(list[4])
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(values).To(Equal(d.Env))
+}
@@ -0,0 +1,117 @@
+// Package frontend is a BuildKit frontend of dockerfile.yml,
+// to build specs with docker build -f dockerfile.yml directly, by adding the syntax line on top of spec:
+//
+//	# syntax=ghcr.io/go-courier/dockerfileyml-frontend
+//
+// The spec is rendered in memory, and converted to LLB by the Dockerfile frontend,
+// so build args, target, platforms and named contexts work as same as Dockerfile.
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/go-courier/dockerfileyml"
+	"github.com/moby/buildkit/exporter/containerimage/image"
+	"github.com/moby/buildkit/frontend/dockerfile/dockerfile2llb"
+	"github.com/moby/buildkit/frontend/dockerui"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/frontend/subrequests/outline"
+	"github.com/moby/buildkit/frontend/subrequests/targets"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Build builds spec of build context with BuildKit gateway client
+func Build(ctx context.Context, c client.Client) (*client.Result, error) {
+	bc, err := dockerui.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := bc.ReadEntrypoint(ctx, "dockerfile.yml")
+	if err != nil {
+		return nil, err
+	}
+
+	dt, err := renderDockerfile(src.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src.Filename, err)
+	}
+
+	convertOpt := dockerfile2llb.ConvertOpt{
+		Config:       bc.Config,
+		Client:       bc,
+		MetaResolver: c,
+	}
+
+	if res, ok, err := bc.HandleSubrequest(ctx, dockerui.RequestHandler{
+		Outline: func(ctx context.Context) (*outline.Outline, error) {
+			return dockerfile2llb.Dockefile2Outline(ctx, dt, convertOpt)
+		},
+		ListTargets: func(ctx context.Context) (*targets.List, error) {
+			return dockerfile2llb.ListTargets(ctx, dt)
+		},
+	}); err != nil {
+		return nil, err
+	} else if ok {
+		return res, nil
+	}
+
+	rb, err := bc.Build(ctx, func(ctx context.Context, platform *ocispecs.Platform, idx int) (client.Reference, *image.Image, error) {
+		opt := convertOpt
+		opt.TargetPlatform = platform
+
+		st, img, _, err := dockerfile2llb.Dockerfile2LLB(ctx, dt, opt)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		def, err := st.Marshal(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal LLB definition failed: %w", err)
+		}
+
+		r, err := c.Solve(ctx, client.SolveRequest{
+			Definition:   def.ToPB(),
+			CacheImports: bc.CacheImports,
+		})
+		if err != nil {
+			p := platforms.DefaultSpec()
+			if platform != nil {
+				p = *platform
+			}
+			return nil, nil, fmt.Errorf("build for %s failed: %w", platforms.Format(p), err)
+		}
+
+		ref, err := r.SingleRef()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return ref, img, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rb.Finalize()
+}
+
+// renderDockerfile renders Dockerfile of spec,
+// which should be single document, includes are not supported without the local filesystem.
+func renderDockerfile(spec []byte) ([]byte, error) {
+	d, err := dockerfileyml.ReadFromYAML(bytes.NewReader(spec))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := dockerfileyml.WriteToDockerfile(buf, *d); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
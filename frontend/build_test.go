@@ -0,0 +1,19 @@
+package frontend
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRenderDockerfile(t *testing.T) {
+	dt, err := renderDockerfile([]byte(`# syntax=ghcr.io/go-courier/dockerfileyml-frontend
+from: busybox
+cmd: [sh]
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(string(dt)).To(Equal("FROM busybox\n\nCMD [\"sh\"]\n\n"))
+
+	_, err = renderDockerfile([]byte("stages: {a: {from: b}, b: {from: a}}\n"))
+	NewWithT(t).Expect(err).NotTo(BeNil())
+}
@@ -0,0 +1,18 @@
+// Command dockerfileyml-frontend is the BuildKit frontend of dockerfile.yml
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-courier/dockerfileyml/frontend"
+	"github.com/moby/buildkit/frontend/gateway/grpcclient"
+	"github.com/moby/buildkit/util/appcontext"
+)
+
+func main() {
+	if err := grpcclient.RunFromEnvironment(appcontext.Context(), frontend.Build); err != nil {
+		fmt.Fprintln(os.Stderr, "dockerfileyml-frontend:", err)
+		os.Exit(1)
+	}
+}
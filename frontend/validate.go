@@ -0,0 +1,46 @@
+package frontend
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/go-courier/dockerfileyml"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Validate parses dockerfile by parser of BuildKit,
+// to use with dockerfileyml.WithValidator, errors at line are returned as *dockerfileyml.LineError.
+func Validate(dockerfile []byte) error {
+	result, err := parser.Parse(bytes.NewReader(dockerfile))
+	if err != nil {
+		return lineError(err)
+	}
+
+	if _, _, err := instructions.Parse(result.AST); err != nil {
+		return lineError(err)
+	}
+
+	return nil
+}
+
+func lineError(err error) error {
+	var el *parser.ErrorLocation
+
+	if errors.As(err, &el) && len(el.Location) > 0 {
+		return &dockerfileyml.LineError{Line: el.Location[0].Start.Line, Err: rootCause(err)}
+	}
+
+	return err
+}
+
+// rootCause drops wrapping messages of line, which is in LineError
+func rootCause(err error) error {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
@@ -0,0 +1,26 @@
+package frontend
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-courier/dockerfileyml"
+	. "github.com/onsi/gomega"
+)
+
+func TestValidate(t *testing.T) {
+	d := dockerfileyml.Dockerfile{}
+	d.Stages = map[string]*dockerfileyml.Stage{
+		"builder": {From: "golang:1.15", Run: dockerfileyml.Scripts("go build")},
+	}
+	d.From = "busybox"
+	d.Copy = dockerfileyml.CopyValues(dockerfileyml.Values{"builder:/go/bin/app": "/bin/"})
+
+	err := dockerfileyml.WriteToDockerfile(bytes.NewBuffer(nil), d, dockerfileyml.WithValidator(Validate))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	d.Copy = dockerfileyml.CopyValues(dockerfileyml.Values{"--bogus ./": "/"})
+
+	err = dockerfileyml.WriteToDockerfile(bytes.NewBuffer(nil), d, dockerfileyml.WithValidator(Validate))
+	NewWithT(t).Expect(err).To(MatchError("invalid Dockerfile: COPY of main stage: line 7: unknown flag: bogus"))
+}
@@ -0,0 +1,77 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// GenerateFile generates Dockerfile to path from spec file, designed for go:generate
+//
+//	//go:generate go run github.com/go-courier/dockerfileyml/cmd/dockerfileyml generate dockerfile.yml
+//
+// or in Go code, see WriteFile.
+func GenerateFile(spec string, path string, opts ...WriteOption) error {
+	d, err := ParseFile(spec)
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, *d, opts...)
+}
+
+// WriteFile writes Dockerfile to path atomically,
+// and skips writing when content is unchanged to keep mtime for build tools.
+func WriteFile(path string, d Dockerfile, opts ...WriteOption) error {
+	buf := bytes.NewBuffer(nil)
+
+	if err := WriteToDockerfile(buf, d, opts...); err != nil {
+		return err
+	}
+
+	return WriteFileIfChanged(path, buf.Bytes())
+}
+
+// WriteFileIfChanged writes data to path atomically like WriteFile,
+// for content rendered already, like .dockerignore and source map written next to Dockerfile.
+func WriteFileIfChanged(path string, data []byte) error {
+	if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Chmod(tmp, 0644); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package dockerfileyml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGenerateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerfileyml")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "build", "Dockerfile")
+
+	err = GenerateFile("testdata/multistage.yml", output)
+	NewWithT(t).Expect(err).To(BeNil())
+
+	data, _ := ioutil.ReadFile(output)
+	expected, _ := ioutil.ReadFile("__snapshots__/multistage.Dockerfile")
+	NewWithT(t).Expect(string(data)).To(Equal(string(expected)))
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	_ = os.Chtimes(output, past, past)
+
+	err = GenerateFile("testdata/multistage.yml", output)
+	NewWithT(t).Expect(err).To(BeNil())
+
+	info, _ := os.Stat(output)
+	NewWithT(t).Expect(info.ModTime().Equal(past)).To(BeTrue())
+
+	files, _ := ioutil.ReadDir(filepath.Dir(output))
+	NewWithT(t).Expect(files).To(HaveLen(1))
+}
+
+func TestWriteFileIfChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerfileyml")
+	NewWithT(t).Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, ".dockerignore")
+
+	NewWithT(t).Expect(WriteFileIfChanged(output, []byte("*\n"))).To(Succeed())
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	_ = os.Chtimes(output, past, past)
+
+	NewWithT(t).Expect(WriteFileIfChanged(output, []byte("*\n"))).To(Succeed())
+	info, _ := os.Stat(output)
+	NewWithT(t).Expect(info.ModTime().Equal(past)).To(BeTrue())
+
+	NewWithT(t).Expect(WriteFileIfChanged(output, []byte("*\n!app\n"))).To(Succeed())
+	data, _ := ioutil.ReadFile(output)
+	NewWithT(t).Expect(string(data)).To(Equal("*\n!app\n"))
+}
@@ -0,0 +1,142 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortStages sorts stages topologically, stages depended on come first,
+// and ones without dependency between are sorted by name.
+func sortStages(stages []*Stage) ([]*Stage, error) {
+	byName := map[string]*Stage{}
+	for _, s := range stages {
+		byName[s.name] = s
+	}
+
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+
+	for _, s := range stages {
+		for dep := range s.deps {
+			if _, ok := byName[dep]; ok {
+				pending[s.name]++
+				dependents[dep] = append(dependents[dep], s.name)
+			}
+		}
+	}
+
+	ready := make([]string, 0)
+	for _, s := range stages {
+		if pending[s.name] == 0 {
+			ready = append(ready, s.name)
+		}
+	}
+
+	sorted := make([]*Stage, 0, len(stages))
+
+	for len(ready) > 0 {
+		sort.Strings(ready)
+
+		name := ready[0]
+		ready = ready[1:]
+
+		sorted = append(sorted, byName[name])
+
+		for _, dependent := range dependents[name] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(sorted) < len(stages) {
+		return nil, fmt.Errorf("circular dependency of stages %s", strings.Join(findCycle(byName), " -> "))
+	}
+
+	return sorted, nil
+}
+
+// findCycle returns the first cycle found by name order, like a -> b -> a
+func findCycle(byName map[string]*Stage) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	states := map[string]int{}
+	path := make([]string, 0)
+
+	var visit func(name string) []string
+
+	visit = func(name string) []string {
+		states[name] = visiting
+		path = append(path, name)
+
+		deps := make([]string, 0)
+		for dep := range byName[name].deps {
+			if _, ok := byName[dep]; ok {
+				deps = append(deps, dep)
+			}
+		}
+		sort.Strings(deps)
+
+		for _, dep := range deps {
+			switch states[dep] {
+			case visiting:
+				for i := range path {
+					if path[i] == dep {
+						return append(append([]string{}, path[i:]...), dep)
+					}
+				}
+			case 0:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[0 : len(path)-1]
+		states[name] = visited
+		return nil
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if states[name] == 0 {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// reachableStages returns stages which s depends on directly or transitively
+func reachableStages(s *Stage, stages map[string]*Stage) []*Stage {
+	reached := map[string]bool{}
+	list := make([]*Stage, 0)
+
+	var walk func(s *Stage)
+
+	walk = func(s *Stage) {
+		for dep := range s.deps {
+			if stage, ok := stages[dep]; ok && !reached[dep] {
+				reached[dep] = true
+				list = append(list, stage)
+				walk(stage)
+			}
+		}
+	}
+
+	walk(s)
+
+	return list
+}
@@ -0,0 +1,120 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func stageOrder(t *testing.T, d Dockerfile) []string {
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d)).To(BeNil())
+
+	names := make([]string, 0)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "FROM ") {
+			words := strings.Fields(line)
+			names = append(names, words[len(words)-1])
+		}
+	}
+	return names
+}
+
+func TestSortStages(t *testing.T) {
+	t.Run("transitive", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Stages = map[string]*Stage{
+			"a": {From: "busybox", WorkingDir: "/a", Copy: CopyValues(Values{"c:./c.txt": "./"})},
+			"b": {From: "busybox", WorkingDir: "/b"},
+			"c": {From: "busybox", WorkingDir: "/c", Copy: CopyValues(Values{"b:./b.txt": "./"})},
+			"d": {From: "busybox", WorkingDir: "/d"},
+		}
+		d.From = "busybox"
+		d.Copy = CopyValues(Values{"a:./a.txt": "./"})
+
+		NewWithT(t).Expect(stageOrder(t, d)).To(Equal([]string{"b", "c", "a", "d", "busybox"}))
+	})
+
+	t.Run("from stage and mount", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Stages = map[string]*Stage{
+			"a": {From: "z"},
+			"b": {From: "busybox", Run: []Script{{Command: "ls /cache", Mount: []string{"type=bind,from=a,target=/cache"}}}},
+			"z": {From: "busybox"},
+		}
+		d.From = "busybox"
+
+		NewWithT(t).Expect(stageOrder(t, d)).To(Equal([]string{"z", "a", "b", "busybox"}))
+	})
+
+	t.Run("needs", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Stages = map[string]*Stage{
+			"a": {From: "busybox", Needs: []string{"b"}},
+			"b": {From: "busybox"},
+		}
+		d.From = "busybox"
+
+		NewWithT(t).Expect(stageOrder(t, d)).To(Equal([]string{"b", "a", "busybox"}))
+
+		d.Stages["a"].Needs = []string{"c"}
+		NewWithT(t).Expect(WriteToDockerfile(bytes.NewBuffer(nil), d)).NotTo(BeNil())
+	})
+
+	t.Run("reused after changes", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Stages = map[string]*Stage{
+			"a": {From: "busybox", Needs: []string{"b"}},
+			"b": {From: "busybox"},
+		}
+		d.From = "busybox"
+
+		NewWithT(t).Expect(stageOrder(t, d)).To(Equal([]string{"b", "a", "busybox"}))
+
+		d.Stages["a"].Needs = nil
+		d.Stages["b"].Needs = []string{"a"}
+		NewWithT(t).Expect(stageOrder(t, d)).To(Equal([]string{"a", "b", "busybox"}))
+	})
+
+	t.Run("target", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Stages = map[string]*Stage{
+			"builder": {From: "golang", WorkingDir: "/go/src"},
+			"tester":  {From: "builder"},
+			"docs":    {From: "busybox"},
+			"release": {From: "busybox", Copy: CopyValues(Values{"builder:./app": "./"})},
+		}
+		d.From = "busybox"
+
+		NewWithT(t).Expect(stageOrder(t, d)).To(Equal([]string{"builder", "docs", "release", "tester", "busybox"}))
+
+		d.Target = "release"
+		NewWithT(t).Expect(stageOrder(t, d)).To(Equal([]string{"builder", "release"}))
+
+		d.Target = "debug"
+		NewWithT(t).Expect(WriteToDockerfile(bytes.NewBuffer(nil), d)).NotTo(BeNil())
+	})
+
+	t.Run("circular", func(t *testing.T) {
+		d := Dockerfile{}
+		d.Stages = map[string]*Stage{
+			"a": {From: "busybox", WorkingDir: "/a", Copy: CopyValues(Values{"b:/b.txt": "./"})},
+			"b": {From: "c"},
+			"c": {From: "busybox", Needs: []string{"a"}},
+			"d": {From: "busybox", Needs: []string{"d"}},
+		}
+		d.From = "busybox"
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(Equal("circular dependency of stages a -> b -> c -> a"))
+
+		delete(d.Stages, "a")
+		d.Stages["c"].Needs = nil
+
+		err = WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err.Error()).To(Equal("circular dependency of stages d -> d"))
+	})
+}
@@ -0,0 +1,72 @@
+package dockerfileyml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Equal tells s and o render same instructions, with references to stages resolved against stages,
+// like sources of builder:./app in workdir of stage builder.
+// name of stage, comments and yaml order of fields are ignored, and invalid stages are not equal.
+// stages are rendered alone, extends and snippets of them are not resolved.
+func (s *Stage) Equal(o *Stage, stages map[string]*Stage) bool {
+	if s == nil || o == nil {
+		return s == o
+	}
+
+	a, err := s.content(stages)
+	if err != nil {
+		return false
+	}
+
+	b, err := o.content(stages)
+	if err != nil {
+		return false
+	}
+
+	return a == b
+}
+
+// Hash returns sha256 of instructions rendered by s alone, like sha256:<hex>, for caching and change detection of stages.
+// references to stages are hashed as written, like builder:./app, see HashIn to resolve them.
+func (s *Stage) Hash() string {
+	c := s.Clone()
+	if c == nil {
+		return hashOf("")
+	}
+
+	c.name = ""
+
+	return hashOf(stageContentOf(renderStage(c)))
+}
+
+// HashIn returns sha256 like Hash, with references to stages resolved against stages,
+// same for stages which are Equal.
+func (s *Stage) HashIn(stages map[string]*Stage) (string, error) {
+	content, err := s.content(stages)
+	if err != nil {
+		return "", err
+	}
+	return hashOf(content), nil
+}
+
+func hashOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// content returns instructions rendered by s for comparing, validated and resolved on a clone of s
+func (s *Stage) content(stages map[string]*Stage) (string, error) {
+	c := s.Clone()
+	if c == nil {
+		return "", nil
+	}
+
+	c.name = ""
+
+	if err := scanAndValidate(c, stages); err != nil {
+		return "", err
+	}
+
+	return stageContentOf(renderStage(c)), nil
+}
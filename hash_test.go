@@ -0,0 +1,87 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestStageEqual(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang
+    workdir: /go/src
+  a:
+    from: golang
+    comments: {from: builds app}
+    run: [go mod download, go build]
+    env: {CGO_ENABLED: "0"}
+  b:
+    env: {CGO_ENABLED: "0"}
+    from: golang
+    run: [go mod download, go build]
+  c:
+    from: golang
+    run: [go build]
+  release:
+    from: alpine
+    copy: {builder:./app: /usr/bin/}
+from: alpine
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	a, b, c := d.Stages["a"], d.Stages["b"], d.Stages["c"]
+
+	hash := func(s *Stage) string {
+		h, err := s.HashIn(d.Stages)
+		NewWithT(t).Expect(err).To(BeNil())
+		return h
+	}
+
+	NewWithT(t).Expect(a.Equal(b, d.Stages)).To(BeTrue())
+	NewWithT(t).Expect(hash(a)).To(Equal(hash(b)))
+	NewWithT(t).Expect(hash(a)).To(HavePrefix("sha256:"))
+
+	NewWithT(t).Expect(a.Equal(c, d.Stages)).To(BeFalse())
+	NewWithT(t).Expect(hash(a)).NotTo(Equal(hash(c)))
+
+	changed := b.Clone()
+	changed.Env.Set("CGO_ENABLED", "1")
+	NewWithT(t).Expect(b.Equal(changed, d.Stages)).To(BeFalse())
+	NewWithT(t).Expect(hash(b)).NotTo(Equal(hash(changed)))
+
+	NewWithT(t).Expect(a.Equal(nil, d.Stages)).To(BeFalse())
+	NewWithT(t).Expect((*Stage)(nil).Equal(nil, d.Stages)).To(BeTrue())
+
+	t.Run("references to stages", func(t *testing.T) {
+		release := d.Stages["release"]
+		before := hash(release)
+
+		NewWithT(t).Expect(release.content(d.Stages)).To(ContainSubstring("COPY --from=builder /go/src/app /usr/bin/"))
+
+		NewWithT(t).Expect(WriteToDockerfile(bytes.NewBuffer(nil), *d)).To(BeNil())
+		NewWithT(t).Expect(hash(release)).To(Equal(before))
+
+		fresh := &Stage{From: "alpine", Copy: CopyValues(Values{"builder:./app": "/usr/bin/"})}
+		NewWithT(t).Expect(release.Equal(fresh, d.Stages)).To(BeTrue())
+
+		_, err := release.HashIn(nil)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("alone", func(t *testing.T) {
+		NewWithT(t).Expect(a.Hash()).To(Equal(b.Hash()))
+		NewWithT(t).Expect(a.Hash()).NotTo(Equal(c.Hash()))
+		NewWithT(t).Expect(a.Hash()).To(HavePrefix("sha256:"))
+
+		release := d.Stages["release"]
+		fresh := &Stage{From: "alpine", Copy: CopyValues(Values{"builder:./app": "/usr/bin/"})}
+		NewWithT(t).Expect(release.Hash()).To(Equal(fresh.Hash()))
+
+		fresh.Copy = CopyValues(Values{"builder:./bin": "/usr/bin/"})
+		NewWithT(t).Expect(release.Hash()).NotTo(Equal(fresh.Hash()))
+	})
+}
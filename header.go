@@ -0,0 +1,55 @@
+package dockerfileyml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// Header is the comment block written on top of generated Dockerfile,
+// in form of Go generated code, which tools and reviewers recognize.
+type Header struct {
+	// Source is path of spec generated from
+	Source string
+	// Version of dockerfileyml, written when not empty
+	Version string
+	// Spec is content of spec, sha256 of which is written when not nil,
+	// to tell whether Dockerfile is generated from current spec.
+	Spec []byte
+}
+
+// WithHeader writes header on top of Dockerfile, no header by default
+func WithHeader(h Header) WriteOption {
+	return func(o *writeOptions) {
+		o.header = &h
+	}
+}
+
+func (h *Header) comments() []string {
+	comments := []string{"Code generated by dockerfileyml. DO NOT EDIT."}
+
+	if h.Source != "" {
+		comments[0] = "Code generated by dockerfileyml from " + h.Source + ". DO NOT EDIT."
+	}
+
+	if h.Version != "" {
+		comments = append(comments, "dockerfileyml version: "+h.Version)
+	}
+
+	if h.Spec != nil {
+		sum := sha256.Sum256(h.Spec)
+		comments = append(comments, "spec sha256: "+hex.EncodeToString(sum[:]))
+	}
+
+	return comments
+}
+
+func writeHeader(w io.Writer, h *Header) error {
+	for _, comment := range h.comments() {
+		if _, err := io.WriteString(w, "# "+comment+"\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,32 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWithHeader(t *testing.T) {
+	d := Dockerfile{Stage: Stage{From: "alpine"}}
+
+	buf := bytes.NewBuffer(nil)
+	err := WriteToDockerfile(buf, d, WithHeader(Header{
+		Source:  "build/dockerfile.yml",
+		Version: "v0.2.0",
+		Spec:    []byte("from: alpine\n"),
+	}))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`# Code generated by dockerfileyml from build/dockerfile.yml. DO NOT EDIT.
+# dockerfileyml version: v0.2.0
+# spec sha256: bd1b49627e33c1f5a9c4886e457ea6090c81617ce8b810c0e2faa86d5ccc27da
+
+FROM alpine
+
+`))
+
+	buf.Reset()
+	err = WriteToDockerfile(buf, d, WithHeader(Header{}))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal("# Code generated by dockerfileyml. DO NOT EDIT.\n\nFROM alpine\n\n"))
+}
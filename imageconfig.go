@@ -0,0 +1,130 @@
+package dockerfileyml
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ImageConfig is config of OCI image,
+// https://github.com/opencontainers/image-spec/blob/main/config.md#properties
+type ImageConfig struct {
+	User         string              `json:"User,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	StopSignal   string              `json:"StopSignal,omitempty"`
+}
+
+// ImageConfigOf resolves OCI image config from the final stage of d.
+//
+// Only instructions of the final stage count, config of base image is not included,
+// which should be merged by the tool assembling image.
+// Values are kept as written, without expanding of args or envs.
+func ImageConfigOf(d Dockerfile) (*ImageConfig, error) {
+	stages, err := renderStages(d)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ImageConfig{}
+	envs := Values{}
+	envKeys := make([]string, 0)
+
+	for _, ins := range stages[len(stages)-1] {
+		switch ins.Key {
+		case "ENV", "LABEL":
+			keyValues, err := parseKeyValues(ins)
+			if err != nil {
+				return nil, err
+			}
+			values := keyValues.Values()
+
+			keys := make([]string, 0, len(values))
+			for k := range values {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				if ins.Key == "LABEL" {
+					if c.Labels == nil {
+						c.Labels = map[string]string{}
+					}
+					c.Labels[k] = values[k]
+					continue
+				}
+
+				if _, ok := envs[k]; !ok {
+					envKeys = append(envKeys, k)
+				}
+				envs[k] = values[k]
+			}
+		case "WORKDIR":
+			c.WorkingDir = path.Join("/", c.WorkingDir, ins.Value)
+		case "USER":
+			c.User = ins.Value
+		case "STOPSIGNAL":
+			c.StopSignal = ins.Value
+		case "EXPOSE":
+			if c.ExposedPorts == nil {
+				c.ExposedPorts = map[string]struct{}{}
+			}
+			for _, port := range strings.Fields(ins.Value) {
+				if !strings.Contains(port, "/") {
+					port = port + "/tcp"
+				}
+				c.ExposedPorts[port] = struct{}{}
+			}
+		case "VOLUME":
+			volumes, ok := ins.jsonArray()
+			if !ok {
+				volumes = strings.Fields(ins.Value)
+			}
+			if c.Volumes == nil {
+				c.Volumes = map[string]struct{}{}
+			}
+			for _, v := range volumes {
+				c.Volumes[v] = struct{}{}
+			}
+		case "ENTRYPOINT":
+			c.Entrypoint = commandOf(ins)
+			// like docker, ENTRYPOINT resets CMD of base image
+			c.Cmd = nil
+		case "CMD":
+			c.Cmd = commandOf(ins)
+		}
+	}
+
+	for _, k := range envKeys {
+		c.Env = append(c.Env, k+"="+envs[k])
+	}
+
+	return c, nil
+}
+
+// WriteToImageConfig writes OCI image config of d as JSON
+func WriteToImageConfig(w io.Writer, d Dockerfile) error {
+	c, err := ImageConfigOf(d)
+	if err != nil {
+		return err
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(c)
+}
+
+// commandOf returns args of ENTRYPOINT or CMD, shell form runs by /bin/sh -c
+func commandOf(ins instruction) []string {
+	if args, ok := ins.jsonArray(); ok {
+		return args
+	}
+	return []string{"/bin/sh", "-c", ins.Value}
+}
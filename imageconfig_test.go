@@ -0,0 +1,50 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestImageConfigOf(t *testing.T) {
+	d := Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {
+				From:       "golang",
+				WorkingDir: "/go/src",
+				Env:        KeyValuesOf(Values{"CGO_ENABLED": "0"}),
+			},
+		},
+		Stage: Stage{
+			From:       "alpine",
+			WorkingDir: "/app",
+			Env:        KeyValuesOf(Values{"PORT": "80", "GIN_MODE": "release"}),
+			User:       "nobody",
+			Expose:     []string{"80", "53/udp"},
+			Label:      KeyValuesOf(Values{"org.opencontainers.image.title": "app"}),
+			Entrypoint: []string{"/app/bin"},
+			Command:    []string{"serve"},
+			Steps: []Step{
+				{WorkingDir: "data"},
+			},
+		},
+	}
+
+	c, err := ImageConfigOf(d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(c).To(Equal(&ImageConfig{
+		User:         "nobody",
+		ExposedPorts: map[string]struct{}{"80/tcp": {}, "53/udp": {}},
+		Env:          []string{"GIN_MODE=release", "PORT=80"},
+		Entrypoint:   []string{"/app/bin"},
+		Cmd:          []string{"serve"},
+		WorkingDir:   "/app/data",
+		Labels:       map[string]string{"org.opencontainers.image.title": "app"},
+	}))
+
+	buf := bytes.NewBuffer(nil)
+	err = WriteToImageConfig(buf, d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring(`"WorkingDir": "/app/data"`))
+}
@@ -0,0 +1,140 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// resolveIncludes loads stages and snippets of included files into d,
+// relative paths are resolved from dir of including file,
+// remote sources are supported too, see fetchRemote.
+//
+// Later included files override earlier ones,
+// and stages or snippets defined in d override all included.
+func resolveIncludes(d *Dockerfile, o *readOptions, chain []string) error {
+	if len(d.Include) == 0 {
+		return nil
+	}
+
+	stages := map[string]*Stage{}
+	snippets := map[string]*Snippet{}
+
+	for _, include := range d.Include {
+		included, err := loadInclude(include, o, chain)
+		if err != nil {
+			return fmt.Errorf("include %s: %w", include, err)
+		}
+
+		for name, s := range included.Stages {
+			stages[name] = s
+		}
+		for name, snippet := range included.Snippets {
+			snippets[name] = snippet
+		}
+	}
+
+	for name, s := range d.Stages {
+		stages[name] = s
+	}
+	for name, snippet := range d.Snippets {
+		snippets[name] = snippet
+	}
+
+	if len(stages) > 0 {
+		d.Stages = stages
+	}
+	if len(snippets) > 0 {
+		d.Snippets = snippets
+	}
+
+	return nil
+}
+
+// WithIncludedFiles calls fn with absolute path of each local file included, also of nested includes,
+// like for watching them.
+func WithIncludedFiles(fn func(filename string)) ReadOption {
+	return func(o *readOptions) {
+		o.included = fn
+	}
+}
+
+func loadInclude(include string, o *readOptions, chain []string) (*Dockerfile, error) {
+	if isRemote(include) {
+		if stringIncludes(chain, include) {
+			return nil, fmt.Errorf("circular include of %s", strings.Join(append(chain, include), " -> "))
+		}
+
+		var d *Dockerfile
+
+		err := fetchRemote(include, func(data []byte, dir string) (err error) {
+			d, err = readIncluded(data, dir, o, append(chain, include))
+			return
+		})
+
+		return d, err
+	}
+
+	if o.dir == remoteDir {
+		return nil, fmt.Errorf("relative include is not supported in file over http")
+	}
+
+	filename := include
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(o.dir, filename)
+	}
+
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if stringIncludes(chain, filename) {
+		return nil, fmt.Errorf("circular include of %s", strings.Join(append(chain, filename), " -> "))
+	}
+
+	if o.included != nil {
+		o.included(filename)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return readIncluded(data, filepath.Dir(filename), o, append(chain, filename))
+}
+
+func readIncluded(data []byte, dir string, o *readOptions, chain []string) (*Dockerfile, error) {
+	included := *o
+	included.dir = dir
+	included.includes = chain
+	included.filename = chain[len(chain)-1]
+	// profiles are applied to the including spec only
+	included.profiles = nil
+	included.vcs = nil
+
+	d, err := ReadFromYAML(bytes.NewReader(data), func(opts *readOptions) {
+		*opts = included
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rest := *d
+	rest.Version = 0
+	rest.Include = nil
+	rest.Stages = nil
+	rest.Snippets = nil
+	rest.positions = nil
+	rest.comments = nil
+
+	if !reflect.ValueOf(rest).IsZero() {
+		return nil, fmt.Errorf("only stages and snippets could be included")
+	}
+
+	return d, nil
+}
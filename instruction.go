@@ -0,0 +1,57 @@
+package dockerfileyml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// instruction is one instruction of Dockerfile
+type instruction struct {
+	Key   string
+	Flags []string
+	Value string
+	Line  int
+
+	// Comments are written as # lines before instruction
+	Comments []string
+	// Attached instruction is written without blank line after,
+	// like ARG of build-in args before the instruction using them
+	Attached bool
+	// Stage is name of stage rendered from, empty for the main stage
+	Stage string
+	// Path is yaml path of field rendered from, like stages.builder.run[0]
+	Path string
+	// Commands are scripts joined into Value of RUN by Join after Prelude, for formatting of scripts
+	Commands []string
+	Join     string
+	Prelude  string
+	// NoJoin RUN is not merged with adjacent ones, see Script.NoJoin
+	NoJoin bool
+	// Pairs are key=value joined into Value of ENV, for folding of values
+	Pairs []string
+}
+
+func (ins *instruction) String() string {
+	parts := append([]string{ins.Key}, ins.Flags...)
+	if ins.Value != "" {
+		parts = append(parts, ins.Value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// jsonArray returns values when Value is in exec form
+func (ins *instruction) jsonArray() ([]string, bool) {
+	if !strings.HasPrefix(ins.Value, "[") {
+		return nil, false
+	}
+	values := make([]string, 0)
+	if err := json.Unmarshal([]byte(ins.Value), &values); err != nil {
+		return nil, false
+	}
+	return values, true
+}
+
+func (ins *instruction) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s: %s", ins.Line, ins.Key, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,195 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// KeyValue is one key with value of KeyValues
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// KeyValues are keys with values in order, for env, arg and label, which are written in order,
+// since values may refer keys before, like
+//
+//	env:
+//	  APP_HOME: /app
+//	  PATH: ${APP_HOME}/bin:${PATH}
+//
+// In yaml it is a mapping, decoded in order of keys.
+//
+// Env, Arg and Label of Stage and Step are KeyValues since v1.0.0, Go callers assigning Values wrap them by KeyValuesOf.
+type KeyValues []KeyValue
+
+var typeKeyValues = reflect.TypeOf(KeyValues{})
+
+// KeyValuesOf returns KeyValues of values sorted by keys
+func KeyValuesOf(values Values) KeyValues {
+	if values == nil {
+		return nil
+	}
+	kv := make(KeyValues, 0, len(values))
+	for _, key := range sortedValueKeys(values) {
+		kv = append(kv, KeyValue{Key: key, Value: values[key]})
+	}
+	return kv
+}
+
+// Get returns value of key
+func (kv KeyValues) Get(key string) (string, bool) {
+	for i := range kv {
+		if kv[i].Key == key {
+			return kv[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets value of key in place, or appends key when it is not in kv
+func (kv *KeyValues) Set(key string, value string) {
+	for i := range *kv {
+		if (*kv)[i].Key == key {
+			(*kv)[i].Value = value
+			return
+		}
+	}
+	*kv = append(*kv, KeyValue{Key: key, Value: value})
+}
+
+// Keys returns keys in order
+func (kv KeyValues) Keys() []string {
+	keys := make([]string, len(kv))
+	for i := range kv {
+		keys[i] = kv[i].Key
+	}
+	return keys
+}
+
+// Values returns keys with values as Values, without order
+func (kv KeyValues) Values() Values {
+	if kv == nil {
+		return nil
+	}
+	values := make(Values, len(kv))
+	for i := range kv {
+		values[kv[i].Key] = kv[i].Value
+	}
+	return values
+}
+
+// merge returns kv with keys of overlay, values of overlay win in place, and new keys are appended in order of overlay
+func (kv KeyValues) merge(overlay KeyValues) KeyValues {
+	if len(kv) == 0 {
+		return overlay
+	}
+	if len(overlay) == 0 {
+		return kv
+	}
+	merged := append(make(KeyValues, 0, len(kv)+len(overlay)), kv...)
+	for _, item := range overlay {
+		merged.Set(item.Key, item.Value)
+	}
+	return merged
+}
+
+func (kv *KeyValues) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// values are decoded as Values, which takes scalars of any type as strings
+	values := Values{}
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+
+	items := yaml.MapSlice{}
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+
+	decoded := make(KeyValues, 0, len(values))
+	added := map[string]bool{}
+
+	for _, item := range items {
+		key := fmt.Sprint(item.Key)
+		if value, ok := values[key]; ok && !added[key] {
+			decoded = append(decoded, KeyValue{Key: key, Value: value})
+			added[key] = true
+		}
+	}
+
+	// keys written in other forms, like yes for true
+	rest := make([]string, 0)
+	for key := range values {
+		if !added[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	for _, key := range rest {
+		decoded = append(decoded, KeyValue{Key: key, Value: values[key]})
+	}
+
+	*kv = decoded
+	return nil
+}
+
+func (kv KeyValues) MarshalYAML() (interface{}, error) {
+	items := make(yaml.MapSlice, len(kv))
+	for i := range kv {
+		items[i] = yaml.MapItem{Key: kv[i].Key, Value: kv[i].Value}
+	}
+	return items, nil
+}
+
+// orderKeyValues orders KeyValues of v decoded from yaml by positions of keys,
+// since documents are decoded through mappings without order, see decodeDocument.
+// keys without positions, like ones added by migration, are kept after them.
+func orderKeyValues(v reflect.Value, path string, positions Positions) {
+	if len(positions) == 0 {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			orderKeyValues(v.Elem(), path, positions)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if stringIncludes(strings.Split(f.Tag.Get("yaml"), ",")[1:], "inline") {
+				orderKeyValues(v.Field(i), path, positions)
+				continue
+			}
+			orderKeyValues(v.Field(i), fieldPath(path, yamlFieldName(f)), positions)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			orderKeyValues(v.MapIndex(key), yamlPath(path, key.String()), positions)
+		}
+	case reflect.Slice:
+		if v.Type() == typeKeyValues {
+			kv := v.Interface().(KeyValues)
+			sort.SliceStable(kv, func(i, j int) bool {
+				a, aok := positions[yamlPath(path, kv[i].Key)]
+				b, bok := positions[yamlPath(path, kv[j].Key)]
+				if !aok || !bok {
+					return aok && !bok
+				}
+				return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+			})
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			orderKeyValues(v.Index(i), path+indexPath(i), positions)
+		}
+	}
+}
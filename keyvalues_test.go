@@ -0,0 +1,76 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+func TestKeyValues(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		kv := KeyValues{}
+		NewWithT(t).Expect(yaml.Unmarshal([]byte("{B: 1, A: yes, C: '${B}'}"), &kv)).To(BeNil())
+		NewWithT(t).Expect(kv).To(Equal(KeyValues{{Key: "B", Value: "1"}, {Key: "A", Value: "yes"}, {Key: "C", Value: "${B}"}}))
+
+		data, err := yaml.Marshal(kv)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(string(data)).To(Equal("B: \"1\"\nA: \"yes\"\nC: ${B}\n"))
+	})
+
+	t.Run("built in Go", func(t *testing.T) {
+		d := Dockerfile{}
+		d.From = "alpine"
+		d.Env = KeyValues{{Key: "APP_HOME", Value: "/app"}, {Key: "PATH", Value: "${APP_HOME}/bin:${PATH}"}, {Key: "MODE", Value: "production"}}
+
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, d)).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal(`FROM alpine
+
+ENV APP_HOME=/app
+
+ENV PATH=${APP_HOME}/bin:${PATH} MODE=production
+
+`))
+	})
+
+	t.Run("merged", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  base:
+    from: golang
+    env: {Z: "1", A: "2"}
+  builder:
+    extends: base
+    env: {A: "3", M: "${Z}"}
+from: alpine
+`))
+		NewWithT(t).Expect(err).To(BeNil())
+
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, *d)).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`FROM golang AS builder
+
+ENV Z=1 A=3
+
+ENV M=${Z}
+`))
+	})
+
+	t.Run("duplicated", func(t *testing.T) {
+		d := Dockerfile{}
+		d.From = "alpine"
+		d.Env = KeyValues{{Key: "A", Value: "1"}}
+		d.Env = append(d.Env, KeyValue{Key: "A", Value: "2"})
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+
+		d.Env = KeyValues{{Key: "A", Value: "1"}}
+		d.Env.Set("A", "2")
+		NewWithT(t).Expect(d.Env).To(Equal(KeyValues{{Key: "A", Value: "2"}}))
+	})
+}
@@ -0,0 +1,103 @@
+package dockerfileyml
+
+import (
+	"strconv"
+	"strings"
+)
+
+// StageLayers is count of layers of stage which modify filesystem, by RUN, COPY and ADD
+type StageLayers struct {
+	// Stage is name of stage, empty for the main stage
+	Stage string `json:"stage"`
+	// Layers of the stage itself
+	Layers int `json:"layers"`
+	// Depth is layers including ones of stages it is from, layers of base images are not counted
+	Depth int `json:"depth"`
+}
+
+// CountLayers returns layers of stages written in order,
+// options of writing which change layers are applied, like WithMergedRuns.
+func CountLayers(d Dockerfile, opts ...WriteOption) ([]StageLayers, error) {
+	o := newWriteOptions(opts)
+
+	stages, err := renderStages(d)
+	if err != nil {
+		return nil, locateError(err, d.positions)
+	}
+
+	list := make([]StageLayers, 0, len(stages))
+	depths := map[string]int{}
+
+	for _, instructions := range stages {
+		if o.mergedRuns {
+			instructions = mergeRuns(instructions)
+		}
+
+		layers := StageLayers{}
+
+		for i, ins := range instructions {
+			switch ins.Key {
+			case "FROM":
+				if i == 0 {
+					layers.Stage = ins.Stage
+				}
+				// from another stage, like FROM builder AS test
+				if words := strings.Fields(strings.SplitN(ins.Value, " AS ", 2)[0]); len(words) > 0 {
+					layers.Depth += depths[words[len(words)-1]]
+				}
+			case "RUN", "COPY", "ADD":
+				layers.Layers++
+			}
+		}
+
+		layers.Depth += layers.Layers
+		depths[layers.Stage] = layers.Depth
+
+		list = append(list, layers)
+	}
+
+	return list, nil
+}
+
+// DefaultMaxLayers is threshold of LayerRule by default,
+// which is below the limit of 127 layers of overlay filesystem, with room for layers of base images.
+const DefaultMaxLayers = 100
+
+// LayerRule warns stages with depth of layers more than Max, see CountLayers,
+// since some registries and older runtimes behave badly with very deep images.
+type LayerRule struct {
+	// Max depth of layers, DefaultMaxLayers when zero
+	Max int
+}
+
+func (r *LayerRule) Check(d *Dockerfile) (findings []Finding) {
+	max := r.Max
+	if max <= 0 {
+		max = DefaultMaxLayers
+	}
+
+	list, err := CountLayers(*d)
+	if err != nil {
+		return nil
+	}
+
+	for _, layers := range list {
+		if layers.Depth <= max {
+			continue
+		}
+
+		path := ""
+		if layers.Stage != "" {
+			path = "stages." + layers.Stage
+		}
+
+		findings = append(findings, Finding{
+			Rule:     "layer-count",
+			Severity: SeverityWarning,
+			Path:     path,
+			Message:  strconv.Itoa(layers.Depth) + " layers of RUN, COPY and ADD, more than " + strconv.Itoa(max) + ", merge RUN or copy fewer times",
+		})
+	}
+
+	return
+}
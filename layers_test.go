@@ -0,0 +1,51 @@
+package dockerfileyml
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCountLayers(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang
+    workdir: /go/src
+    copy: {./go.mod: ./}
+    run: [go mod download, {cmd: go build ./..., no-join: true}]
+  test:
+    from: builder
+    run: [go test ./...]
+from: alpine
+copy:
+  builder:/go/bin/app: /bin/app
+steps:
+  - run: [apk add curl]
+  - run: [apk add git]
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	list, err := CountLayers(*d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(list).To(Equal([]StageLayers{
+		{Stage: "builder", Layers: 3, Depth: 3},
+		{Stage: "test", Layers: 1, Depth: 4},
+		{Stage: "", Layers: 3, Depth: 3},
+	}))
+
+	list, err = CountLayers(*d, WithMergedRuns())
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(list[2]).To(Equal(StageLayers{Stage: "", Layers: 2, Depth: 2}))
+
+	t.Run("rule", func(t *testing.T) {
+		NewWithT(t).Expect((&LayerRule{}).Check(d)).To(BeEmpty())
+		NewWithT(t).Expect((&LayerRule{Max: 3}).Check(d)).To(Equal([]Finding{{
+			Rule:     "layer-count",
+			Severity: SeverityWarning,
+			Path:     "stages.test",
+			Message:  "4 layers of RUN, COPY and ADD, more than 3, merge RUN or copy fewer times",
+		}}))
+	})
+}
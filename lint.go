@@ -0,0 +1,204 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity of finding
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+	// SeverityOff disables rule
+	SeverityOff Severity = "off"
+)
+
+// UnmarshalYAML accepts warn as warning too,
+// and false as off, since unquoted off is false in yaml 1.1.
+func (s *Severity) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	off := true
+	if err := unmarshal(&off); err == nil && !off {
+		*s = SeverityOff
+		return nil
+	}
+
+	v := ""
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v == "warn" {
+		v = string(SeverityWarning)
+	}
+	if err := validateSeverity(Severity(v)); err != nil {
+		return err
+	}
+	*s = Severity(v)
+	return nil
+}
+
+func validateSeverity(s Severity) error {
+	switch s {
+	case SeverityError, SeverityWarning, SeverityInfo, SeverityOff:
+		return nil
+	}
+	return fmt.Errorf("invalid severity %s, should be one of %s, %s, %s or %s", s, SeverityError, SeverityWarning, SeverityInfo, SeverityOff)
+}
+
+// Finding is a problem found by rule
+type Finding struct {
+	// Rule is id of rule, like shell-form-entrypoint
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Path is yaml path of field, like stages.builder.run[0], empty for the document
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+	// Position of path in source yaml, when read from yaml
+	Position Position `json:"position"`
+}
+
+func (f Finding) String() string {
+	s := ""
+	if f.Position.IsValid() {
+		s = f.Position.String() + ": "
+	}
+	if f.Path == "" {
+		return s + fmt.Sprintf("%s[%s]: %s", f.Severity, f.Rule, f.Message)
+	}
+	return s + fmt.Sprintf("%s[%s] %s: %s", f.Severity, f.Rule, f.Path, f.Message)
+}
+
+// Rule checks Dockerfile spec
+type Rule interface {
+	Check(d *Dockerfile) []Finding
+}
+
+// RuleFunc adapts func as Rule
+type RuleFunc func(d *Dockerfile) []Finding
+
+func (fn RuleFunc) Check(d *Dockerfile) []Finding {
+	return fn(d)
+}
+
+// DefaultRules are rules of Lint when no rules given
+var DefaultRules = append([]Rule{RuleFunc(checkShellForm), RuleFunc(checkSecretInEnv)}, builtinRules...)
+
+// RegisterRule adds rule to DefaultRules, should be called in init
+func RegisterRule(rule Rule) {
+	DefaultRules = append(DefaultRules, rule)
+}
+
+// Lint checks d by rules, DefaultRules when no rules given,
+// severities of findings are overridden by lint of d,
+// findings are sorted by path and rule.
+func Lint(d *Dockerfile, rules ...Rule) []Finding {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+
+	findings := make([]Finding, 0)
+
+	for _, rule := range rules {
+		for _, f := range rule.Check(d) {
+			if s, ok := d.Lint[f.Rule]; ok {
+				f.Severity = s
+			}
+			if f.Severity != SeverityOff {
+				if !f.Position.IsValid() && f.Path != "" {
+					f.Position = d.positions.Of(f.Path)
+				}
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+
+	return findings
+}
+
+// WithLint checks spec by DefaultRules before writing, and fails on findings of error,
+// severities override lint of spec.
+func WithLint(severities map[string]Severity) WriteOption {
+	return func(o *writeOptions) {
+		o.lint = true
+		o.severities = severities
+	}
+}
+
+// lintErrors returns error of findings of error severity
+func lintErrors(d Dockerfile, severities map[string]Severity) error {
+	if len(severities) > 0 {
+		merged := map[string]Severity{}
+		for rule, s := range d.Lint {
+			merged[rule] = s
+		}
+		for rule, s := range severities {
+			if err := validateSeverity(s); err != nil {
+				return fmt.Errorf("lint of %s: %w", rule, err)
+			}
+			merged[rule] = s
+		}
+		d.Lint = merged
+	}
+
+	errors := make([]string, 0)
+
+	for _, f := range Lint(&d) {
+		if f.Severity == SeverityError {
+			errors = append(errors, f.String())
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("lint failed:\n%s", strings.Join(errors, "\n"))
+	}
+
+	return nil
+}
+
+// eachStage calls fn for the main stage and stages in order of names, with yaml path of stage
+func eachStage(d *Dockerfile, fn func(path string, s *Stage)) {
+	fn("", &d.Stage)
+
+	names := make([]string, 0, len(d.Stages))
+	for name := range d.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fn(yamlPath("stages", name), d.Stages[name])
+	}
+}
+
+// fieldPath joins yaml path of field
+func fieldPath(path string, field string) string {
+	if path == "" || strings.HasPrefix(field, "[") {
+		return path + field
+	}
+	return path + "." + field
+}
+
+// checkShellForm checks entrypoint in shell form, which is written as warnings in Dockerfile too
+func checkShellForm(d *Dockerfile) (findings []Finding) {
+	eachStage(d, func(path string, s *Stage) {
+		for _, warning := range s.shellFormWarnings() {
+			findings = append(findings, Finding{
+				Rule:     "shell-form-entrypoint",
+				Severity: SeverityWarning,
+				Path:     fieldPath(path, "entrypoint"),
+				Message:  warning,
+			})
+		}
+	})
+	return
+}
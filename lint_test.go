@@ -0,0 +1,85 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLint(t *testing.T) {
+	d := &Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {
+				From:           "golang",
+				Entrypoint:     []string{"app"},
+				EntrypointForm: FormShell,
+				StopSignal:     "SIGQUIT",
+			},
+		},
+		Stage: Stage{
+			From: "alpine",
+		},
+	}
+
+	t.Run("default rules", func(t *testing.T) {
+		findings := Lint(d)
+		NewWithT(t).Expect(findings).To(HaveLen(1))
+		NewWithT(t).Expect(findings[0].String()).To(Equal("warning[shell-form-entrypoint] stages.builder.entrypoint: shell form ENTRYPOINT runs under /bin/sh -c, STOPSIGNAL SIGQUIT will not reach the process"))
+	})
+
+	t.Run("custom rules", func(t *testing.T) {
+		findings := Lint(d, RuleFunc(func(d *Dockerfile) (findings []Finding) {
+			eachStage(d, func(path string, s *Stage) {
+				if s.From == "golang" {
+					findings = append(findings, Finding{Rule: "no-golang", Severity: SeverityError, Path: fieldPath(path, "from"), Message: "use golang of version"})
+				}
+			})
+			return
+		}))
+		NewWithT(t).Expect(findings).To(Equal([]Finding{
+			{Rule: "no-golang", Severity: SeverityError, Path: "stages.builder.from", Message: "use golang of version"},
+		}))
+	})
+
+	t.Run("paths of stages with dots", func(t *testing.T) {
+		paths := make([]string, 0)
+		eachStage(&Dockerfile{Stages: map[string]*Stage{"build.1": {From: "golang"}}}, func(path string, s *Stage) {
+			paths = append(paths, fieldPath(path, "from"))
+		})
+		NewWithT(t).Expect(paths).To(Equal([]string{"from", `stages["build.1"].from`}))
+	})
+
+	t.Run("severities", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader(`
+from: alpine
+run:
+  - cd /tmp && sudo make
+lint:
+  cd-in-run: error
+  sudo: off
+  apt-cleanup: warn
+`))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Lint["apt-cleanup"]).To(Equal(SeverityWarning))
+
+		findings := Lint(d)
+		NewWithT(t).Expect(findings).To(Equal([]Finding{
+			{Rule: "cd-in-run", Severity: SeverityError, Path: "run[0]", Message: "use workdir to switch directory instead of cd", Position: Position{Line: 4, Column: 5}},
+		}))
+
+		err = WriteToDockerfile(bytes.NewBuffer(nil), *d, WithLint(nil))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("error[cd-in-run] run[0]"))
+
+		err = WriteToDockerfile(bytes.NewBuffer(nil), *d, WithLint(map[string]Severity{"cd-in-run": SeverityWarning}))
+		NewWithT(t).Expect(err).To(BeNil())
+
+		err = WriteToDockerfile(bytes.NewBuffer(nil), *d)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		_, err = ReadFromYAML(strings.NewReader("from: alpine\nlint:\n  sudo: fatal\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
@@ -0,0 +1,115 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ExecRule is a rule checking by external command, for policies of organization in any language.
+//
+// The spec is written to stdin of command in yaml,
+// and findings should be written to stdout in JSON array, like
+//
+//	[{"rule": "org-registry", "severity": "error", "path": "from", "message": "use images of registry.corp.example"}]
+//
+// Severity defaults to warning. Failure of command is reported as finding of error.
+type ExecRule struct {
+	Command string
+	Args    []string
+}
+
+func (r *ExecRule) Check(d *Dockerfile) []Finding {
+	findings, err := r.run(d)
+	if err != nil {
+		return []Finding{{
+			Rule:     "plugin",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("%s: %s", r.Command, err),
+		}}
+	}
+	return findings
+}
+
+func (r *ExecRule) run(d *Dockerfile) ([]Finding, error) {
+	spec, err := yaml.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+
+	cmd := exec.Command(r.Command, r.Args...)
+	cmd.Stdin = bytes.NewReader(spec)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	findings := make([]Finding, 0)
+
+	if err := json.Unmarshal(stdout.Bytes(), &findings); err != nil {
+		return nil, fmt.Errorf("invalid output, should be JSON array of findings: %w", err)
+	}
+
+	for i := range findings {
+		if findings[i].Severity == "" {
+			findings[i].Severity = SeverityWarning
+		}
+		if err := validateSeverity(findings[i].Severity); err != nil {
+			return nil, err
+		}
+		if findings[i].Rule == "" {
+			findings[i].Rule = filepath.Base(r.Command)
+		}
+	}
+
+	return findings, nil
+}
+
+// LoadRulePlugins returns rules of executable files in dir, in order of names,
+// a rule bundle could be a dir of scripts, see ExecRule.
+func LoadRulePlugins(dir string) ([]Rule, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	rules := make([]Rule, 0)
+
+	for _, f := range files {
+		if f.IsDir() || f.Mode()&0111 == 0 || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+
+		command, err := filepath.Abs(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, &ExecRule{Command: command})
+	}
+
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no executable rules in %s", dir)
+	}
+
+	return rules, nil
+}
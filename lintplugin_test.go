@@ -0,0 +1,38 @@
+package dockerfileyml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRulePlugins(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "rules")
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "org-registry"), []byte(`#!/bin/sh
+if grep -q '^from: registry.corp.example/' ; then
+  echo '[]'
+else
+  echo '[{"severity": "error", "path": "from", "message": "use images of registry.corp.example"}]'
+fi
+`), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, "broken"), []byte("#!/bin/sh\necho oops >&2\nexit 1\n"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("rules of org\n"), 0644)
+
+	rules, err := LoadRulePlugins(dir)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(rules).To(HaveLen(2))
+
+	findings := Lint(&Dockerfile{Stage: Stage{From: "alpine"}}, rules...)
+	NewWithT(t).Expect(findings).To(HaveLen(2))
+	NewWithT(t).Expect(findings[0].Rule).To(Equal("plugin"))
+	NewWithT(t).Expect(findings[0].Message).To(ContainSubstring("exit status 1: oops"))
+	NewWithT(t).Expect(findings[1]).To(Equal(Finding{Rule: "org-registry", Severity: SeverityError, Path: "from", Message: "use images of registry.corp.example"}))
+
+	findings = Lint(&Dockerfile{Stage: Stage{From: "registry.corp.example/alpine"}}, rules[1])
+	NewWithT(t).Expect(findings).To(BeEmpty())
+}
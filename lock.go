@@ -0,0 +1,277 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LockfileName is the conventional name of lockfile, next to spec
+const LockfileName = "dockerfile.lock"
+
+// Lockfile records digests of base images and checksums of remote sources of ADD,
+// so generation is reproducible, and updates are explicit by UpdateLockfile.
+//
+//	version: 1
+//	images:
+//	  busybox:1.36: sha256:<hex>
+//	sources:
+//	  https://example.com/app.tar.gz: sha256:<hex>
+type Lockfile struct {
+	Version int    `yaml:"version"`
+	Images  Values `yaml:"images,omitempty"`
+	Sources Values `yaml:"sources,omitempty"`
+}
+
+// ReadLockfile reads lockfile from path
+func ReadLockfile(path string) (*Lockfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lockfile{}
+	if err := yaml.UnmarshalStrict(data, l); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if l.Version != 1 {
+		return nil, fmt.Errorf("%s: unsupported version %d of lockfile", path, l.Version)
+	}
+
+	return l, nil
+}
+
+// WriteLockfile writes lockfile to path, skips writing when unchanged
+func WriteLockfile(path string, l *Lockfile) error {
+	buf := bytes.NewBuffer(nil)
+
+	if err := yaml.NewEncoder(buf).Encode(l); err != nil {
+		return err
+	}
+
+	return WriteFileIfChanged(path, buf.Bytes())
+}
+
+// UpdateLockfile resolves digests of images and sources of documents missing in current,
+// entries of current are kept unless in names to update, entries no longer used are dropped.
+// All entries are resolved when current is nil.
+func UpdateLockfile(ctx context.Context, current *Lockfile, list []*Dockerfile, r *Resolver, names ...string) (*Lockfile, error) {
+	images, sources, err := lockables(list)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		current = &Lockfile{}
+	}
+
+	keep := func(locked Values, name string) (string, bool) {
+		if stringIncludes(names, name) {
+			return "", false
+		}
+		v, ok := locked[name]
+		return v, ok
+	}
+
+	l := &Lockfile{Version: 1}
+
+	for _, image := range images {
+		digest, ok := keep(current.Images, image)
+		if !ok {
+			digest, err = r.Digest(ctx, image)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if l.Images == nil {
+			l.Images = Values{}
+		}
+		l.Images[image] = digest
+	}
+
+	for _, src := range sources {
+		checksum, ok := keep(current.Sources, src)
+		if !ok {
+			checksum, err = r.checksum(ctx, src)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if l.Sources == nil {
+			l.Sources = Values{}
+		}
+		l.Sources[src] = checksum
+	}
+
+	return l, nil
+}
+
+// Verify checks all images and sources of documents are locked
+func (l *Lockfile) Verify(list ...*Dockerfile) error {
+	images, sources, err := lockables(list)
+	if err != nil {
+		return err
+	}
+
+	missing := make([]string, 0)
+
+	for _, image := range images {
+		if _, ok := l.Images[image]; !ok {
+			missing = append(missing, image)
+		}
+	}
+
+	for _, src := range sources {
+		if _, ok := l.Sources[src]; !ok {
+			missing = append(missing, src)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("not locked: %s, lockfile should be updated", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// Apply returns a new Dockerfile with images pinned by locked digests,
+// and remote sources of ADD verified by locked checksums.
+func (l *Lockfile) Apply(d *Dockerfile) (*Dockerfile, error) {
+	if err := l.Verify(d); err != nil {
+		return nil, err
+	}
+
+	return mapStages(d, func(s *Stage, stages []string) error {
+		from, err := pinFrom(s.From, stages, func(image string) (string, error) {
+			return image + "@" + l.Images[image], nil
+		})
+		if err != nil {
+			return err
+		}
+		s.From = from
+
+		s.Add = l.checksumAdd(s.Add)
+		for i := range s.Steps {
+			s.Steps[i].Add = l.checksumAdd(s.Steps[i].Add)
+		}
+
+		return nil
+	})
+}
+
+// checksumAdd adds --checksum to ADD of remote sources
+func (l *Lockfile) checksumAdd(add Values) Values {
+	if add == nil {
+		return nil
+	}
+
+	values := Values{}
+
+	for src, dest := range add {
+		if url := remoteSourceOfAdd(src); url != "" {
+			src = "--checksum=" + l.Sources[url] + " " + src
+		}
+		values[src] = dest
+	}
+
+	return values
+}
+
+// lockables returns images and remote sources of documents to lock, in order
+func lockables(list []*Dockerfile) (images []string, sources []string, err error) {
+	for _, d := range list {
+		_, err := mapStages(d, func(s *Stage, stages []string) error {
+			if image := imageOfFrom(s.From); shouldPin(image, stages) && !stringIncludes(images, image) {
+				images = append(images, image)
+			}
+
+			adds := []Values{s.Add}
+			for _, step := range s.Steps {
+				adds = append(adds, step.Add)
+			}
+
+			for _, add := range adds {
+				for src := range add {
+					if url := remoteSourceOfAdd(src); url != "" && !stringIncludes(sources, url) {
+						sources = append(sources, url)
+					}
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.Strings(images)
+	sort.Strings(sources)
+
+	return images, sources, nil
+}
+
+// remoteSourceOfAdd returns url of source of ADD to lock,
+// empty when local, from git, with args, or already with --checksum.
+func remoteSourceOfAdd(src string) string {
+	words := strings.Fields(src)
+	if len(words) == 0 {
+		return ""
+	}
+
+	url := words[len(words)-1]
+
+	if !(strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")) ||
+		strings.HasSuffix(url, ".git") ||
+		strings.Contains(url, "$") {
+		return ""
+	}
+
+	for _, flag := range words[0 : len(words)-1] {
+		if strings.HasPrefix(flag, "--checksum=") {
+			return ""
+		}
+	}
+
+	return url
+}
+
+// checksum downloads url to compute sha256, in timeout of r like manifests
+func (r *Resolver) checksum(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch %s failed: %s", url, resp.Status)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,115 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLockfile(t *testing.T) {
+	s := newTestRegistry(t)
+	defer s.Close()
+
+	files := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("content"))
+	}))
+	defer files.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+	image := host + "/library/busybox:1.36"
+
+	d := &Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {From: image},
+		},
+		Stage: Stage{
+			From: "builder",
+			Add:  Values{files.URL + "/app.tar.gz": "/app/"},
+		},
+	}
+
+	l, err := UpdateLockfile(context.Background(), nil, []*Dockerfile{d}, newTestResolver())
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(l).To(Equal(&Lockfile{
+		Version: 1,
+		Images:  Values{image: testDigest},
+		Sources: Values{files.URL + "/app.tar.gz": "sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"},
+	}))
+
+	t.Run("write and read", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "lock")
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, LockfileName)
+
+		err := WriteLockfile(path, l)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		read, err := ReadLockfile(path)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(read).To(Equal(l))
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		locked, err := l.Apply(d)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *locked)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("FROM " + image + "@" + testDigest + " AS builder"))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("ADD --checksum=sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73 " + files.URL + "/app.tar.gz /app/"))
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		changed := Merge(d, &Dockerfile{Stages: map[string]*Stage{"tools": {From: "alpine:3.18"}}})
+
+		err := l.Verify(changed)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("not locked: alpine:3.18"))
+	})
+
+	t.Run("update kept and dropped", func(t *testing.T) {
+		current := &Lockfile{
+			Version: 1,
+			Images:  Values{image: "sha256:old", "alpine:3.17": "sha256:unused"},
+			Sources: l.Sources,
+		}
+
+		updated, err := UpdateLockfile(context.Background(), current, []*Dockerfile{d}, newTestResolver())
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(updated.Images).To(Equal(Values{image: "sha256:old"}))
+
+		updated, err = UpdateLockfile(context.Background(), current, []*Dockerfile{d}, newTestResolver(), image)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(updated.Images).To(Equal(Values{image: testDigest}))
+	})
+}
+
+func TestChecksumTimeout(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	files := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case <-done:
+		case <-req.Context().Done():
+		}
+	}))
+	defer files.Close()
+
+	r := &Resolver{Timeout: 50 * time.Millisecond}
+
+	_, err := r.checksum(context.Background(), files.URL+"/app.tar.gz")
+	NewWithT(t).Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+}
@@ -0,0 +1,89 @@
+package dockerfileyml
+
+import (
+	"reflect"
+)
+
+// mergeValue returns a new value of overlay merged onto base.
+//
+// maps are merged with keys of overlay win, and stages of same name are merged too;
+// key values are merged like maps, with keys of base in place and new keys of overlay appended;
+// slices are appended, unless field tagged with merge:"replace";
+// others of overlay win when not zero.
+func mergeValue(base reflect.Value, overlay reflect.Value) reflect.Value {
+	if base.Type() == typeKeyValues {
+		return reflect.ValueOf(base.Interface().(KeyValues).merge(overlay.Interface().(KeyValues)))
+	}
+
+	switch base.Kind() {
+	case reflect.Struct:
+		merged := reflect.New(base.Type()).Elem()
+
+		for i := 0; i < base.NumField(); i++ {
+			field := base.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			if field.Type.Kind() == reflect.Slice && field.Tag.Get("merge") == "replace" {
+				if overlay.Field(i).Len() > 0 {
+					merged.Field(i).Set(overlay.Field(i))
+				} else {
+					merged.Field(i).Set(base.Field(i))
+				}
+				continue
+			}
+
+			merged.Field(i).Set(mergeValue(base.Field(i), overlay.Field(i)))
+		}
+
+		return merged
+	case reflect.Ptr:
+		if base.IsNil() {
+			return overlay
+		}
+		if overlay.IsNil() {
+			return base
+		}
+		merged := reflect.New(base.Type().Elem())
+		merged.Elem().Set(mergeValue(base.Elem(), overlay.Elem()))
+		return merged
+	case reflect.Map:
+		if base.IsNil() {
+			return overlay
+		}
+		if overlay.IsNil() {
+			return base
+		}
+
+		merged := reflect.MakeMap(base.Type())
+
+		for _, key := range base.MapKeys() {
+			merged.SetMapIndex(key, base.MapIndex(key))
+		}
+
+		for _, key := range overlay.MapKeys() {
+			v := overlay.MapIndex(key)
+			if b := base.MapIndex(key); b.IsValid() && v.Kind() == reflect.Ptr {
+				v = mergeValue(b, v)
+			}
+			merged.SetMapIndex(key, v)
+		}
+
+		return merged
+	case reflect.Slice:
+		if base.Len() == 0 {
+			return overlay
+		}
+		if overlay.Len() == 0 {
+			return base
+		}
+		merged := reflect.MakeSlice(base.Type(), 0, base.Len()+overlay.Len())
+		return reflect.AppendSlice(reflect.AppendSlice(merged, base), overlay)
+	}
+
+	if overlay.IsZero() {
+		return base
+	}
+	return overlay
+}
@@ -0,0 +1,66 @@
+package dockerfileyml
+
+import (
+	"fmt"
+)
+
+// CurrentVersion is the latest version of dockerfile.yml format
+const CurrentVersion = 1
+
+type migration struct {
+	// version migrate from, to version+1
+	version int
+	migrate func(doc map[interface{}]interface{}) error
+}
+
+// migrations upgrade document step by step, should be sorted by version.
+// breaking change of format should bump CurrentVersion and append one here.
+var migrations = []migration{
+	{
+		// documents without version are of the original format,
+		// which is compatible with version 1
+		version: 0,
+		migrate: func(doc map[interface{}]interface{}) error {
+			return nil
+		},
+	},
+}
+
+// migrate upgrades yaml document to CurrentVersion before decoding
+func migrate(v interface{}) (interface{}, error) {
+	doc, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return v, nil
+	}
+
+	version := 0
+
+	if raw, ok := doc["version"]; ok {
+		n, ok := raw.(int)
+		if !ok {
+			return nil, fmt.Errorf("version should be integer, but got %v", raw)
+		}
+		version = n
+	}
+
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("version %d is newer than supported version %d, please upgrade dockerfileyml", version, CurrentVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version < version {
+			continue
+		}
+		if m.version >= CurrentVersion {
+			break
+		}
+		if err := m.migrate(doc); err != nil {
+			return nil, fmt.Errorf("migrate from version %d failed: %w", m.version, err)
+		}
+		version = m.version + 1
+	}
+
+	doc["version"] = version
+
+	return doc, nil
+}
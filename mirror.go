@@ -0,0 +1,47 @@
+package dockerfileyml
+
+import (
+	"strings"
+)
+
+// WithMirrors rewrites images to mirrors, keyed by registry or repository prefix, like
+//
+//	docker.io: mirror.corp.example
+//	ghcr.io/org: mirror.corp.example/ghcr/org
+//
+// the longest matched prefix wins, and short names are matched as docker.io/library/<name>.
+func WithMirrors(mirrors Values) WriteOption {
+	return func(o *writeOptions) {
+		o.imageMappers = append(o.imageMappers, func(image string) (string, error) {
+			return mirrorImage(image, mirrors)
+		})
+	}
+}
+
+func mirrorImage(image string, mirrors Values) (string, error) {
+	normalized, err := NormalizeImage(image)
+	if err != nil {
+		return "", err
+	}
+
+	prefix := ""
+
+	for key := range mirrors {
+		key = strings.TrimSuffix(key, "/")
+
+		if strings.HasPrefix(normalized, key+"/") && len(key) > len(prefix) {
+			prefix = key
+		}
+	}
+
+	if prefix == "" {
+		return image, nil
+	}
+
+	mirror := mirrors[prefix]
+	if mirror == "" {
+		mirror = mirrors[prefix+"/"]
+	}
+
+	return strings.TrimSuffix(mirror, "/") + strings.TrimPrefix(normalized, prefix), nil
+}
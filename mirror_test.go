@@ -0,0 +1,50 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWithMirrors(t *testing.T) {
+	mirrors := Values{
+		"docker.io":   "mirror.corp.example",
+		"ghcr.io/org": "mirror.corp.example/ghcr/org/",
+	}
+
+	cases := map[string]string{
+		"busybox:1.36":           "mirror.corp.example/library/busybox:1.36",
+		"org/app@sha256:" + hex0: "mirror.corp.example/org/app@sha256:" + hex0,
+		"ghcr.io/org/app:v1":     "mirror.corp.example/ghcr/org/app:v1",
+		"ghcr.io/other/app:v1":   "ghcr.io/other/app:v1",
+		"ghcr.io/organization/x": "ghcr.io/organization/x",
+	}
+
+	for image, expected := range cases {
+		mirrored, err := mirrorImage(image, mirrors)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(mirrored).To(Equal(expected), image)
+	}
+
+	d := Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {From: "golang:1.20"},
+		},
+		Stage: Stage{
+			From: "builder",
+			Copy: CopyValues(Values{"--from=nginx /etc/nginx/": "/etc/nginx/"}),
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := WriteToDockerfile(buf, d, WithMirrors(mirrors))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM mirror.corp.example/library/golang:1.20 AS builder
+
+FROM builder
+
+COPY --from=mirror.corp.example/library/nginx /etc/nginx/ /etc/nginx/
+
+`))
+}
@@ -0,0 +1,70 @@
+package dockerfileyml
+
+import (
+	"strings"
+)
+
+// WithMergedRuns merges adjacent RUN of each stage into one, to reduce layers,
+// like RUN of steps between which there are no other instructions.
+//
+// RUN are merged only when they have same flags, like --mount, and none of them has heredoc,
+// scripts with no-join opt out, like
+//
+//	steps:
+//	  - run: [{cmd: make test, no-join: true}]
+func WithMergedRuns() WriteOption {
+	return func(o *writeOptions) {
+		o.mergedRuns = true
+	}
+}
+
+func mergeRuns(list []instruction) []instruction {
+	merged := make([]instruction, 0, len(list))
+
+	for i := range list {
+		ins := list[i]
+
+		if n := len(merged); n > 0 && mergeableRuns(merged[n-1], ins) {
+			last := &merged[n-1]
+
+			last.Commands = append(append([]string{}, last.Commands...), ins.Commands...)
+			last.Comments = append(append([]string{}, last.Comments...), ins.Comments...)
+			last.Value = shellLines(joinScripts(last.Commands, last.Join, last.Prelude, " "))
+			continue
+		}
+
+		merged = append(merged, ins)
+	}
+
+	return merged
+}
+
+// mergeableRuns tells b could be merged into a
+func mergeableRuns(a instruction, b instruction) bool {
+	if a.Key != "RUN" || b.Key != "RUN" || a.Stage != b.Stage || a.Attached {
+		return false
+	}
+
+	// exec form, or scripts with no-join
+	if len(a.Commands) == 0 || len(b.Commands) == 0 || a.NoJoin || b.NoJoin {
+		return false
+	}
+
+	if a.Join != b.Join || a.Prelude != b.Prelude || strings.Join(a.Flags, " ") != strings.Join(b.Flags, " ") {
+		return false
+	}
+
+	return !stringSome(append(append([]string{}, a.Commands...), b.Commands...), func(command string, i int) bool {
+		return isHeredoc(command)
+	})
+}
+
+// isHeredoc tells command has heredoc, like cat <<EOF
+func isHeredoc(command string) bool {
+	for _, word := range strings.Fields(command) {
+		if strings.HasPrefix(word, "<<") && !strings.HasPrefix(word, "<<<") {
+			return true
+		}
+	}
+	return false
+}
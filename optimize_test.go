@@ -0,0 +1,54 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestMergedRuns(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+from: alpine
+run:
+  - apk add make
+  - {cmd: go mod download, mount: ["type=cache,target=/go/pkg/mod"]}
+steps:
+  - run: [make]
+  - run: [make install]
+  - run: [make clean]
+  - run: [{cmd: make test, no-join: true}]
+  - run: [make dist]
+  - copy: {./dist: /dist}
+  - run: [ls /dist]
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d, WithMergedRuns())).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM alpine
+
+RUN apk add make
+
+RUN --mount=type=cache,target=/go/pkg/mod go mod download
+
+RUN make && make install && make clean
+
+RUN make test
+
+RUN make dist
+
+COPY ./dist /dist
+
+RUN ls /dist
+
+`))
+}
+
+func TestIsHeredoc(t *testing.T) {
+	NewWithT(t).Expect(isHeredoc("cat <<EOF > /etc/motd")).To(BeTrue())
+	NewWithT(t).Expect(isHeredoc("cat <<-'EOF'")).To(BeTrue())
+	NewWithT(t).Expect(isHeredoc("grep a <<< $b")).To(BeFalse())
+	NewWithT(t).Expect(isHeredoc("echo a > b")).To(BeFalse())
+}
@@ -0,0 +1,662 @@
+package dockerfileyml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// keys which could carry --flags
+var flaggedKeys = map[string]bool{
+	"FROM": true,
+	"ADD":  true,
+	"COPY": true,
+	"RUN":  true,
+}
+
+// parser directives which are kept
+var knownDirectives = map[string]bool{
+	"syntax": true,
+	"escape": true,
+	"check":  true,
+}
+
+func parseInstructions(r io.Reader) ([]instruction, error) {
+	list, _, err := parseSource(r)
+	return list, err
+}
+
+// parseSource parses instructions and parser directives of Dockerfile,
+// bodies of heredocs are kept as they are in values of instructions, with lines of them.
+func parseSource(r io.Reader) ([]instruction, Values, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	list := make([]instruction, 0)
+	var directives Values
+
+	buf := ""
+	start := 0
+	line := 0
+	// directives are only recognized on top, before any other line
+	inDirectives := true
+	// heredocs of the instruction in buf, which are not terminated yet
+	heredocs := make([]heredoc, 0)
+
+	for scanner.Scan() {
+		line++
+
+		if len(heredocs) > 0 {
+			buf += "\n" + scanner.Text()
+			if heredocs[0].terminatedBy(scanner.Text()) {
+				heredocs = heredocs[1:]
+			}
+			if len(heredocs) == 0 {
+				list = append(list, parseInstruction(buf, start))
+				buf = ""
+			}
+			continue
+		}
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if inDirectives {
+			if key, value, ok := parseDirective(text); ok && knownDirectives[key] {
+				if key == "escape" && value != "\\" {
+					return nil, nil, fmt.Errorf("line %d: escape directive %s is unsupported, only \\ is supported", line, value)
+				}
+				if directives == nil {
+					directives = Values{}
+				}
+				directives[key] = value
+				continue
+			}
+			inDirectives = false
+		}
+
+		// comments and blank lines are skipped, even in continuation
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if buf == "" {
+			start = line
+		}
+
+		if strings.HasSuffix(text, "\\") {
+			buf += strings.TrimSpace(strings.TrimSuffix(text, "\\")) + " "
+			continue
+		}
+
+		buf += text
+
+		if heredocs = heredocsOf(buf); len(heredocs) > 0 {
+			continue
+		}
+
+		list = append(list, parseInstruction(buf, start))
+		buf = ""
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(heredocs) > 0 {
+		return nil, nil, fmt.Errorf("line %d: missing %s of heredoc", start, heredocs[0].word)
+	}
+
+	if buf != "" {
+		list = append(list, parseInstruction(buf, start))
+	}
+
+	return list, directives, nil
+}
+
+// heredoc is heredoc of instruction, like <<EOF, or <<-EOF with tabs stripped from lines
+type heredoc struct {
+	word      string
+	stripTabs bool
+}
+
+func (h heredoc) terminatedBy(line string) bool {
+	if h.stripTabs {
+		line = strings.TrimLeft(line, "\t")
+	}
+	return line == h.word
+}
+
+// heredocsOf returns heredocs of instruction in order, like <<EOF, <<-EOF or <<"EOF"
+func heredocsOf(s string) []heredoc {
+	heredocs := make([]heredoc, 0)
+
+	for _, word := range strings.Fields(s) {
+		if !strings.HasPrefix(word, "<<") || strings.HasPrefix(word, "<<<") {
+			continue
+		}
+
+		h := heredoc{word: strings.TrimPrefix(word, "<<")}
+
+		if strings.HasPrefix(h.word, "-") {
+			h.stripTabs = true
+			h.word = strings.TrimPrefix(h.word, "-")
+		}
+
+		h.word = strings.Trim(h.word, `"'`)
+
+		if h.word != "" {
+			heredocs = append(heredocs, h)
+		}
+	}
+
+	return heredocs
+}
+
+func parseInstruction(s string, line int) instruction {
+	key, value := cutWord(strings.TrimSpace(s))
+
+	ins := instruction{
+		Key:   strings.ToUpper(key),
+		Line:  line,
+		Value: value,
+	}
+
+	if flaggedKeys[ins.Key] {
+		for strings.HasPrefix(ins.Value, "--") {
+			flag, value := cutWord(ins.Value)
+			ins.Flags = append(ins.Flags, flag)
+			ins.Value = value
+		}
+	}
+
+	return ins
+}
+
+// cutWord returns the first word of s, and the rest after the whitespace following it, like spaces or tabs
+func cutWord(s string) (string, string) {
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimLeftFunc(s[i:], unicode.IsSpace)
+}
+
+// ParseDockerfile converts Dockerfile to Dockerfile spec.
+//
+// The last stage becomes the main stage, others become named stages.
+// Instructions affecting build are kept in fields while order of fields allows,
+// the rest goes to steps to keep their order.
+// ARG before the first FROM become args of stages using them in FROM, or declaring them without value.
+func ParseDockerfile(r io.Reader) (*Dockerfile, error) {
+	list, directives, err := parseSource(r)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([][]instruction, 0)
+	fromArgs := KeyValues{}
+
+	for _, ins := range list {
+		if ins.Key == "FROM" {
+			groups = append(groups, []instruction{ins})
+			continue
+		}
+
+		if len(groups) == 0 {
+			if ins.Key == "ARG" {
+				parts := strings.SplitN(ins.Value, "=", 2)
+				// build-in args are added automatically when used
+				if stringIncludes(globalArgs, parts[0]) {
+					continue
+				}
+				value := ""
+				if len(parts) == 2 {
+					value = unquote(parts[1])
+				}
+				fromArgs.Set(parts[0], value)
+				continue
+			}
+			return nil, ins.errorf("only FROM or ARG is supported before first FROM")
+		}
+
+		groups[len(groups)-1] = append(groups[len(groups)-1], ins)
+	}
+
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("missing FROM")
+	}
+
+	// names of stages, except the last one which is the main stage
+	names := make([]string, 0, len(groups))
+
+	for i, group := range groups[0 : len(groups)-1] {
+		name, _, err := parseFrom(group[0])
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			name = "stage" + strconv.Itoa(i)
+		}
+		if stringIncludes(names, name) {
+			return nil, group[0].errorf("duplicated stage %s", name)
+		}
+		names = append(names, name)
+	}
+
+	d := &Dockerfile{Directives: directives}
+
+	for i, group := range groups {
+		s, err := importStage(group, names)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Arg = withFromArgs(s, fromArgs)
+
+		if i == len(groups)-1 {
+			d.Stage = *s
+			continue
+		}
+
+		s.name = names[i]
+
+		if d.Stages == nil {
+			d.Stages = map[string]*Stage{}
+		}
+
+		d.Stages[s.name] = s
+	}
+
+	return d, nil
+}
+
+// withFromArgs returns args of s with args before the first FROM used by from of s,
+// args declared in s without value, also in steps, get values of them.
+func withFromArgs(s *Stage, fromArgs KeyValues) KeyValues {
+	args := KeyValues{}
+
+	for _, item := range fromArgs {
+		if refersAny(s.From, map[string]bool{item.Key: true}) {
+			args.Set(item.Key, item.Value)
+		}
+	}
+
+	for _, item := range s.Arg {
+		// ARG without value in stage gets value of the one before the first FROM
+		if value, ok := fromArgs.Get(item.Key); ok && item.Value == "" {
+			item.Value = value
+		}
+		args.Set(item.Key, item.Value)
+	}
+
+	for i := range s.Steps {
+		for j, item := range s.Steps[i].Arg {
+			if value, ok := fromArgs.Get(item.Key); ok && item.Value == "" {
+				s.Steps[i].Arg[j].Value = value
+			}
+		}
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+// parseFrom parses FROM [--platform=<platform>] <image> [AS <name>]
+func parseFrom(ins instruction) (name string, from string, err error) {
+	words := strings.Fields(ins.Value)
+
+	switch {
+	case len(words) == 3 && strings.ToUpper(words[1]) == "AS":
+		name = words[2]
+	case len(words) != 1:
+		return "", "", ins.errorf("should be FROM [--platform=<platform>] <image> [AS <name>]")
+	}
+
+	return name, strings.Join(append(append([]string{}, ins.Flags...), words[0]), " "), nil
+}
+
+// ranks of build instructions by order of Stage fields
+var buildRanks = map[string]int{
+	"WORKDIR": 1,
+	"ARG":     2,
+	"ENV":     3,
+	"ADD":     4,
+	"COPY":    5,
+	"RUN":     6,
+	"USER":    7,
+}
+
+// importStage converts instructions of one stage, stages are names of previous stages
+func importStage(list []instruction, stages []string) (*Stage, error) {
+	s := &Stage{}
+
+	_, from, err := parseFrom(list[0])
+	if err != nil {
+		return nil, err
+	}
+	s.From = from
+
+	lastBuild := 0
+	for i, ins := range list {
+		if _, ok := buildRanks[ins.Key]; ok {
+			lastBuild = i
+		}
+	}
+
+	rank := 0
+	inSteps := false
+
+	for i, ins := range list[1:] {
+		i = i + 1
+
+		if r, ok := buildRanks[ins.Key]; ok {
+			step, err := importStep(ins, stages)
+			if err != nil {
+				return nil, err
+			}
+
+			if step == nil {
+				continue
+			}
+
+			// stage user is written after steps,
+			// so it could only be in field when no more build instructions.
+			inField := (ins.Key == "USER" && i == lastBuild) || (!inSteps && r > rank && ins.Key != "USER")
+
+			if inField {
+				mergeStep(s, step)
+				rank = r
+			} else {
+				inSteps = true
+				s.Steps = append(s.Steps, *step)
+			}
+
+			continue
+		}
+
+		if err := importMetadata(s, ins); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// mergeStep sets fields of step to same fields of stage
+func mergeStep(s *Stage, step *Step) {
+	sv := reflect.ValueOf(s).Elem()
+	rv := reflect.ValueOf(step).Elem()
+
+	for i := 0; i < rv.NumField(); i++ {
+		v := rv.Field(i)
+		if c, ok := v.Interface().(Copies); ok {
+			if c.Len() > 0 {
+				sv.FieldByName(rv.Type().Field(i).Name).Set(v)
+			}
+			continue
+		}
+		if v.Len() > 0 {
+			sv.FieldByName(rv.Type().Field(i).Name).Set(v)
+		}
+	}
+}
+
+func importStep(ins instruction, stages []string) (*Step, error) {
+	switch ins.Key {
+	case "WORKDIR":
+		return &Step{WorkingDir: ins.Value}, nil
+	case "USER":
+		return &Step{User: ins.Value}, nil
+	case "ARG":
+		parts := strings.SplitN(ins.Value, "=", 2)
+
+		if len(parts) == 1 {
+			// build-in args are added automatically when used
+			if stringIncludes(globalArgs, parts[0]) {
+				return nil, nil
+			}
+			return &Step{Arg: KeyValues{{Key: parts[0]}}}, nil
+		}
+
+		return &Step{Arg: KeyValues{{Key: parts[0], Value: unquote(parts[1])}}}, nil
+	case "ENV":
+		values, err := parseKeyValues(ins)
+		if err != nil {
+			return nil, err
+		}
+		return &Step{Env: values}, nil
+	case "ADD", "COPY":
+		if len(heredocsOf(ins.Value)) > 0 {
+			return nil, ins.errorf("heredoc is unsupported")
+		}
+
+		sources, ok := ins.jsonArray()
+		if !ok {
+			sources = strings.Fields(ins.Value)
+		}
+
+		if len(sources) < 2 {
+			return nil, ins.errorf("should be %s <src>... <dest>", ins.Key)
+		}
+
+		dest := sources[len(sources)-1]
+		sources = sources[0 : len(sources)-1]
+
+		values := Values{}
+
+		if ins.Key == "ADD" {
+			if hasWhitespace(append(sources, dest)...) {
+				return nil, ins.errorf("paths with whitespace are only supported by COPY")
+			}
+			for _, src := range sources {
+				values[strings.Join(append(append([]string{}, ins.Flags...), src), " ")] = dest
+			}
+			return &Step{Add: values}, nil
+		}
+
+		flags := make([]string, 0, len(ins.Flags))
+		from := ""
+
+		for _, flag := range ins.Flags {
+			if strings.HasPrefix(flag, "--from=") {
+				from = strings.TrimPrefix(flag, "--from=")
+
+				// stage could be referred by index
+				if i, err := strconv.Atoi(from); err == nil && i >= 0 && i < len(stages) {
+					from = stages[i]
+					flag = "--from=" + from
+				}
+			}
+			flags = append(flags, flag)
+		}
+
+		// paths with whitespace are kept in JSON array by list form,
+		// sources copied from stage are relative to root, like in mapping form.
+		if hasWhitespace(append(sources, dest)...) {
+			e := CopyEntry{Src: sources, Dst: dest}
+			for _, flag := range flags {
+				switch {
+				case strings.HasPrefix(flag, "--from="):
+					e.From = strings.TrimPrefix(flag, "--from=")
+				case strings.HasPrefix(flag, "--chown="):
+					e.Chown = strings.TrimPrefix(flag, "--chown=")
+				case strings.HasPrefix(flag, "--chmod="):
+					e.Chmod = strings.TrimPrefix(flag, "--chmod=")
+				default:
+					return nil, ins.errorf("flag %s is unsupported with paths having whitespace", flag)
+				}
+			}
+			if stringIncludes(stages, from) {
+				for i := range e.Src {
+					e.Src[i] = path.Join("/", e.Src[i])
+				}
+			}
+			return &Step{Copy: CopyEntries(e)}, nil
+		}
+
+		// copy from stage is written as <stage>:<path>,
+		// path in source stage is relative to root.
+		if stringIncludes(stages, from) && len(flags) == 1 && len(sources) == 1 {
+			values[from+":"+path.Join("/", sources[0])] = dest
+			return &Step{Copy: CopyValues(values)}, nil
+		}
+
+		values[strings.Join(append(flags, sources...), " ")] = dest
+		return &Step{Copy: CopyValues(values)}, nil
+	case "RUN":
+		script := Script{Command: ins.Value}
+
+		for _, flag := range ins.Flags {
+			parts := strings.SplitN(strings.TrimPrefix(flag, "--"), "=", 2)
+			if len(parts) != 2 {
+				return nil, ins.errorf("invalid flag %s", flag)
+			}
+
+			switch parts[0] {
+			case "mount":
+				script.Mount = append(script.Mount, parts[1])
+			case "network":
+				script.Network = parts[1]
+			case "security":
+				script.Security = parts[1]
+			default:
+				return nil, ins.errorf("unsupported flag %s", flag)
+			}
+		}
+
+		if args, ok := ins.jsonArray(); ok {
+			script.Command = strings.Join(args, " ")
+		}
+
+		return &Step{Run: []Script{script}}, nil
+	}
+
+	return nil, ins.errorf("unsupported")
+}
+
+func importMetadata(s *Stage, ins instruction) error {
+	switch ins.Key {
+	case "LABEL":
+		values, err := parseKeyValues(ins)
+		if err != nil {
+			return err
+		}
+
+		s.Label = s.Label.merge(values)
+	case "EXPOSE":
+		s.Expose = append(s.Expose, strings.Fields(ins.Value)...)
+	case "VOLUME":
+		volumes, ok := ins.jsonArray()
+		if !ok {
+			volumes = strings.Fields(ins.Value)
+		}
+		s.Volume = append(s.Volume, volumes...)
+	case "STOPSIGNAL":
+		s.StopSignal = ins.Value
+	case "HEALTHCHECK":
+		s.Healthcheck = strings.Join(append(append([]string{}, ins.Flags...), ins.Value), " ")
+	case "ENTRYPOINT":
+		s.Entrypoint, s.EntrypointForm = importCommand(ins)
+	case "CMD":
+		s.Command, s.CommandForm = importCommand(ins)
+	default:
+		return ins.errorf("unsupported")
+	}
+
+	return nil
+}
+
+func importCommand(ins instruction) ([]string, Form) {
+	if args, ok := ins.jsonArray(); ok {
+		return args, ""
+	}
+	return []string{ins.Value}, FormShell
+}
+
+// parseKeyValues parses values of ENV or LABEL, in form of k=v k2="v 2" or k v
+func parseKeyValues(ins instruction) (KeyValues, error) {
+	words := splitWords(ins.Value)
+	values := KeyValues{}
+
+	if len(words) == 0 {
+		return nil, ins.errorf("missing key")
+	}
+
+	if !strings.Contains(words[0], "=") {
+		parts := strings.SplitN(ins.Value, " ", 2)
+		if len(parts) != 2 {
+			return nil, ins.errorf("missing value of %s", parts[0])
+		}
+		values.Set(parts[0], strings.TrimSpace(parts[1]))
+		return values, nil
+	}
+
+	for _, word := range words {
+		parts := strings.SplitN(word, "=", 2)
+		if len(parts) != 2 {
+			return nil, ins.errorf("should be key=value, but got %s", word)
+		}
+		values.Set(parts[0], parts[1])
+	}
+
+	return values, nil
+}
+
+// splitWords splits s by whitespace outside quotes, and removes quotes
+func splitWords(s string) []string {
+	words := make([]string, 0)
+
+	word := strings.Builder{}
+	inWord := false
+	quote := rune(0)
+	escaped := false
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words
+}
+
+func unquote(s string) string {
+	if words := splitWords(s); len(words) == 1 {
+		return words[0]
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseDockerfile(t *testing.T) {
+	t.Run("import", func(t *testing.T) {
+		f, err := os.Open("testdata/import.Dockerfile")
+		NewWithT(t).Expect(err).To(BeNil())
+		defer f.Close()
+
+		d, err := ParseDockerfile(f)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		data, err := yaml.Marshal(d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(string(data)).To(MatchSnapshot("import.yml"))
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("import.Dockerfile"))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := ParseDockerfile(strings.NewReader("FROM busybox\nONBUILD RUN ls\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(HavePrefix("line 2: ONBUILD"))
+	})
+
+	t.Run("copy from stage index", func(t *testing.T) {
+		d, err := ParseDockerfile(strings.NewReader(`
+FROM golang:1.15
+RUN go build -o /app
+FROM busybox AS tools
+FROM busybox
+COPY --from=0 /app /bin/app
+COPY --from=1 bin/sh /bin/
+COPY --from=tools --chown=nobody /bin/ls /bin/
+COPY --from=alpine /etc/passwd /etc/
+`))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Stages).To(HaveKey("stage0"))
+		NewWithT(t).Expect(d.Copy).To(Equal(CopyValues(Values{"stage0:/app": "/bin/app"})))
+		NewWithT(t).Expect(d.Steps).To(Equal([]Step{
+			{Copy: CopyValues(Values{"tools:/bin/sh": "/bin/"})},
+			{Copy: CopyValues(Values{"--from=tools --chown=nobody /bin/ls": "/bin/"})},
+			{Copy: CopyValues(Values{"--from=alpine /etc/passwd": "/etc/"})},
+		}))
+	})
+
+	t.Run("args before first FROM", func(t *testing.T) {
+		d, err := ParseDockerfile(strings.NewReader(`
+ARG GO_VERSION=1.20
+ARG VERSION
+ARG ALPINE="3.12"
+FROM golang:${GO_VERSION} AS builder
+RUN go build
+FROM alpine:$ALPINE
+ARG VERSION
+`))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Stages["builder"].Arg).To(Equal(KeyValues{{Key: "GO_VERSION", Value: "1.20"}}))
+		NewWithT(t).Expect(d.Arg).To(Equal(KeyValues{{Key: "ALPINE", Value: "3.12"}, {Key: "VERSION"}}))
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(HavePrefix("ARG GO_VERSION=1.20\nARG ALPINE=3.12\nFROM golang:${GO_VERSION} AS builder\n"))
+	})
+
+	t.Run("tabs", func(t *testing.T) {
+		d, err := ParseDockerfile(strings.NewReader("FROM\tgolang:1.20\nRUN\t--network=none\tgo build\n"))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.From).To(Equal("golang:1.20"))
+		NewWithT(t).Expect(d.Run).To(Equal([]Script{{Command: "go build", Network: "none"}}))
+	})
+
+	t.Run("heredoc", func(t *testing.T) {
+		d, err := ParseDockerfile(strings.NewReader(`
+FROM alpine
+RUN <<EOF
+# not a comment of Dockerfile
+echo "a  b" \
+  > /x
+EOF
+`))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Run).To(Equal([]Script{{Command: "<<EOF\n# not a comment of Dockerfile\necho \"a  b\" \\\n  > /x\nEOF"}}))
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("RUN <<EOF\n# not a comment of Dockerfile\necho \"a  b\" \\\n  > /x\nEOF\n"))
+
+		_, err = ParseDockerfile(strings.NewReader("FROM alpine\nRUN <<EOF\necho\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("directives", func(t *testing.T) {
+		d, err := ParseDockerfile(strings.NewReader("# syntax=docker/dockerfile:1.4\n# check=skip=all\nFROM alpine\n"))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Directives).To(Equal(Values{"syntax": "docker/dockerfile:1.4", "check": "skip=all"}))
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(HavePrefix("# check=skip=all\n# syntax=docker/dockerfile:1.4\n\nFROM alpine\n"))
+
+		_, err = ParseDockerfile(strings.NewReader("# escape=`\nFROM alpine\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+
+		err = WriteToDockerfile(bytes.NewBuffer(nil), Dockerfile{Directives: Values{"escape": "`"}, Stage: Stage{From: "alpine"}})
+		NewWithT(t).Expect(errors.Is(err, ErrInvalidValue)).To(BeTrue())
+	})
+
+	t.Run("copy paths with whitespace", func(t *testing.T) {
+		d, err := ParseDockerfile(strings.NewReader(`
+FROM alpine AS builder
+FROM alpine
+COPY --chown=nobody ["my file.txt", "/app/"]
+COPY --from=0 ["a b", "/c"]
+`))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Copy).To(Equal(CopyEntries(CopyEntry{Src: Paths{"my file.txt"}, Dst: "/app/", Chown: "nobody"})))
+		NewWithT(t).Expect(d.Steps).To(Equal([]Step{
+			{Copy: CopyEntries(CopyEntry{Src: Paths{"/a b"}, Dst: "/c", From: "builder"})},
+		}))
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`COPY --chown=nobody ["my file.txt","/app/"]`))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring(`COPY --from=builder ["/a b","/c"]`))
+
+		_, err = ParseDockerfile(strings.NewReader("FROM alpine\nADD [\"a b\", \"/c\"]\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := ParseDockerfile(strings.NewReader("# nothing\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
@@ -0,0 +1,187 @@
+package dockerfileyml
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PinDigests returns a new Dockerfile with images of from pinned by digests resolved by r,
+// like busybox:1.36 to busybox:1.36@sha256:<hex>, includes stages, variants and platform overrides.
+//
+// Stages, scratch, images already pinned, and images with args are kept as they are.
+func PinDigests(ctx context.Context, d *Dockerfile, r *Resolver) (*Dockerfile, error) {
+	return mapStages(d, func(s *Stage, stages []string) error {
+		from, err := pinFrom(s.From, stages, func(image string) (string, error) {
+			return r.Pin(ctx, image)
+		})
+		if err != nil {
+			return err
+		}
+		s.From = from
+		return nil
+	})
+}
+
+// mapStages returns a copy of d with fn applied to copy of each stage,
+// includes stages of variants, platform overrides and profiles,
+// stages are names of stages which could be referred.
+func mapStages(d *Dockerfile, fn func(s *Stage, stages []string) error) (*Dockerfile, error) {
+	return mapStagesOf(d, fn, nil)
+}
+
+func mapStagesOf(d *Dockerfile, fn func(s *Stage, stages []string) error, stages []string) (*Dockerfile, error) {
+	mapped := *d
+
+	for name := range d.Stages {
+		stages = append(stages, name)
+	}
+
+	apply := func(s *Stage) error {
+		if s.Steps != nil {
+			s.Steps = append([]Step{}, s.Steps...)
+		}
+		return fn(s, stages)
+	}
+
+	if err := apply(&mapped.Stage); err != nil {
+		return nil, err
+	}
+
+	if d.Stages != nil {
+		mapped.Stages = make(map[string]*Stage, len(d.Stages))
+
+		for name := range d.Stages {
+			s := *d.Stages[name]
+			if err := apply(&s); err != nil {
+				return nil, err
+			}
+			mapped.Stages[name] = &s
+		}
+	}
+
+	for _, patches := range []*map[string]*Dockerfile{&mapped.Variants, &mapped.PlatformOverrides, &mapped.Profiles} {
+		if *patches == nil {
+			continue
+		}
+
+		m := make(map[string]*Dockerfile, len(*patches))
+
+		for name, patch := range *patches {
+			p, err := mapStagesOf(patch, fn, stages)
+			if err != nil {
+				return nil, err
+			}
+			m[name] = p
+		}
+
+		*patches = m
+	}
+
+	return &mapped, nil
+}
+
+// pinFrom pins image of from, which could be with flags like --platform=$BUILDPLATFORM
+func pinFrom(from string, stages []string, pin func(image string) (string, error)) (string, error) {
+	image := imageOfFrom(from)
+
+	if !shouldPin(image, stages) {
+		return from, nil
+	}
+
+	pinned, err := pin(image)
+	if err != nil {
+		return "", err
+	}
+
+	words := strings.Fields(from)
+	words[len(words)-1] = pinned
+
+	return strings.Join(words, " "), nil
+}
+
+// imageOfFrom returns image of from without flags
+func imageOfFrom(from string) string {
+	words := strings.Fields(from)
+	if len(words) == 0 {
+		return ""
+	}
+	return words[len(words)-1]
+}
+
+func shouldPin(image string, stages []string) bool {
+	return image != "" &&
+		image != "scratch" &&
+		!stringIncludes(stages, image) &&
+		!strings.Contains(image, "@") &&
+		!strings.Contains(image, "$")
+}
+
+// WithPinComments writes comment for dependency update bots before FROM of pinned images,
+// like Renovate regex managers expect:
+//
+//	# renovate: datasource=docker depName=busybox versioning=docker
+//	FROM busybox:1.36@sha256:<hex>
+func WithPinComments() WriteOption {
+	return func(o *writeOptions) {
+		o.pinComments = true
+	}
+}
+
+func addPinComments(list []instruction) []instruction {
+	for i := range list {
+		if list[i].Key != "FROM" {
+			continue
+		}
+
+		image := strings.Fields(list[i].Value)[0]
+		if !strings.Contains(image, "@") {
+			continue
+		}
+
+		name := strings.SplitN(image, "@", 2)[0]
+		if j := strings.LastIndex(name, ":"); j >= 0 && !strings.Contains(name[j:], "/") {
+			name = name[0:j]
+		}
+
+		list[i].Comments = append(list[i].Comments, "renovate: datasource=docker depName="+name+" versioning=docker")
+	}
+
+	return list
+}
+
+// WithRequiredDigests fails writing when images of from, copy --from and mount from are not pinned by digests,
+// or pins them by digests resolved by r when r is not nil.
+func WithRequiredDigests(r *Resolver) WriteOption {
+	return func(o *writeOptions) {
+		o.requiredDigests = true
+		o.digestResolver = r
+	}
+}
+
+// requireDigests returns d with images pinned by r when not nil, or errors of images without digests
+func requireDigests(d *Dockerfile, r *Resolver) (*Dockerfile, error) {
+	if r != nil {
+		pinned, err := mapImages(d, func(image string) (string, error) {
+			return r.Pin(context.Background(), image)
+		})
+		if err != nil {
+			return nil, err
+		}
+		d = pinned
+	}
+
+	errs := errorList{}
+
+	eachImageRef(d, func(path string, image string) {
+		if !strings.Contains(image, "@") {
+			errs.add(fieldError(path, fmt.Errorf("%w: image %s should be pinned by digest, like %s@sha256:<hex>", ErrMissingDigest, image, image)))
+		}
+	})
+
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
@@ -0,0 +1,193 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// platformArch returns arch of platform, like arm64 of linux/arm64
+func platformArch(platform string) string {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 {
+		return platform
+	}
+	return parts[1]
+}
+
+// platformSpecs returns d with override of each platform merged, in order of platforms
+func platformSpecs(d *Dockerfile) ([]*Dockerfile, error) {
+	for platform := range d.PlatformOverrides {
+		if !stringIncludes(d.Platforms, platform) {
+			return nil, fmt.Errorf("platform %s of overrides is not in platforms", platform)
+		}
+	}
+
+	specs := make([]*Dockerfile, 0, len(d.Platforms))
+
+	for _, platform := range d.Platforms {
+		base := *d
+		base.PlatformOverrides = nil
+
+		p := Merge(&base, d.PlatformOverrides[platform])
+		p.Platforms = []string{platform}
+
+		specs = append(specs, p)
+	}
+
+	return specs, nil
+}
+
+// ExpandPlatforms returns one Dockerfile for each platform, with override of platform merged,
+// output defaults to output of spec (or Dockerfile) with .<os>-<arch> suffix.
+func ExpandPlatforms(d *Dockerfile) ([]*Dockerfile, error) {
+	specs, err := platformSpecs(d)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, platform := range d.Platforms {
+		p := specs[i]
+		suffix := strings.Replace(platform, "/", "-", -1)
+
+		if p.Name == d.Name {
+			p.Name = suffix
+			if d.Name != "" {
+				p.Name = d.Name + "-" + suffix
+			}
+		}
+
+		if p.Output == d.Output {
+			output := d.Output
+			if output == "" {
+				output = "Dockerfile"
+			}
+			p.Output = output + "." + suffix
+		}
+	}
+
+	return specs, nil
+}
+
+// resolvePlatforms makes one Dockerfile for all platforms,
+// each stage changed by platform overrides is split into stages of <stage>-<arch>,
+// and the stage itself becomes FROM <stage>-${TARGETARCH}.
+// The main stage is split as main-<arch>.
+func resolvePlatforms(d *Dockerfile) error {
+	if len(d.PlatformOverrides) == 0 {
+		return nil
+	}
+
+	specs, err := platformSpecs(d)
+	if err != nil {
+		return err
+	}
+
+	archs := make([]string, len(d.Platforms))
+
+	for i, platform := range d.Platforms {
+		archs[i] = platformArch(platform)
+		if stringIncludes(archs[0:i], archs[i]) {
+			return fmt.Errorf("platforms of same arch %s could not be in one Dockerfile, render per platform instead", archs[i])
+		}
+	}
+
+	resolve := func(d *Dockerfile) error {
+		if err := resolveExtends(d); err != nil {
+			return err
+		}
+		return expandSnippets(d)
+	}
+
+	if err := resolve(d); err != nil {
+		return err
+	}
+
+	for _, p := range specs {
+		if err := resolve(p); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(d.Stages))
+	for _, p := range append([]*Dockerfile{d}, specs...) {
+		for name := range p.Stages {
+			if !stringIncludes(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	stages := make(map[string]*Stage, len(d.Stages))
+	for name := range d.Stages {
+		stages[name] = d.Stages[name]
+	}
+
+	split := func(name string, stageOf func(d *Dockerfile) *Stage) (*Stage, error) {
+		changed := false
+		for _, p := range specs {
+			if !reflect.DeepEqual(stageOf(p), stageOf(d)) {
+				changed = true
+			}
+		}
+
+		if !changed {
+			return stageOf(d), nil
+		}
+
+		s := &Stage{From: name + "-${TARGETARCH}"}
+
+		for i, p := range specs {
+			if stageOf(p) == nil {
+				return nil, fmt.Errorf("stage %s is not defined for platform %s", name, d.Platforms[i])
+			}
+
+			archName := name + "-" + archs[i]
+			if _, ok := stages[archName]; ok {
+				return nil, fmt.Errorf("stage %s of platform %s is already defined", archName, d.Platforms[i])
+			}
+			stages[archName] = stageOf(p)
+			s.Needs = append(s.Needs, archName)
+
+			// for copy from the stage and artifacts of it, which are same for all platforms
+			if i == 0 {
+				s.WorkingDir = stageOf(p).WorkingDir
+				s.Artifacts = stageOf(p).Artifacts
+			} else if stageOf(p).WorkingDir != s.WorkingDir {
+				return nil, fmt.Errorf("workdir of stage %s should be same for all platforms, but got %s of platform %s", name, stageOf(p).WorkingDir, d.Platforms[i])
+			} else if !reflect.DeepEqual(stageOf(p).Artifacts, s.Artifacts) {
+				return nil, fmt.Errorf("artifacts of stage %s should be same for all platforms, but changed by platform %s", name, d.Platforms[i])
+			}
+		}
+
+		return s, nil
+	}
+
+	for _, name := range names {
+		n := name
+
+		s, err := split(n, func(d *Dockerfile) *Stage {
+			return d.Stages[n]
+		})
+		if err != nil {
+			return err
+		}
+
+		stages[n] = s
+	}
+
+	main, err := split("main", func(d *Dockerfile) *Stage {
+		return &d.Stage
+	})
+	if err != nil {
+		return err
+	}
+
+	d.Stages = stages
+	d.Stage = *main
+	d.PlatformOverrides = nil
+
+	return nil
+}
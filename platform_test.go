@@ -0,0 +1,90 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+)
+
+const platformsSpec = `
+platforms: [linux/amd64, linux/arm64]
+platform-overrides:
+  linux/arm64:
+    stages:
+      builder:
+        env:
+          GOARCH: arm64
+    from: arm64v8/alpine
+stages:
+  builder:
+    from: golang:1.15
+    workdir: /go/src
+    run: [go build -o app]
+  assets:
+    from: node:14
+    run: [npm run build]
+from: alpine
+copy:
+  builder:./app: /usr/local/bin/
+  assets:/dist: /usr/share/html
+cmd: [app]
+`
+
+func TestPlatforms(t *testing.T) {
+	t.Run("one Dockerfile", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader(platformsSpec))
+		NewWithT(t).Expect(err).To(BeNil())
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("platforms.Dockerfile"))
+	})
+
+	t.Run("per platform", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader(platformsSpec))
+		NewWithT(t).Expect(err).To(BeNil())
+
+		list, err := ExpandPlatforms(d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(list).To(HaveLen(2))
+
+		NewWithT(t).Expect(list[0].Output).To(Equal("Dockerfile.linux-amd64"))
+		NewWithT(t).Expect(list[0].From).To(Equal("alpine"))
+		NewWithT(t).Expect(list[1].Output).To(Equal("Dockerfile.linux-arm64"))
+		NewWithT(t).Expect(list[1].From).To(Equal("arm64v8/alpine"))
+		NewWithT(t).Expect(list[1].Stages["builder"].Env.Values()).To(Equal(Values{"GOARCH": "arm64"}))
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *list[1])
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).NotTo(ContainSubstring("TARGETARCH"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		d := Dockerfile{}
+		d.From = "alpine"
+		d.Platforms = []string{"linux/arm/v6", "linux/arm/v7"}
+		d.PlatformOverrides = map[string]*Dockerfile{"linux/arm/v6": {}}
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+
+		d.PlatformOverrides = map[string]*Dockerfile{"linux/s390x": {}}
+		err = WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("workdir changed by platform", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader(platformsSpec))
+		NewWithT(t).Expect(err).To(BeNil())
+
+		d.PlatformOverrides["linux/arm64"].Stages["builder"].WorkingDir = "/src"
+
+		err = WriteToDockerfile(bytes.NewBuffer(nil), *d)
+		NewWithT(t).Expect(err).To(MatchError(ContainSubstring("workdir of stage builder should be same for all platforms")))
+	})
+}
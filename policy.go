@@ -0,0 +1,264 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PolicyPackage is the default package of Rego policies
+const PolicyPackage = "dockerfileyml"
+
+// PolicyRule is a rule evaluating Rego policies by opa,
+// for security teams to enforce policies without forking rules.
+//
+// Input of policies is spec in JSON, with instructions of rendered Dockerfile:
+//
+//	{
+//	  "spec": {"from": "alpine", "stages": {...}},
+//	  "instructions": [{"key": "FROM", "flags": [], "value": "alpine", "stage": "", "path": "from"}]
+//	}
+//
+// Results of deny in the package are findings of error, and results of warn are findings of warning.
+// Each result is a message, or an object of finding, like
+//
+//	package dockerfileyml
+//
+//	deny[{"path": "user", "message": "final stage should run as non-root"}] {
+//	  not input.spec.user
+//	}
+type PolicyRule struct {
+	// Policies are files or dirs of Rego policies
+	Policies []string
+	// Package of deny and warn, PolicyPackage by default
+	Package string
+	// OPA is command of opa, opa in PATH by default
+	OPA string
+}
+
+func (r *PolicyRule) Check(d *Dockerfile) []Finding {
+	findings, err := r.eval(d)
+	if err != nil {
+		return []Finding{{
+			Rule:     "policy",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("evaluate policies %s: %s", strings.Join(r.Policies, ", "), err),
+		}}
+	}
+	return findings
+}
+
+// policyInstruction is instruction in input of policies
+type policyInstruction struct {
+	Key   string   `json:"key"`
+	Flags []string `json:"flags"`
+	Value string   `json:"value"`
+	Stage string   `json:"stage"`
+	Path  string   `json:"path,omitempty"`
+}
+
+// PolicyInput returns input of policies for d, see PolicyRule
+func PolicyInput(d *Dockerfile) ([]byte, error) {
+	spec, err := jsonValueOf(d)
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := make([]policyInstruction, 0)
+
+	// invalid spec is reported by validation, policies could still check the spec
+	if list, err := renderDockerfile(*d); err == nil {
+		for _, ins := range list {
+			flags := ins.Flags
+			if flags == nil {
+				flags = []string{}
+			}
+			instructions = append(instructions, policyInstruction{
+				Key:   ins.Key,
+				Flags: flags,
+				Value: ins.Value,
+				Stage: ins.Stage,
+				Path:  ins.Path,
+			})
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"spec":         spec,
+		"instructions": instructions,
+	})
+}
+
+// jsonValueOf returns value of v in yaml as JSON compatible value, with keys of yaml
+func jsonValueOf(v interface{}) (interface{}, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	var convert func(v interface{}) interface{}
+
+	convert = func(v interface{}) interface{} {
+		switch x := v.(type) {
+		case map[interface{}]interface{}:
+			m := make(map[string]interface{}, len(x))
+			for k, item := range x {
+				m[fmt.Sprint(k)] = convert(item)
+			}
+			return m
+		case []interface{}:
+			list := make([]interface{}, len(x))
+			for i := range x {
+				list[i] = convert(x[i])
+			}
+			return list
+		}
+		return v
+	}
+
+	return convert(value), nil
+}
+
+func (r *PolicyRule) eval(d *Dockerfile) ([]Finding, error) {
+	input, err := PolicyInput(d)
+	if err != nil {
+		return nil, err
+	}
+
+	opa := r.OPA
+	if opa == "" {
+		opa = "opa"
+	}
+
+	pkg := r.Package
+	if pkg == "" {
+		pkg = PolicyPackage
+	}
+
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, p := range r.Policies {
+		args = append(args, "--data", p)
+	}
+	args = append(args, "data."+pkg)
+
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+
+	cmd := exec.Command(opa, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String() + stdout.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	output := struct {
+		Result []struct {
+			Expressions []struct {
+				Value struct {
+					Deny []json.RawMessage `json:"deny"`
+					Warn []json.RawMessage `json:"warn"`
+				} `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("invalid output of opa: %w", err)
+	}
+
+	findings := make([]Finding, 0)
+
+	for _, result := range output.Result {
+		for _, expr := range result.Expressions {
+			for _, results := range []struct {
+				severity Severity
+				values   []json.RawMessage
+			}{
+				{SeverityError, expr.Value.Deny},
+				{SeverityWarning, expr.Value.Warn},
+			} {
+				for _, raw := range results.values {
+					f, err := policyFinding(raw, results.severity)
+					if err != nil {
+						return nil, err
+					}
+					findings = append(findings, f)
+				}
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// policyFinding returns finding of result of policy, which is a message or an object of finding
+func policyFinding(raw json.RawMessage, severity Severity) (Finding, error) {
+	f := Finding{Rule: "policy", Severity: severity}
+
+	if err := json.Unmarshal(raw, &f.Message); err == nil {
+		return f, nil
+	}
+
+	result := struct {
+		Rule    string `json:"rule"`
+		Path    string `json:"path"`
+		Message string `json:"message"`
+		Msg     string `json:"msg"`
+	}{}
+
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return f, fmt.Errorf("invalid result of policy, should be a message or an object of finding: %s", raw)
+	}
+
+	if result.Rule != "" {
+		f.Rule = result.Rule
+	}
+	f.Path = result.Path
+	f.Message = result.Message
+	if f.Message == "" {
+		// msg is conventional in policies of conftest
+		f.Message = result.Msg
+	}
+
+	return f, nil
+}
+
+// WithPolicy evaluates Rego policies of r before writing, and fails on results of deny
+func WithPolicy(r *PolicyRule) WriteOption {
+	return func(o *writeOptions) {
+		o.policies = append(o.policies, r)
+	}
+}
+
+func policyErrors(d *Dockerfile, policies []Rule) error {
+	errs := errorList{}
+
+	for _, f := range Lint(d, policies...) {
+		if f.Severity != SeverityError {
+			continue
+		}
+
+		err := fmt.Errorf("%w: %s", ErrPolicyDenied, f.Message)
+		if f.Path != "" {
+			errs.add(fieldError(f.Path, err))
+			continue
+		}
+		errs.add(err)
+	}
+
+	return errs.err()
+}
@@ -0,0 +1,85 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPolicyInput(t *testing.T) {
+	d, err := ReadFromYAML(bytes.NewBufferString(`
+from: alpine
+user: app
+run:
+  - apk add curl
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	data, err := PolicyInput(d)
+	NewWithT(t).Expect(err).To(BeNil())
+
+	input := struct {
+		Spec         map[string]interface{} `json:"spec"`
+		Instructions []policyInstruction    `json:"instructions"`
+	}{}
+	NewWithT(t).Expect(json.Unmarshal(data, &input)).To(BeNil())
+
+	NewWithT(t).Expect(input.Spec["from"]).To(Equal("alpine"))
+	NewWithT(t).Expect(input.Spec["user"]).To(Equal("app"))
+	NewWithT(t).Expect(input.Instructions).To(ContainElement(policyInstruction{Key: "RUN", Flags: []string{}, Value: "apk add curl", Path: "run[0]"}))
+}
+
+func TestPolicyRule(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "policy")
+	defer os.RemoveAll(dir)
+
+	// fake opa, denies root user and warns without policies of args
+	opa := filepath.Join(dir, "opa")
+	_ = ioutil.WriteFile(opa, []byte(`#!/bin/sh
+case "$*" in
+  *"--data org.rego data.dockerfileyml") ;;
+  *) echo "unexpected args $*" >&2; exit 1 ;;
+esac
+if grep -q '"user":"app"' ; then
+  echo '{"result": [{"expressions": [{"value": {"deny": [], "warn": ["missing healthcheck"]}}]}]}'
+else
+  echo '{"result": [{"expressions": [{"value": {"deny": [{"path": "user", "msg": "final stage should run as non-root"}]}}]}]}'
+fi
+`), 0755)
+
+	r := &PolicyRule{Policies: []string{"org.rego"}, OPA: opa}
+
+	t.Run("findings", func(t *testing.T) {
+		NewWithT(t).Expect(Lint(&Dockerfile{Stage: Stage{From: "alpine", User: "app"}}, r)).To(Equal([]Finding{
+			{Rule: "policy", Severity: SeverityWarning, Message: "missing healthcheck"},
+		}))
+
+		NewWithT(t).Expect(Lint(&Dockerfile{Stage: Stage{From: "alpine"}}, r)).To(Equal([]Finding{
+			{Rule: "policy", Severity: SeverityError, Path: "user", Message: "final stage should run as non-root"},
+		}))
+	})
+
+	t.Run("failed opa", func(t *testing.T) {
+		findings := Lint(&Dockerfile{Stage: Stage{From: "alpine"}}, &PolicyRule{Policies: []string{"other.rego"}, OPA: opa})
+		NewWithT(t).Expect(findings).To(HaveLen(1))
+		NewWithT(t).Expect(findings[0].Severity).To(Equal(SeverityError))
+		NewWithT(t).Expect(findings[0].Message).To(ContainSubstring("unexpected args"))
+	})
+
+	t.Run("write with policy", func(t *testing.T) {
+		err := WriteToDockerfile(ioutil.Discard, Dockerfile{Stage: Stage{From: "alpine", User: "app"}}, WithPolicy(r))
+		NewWithT(t).Expect(err).To(BeNil())
+
+		d, _ := ReadFromYAML(bytes.NewBufferString("from: alpine\nuser: root\n"), WithFilename("dockerfile.yml"))
+
+		err = WriteToDockerfile(ioutil.Discard, *d, WithPolicy(r))
+		NewWithT(t).Expect(errors.Is(err, ErrPolicyDenied)).To(BeTrue())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("dockerfile.yml:2:1: user: denied by policy: final stage should run as non-root"))
+	})
+}
@@ -0,0 +1,174 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Position is location of field in source yaml
+type Position struct {
+	Filename string `json:"filename,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// IsValid tells position is known
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns position as file:line:col, which is clickable in editors
+func (p Position) String() string {
+	s := strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+	if p.Filename != "" {
+		return p.Filename + ":" + s
+	}
+	return s
+}
+
+// Positions are positions of fields of spec by yaml path
+type Positions map[string]Position
+
+// Of returns position of path, or of its nearest parent when path is not in source,
+// like fields merged from included specs.
+func (p Positions) Of(path string) Position {
+	if pos, ok := p[path]; ok {
+		return pos
+	}
+
+	matched := ""
+	pos := Position{}
+
+	for prefix, v := range p {
+		if len(prefix) <= len(matched) || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if next := path[len(prefix)]; next == '.' || next == '[' {
+			matched, pos = prefix, v
+		}
+	}
+
+	return pos
+}
+
+// PositionsOf returns positions of fields in each document of yaml stream
+func PositionsOf(data []byte, filename string) ([]Positions, error) {
+	decoder := yamlv3.NewDecoder(bytes.NewReader(data))
+
+	list := make([]Positions, 0)
+
+	for {
+		node := &yamlv3.Node{}
+		if err := decoder.Decode(node); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		p := Positions{}
+		walkNodes("", node, func(path string, key *yamlv3.Node, value *yamlv3.Node) {
+			p[path] = Position{Filename: filename, Line: key.Line, Column: key.Column}
+		})
+		list = append(list, p)
+	}
+
+	return list, nil
+}
+
+// walkNodes calls fn with yaml path for each key of mappings and each item of sequences under node,
+// key is the item itself for items of sequences.
+func walkNodes(path string, node *yamlv3.Node, fn func(path string, key *yamlv3.Node, value *yamlv3.Node)) {
+	w := &nodeWalker{fn: fn, aliases: map[*yamlv3.Node]bool{}}
+	w.walk(path, node)
+}
+
+type nodeWalker struct {
+	fn func(path string, key *yamlv3.Node, value *yamlv3.Node)
+	// anchors of aliases being walked
+	aliases map[*yamlv3.Node]bool
+}
+
+func (w *nodeWalker) walk(path string, node *yamlv3.Node) {
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, n := range node.Content {
+			w.walk(path, n)
+		}
+	case yamlv3.AliasNode:
+		// anchor containing alias of itself, like stages: &x {a: *x}, is not walked again in it
+		if w.aliases[node.Alias] {
+			return
+		}
+		w.aliases[node.Alias] = true
+		w.walk(path, node.Alias)
+		delete(w.aliases, node.Alias)
+	case yamlv3.MappingNode:
+		// merged keys first, to be overridden by keys of mapping
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if key, value := node.Content[i], node.Content[i+1]; key.Value == "<<" {
+				if value.Kind == yamlv3.SequenceNode {
+					for _, n := range value.Content {
+						w.walk(path, n)
+					}
+				} else {
+					w.walk(path, value)
+				}
+			}
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" || isExtensionKey(key.Value) {
+				continue
+			}
+
+			keyPath := yamlPath(path, key.Value)
+			w.fn(keyPath, key, value)
+			w.walk(keyPath, value)
+		}
+	case yamlv3.SequenceNode:
+		for i, n := range node.Content {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			w.fn(itemPath, n, n)
+			w.walk(itemPath, n)
+		}
+	}
+}
+
+// WithFilename sets filename of yaml, for positions of findings and errors,
+// ParseFile and ParseFileAll use the file by default.
+func WithFilename(filename string) ReadOption {
+	return func(o *readOptions) {
+		o.filename = filename
+	}
+}
+
+// Positions returns positions of fields in source yaml, nil when not read from yaml
+func (d *Dockerfile) Positions() Positions {
+	return d.positions
+}
+
+// locateError sets positions of field errors in err
+func locateError(err error, positions Positions) error {
+	if len(positions) == 0 {
+		return err
+	}
+
+	switch e := err.(type) {
+	case *ValidationError:
+		for _, err := range e.Errors {
+			locateError(err, positions)
+		}
+	case *FieldError:
+		if !e.Position.IsValid() {
+			e.Position = positions.Of(e.Path)
+		}
+	}
+
+	return err
+}
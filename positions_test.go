@@ -0,0 +1,91 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPositionsOf(t *testing.T) {
+	list, err := PositionsOf([]byte(`
+x-go: &go
+  from: golang
+  workdir: /go/src
+stages:
+  builder:
+    <<: *go
+    workdir: /src
+    copy:
+      builder2:./b.txt: ./
+---
+name: other
+run: [make]
+`), "dockerfile.yml")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(list).To(HaveLen(2))
+
+	p := list[0]
+
+	NewWithT(t).Expect(p.Of("stages.builder.from").String()).To(Equal("dockerfile.yml:3:3"))
+	NewWithT(t).Expect(p.Of("stages.builder.workdir").String()).To(Equal("dockerfile.yml:8:5"))
+	NewWithT(t).Expect(p.Of(`stages.builder.copy["builder2:./b.txt"]`).String()).To(Equal("dockerfile.yml:10:7"))
+	NewWithT(t).Expect(p.Of("stages.builder.steps[0]").String()).To(Equal("dockerfile.yml:6:3"))
+	NewWithT(t).Expect(p.Of("stages.builder2").String()).To(Equal("dockerfile.yml:5:1"))
+	NewWithT(t).Expect(p.Of("x-go").IsValid()).To(BeFalse())
+
+	NewWithT(t).Expect(list[1].Of("run[0]").String()).To(Equal("dockerfile.yml:13:7"))
+}
+
+func TestPositionsOfErrors(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+from: busybox
+copy:
+  builder:./a.txt: ./
+stages:
+  builder:
+    from: golang
+`), WithFilename("dockerfile.yml"))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	err = WriteToDockerfile(bytes.NewBuffer(nil), *d)
+	NewWithT(t).Expect(err).NotTo(BeNil())
+	NewWithT(t).Expect(err.Error()).To(Equal(`dockerfile.yml:4:3: copy["builder:./a.txt"]: missing workdir of stage builder for copy file`))
+
+	fe := &FieldError{}
+	NewWithT(t).Expect(errors.As(err, &fe)).To(BeTrue())
+	NewWithT(t).Expect(fe.Position).To(Equal(Position{Filename: "dockerfile.yml", Line: 4, Column: 3}))
+}
+
+func TestPositionsOfDocuments(t *testing.T) {
+	list, err := ReadAllFromYAML(strings.NewReader(`
+---
+name: a
+from: busybox
+---
+name: b
+from: alpine
+run: [cd /tmp]
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(list).To(HaveLen(2))
+
+	findings := Lint(list[1])
+	NewWithT(t).Expect(findings).To(HaveLen(1))
+	NewWithT(t).Expect(findings[0].Position.String()).To(Equal("8:7"))
+}
+
+func TestPositionsOfRecursiveAnchors(t *testing.T) {
+	list, err := PositionsOf([]byte("stages: &x\n  a: *x\n"), "")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(list[0].Of("stages.a").String()).To(Equal("2:3"))
+	NewWithT(t).Expect(list[0].Of("stages.a.a").String()).To(Equal("2:3"))
+
+	_, err = ReadFromYAML(strings.NewReader("stages: &x\n  a: *x\n"))
+	NewWithT(t).Expect(err).NotTo(BeNil())
+
+	_, err = Format([]byte("stages: &x\n  a: *x\n"))
+	NewWithT(t).Expect(err).NotTo(BeNil())
+}
@@ -0,0 +1,55 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Merge returns a new Dockerfile of overlay merged onto base, base and overlay are not changed.
+//
+// Maps are merged with keys of overlay win, and stages or snippets of same name are merged too;
+// slices are appended, except entrypoint and cmd which are replaced;
+// others of overlay win when not zero.
+func Merge(base, overlay *Dockerfile) *Dockerfile {
+	if base == nil {
+		base = &Dockerfile{}
+	}
+	if overlay == nil {
+		overlay = &Dockerfile{}
+	}
+
+	merged := mergeValue(reflect.ValueOf(*base), reflect.ValueOf(*overlay)).Interface().(Dockerfile)
+	return &merged
+}
+
+// WithProfiles merges profiles of names in order onto each document when reading
+func WithProfiles(names ...string) ReadOption {
+	return func(o *readOptions) {
+		o.profiles = append(o.profiles, names...)
+	}
+}
+
+// ApplyProfiles returns a new Dockerfile with profiles of names merged in order,
+// profiles are dropped from the result.
+//
+//	profiles:
+//	  dev:
+//	    env:
+//	      LOG_LEVEL: debug
+func ApplyProfiles(d *Dockerfile, names ...string) (*Dockerfile, error) {
+	applied := *d
+	applied.Profiles = nil
+
+	for _, name := range names {
+		p, ok := d.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("missing profile %s", name)
+		}
+		if len(p.Profiles) > 0 {
+			return nil, fmt.Errorf("profile %s: profiles in profile is not supported", name)
+		}
+		applied = *Merge(&applied, p)
+	}
+
+	return &applied, nil
+}
@@ -0,0 +1,69 @@
+package dockerfileyml
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestMerge(t *testing.T) {
+	base := &Dockerfile{}
+	base.From = "golang:1.15"
+	base.Env = KeyValuesOf(Values{"LOG_LEVEL": "info", "PORT": "80"})
+	base.Expose = []string{"80"}
+	base.Command = Args("app", "serve")
+	base.Stages = map[string]*Stage{
+		"builder": {From: "golang:1.15", Run: Scripts("go build")},
+	}
+
+	overlay := &Dockerfile{}
+	overlay.Env = KeyValuesOf(Values{"LOG_LEVEL": "debug"})
+	overlay.Expose = []string{"8080"}
+	overlay.Command = Args("app", "debug")
+	overlay.Stages = map[string]*Stage{
+		"builder": {Run: Scripts("go vet ./...")},
+	}
+
+	merged := Merge(base, overlay)
+
+	NewWithT(t).Expect(merged.From).To(Equal("golang:1.15"))
+	NewWithT(t).Expect(merged.Env.Values()).To(Equal(Values{"LOG_LEVEL": "debug", "PORT": "80"}))
+	NewWithT(t).Expect(merged.Expose).To(Equal([]string{"80", "8080"}))
+	NewWithT(t).Expect(merged.Command).To(Equal(Args("app", "debug")))
+	NewWithT(t).Expect(merged.Stages["builder"].Run).To(Equal(Scripts("go build", "go vet ./...")))
+
+	NewWithT(t).Expect(base.Env.Values()).To(Equal(Values{"LOG_LEVEL": "info", "PORT": "80"}))
+	NewWithT(t).Expect(base.Stages["builder"].Run).To(Equal(Scripts("go build")))
+
+	NewWithT(t).Expect(Merge(nil, base)).To(Equal(base))
+}
+
+func TestProfiles(t *testing.T) {
+	spec := `
+from: busybox
+env:
+  LOG_LEVEL: info
+profiles:
+  dev:
+    env:
+      LOG_LEVEL: debug
+    cmd: [app, --watch]
+  prod:
+    from: gcr.io/distroless/base
+`
+
+	d, err := ReadFromYAML(strings.NewReader(spec), WithProfiles("dev", "prod"))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(d.From).To(Equal("gcr.io/distroless/base"))
+	NewWithT(t).Expect(d.Env.Values()).To(Equal(Values{"LOG_LEVEL": "debug"}))
+	NewWithT(t).Expect(d.Command).To(Equal(Args("app", "--watch")))
+	NewWithT(t).Expect(d.Profiles).To(BeNil())
+
+	d, err = ReadFromYAML(strings.NewReader(spec))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(d.Profiles).To(HaveLen(2))
+
+	_, err = ApplyProfiles(d, "staging")
+	NewWithT(t).Expect(err).To(MatchError("missing profile staging"))
+}
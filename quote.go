@@ -0,0 +1,112 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// quoteWord returns s as a word of Dockerfile, like value of key=value of ENV, LABEL and ARG.
+//
+// s is quoted by double quotes when empty or with whitespace, quotes, # or backslashes except \$,
+// quotes and backslashes are escaped, while $ and \$ are kept for expansion of variables.
+// Newlines could not be in a word, see validateValues.
+func quoteWord(s string) string {
+	if s != "" && !strings.ContainsAny(strings.Replace(s, `\$`, "$", -1), " \t\"'#\\") {
+		return s
+	}
+
+	b := strings.Builder{}
+	b.WriteByte('"')
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '$' {
+				// \$ is literal $
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteString(`\\`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
+
+// jsonArrayOf returns values in exec form, without escaping of <, > and & like json.Marshal
+func jsonArrayOf(values []string) string {
+	buf := bytes.NewBuffer(nil)
+
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(values); err != nil {
+		panic(err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsonStringOf returns s as string of JSON, like values of exec form
+func jsonStringOf(s string) string {
+	v := jsonArrayOf([]string{s})
+	return v[1 : len(v)-1]
+}
+
+// shellLines returns command in shell form with lines of script continued by \,
+// since a newline ends the instruction in Dockerfile.
+//
+// Lines are separated by ; unless continued already, like by && or |,
+// blank lines and comments are dropped, since lines are joined into one command.
+func shellLines(command string) string {
+	if !strings.Contains(command, "\n") {
+		return command
+	}
+
+	lines := make([]string, 0)
+
+	for _, line := range strings.Split(command, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if len(lines) > 0 {
+			line = defaultIndent + line
+		}
+		lines = append(lines, line)
+	}
+
+	for i := 0; i < len(lines)-1; i++ {
+		if strings.HasSuffix(lines[i], "\\") {
+			continue
+		}
+		if !continuesCommand(lines[i]) {
+			lines[i] += ";"
+		}
+		lines[i] += " \\"
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// continuesCommand tells command of line continues in next line, like a && or if a; then
+func continuesCommand(line string) bool {
+	for _, suffix := range []string{"&&", "||", "|", ";", "{", "("} {
+		if strings.HasSuffix(line, suffix) {
+			return true
+		}
+	}
+
+	words := strings.Fields(line)
+	switch words[len(words)-1] {
+	case "do", "then", "else", "in":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestQuoteWord(t *testing.T) {
+	cases := map[string]string{
+		"":         `""`,
+		"plain":    `plain`,
+		"$HOME":    `$HOME`,
+		`\$HOME`:   `\$HOME`,
+		"a b":      `"a b"`,
+		"a\tb":     "\"a\tb\"",
+		`say "hi"`: `"say \"hi\""`,
+		"it's":     `"it's"`,
+		"a#b":      `"a#b"`,
+		`C:\app`:   `"C:\\app"`,
+		`\$a \b`:   `"\$a \\b"`,
+	}
+
+	for s, quoted := range cases {
+		NewWithT(t).Expect(quoteWord(s)).To(Equal(quoted), s)
+	}
+}
+
+func TestShellLines(t *testing.T) {
+	NewWithT(t).Expect(shellLines("echo a")).To(Equal("echo a"))
+	NewWithT(t).Expect(shellLines(`if true; then
+  echo a &&
+    echo b
+fi
+
+# done
+echo c |
+  cat
+echo d \
+  e`)).To(Equal(`if true; then \
+    echo a && \
+    echo b; \
+    fi; \
+    echo c | \
+    cat; \
+    echo d \
+    e`))
+}
+
+func TestQuotedValues(t *testing.T) {
+	d := Dockerfile{
+		Stage: Stage{
+			From:    "busybox",
+			Label:   KeyValuesOf(Values{"description": `say "hi"`, "my key": "#1"}),
+			Env:     KeyValuesOf(Values{"PATH_WIN": `C:\app`, "TAB": "a\tb"}),
+			Run:     Scripts("echo a\necho b"),
+			Command: []string{"echo", "<a&b>\n"},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, d)).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM busybox
+
+LABEL description="say \"hi\""
+
+LABEL "my key"="#1"
+
+ENV PATH_WIN="C:\\app" TAB="a` + "\t" + `b"
+
+RUN echo a; \
+    echo b
+
+CMD ["echo","<a&b>\n"]
+
+`))
+
+	list, err := parseInstructions(bytes.NewReader(buf.Bytes()))
+	NewWithT(t).Expect(err).To(BeNil())
+	values, err := parseKeyValues(list[3])
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(values).To(Equal(d.Env))
+
+	t.Run("newlines", func(t *testing.T) {
+		d.Env = KeyValuesOf(Values{"MULTI": "a\nb"})
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), d)
+		NewWithT(t).Expect(errors.Is(err, ErrInvalidValue)).To(BeTrue())
+		NewWithT(t).Expect(err.Error()).To(Equal("env.MULTI: invalid value of MULTI, newlines are not allowed"))
+	})
+}
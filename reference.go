@@ -0,0 +1,279 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// grammar of image reference, by https://github.com/distribution/reference
+var (
+	reDomain    = regexp.MustCompile(`^(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?$`)
+	rePathPart  = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*$`)
+	reTag       = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	reDigest    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+	maxNameSize = 255
+)
+
+// validateImage validates image reference, like ghcr.io/org/app:v1@sha256:<hex>
+func validateImage(image string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %s: %s", ErrInvalidImage, image, reason)
+	}
+
+	name := image
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		if !reDigest.MatchString(name[i+1:]) {
+			return invalid("invalid digest")
+		}
+		name = name[0:i]
+	}
+
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		if !reTag.MatchString(name[i+1:]) {
+			return invalid("invalid tag")
+		}
+		name = name[0:i]
+	}
+
+	if name == "" {
+		return invalid("missing repository name")
+	}
+
+	if len(name) > maxNameSize {
+		return invalid("repository name must not be more than " + strconv.Itoa(maxNameSize) + " characters")
+	}
+
+	parts := strings.Split(name, "/")
+
+	if len(parts) > 1 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		if !reDomain.MatchString(parts[0]) {
+			return invalid("invalid domain")
+		}
+		parts = parts[1:]
+	}
+
+	for _, part := range parts {
+		if !rePathPart.MatchString(part) {
+			if strings.ToLower(part) != part {
+				return invalid("repository name must be lowercase")
+			}
+			return invalid("invalid repository name")
+		}
+	}
+
+	return nil
+}
+
+// NormalizeImage returns image with full name, like busybox to docker.io/library/busybox,
+// tag and digest are kept as they are.
+func NormalizeImage(image string) (string, error) {
+	if err := validateImage(image); err != nil {
+		return "", err
+	}
+
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return "", err
+	}
+
+	normalized := ref.Domain + "/" + ref.Path
+
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[0:i]
+	}
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		normalized += ":" + ref.Tag
+	}
+	if ref.Digest != "" {
+		normalized += "@" + ref.Digest
+	}
+
+	return normalized, nil
+}
+
+// WithNormalizedImages writes images with full names, like docker.io/library/busybox for busybox
+func WithNormalizedImages() WriteOption {
+	return func(o *writeOptions) {
+		o.imageMappers = append(o.imageMappers, NormalizeImage)
+	}
+}
+
+// validateImages validates images of from, copy --from and mount from of stages of d,
+// returns all invalid ones with yaml paths.
+func validateImages(d *Dockerfile) error {
+	errs := errorList{}
+
+	eachImageRef(d, func(path string, image string) {
+		errs.add(fieldError(path, validateImage(image)))
+	})
+
+	return errs.err()
+}
+
+// eachImageRef calls fn for each image of from, copy --from and mount from of stages of d,
+// with yaml path of field, stages, named contexts, scratch and images with args are skipped.
+func eachImageRef(d *Dockerfile, fn func(path string, image string)) {
+	stages := make([]string, 0, len(d.Stages))
+	for name := range d.Stages {
+		stages = append(stages, name)
+	}
+
+	isImage := imageRefChecker(d, stages)
+
+	visit := func(path string, image string) {
+		if isImage(image) {
+			fn(path, image)
+		}
+	}
+
+	visitCopy := func(path string, copy Copies) {
+		for _, pair := range copy.pairs(path) {
+			for _, word := range strings.Fields(pair.key) {
+				if strings.HasPrefix(word, "--from=") {
+					visit(pair.path, strings.TrimPrefix(word, "--from="))
+				}
+			}
+		}
+	}
+
+	visitRun := func(path string, scripts []Script) {
+		for i, script := range scripts {
+			for j, mount := range script.Mount {
+				for _, option := range strings.Split(mount, ",") {
+					if strings.HasPrefix(option, "from=") {
+						visit(path+indexPath(i)+".mount"+indexPath(j), strings.TrimPrefix(option, "from="))
+					}
+				}
+			}
+		}
+	}
+
+	eachStage(d, func(path string, s *Stage) {
+		visit(fieldPath(path, "from"), imageOfFrom(s.From))
+		visitCopy(fieldPath(path, "copy"), s.Copy)
+		visitRun(fieldPath(path, "run"), s.Run)
+
+		for i, step := range s.Steps {
+			stepPath := fieldPath(path, "steps") + indexPath(i)
+			visitCopy(stepPath+".copy", step.Copy)
+			visitRun(stepPath+".run", step.Run)
+		}
+	})
+}
+
+// imageRefChecker returns func to tell ref is an image,
+// but not stage, named context, scratch or one with args.
+func imageRefChecker(d *Dockerfile, stages []string) func(ref string) bool {
+	return func(ref string) bool {
+		if _, err := strconv.Atoi(ref); err == nil {
+			// stage by index
+			return false
+		}
+		_, isContext := d.Contexts[ref]
+		return ref != "" && ref != "scratch" && !isContext && !stringIncludes(stages, ref) && !strings.Contains(ref, "$")
+	}
+}
+
+// mapImages returns a copy of d with images of from, copy --from and mount from mapped by fn,
+// stages, named contexts, scratch and images with args are skipped.
+func mapImages(d *Dockerfile, fn func(image string) (string, error)) (*Dockerfile, error) {
+	return mapStages(d, func(s *Stage, stages []string) error {
+		isImage := imageRefChecker(d, stages)
+
+		mapFlag := func(words []string, prefix string, i int) error {
+			image := strings.TrimPrefix(words[i], prefix)
+			if !isImage(image) {
+				return nil
+			}
+			mapped, err := fn(image)
+			if err != nil {
+				return err
+			}
+			words[i] = prefix + mapped
+			return nil
+		}
+
+		if words := strings.Fields(s.From); len(words) > 0 {
+			if err := mapFlag(words, "", len(words)-1); err != nil {
+				return err
+			}
+			s.From = strings.Join(words, " ")
+		}
+
+		mapCopy := func(path string, copy Copies) (Copies, error) {
+			return copy.mapKeys(path, func(src string) (string, error) {
+				if !strings.HasPrefix(src, "--") {
+					return src, nil
+				}
+				words := strings.Fields(src)
+				for i := range words {
+					if strings.HasPrefix(words[i], "--from=") {
+						if err := mapFlag(words, "--from=", i); err != nil {
+							return "", err
+						}
+					}
+				}
+				return strings.Join(words, " "), nil
+			}, func(e CopyEntry) (CopyEntry, error) {
+				from := []string{e.From}
+				if err := mapFlag(from, "", 0); err != nil {
+					return CopyEntry{}, err
+				}
+				e.From = from[0]
+				return e, nil
+			})
+		}
+
+		mapRun := func(scripts []Script) ([]Script, error) {
+			if scripts == nil {
+				return nil, nil
+			}
+
+			mapped := make([]Script, len(scripts))
+
+			for i, script := range scripts {
+				if script.Mount != nil {
+					mounts := make([]string, len(script.Mount))
+					for j, mount := range script.Mount {
+						options := strings.Split(mount, ",")
+						for k := range options {
+							if strings.HasPrefix(options[k], "from=") {
+								if err := mapFlag(options, "from=", k); err != nil {
+									return nil, err
+								}
+							}
+						}
+						mounts[j] = strings.Join(options, ",")
+					}
+					script.Mount = mounts
+				}
+				mapped[i] = script
+			}
+
+			return mapped, nil
+		}
+
+		var err error
+
+		if s.Copy, err = mapCopy("copy", s.Copy); err != nil {
+			return err
+		}
+		if s.Run, err = mapRun(s.Run); err != nil {
+			return err
+		}
+
+		for i := range s.Steps {
+			if s.Steps[i].Copy, err = mapCopy("steps"+indexPath(i)+".copy", s.Steps[i].Copy); err != nil {
+				return err
+			}
+			if s.Steps[i].Run, err = mapRun(s.Steps[i].Run); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestValidateImage(t *testing.T) {
+	valid := []string{
+		"busybox",
+		"golang:1.20-alpine",
+		"ghcr.io/org/app:v1.2.3",
+		"localhost:5000/app",
+		"registry.example.com:443/a/b/c_d__e-f:latest",
+		"busybox@sha256:0d3bcf4f4a3f3a5e8d28d2a8b8b2c6c1c8d7f3c6f0e0d9a1b2c3d4e5f6a7b8c9",
+	}
+
+	for _, image := range valid {
+		NewWithT(t).Expect(validateImage(image)).To(BeNil(), image)
+	}
+
+	invalid := map[string]string{
+		"Busybox":            "repository name must be lowercase",
+		"busybox:":           "invalid tag",
+		"busybox@sha256:xyz": "invalid digest",
+		"org/-app":           "invalid repository name",
+		"bad_.io:80/app":     "invalid domain",
+		":latest":            "missing repository name",
+	}
+
+	for image, reason := range invalid {
+		err := validateImage(image)
+		NewWithT(t).Expect(err).NotTo(BeNil(), image)
+		NewWithT(t).Expect(err.Error()).To(HaveSuffix(reason), image)
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	cases := map[string]string{
+		"busybox":                "docker.io/library/busybox",
+		"busybox:1.36":           "docker.io/library/busybox:1.36",
+		"org/app@sha256:" + hex0: "docker.io/org/app@sha256:" + hex0,
+		"ghcr.io/org/app:v1":     "ghcr.io/org/app:v1",
+	}
+
+	for image, expected := range cases {
+		normalized, err := NormalizeImage(image)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(normalized).To(Equal(expected))
+	}
+}
+
+const hex0 = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func TestImageReferences(t *testing.T) {
+	d := Dockerfile{
+		Contexts: Values{"docs": "../docs"},
+		Stages: map[string]*Stage{
+			"builder": {
+				From: "--platform=${BUILDPLATFORM} golang:1.20",
+				Run:  []Script{{Command: "go build", Mount: []string{"type=bind,from=tools,target=/tools"}}},
+			},
+		},
+		Stage: Stage{
+			From: "busybox",
+			Copy: CopyValues(Values{
+				"--from=builder /go/bin/app":              "/bin/",
+				"--from=docs /":                           "/docs/",
+				"--from=nginx:1.25 /etc/nginx/nginx.conf": "/etc/nginx/",
+			}),
+		},
+	}
+
+	t.Run("normalized", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, d, WithNormalizedImages())
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("FROM --platform=${BUILDPLATFORM} docker.io/library/golang:1.20 AS builder"))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("RUN --mount=type=bind,from=docker.io/library/tools,target=/tools"))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("FROM docker.io/library/busybox\n"))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("COPY --from=builder /go/bin/app /bin/"))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("COPY --from=docs / /docs/"))
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("COPY --from=docker.io/library/nginx:1.25 /etc/nginx/nginx.conf /etc/nginx/"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := Merge(&d, &Dockerfile{Stage: Stage{Copy: CopyValues(Values{"--from=Nginx /a": "/b"})}})
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), *invalid)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("invalid image reference Nginx"))
+	})
+}
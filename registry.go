@@ -0,0 +1,387 @@
+package dockerfileyml
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dockerHubDomain   = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+// media types of manifest accepted,
+// index and manifest list first to get digest covering all platforms.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// imageRef is a parsed image reference, like docker.io/library/busybox:1.36@sha256:<hex>
+type imageRef struct {
+	Domain string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// parseImageRef parses image reference,
+// domain defaults to docker.io, and official images are under library/.
+func parseImageRef(image string) (*imageRef, error) {
+	r := &imageRef{}
+	name := image
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, r.Digest = name[0:i], name[i+1:]
+	}
+
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		name, r.Tag = name[0:i], name[i+1:]
+	}
+
+	r.Domain = dockerHubDomain
+	r.Path = name
+
+	if i := strings.Index(name, "/"); i >= 0 {
+		if domain := name[0:i]; strings.ContainsAny(domain, ".:") || domain == "localhost" {
+			r.Domain, r.Path = domain, name[i+1:]
+		}
+	}
+
+	if r.Domain == dockerHubDomain && !strings.Contains(r.Path, "/") {
+		r.Path = "library/" + r.Path
+	}
+
+	if r.Path == "" {
+		return nil, fmt.Errorf("invalid image %s", image)
+	}
+
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+
+	return r, nil
+}
+
+// registry is host of registry api
+func (r *imageRef) registry() string {
+	if r.Domain == dockerHubDomain {
+		return dockerHubRegistry
+	}
+	return r.Domain
+}
+
+// Resolver resolves digest of images by registry HTTP API v2
+type Resolver struct {
+	// Client to request registry, http.DefaultClient by default
+	Client *http.Client
+	// Timeout of resolving one image, or checksum of one source, 30s by default
+	Timeout time.Duration
+	// Credentials returns username and password of registry host,
+	// DockerConfigCredentials by default.
+	Credentials func(host string) (username string, password string)
+	// PlainHTTP requests registries over http instead of https, for local registries
+	PlainHTTP bool
+
+	// mu guards digests, since images could be resolved concurrently
+	mu      sync.Mutex
+	digests map[string]string
+}
+
+// Digest resolves digest of image, like sha256:<hex>,
+// the digest of image is returned directly when already pinned.
+func (r *Resolver) Digest(ctx context.Context, image string) (string, error) {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return "", err
+	}
+
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+
+	r.mu.Lock()
+	digest, ok := r.digests[image]
+	r.mu.Unlock()
+
+	if ok {
+		return digest, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout())
+	defer cancel()
+
+	digest, err = r.manifestDigest(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve digest of %s: %w", image, err)
+	}
+
+	r.mu.Lock()
+	if r.digests == nil {
+		r.digests = map[string]string{}
+	}
+	r.digests[image] = digest
+	r.mu.Unlock()
+
+	return digest, nil
+}
+
+// Pin returns image with digest, like busybox:1.36@sha256:<hex>
+func (r *Resolver) Pin(ctx context.Context, image string) (string, error) {
+	if strings.Contains(image, "@") {
+		return image, nil
+	}
+
+	digest, err := r.Digest(ctx, image)
+	if err != nil {
+		return "", err
+	}
+
+	return image + "@" + digest, nil
+}
+
+func (r *Resolver) manifestDigest(ctx context.Context, ref *imageRef) (string, error) {
+	resp, err := r.do(ctx, http.MethodHead, ref, "/manifests/"+ref.Tag)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+			return digest, nil
+		}
+	}
+
+	// some registries don't support HEAD or not return digest header
+	resp, err = r.do(ctx, http.MethodGet, ref, "/manifests/"+ref.Tag)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// do requests api of repository of ref,
+// authorizes by the challenge of registry when got 401.
+func (r *Resolver) do(ctx context.Context, method string, ref *imageRef, path string) (*http.Response, error) {
+	scheme := "https"
+	if r.PlainHTTP {
+		scheme = "http"
+	}
+
+	u := scheme + "://" + ref.registry() + "/v2/" + ref.Path + path
+
+	newRequest := func(authorization string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return req, nil
+	}
+
+	req, err := newRequest("")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	authorization, err := r.authorize(ctx, ref, resp.Header.Get("WWW-Authenticate"))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err = newRequest(authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.client().Do(req)
+}
+
+var reChallengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authorize returns Authorization header by challenge of registry,
+// https://distribution.github.io/distribution/spec/auth/token/
+func (r *Resolver) authorize(ctx context.Context, ref *imageRef, challenge string) (string, error) {
+	username, password := r.credentials(ref.Domain)
+
+	parts := strings.SplitN(challenge, " ", 2)
+
+	switch strings.ToLower(parts[0]) {
+	case "basic":
+		if username == "" {
+			return "", fmt.Errorf("unauthorized, missing credentials of %s", ref.Domain)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+
+	params := map[string]string{}
+	if len(parts) == 2 {
+		for _, m := range reChallengeParam.FindAllStringSubmatch(parts[1], -1) {
+			params[m[1]] = m[2]
+		}
+	}
+
+	if params["realm"] == "" {
+		return "", fmt.Errorf("missing realm of auth challenge %q", challenge)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Path + ":pull"
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request token of %s: %s", ref.Domain, resp.Status)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decode token of %s: %w", ref.Domain, err)
+	}
+
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+
+	return "Bearer " + token.Token, nil
+}
+
+func (r *Resolver) timeout() time.Duration {
+	if r.Timeout == 0 {
+		return 30 * time.Second
+	}
+	return r.Timeout
+}
+
+func (r *Resolver) client() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return http.DefaultClient
+}
+
+func (r *Resolver) credentials(host string) (string, string) {
+	if r.Credentials != nil {
+		return r.Credentials(host)
+	}
+	return DockerConfigCredentials(host)
+}
+
+// DockerConfigCredentials returns credentials of host from auths of docker config.json,
+// which is under $DOCKER_CONFIG or ~/.docker. Credential helpers are not supported.
+func DockerConfigCredentials(host string) (username string, password string) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return "", ""
+	}
+
+	config := struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}{}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", ""
+	}
+
+	keys := []string{host, "https://" + host}
+	if host == dockerHubDomain {
+		keys = append(keys, "https://index.docker.io/v1/")
+	}
+
+	for _, key := range keys {
+		if auth, ok := config.Auths[key]; ok && auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return "", ""
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) == 2 {
+				return parts[0], parts[1]
+			}
+		}
+	}
+
+	return "", ""
+}
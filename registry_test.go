@@ -0,0 +1,179 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseImageRef(t *testing.T) {
+	cases := map[string]imageRef{
+		"busybox":                       {Domain: "docker.io", Path: "library/busybox", Tag: "latest"},
+		"golang:1.20":                   {Domain: "docker.io", Path: "library/golang", Tag: "1.20"},
+		"org/app:v1":                    {Domain: "docker.io", Path: "org/app", Tag: "v1"},
+		"ghcr.io/org/app:v1@sha256:abc": {Domain: "ghcr.io", Path: "org/app", Tag: "v1", Digest: "sha256:abc"},
+		"localhost:5000/app":            {Domain: "localhost:5000", Path: "app", Tag: "latest"},
+	}
+
+	for image, expected := range cases {
+		ref, err := parseImageRef(image)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(*ref).To(Equal(expected), image)
+	}
+}
+
+const testDigest = "sha256:0d3bcf4f4a3f3a5e8d28d2a8b8b2c6c1c8d7f3c6f0e0d9a1b2c3d4e5f6a7b8c9"
+
+// newTestRegistry serves manifests of busybox:1.36 with token auth
+func newTestRegistry(t *testing.T) *httptest.Server {
+	var s *httptest.Server
+
+	s = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/token":
+			if u, p, ok := req.BasicAuth(); !ok || u != "user" || p != "pass" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			NewWithT(t).Expect(req.URL.Query().Get("scope")).To(Equal("repository:library/busybox:pull"))
+			_, _ = rw.Write([]byte(`{"token":"t0ken"}`))
+		case "/v2/library/busybox/manifests/1.36":
+			if req.Header.Get("Authorization") != "Bearer t0ken" {
+				rw.Header().Set("WWW-Authenticate", `Bearer realm="`+s.URL+`/token",service="test",scope="repository:library/busybox:pull"`)
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			NewWithT(t).Expect(req.Header.Get("Accept")).To(ContainSubstring("application/vnd.oci.image.index.v1+json"))
+			rw.Header().Set("Docker-Content-Digest", testDigest)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return s
+}
+
+func newTestResolver() *Resolver {
+	return &Resolver{
+		PlainHTTP: true,
+		Timeout:   5 * time.Second,
+		Credentials: func(host string) (string, string) {
+			return "user", "pass"
+		},
+	}
+}
+
+func TestResolver(t *testing.T) {
+	s := newTestRegistry(t)
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+	r := newTestResolver()
+
+	pinned, err := r.Pin(context.Background(), host+"/library/busybox:1.36")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(pinned).To(Equal(host + "/library/busybox:1.36@" + testDigest))
+
+	_, err = r.Pin(context.Background(), host+"/library/busybox:missing")
+	NewWithT(t).Expect(err).NotTo(BeNil())
+	NewWithT(t).Expect(err.Error()).To(ContainSubstring("404"))
+
+	r.Credentials = func(host string) (string, string) { return "", "" }
+	_, err = (&Resolver{PlainHTTP: true, Credentials: r.Credentials}).Pin(context.Background(), host+"/library/busybox:1.36")
+	NewWithT(t).Expect(err).NotTo(BeNil())
+}
+
+func TestResolverConcurrently(t *testing.T) {
+	s := newTestRegistry(t)
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+	r := newTestResolver()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			digest, err := r.Digest(context.Background(), host+"/library/busybox:1.36")
+			NewWithT(t).Expect(err).To(BeNil())
+			NewWithT(t).Expect(digest).To(Equal(testDigest))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPinDigests(t *testing.T) {
+	s := newTestRegistry(t)
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	d := &Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {From: "--platform=${BUILDPLATFORM} " + host + "/library/busybox:1.36"},
+		},
+		Variants: map[string]*Dockerfile{
+			"scratch": {Stage: Stage{From: "scratch"}},
+		},
+		Stage: Stage{From: "builder"},
+	}
+
+	pinned, err := PinDigests(context.Background(), d, newTestResolver())
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(pinned.Stages["builder"].From).To(Equal("--platform=${BUILDPLATFORM} " + host + "/library/busybox:1.36@" + testDigest))
+	NewWithT(t).Expect(pinned.From).To(Equal("builder"))
+	NewWithT(t).Expect(pinned.Variants["scratch"].From).To(Equal("scratch"))
+	NewWithT(t).Expect(d.Stages["builder"].From).NotTo(ContainSubstring("@"))
+
+	buf := bytes.NewBuffer(nil)
+	err = WriteToDockerfile(buf, *pinned, WithPinComments())
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring("# renovate: datasource=docker depName=" + host + "/library/busybox versioning=docker\nFROM --platform=${BUILDPLATFORM} " + host + "/library/busybox:1.36@"))
+}
+
+func TestRequiredDigests(t *testing.T) {
+	s := newTestRegistry(t)
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	d := Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {
+				From:       host + "/library/busybox:1.36",
+				WorkingDir: "/src",
+			},
+		},
+		Stage: Stage{
+			From: "alpine@" + testDigest,
+			Copy: CopyValues(Values{
+				"--from=" + host + "/library/busybox:1.36 /bin/busybox": "/bin/",
+				"builder:./app": "/bin/",
+			}),
+		},
+	}
+
+	err := WriteToDockerfile(bytes.NewBuffer(nil), d, WithRequiredDigests(nil))
+	NewWithT(t).Expect(errors.Is(err, ErrMissingDigest)).To(BeTrue())
+
+	v := &ValidationError{}
+	NewWithT(t).Expect(errors.As(err, &v)).To(BeTrue())
+	NewWithT(t).Expect(v.Errors).To(HaveLen(2))
+	NewWithT(t).Expect(v.Errors[0].(*FieldError).Path).To(Equal(`copy["--from=` + host + `/library/busybox:1.36 /bin/busybox"]`))
+	NewWithT(t).Expect(v.Errors[1].(*FieldError).Path).To(Equal("stages.builder.from"))
+
+	buf := bytes.NewBuffer(nil)
+	err = WriteToDockerfile(buf, d, WithRequiredDigests(newTestResolver()))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring("FROM " + host + "/library/busybox:1.36@" + testDigest + " AS builder\n"))
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring("COPY --from=" + host + "/library/busybox:1.36@" + testDigest + " /bin/busybox /bin/\n"))
+}
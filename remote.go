@@ -0,0 +1,229 @@
+package dockerfileyml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dir of files fetched over http, which could not include relative paths
+const remoteDir = "\x00remote"
+
+// reCommit matches full sha of commit, sha1 or sha256
+var reCommit = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+func isRemote(src string) bool {
+	return strings.HasPrefix(src, "git::") || strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+type remoteSource struct {
+	// url of git repo or file
+	url string
+	// path in git repo
+	path string
+	// ref of git repo, commit, tag or branch
+	ref string
+	// checksum as sha256:<hex>
+	checksum string
+}
+
+// parseRemote parses remote source, in form of
+//
+//	git::https://github.com/org/repo.git//path/to/file.yml?ref=<commit>[&checksum=sha256:<hex>]
+//	https://example.com/path/to/file.yml?checksum=sha256:<hex>
+//
+// git source must be pinned by ref of full commit sha, or by checksum when ref is a tag or branch,
+// since tags and branches could be moved. http source must be pinned by checksum.
+func parseRemote(src string) (*remoteSource, error) {
+	r := &remoteSource{}
+
+	s := src
+	query := ""
+
+	if i := strings.LastIndex(s, "?"); i >= 0 {
+		s, query = s[0:i], s[i+1:]
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	r.checksum = values.Get("checksum")
+	if r.checksum != "" && !strings.HasPrefix(r.checksum, "sha256:") {
+		return nil, fmt.Errorf("unsupported checksum %s, should be sha256:<hex>", r.checksum)
+	}
+
+	if !strings.HasPrefix(s, "git::") {
+		if r.checksum == "" {
+			return nil, fmt.Errorf("checksum is required to pin file over http")
+		}
+		values.Del("checksum")
+		r.url = s
+		if q := values.Encode(); q != "" {
+			r.url += "?" + q
+		}
+		return r, nil
+	}
+
+	s = strings.TrimPrefix(s, "git::")
+
+	// path in repo is separated by // after scheme
+	start := 0
+	if i := strings.Index(s, "://"); i >= 0 {
+		start = i + 3
+	}
+
+	i := strings.Index(s[start:], "//")
+	if i < 0 {
+		return nil, fmt.Errorf("missing path in git repo, should be git::<repo>//<path>?ref=<ref>")
+	}
+
+	r.url, r.path = s[0:start+i], s[start+i+2:]
+
+	r.ref = values.Get("ref")
+	if r.ref == "" {
+		return nil, fmt.Errorf("ref is required to pin git repo")
+	}
+
+	if !reCommit.MatchString(r.ref) && r.checksum == "" {
+		return nil, fmt.Errorf("ref %s is not a commit, checksum is required to pin git repo by tag or branch", r.ref)
+	}
+
+	// path should be in checkout of repo
+	if path.Clean("/"+r.path) == "/" || stringIncludes(strings.Split(r.path, "/"), "..") {
+		return nil, fmt.Errorf("invalid path %s in git repo, should be relative to root of repo without ..", r.path)
+	}
+
+	// which would be taken as options of git
+	if strings.HasPrefix(r.url, "-") || strings.HasPrefix(r.ref, "-") {
+		return nil, fmt.Errorf("invalid git repo %s or ref %s, should not start with -", r.url, r.ref)
+	}
+
+	return r, nil
+}
+
+// fetchRemote fetches content of remote source, and verifies checksum when set,
+// then calls read with content and dir to resolve relative includes from.
+func fetchRemote(src string, read func(data []byte, dir string) error) error {
+	r, err := parseRemote(src)
+	if err != nil {
+		return err
+	}
+
+	if r.path == "" {
+		data, err := fetchHTTP(r.url)
+		if err != nil {
+			return err
+		}
+		if err := r.verify(data); err != nil {
+			return err
+		}
+		return read(data, remoteDir)
+	}
+
+	dir, err := ioutil.TempDir("", "dockerfileyml-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := fetchGit(dir, r.url, r.ref); err != nil {
+		return err
+	}
+
+	filename, err := checkoutFile(dir, r.path)
+	if err != nil {
+		return fmt.Errorf("%w in %s@%s", err, r.url, r.ref)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("missing %s in %s@%s", r.path, r.url, r.ref)
+	}
+
+	if err := r.verify(data); err != nil {
+		return err
+	}
+
+	return read(data, filepath.Dir(filename))
+}
+
+// checkoutFile returns filename of p in checkout dir,
+// which should not be out of dir, like by .. or symlinks in repo.
+func checkoutFile(dir string, p string) (string, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	filename, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(path.Clean("/"+p))))
+	if err != nil {
+		return "", fmt.Errorf("missing %s", p)
+	}
+
+	if rel, err := filepath.Rel(root, filename); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is out of repo", p)
+	}
+
+	return filename, nil
+}
+
+func (r *remoteSource) verify(data []byte) error {
+	if r.checksum == "" {
+		return nil
+	}
+
+	sum := sha256.Sum256(data)
+
+	if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != r.checksum {
+		return fmt.Errorf("checksum mismatched, expect %s, but got %s", r.checksum, actual)
+	}
+
+	return nil
+}
+
+// remoteClient fetches files over http, which should not hang loading of spec
+var remoteClient = &http.Client{Timeout: 30 * time.Second}
+
+func fetchHTTP(u string) ([]byte, error) {
+	resp, err := remoteClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s failed: %s", u, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// fetchGit checkouts ref of repo into dir, ref could be commit too
+func fetchGit(dir string, repo string, ref string) error {
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"fetch", "-q", "--depth=1", "--", repo, ref},
+		{"checkout", "-q", "FETCH_HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(output)))
+		}
+	}
+
+	return nil
+}
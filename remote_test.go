@@ -0,0 +1,132 @@
+package dockerfileyml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const remoteStages = `
+stages:
+  base:
+    from: alpine
+`
+
+func TestParseRemote(t *testing.T) {
+	commit := "0123456789abcdef0123456789abcdef01234567"
+
+	r, err := parseRemote("git::https://github.com/org/repo.git//stages/go.yml?ref=" + commit)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(*r).To(Equal(remoteSource{url: "https://github.com/org/repo.git", path: "stages/go.yml", ref: commit}))
+
+	r, err = parseRemote("git::file:///tmp/repo//go.yml?ref=main&checksum=sha256:00")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(*r).To(Equal(remoteSource{url: "file:///tmp/repo", path: "go.yml", ref: "main", checksum: "sha256:00"}))
+
+	r, err = parseRemote("https://example.com/go.yml?v=1&checksum=sha256:00")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(*r).To(Equal(remoteSource{url: "https://example.com/go.yml?v=1", checksum: "sha256:00"}))
+
+	for _, src := range []string{
+		"git::https://github.com/org/repo.git//go.yml",
+		"git::https://github.com/org/repo.git?ref=main",
+		"https://example.com/go.yml",
+		"https://example.com/go.yml?checksum=md5:00",
+		"git::--upload-pack=touch /tmp/x//go.yml?ref=main",
+		"git::https://github.com/org/repo.git//go.yml?ref=--upload-pack=touch /tmp/x",
+		"git::https://github.com/org/repo.git//go.yml?ref=main",
+		"git::https://github.com/org/repo.git//go.yml?ref=v1.2.3",
+		"git::https://github.com/org/repo.git//../go.yml?ref=" + commit,
+		"git::https://github.com/org/repo.git//stages/../../go.yml?ref=" + commit,
+		"git::https://github.com/org/repo.git//?ref=" + commit,
+	} {
+		_, err := parseRemote(src)
+		NewWithT(t).Expect(err).NotTo(BeNil(), src)
+	}
+}
+
+func TestRemoteInclude(t *testing.T) {
+	sum := sha256.Sum256([]byte(remoteStages))
+	checksum := "sha256:" + hex.EncodeToString(sum[:])
+
+	t.Run("http", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/stages.yml" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(remoteStages))
+		}))
+		defer s.Close()
+
+		d, err := ReadFromYAML(strings.NewReader("include: ['" + s.URL + "/stages.yml?checksum=" + checksum + "']\n"))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Stages["base"].From).To(Equal("alpine"))
+
+		_, err = ReadFromYAML(strings.NewReader("include: ['" + s.URL + "/stages.yml?checksum=sha256:00']\n"))
+		NewWithT(t).Expect(err).To(MatchError(ContainSubstring("checksum mismatched")))
+
+		_, err = ReadFromYAML(strings.NewReader("include: ['" + s.URL + "/missing.yml?checksum=sha256:00']\n"))
+		NewWithT(t).Expect(err).To(MatchError(ContainSubstring("404")))
+	})
+
+	t.Run("git", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git is not installed")
+		}
+
+		repo, err := ioutil.TempDir("", "repo-")
+		NewWithT(t).Expect(err).To(BeNil())
+		defer os.RemoveAll(repo)
+
+		NewWithT(t).Expect(os.MkdirAll(filepath.Join(repo, "stages"), 0755)).To(Succeed())
+		NewWithT(t).Expect(ioutil.WriteFile(filepath.Join(repo, "stages/base.yml"), []byte(remoteStages), 0644)).To(Succeed())
+		NewWithT(t).Expect(ioutil.WriteFile(filepath.Join(repo, "stages/go.yml"), []byte("include: [base.yml]\n"), 0644)).To(Succeed())
+		NewWithT(t).Expect(os.Symlink(filepath.Join(repo, "stages/base.yml"), filepath.Join(repo, "outside.yml"))).To(Succeed())
+
+		for _, args := range [][]string{
+			{"init", "-q"},
+			{"add", "."},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+			{"tag", "v1.0.0"},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = repo
+			output, err := cmd.CombinedOutput()
+			NewWithT(t).Expect(err).To(BeNil(), string(output))
+		}
+
+		cmd := exec.Command("git", "rev-parse", "HEAD")
+		cmd.Dir = repo
+		output, err := cmd.Output()
+		NewWithT(t).Expect(err).To(BeNil())
+		commit := strings.TrimSpace(string(output))
+
+		d, err := ReadFromYAML(strings.NewReader("include: ['git::file://" + repo + "//stages/go.yml?ref=" + commit + "']\n"))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Stages["base"].From).To(Equal("alpine"))
+
+		_, err = ReadFromYAML(strings.NewReader("include: ['git::file://" + repo + "//stages/go.yml?ref=v1.0.0']\n"))
+		NewWithT(t).Expect(err).To(MatchError(ContainSubstring("checksum is required")))
+
+		// symlink to file out of checkout
+		_, err = ReadFromYAML(strings.NewReader("include: ['git::file://" + repo + "//outside.yml?ref=" + commit + "']\n"))
+		NewWithT(t).Expect(err).To(MatchError(ContainSubstring("out of repo")))
+
+		d, err = ReadFromYAML(strings.NewReader("include: ['git::file://" + repo + "//stages/base.yml?ref=v1.0.0&checksum=" + checksum + "']\n"))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Stages["base"].From).To(Equal("alpine"))
+
+		_, err = ReadFromYAML(strings.NewReader("include: ['git::file://" + repo + "//stages/base.yml?ref=v9.9.9&checksum=" + checksum + "']\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
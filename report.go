@@ -0,0 +1,158 @@
+package dockerfileyml
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+)
+
+// WriteFindingsJSON writes findings as json array, for scripts of CI
+func WriteFindingsJSON(w io.Writer, findings []Finding) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	return writeJSON(w, findings)
+}
+
+// WriteFindingsSARIF writes findings as SARIF 2.1.0 log, for code scanning UIs to annotate pull requests,
+// version is version of dockerfileyml, could be empty.
+//
+// Findings are located in files of their positions, findings without filename are reported without location.
+func WriteFindingsSARIF(w io.Writer, findings []Finding, version string) error {
+	rules := make([]sarifRule, 0)
+	ruleIndexes := map[string]int{}
+
+	results := make([]sarifResult, 0, len(findings))
+
+	for _, f := range findings {
+		i, ok := ruleIndexes[f.Rule]
+		if !ok {
+			i = len(rules)
+			ruleIndexes[f.Rule] = i
+			rules = append(rules, sarifRule{ID: f.Rule})
+		}
+
+		result := sarifResult{
+			RuleID:    f.Rule,
+			RuleIndex: i,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: f.Message},
+			Locations: make([]sarifLocation, 0),
+		}
+
+		if f.Position.Filename != "" || f.Path != "" {
+			location := sarifLocation{}
+
+			if f.Position.Filename != "" {
+				location.PhysicalLocation = &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.Position.Filename)},
+				}
+				if f.Position.IsValid() {
+					location.PhysicalLocation.Region = &sarifRegion{StartLine: f.Position.Line, StartColumn: f.Position.Column}
+				}
+			}
+
+			if f.Path != "" {
+				location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: f.Path}}
+			}
+
+			result.Locations = append(result.Locations, location)
+		}
+
+		results = append(results, result)
+	}
+
+	return writeJSON(w, sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "dockerfileyml",
+				Version:        version,
+				InformationURI: "https://github.com/go-courier/dockerfileyml",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	})
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
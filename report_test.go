@@ -0,0 +1,74 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWriteFindings(t *testing.T) {
+	findings := []Finding{
+		{Rule: "shell-form-entrypoint", Severity: SeverityWarning, Path: "entrypoint", Message: "shell form", Position: Position{Filename: "dockerfile.yml", Line: 2, Column: 1}},
+		{Rule: "plugin", Severity: SeverityError, Message: "broken"},
+		{Rule: "shell-form-entrypoint", Severity: SeverityInfo, Path: "stages.app.entrypoint", Message: "shell form"},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteFindingsJSON(buf, nil)).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("[]\n"))
+
+		buf.Reset()
+		NewWithT(t).Expect(WriteFindingsJSON(buf, findings)).To(BeNil())
+
+		decoded := make([]Finding, 0)
+		NewWithT(t).Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(BeNil())
+		NewWithT(t).Expect(decoded).To(Equal(findings))
+	})
+
+	t.Run("sarif", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteFindingsSARIF(buf, findings, "v1.0.0")).To(BeNil())
+
+		log := sarifLog{}
+		NewWithT(t).Expect(json.Unmarshal(buf.Bytes(), &log)).To(BeNil())
+		NewWithT(t).Expect(log.Version).To(Equal("2.1.0"))
+		NewWithT(t).Expect(log.Runs).To(HaveLen(1))
+
+		run := log.Runs[0]
+		NewWithT(t).Expect(run.Tool.Driver.Version).To(Equal("v1.0.0"))
+		NewWithT(t).Expect(run.Tool.Driver.Rules).To(Equal([]sarifRule{{ID: "shell-form-entrypoint"}, {ID: "plugin"}}))
+
+		NewWithT(t).Expect(run.Results).To(Equal([]sarifResult{
+			{
+				RuleID:  "shell-form-entrypoint",
+				Level:   "warning",
+				Message: sarifMessage{Text: "shell form"},
+				Locations: []sarifLocation{{
+					PhysicalLocation: &sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: "dockerfile.yml"},
+						Region:           &sarifRegion{StartLine: 2, StartColumn: 1},
+					},
+					LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: "entrypoint"}},
+				}},
+			},
+			{
+				RuleID:    "plugin",
+				RuleIndex: 1,
+				Level:     "error",
+				Message:   sarifMessage{Text: "broken"},
+				Locations: []sarifLocation{},
+			},
+			{
+				RuleID:  "shell-form-entrypoint",
+				Level:   "note",
+				Message: sarifMessage{Text: "shell form"},
+				Locations: []sarifLocation{{
+					LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: "stages.app.entrypoint"}},
+				}},
+			},
+		}))
+	})
+}
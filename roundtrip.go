@@ -0,0 +1,82 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RoundTrip parses Dockerfile from r and writes it back to w by WriteToDockerfile
+func RoundTrip(w io.Writer, r io.Reader) error {
+	d, err := ParseDockerfile(r)
+	if err != nil {
+		return err
+	}
+	return WriteToDockerfile(w, *d)
+}
+
+// CheckRoundTrip makes sure RoundTrip of Dockerfile is stable,
+// which means its output parses to the same spec, like args of instructions,
+// and round trip of its output gives the same output.
+func CheckRoundTrip(r io.Reader) error {
+	d, err := ParseDockerfile(r)
+	if err != nil {
+		return err
+	}
+
+	first := bytes.NewBuffer(nil)
+	if err := WriteToDockerfile(first, *d); err != nil {
+		return err
+	}
+
+	parsed, err := ParseDockerfile(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		return fmt.Errorf("round trip output could not be parsed: %w", err)
+	}
+
+	spec, err := yaml.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	parsedSpec, err := yaml.Marshal(parsed)
+	if err != nil {
+		return err
+	}
+
+	if err := compareLines("round trip output parses to different spec", string(spec), string(parsedSpec)); err != nil {
+		return err
+	}
+
+	second := bytes.NewBuffer(nil)
+	if err := WriteToDockerfile(second, *parsed); err != nil {
+		return fmt.Errorf("round trip output could not be parsed: %w", err)
+	}
+
+	return compareLines("unstable round trip", first.String(), second.String())
+}
+
+// compareLines returns error with the first different line of first and second
+func compareLines(message string, first string, second string) error {
+	if first == second {
+		return nil
+	}
+
+	firstLines := strings.Split(first, "\n")
+	secondLines := strings.Split(second, "\n")
+
+	for i := range firstLines {
+		if i >= len(secondLines) || firstLines[i] != secondLines[i] {
+			got := ""
+			if i < len(secondLines) {
+				got = secondLines[i]
+			}
+			return fmt.Errorf("%s at line %d:\n  first:  %s\n  second: %s", message, i+1, firstLines[i], got)
+		}
+	}
+
+	return fmt.Errorf("%s at line %d:\n  first:  \n  second: %s", message, len(firstLines)+1, secondLines[len(firstLines)])
+}
@@ -0,0 +1,26 @@
+package dockerfileyml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCheckRoundTrip(t *testing.T) {
+	files, _ := filepath.Glob("testdata/*.Dockerfile")
+	snapshots, _ := filepath.Glob("__snapshots__/*.Dockerfile")
+
+	for _, file := range append(files, snapshots...) {
+		file := file
+
+		t.Run(file, func(t *testing.T) {
+			f, err := os.Open(file)
+			NewWithT(t).Expect(err).To(BeNil())
+			defer f.Close()
+
+			NewWithT(t).Expect(CheckRoundTrip(f)).To(BeNil())
+		})
+	}
+}
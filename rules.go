@@ -0,0 +1,576 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// built-in rules, equivalent to ones of hadolint of code in comment
+var builtinRules = []Rule{
+	// DL3009
+	RuleFunc(checkAptCleanup),
+	// DL3003
+	RuleFunc(checkCdInRun),
+	// DL3004
+	RuleFunc(checkSudo),
+	// DL3020
+	RuleFunc(checkAddInsteadOfCopy),
+	// DL3045
+	RuleFunc(checkMissingWorkdir),
+	// DL3013
+	RuleFunc(checkPipUnpinned),
+	// DL3008
+	RuleFunc(checkAptUnpinned),
+	// DL3018
+	RuleFunc(checkApkUnpinned),
+	// DL3002, off by default, enable by lint of spec, like non-root-user: error
+	RuleFunc(checkNonRootUser),
+	// DL3006 and DL3007, off by default, enable by lint of spec, like untagged-image: error
+	RuleFunc(checkUntaggedImage),
+	// CIS-DI-0006 of dockle, off by default, enable by lint of spec, like missing-healthcheck: warning
+	RuleFunc(checkMissingHealthcheck),
+	RuleFunc(checkPrivilegedPort),
+	// CIS-DI-0008 of dockle
+	RuleFunc(checkSetuid),
+	RuleFunc(checkShellFormSignals),
+	// DL3021 for multiple sources
+	RuleFunc(checkCopyDirectory),
+	// DL3000
+	RuleFunc(checkRelativeWorkdir),
+	&LayerRule{},
+	RuleFunc(checkCacheOrder),
+	RuleFunc(checkSharedBase),
+	RuleFunc(checkDuplicateStage),
+}
+
+// eachScript calls fn for each script of run in stages, including ones of steps, with yaml path of script
+func eachScript(d *Dockerfile, fn func(path string, script Script)) {
+	eachStage(d, func(stagePath string, s *Stage) {
+		for i, script := range s.Run {
+			fn(fieldPath(stagePath, "run")+indexPath(i), script)
+		}
+		for i, step := range s.Steps {
+			for j, script := range step.Run {
+				fn(fieldPath(stagePath, "steps")+indexPath(i)+".run"+indexPath(j), script)
+			}
+		}
+	})
+}
+
+func indexPath(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+var reCommandSeparator = regexp.MustCompile(`&&|\|\||;|\||\n`)
+
+// commandsOf splits script into simple commands, each in words
+func commandsOf(script Script) [][]string {
+	commands := make([][]string, 0)
+	for _, c := range reCommandSeparator.Split(script.Command, -1) {
+		if words := strings.Fields(c); len(words) > 0 {
+			commands = append(commands, words)
+		}
+	}
+	return commands
+}
+
+func checkAptCleanup(d *Dockerfile) (findings []Finding) {
+	eachScript(d, func(p string, script Script) {
+		if !strings.Contains(script.Command, "apt-get install") && !strings.Contains(script.Command, "apt install") {
+			return
+		}
+
+		// lists in cache mount are not in layer
+		for _, mount := range script.Mount {
+			if strings.Contains(mount, "type=cache") && strings.Contains(mount, "/var/lib/apt") {
+				return
+			}
+		}
+
+		if strings.Contains(script.Command, "rm -rf /var/lib/apt/lists") {
+			return
+		}
+
+		findings = append(findings, Finding{
+			Rule:     "apt-cleanup",
+			Severity: SeverityWarning,
+			Path:     p,
+			Message:  "apt lists are kept in layer, rm -rf /var/lib/apt/lists/* after apt-get install",
+		})
+	})
+	return
+}
+
+func checkCdInRun(d *Dockerfile) (findings []Finding) {
+	eachScript(d, func(p string, script Script) {
+		for _, words := range commandsOf(script) {
+			if words[0] == "cd" {
+				findings = append(findings, Finding{
+					Rule:     "cd-in-run",
+					Severity: SeverityWarning,
+					Path:     p,
+					Message:  "use workdir to switch directory instead of cd",
+				})
+				return
+			}
+		}
+	})
+	return
+}
+
+func checkSudo(d *Dockerfile) (findings []Finding) {
+	eachScript(d, func(p string, script Script) {
+		for _, words := range commandsOf(script) {
+			if words[0] == "sudo" {
+				findings = append(findings, Finding{
+					Rule:     "sudo",
+					Severity: SeverityWarning,
+					Path:     p,
+					Message:  "sudo has unpredictable behavior of tty and signals, use user to switch users instead",
+				})
+				return
+			}
+		}
+	})
+	return
+}
+
+var archiveExts = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.xz", ".txz", ".tar.zst"}
+
+func checkAddInsteadOfCopy(d *Dockerfile) (findings []Finding) {
+	check := func(p string, add Values) {
+		for src := range add {
+			words := strings.Fields(src)
+			if len(words) == 0 {
+				continue
+			}
+
+			file := words[len(words)-1]
+
+			if strings.Contains(file, "://") || strings.HasPrefix(file, "git@") || strings.Contains(file, "$") {
+				continue
+			}
+
+			if stringSome(archiveExts, func(ext string, i int) bool { return strings.HasSuffix(file, ext) }) {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Rule:     "add-instead-of-copy",
+				Severity: SeverityWarning,
+				Path:     yamlPath(p, src),
+				Message:  "use copy for files which are not urls or archives",
+			})
+		}
+	}
+
+	eachStage(d, func(p string, s *Stage) {
+		check(fieldPath(p, "add"), s.Add)
+		for i, step := range s.Steps {
+			check(fieldPath(p, "steps")+indexPath(i)+".add", step.Add)
+		}
+	})
+	return
+}
+
+func checkMissingWorkdir(d *Dockerfile) (findings []Finding) {
+	eachStage(d, func(p string, s *Stage) {
+		if s.WorkingDir != "" || s.Extends != "" {
+			return
+		}
+
+		relative := func(dest string) bool {
+			return !path.IsAbs(dest) && !strings.HasPrefix(dest, "$")
+		}
+
+		check := func(p string, c Copies) {
+			for _, pair := range c.pairs(p) {
+				if relative(pair.dst) {
+					findings = append(findings, Finding{
+						Rule:     "missing-workdir",
+						Severity: SeverityWarning,
+						Path:     pair.path,
+						Message:  "relative dest " + pair.dst + " without workdir, which depends on workdir of base image",
+					})
+				}
+			}
+		}
+
+		check(fieldPath(p, "add"), CopyValues(s.Add))
+		check(fieldPath(p, "copy"), s.Copy)
+
+		for i, step := range s.Steps {
+			if step.WorkingDir != "" {
+				return
+			}
+			check(fieldPath(p, "steps")+indexPath(i)+".add", CopyValues(step.Add))
+			check(fieldPath(p, "steps")+indexPath(i)+".copy", step.Copy)
+		}
+	})
+	return
+}
+
+// installCommand returns args after subcommand of package manager in words,
+// like args of apt-get install, env assignments and sudo before are skipped.
+func installCommand(words []string, managers []string, subcommand string) ([]string, bool) {
+	i := 0
+	for i < len(words) && (strings.Contains(words[i], "=") || words[i] == "sudo") {
+		i++
+	}
+	if i >= len(words) || !stringIncludes(managers, words[i]) {
+		return nil, false
+	}
+
+	// options before subcommand, like apt-get -y install
+	for i++; i < len(words) && strings.HasPrefix(words[i], "-"); i++ {
+	}
+
+	if i >= len(words) || words[i] != subcommand {
+		return nil, false
+	}
+
+	return words[i+1:], true
+}
+
+// unpinnedPackages returns packages of args not pinned,
+// options and values of valueOptions are skipped, as well as packages with args.
+func unpinnedPackages(args []string, valueOptions []string, pinned func(pkg string) bool) []string {
+	unpinned := make([]string, 0)
+
+	for j := 0; j < len(args); j++ {
+		word := args[j]
+
+		if strings.HasPrefix(word, "-") {
+			// value of option, like -r requirements.txt
+			if !strings.Contains(word, "=") && stringIncludes(valueOptions, word) {
+				j++
+			}
+			continue
+		}
+
+		if strings.Contains(word, "$") || pinned(word) {
+			continue
+		}
+
+		unpinned = append(unpinned, word)
+	}
+
+	return unpinned
+}
+
+func unpinnedFinding(rule string, p string, kind string, unpinned []string, example string) Finding {
+	return Finding{
+		Rule:     rule,
+		Severity: SeverityWarning,
+		Path:     p,
+		Message:  "pin versions of " + kind + " packages " + strings.Join(unpinned, ", ") + ", like " + unpinned[0] + example,
+	}
+}
+
+func checkPipUnpinned(d *Dockerfile) (findings []Finding) {
+	eachScript(d, func(p string, script Script) {
+		for _, words := range commandsOf(script) {
+			i := 0
+			for i < len(words) && (words[i] == "python" || words[i] == "python3" || words[i] == "-m") {
+				i++
+			}
+
+			if i+1 >= len(words) || !strings.HasPrefix(words[i], "pip") || words[i+1] != "install" {
+				continue
+			}
+
+			unpinned := unpinnedPackages(words[i+2:], []string{"-r", "--requirement", "-c", "--constraint", "-i", "--index-url", "--extra-index-url", "-t", "--target"}, func(pkg string) bool {
+				return strings.ContainsAny(pkg, "=<>~@/") || strings.HasPrefix(pkg, ".") || strings.HasSuffix(pkg, ".whl")
+			})
+
+			if len(unpinned) > 0 {
+				findings = append(findings, unpinnedFinding("pip-unpinned", p, "pip", unpinned, "==<version>"))
+				return
+			}
+		}
+	})
+	return
+}
+
+func checkAptUnpinned(d *Dockerfile) (findings []Finding) {
+	eachScript(d, func(p string, script Script) {
+		for _, words := range commandsOf(script) {
+			args, ok := installCommand(words, []string{"apt-get", "apt"}, "install")
+			if !ok {
+				continue
+			}
+
+			unpinned := unpinnedPackages(args, []string{"-o", "--option", "-t", "--target-release", "-c", "--config-file"}, func(pkg string) bool {
+				// local debs are pinned by files
+				return strings.Contains(pkg, "=") || strings.Contains(pkg, "/") || strings.HasSuffix(pkg, ".deb")
+			})
+
+			if len(unpinned) > 0 {
+				findings = append(findings, unpinnedFinding("apt-unpinned", p, "apt", unpinned, "=<version>"))
+				return
+			}
+		}
+	})
+	return
+}
+
+func checkApkUnpinned(d *Dockerfile) (findings []Finding) {
+	eachScript(d, func(p string, script Script) {
+		for _, words := range commandsOf(script) {
+			args, ok := installCommand(words, []string{"apk"}, "add")
+			if !ok {
+				continue
+			}
+
+			unpinned := unpinnedPackages(args, []string{"-t", "--virtual", "-X", "--repository", "--arch", "-p", "--root"}, func(pkg string) bool {
+				return strings.ContainsAny(pkg, "=~<>") || strings.HasSuffix(pkg, ".apk")
+			})
+
+			if len(unpinned) > 0 {
+				findings = append(findings, unpinnedFinding("apk-unpinned", p, "apk", unpinned, "=<version>"))
+				return
+			}
+		}
+	})
+	return
+}
+
+// eachFinalStage calls fn for final stage and stages it is based on by extends or from, in order,
+// with yaml path of stage, until fn returns false.
+func eachFinalStage(d *Dockerfile, fn func(path string, s *Stage) bool) {
+	name, s := "", &d.Stage
+	if d.Target != "" {
+		name, s = d.Target, d.Stages[d.Target]
+	}
+
+	visited := map[string]bool{}
+
+	for s != nil && !visited[name] {
+		visited[name] = true
+
+		stagePath := ""
+		if name != "" {
+			stagePath = yamlPath("stages", name)
+		}
+
+		if !fn(stagePath, s) {
+			return
+		}
+
+		switch {
+		case s.Extends != "":
+			name = s.Extends
+		case d.Stages[imageOfFrom(s.From)] != nil:
+			name = imageOfFrom(s.From)
+		default:
+			return
+		}
+
+		s = d.Stages[name]
+	}
+}
+
+// finalUser returns user of final stage with yaml path of it,
+// following extends and from of stages, empty when not set.
+func finalUser(d *Dockerfile) (user string, path string) {
+	eachFinalStage(d, func(stagePath string, s *Stage) bool {
+		if s.User != "" {
+			user, path = s.User, fieldPath(stagePath, "user")
+			return false
+		}
+
+		for i := len(s.Steps) - 1; i >= 0; i-- {
+			if s.Steps[i].User != "" {
+				user, path = s.Steps[i].User, fieldPath(stagePath, "steps")+indexPath(i)+".user"
+				return false
+			}
+		}
+
+		return true
+	})
+
+	return
+}
+
+func isRootUser(user string) bool {
+	name := strings.SplitN(user, ":", 2)[0]
+	return name == "root" || name == "0"
+}
+
+// checkNonRootUser checks final stage runs as non-root user, as required by policies of clusters
+func checkNonRootUser(d *Dockerfile) (findings []Finding) {
+	user, p := finalUser(d)
+
+	if user == "" {
+		p = "user"
+		if d.Target != "" {
+			p = yamlPath("stages", d.Target) + ".user"
+		}
+
+		return []Finding{{
+			Rule:     "non-root-user",
+			Severity: SeverityOff,
+			Path:     p,
+			Message:  "final stage runs as user of base image, which is root by default, set user to a non-root one",
+		}}
+	}
+
+	if isRootUser(user) {
+		return []Finding{{
+			Rule:     "non-root-user",
+			Severity: SeverityOff,
+			Path:     p,
+			Message:  "final stage runs as root, set user to a non-root one",
+		}}
+	}
+
+	return nil
+}
+
+// WithNonRootUser fails writing when final stage runs as root,
+// or as user of base image without user set.
+func WithNonRootUser() WriteOption {
+	return func(o *writeOptions) {
+		o.nonRootUser = true
+	}
+}
+
+func nonRootUserError(d *Dockerfile) error {
+	for _, f := range checkNonRootUser(d) {
+		return fieldError(f.Path, fmt.Errorf("%w: %s", ErrRootUser, f.Message))
+	}
+	return nil
+}
+
+// checkUntaggedImage checks images of from have tag other than latest, for reproducible builds
+func checkUntaggedImage(d *Dockerfile) (findings []Finding) {
+	stages := make([]string, 0, len(d.Stages))
+	for name := range d.Stages {
+		stages = append(stages, name)
+	}
+
+	isImage := imageRefChecker(d, stages)
+
+	eachStage(d, func(p string, s *Stage) {
+		image := imageOfFrom(s.From)
+		if !isImage(image) {
+			return
+		}
+
+		stage := "main stage"
+		for name := range d.Stages {
+			if d.Stages[name] == s {
+				stage = "stage " + name
+			}
+		}
+
+		if strings.Contains(image, "@") {
+			// pinned by digest
+			return
+		}
+
+		message := ""
+
+		if i := strings.LastIndex(image, ":"); i < 0 || strings.Contains(image[i:], "/") {
+			message = "image " + image + " of " + stage + " has no tag, which is latest"
+		} else if image[i+1:] == "latest" {
+			message = "image " + image + " of " + stage + " is of latest tag"
+		}
+
+		if message != "" {
+			findings = append(findings, Finding{
+				Rule:     "untagged-image",
+				Severity: SeverityOff,
+				Path:     fieldPath(p, "from"),
+				Message:  message + ", set a tag for reproducible builds",
+			})
+		}
+	})
+	return
+}
+
+// WithTaggedImages fails writing when images of from have no tag or latest tag
+func WithTaggedImages() WriteOption {
+	return func(o *writeOptions) {
+		o.taggedImages = true
+	}
+}
+
+func untaggedImageErrors(d *Dockerfile) error {
+	errs := errorList{}
+	for _, f := range checkUntaggedImage(d) {
+		errs.add(fieldError(f.Path, fmt.Errorf("%w: %s", ErrUntaggedImage, f.Message)))
+	}
+	return errs.err()
+}
+
+// normalizeWorkdir normalizes separators of workdir to /, and cleans it when without args
+func normalizeWorkdir(workdir string) string {
+	workdir = strings.Replace(workdir, "\\", "/", -1)
+	if strings.Contains(workdir, "$") {
+		return workdir
+	}
+	return path.Clean(workdir)
+}
+
+// firstWorkdir returns first workdir of stage with yaml path of it, empty when not set
+func firstWorkdir(p string, s *Stage) (string, string) {
+	if s.WorkingDir != "" {
+		return s.WorkingDir, fieldPath(p, "workdir")
+	}
+	for i, step := range s.Steps {
+		if step.WorkingDir != "" {
+			return step.WorkingDir, fieldPath(p, "steps") + indexPath(i) + ".workdir"
+		}
+	}
+	return "", ""
+}
+
+// checkRelativeWorkdir checks first workdir of stages is absolute,
+// since relative one depends on workdir of base image.
+func checkRelativeWorkdir(d *Dockerfile) (findings []Finding) {
+	eachStage(d, func(p string, s *Stage) {
+		if s.Extends != "" || d.Stages[imageOfFrom(s.From)] != nil {
+			// relative to workdir of stage based on
+			return
+		}
+
+		workdir, workdirPath := firstWorkdir(p, s)
+		if workdir == "" || strings.HasPrefix(workdir, "$") {
+			return
+		}
+
+		if normalized := normalizeWorkdir(workdir); !path.IsAbs(normalized) && !isWindowsAbs(normalized) {
+			findings = append(findings, Finding{
+				Rule:     "relative-workdir",
+				Severity: SeverityWarning,
+				Path:     workdirPath,
+				Message:  "relative workdir " + workdir + " depends on workdir of base image, use an absolute one",
+			})
+		}
+	})
+	return
+}
+
+// isWindowsAbs tells path is absolute path of windows containers, like C:/app
+func isWindowsAbs(p string) bool {
+	return len(p) >= 3 && p[1] == ':' && p[2] == '/' && (p[0]|0x20 >= 'a' && p[0]|0x20 <= 'z')
+}
+
+// WithAbsoluteWorkdir fails writing when first workdir of stages is relative
+func WithAbsoluteWorkdir() WriteOption {
+	return func(o *writeOptions) {
+		o.absoluteWorkdir = true
+	}
+}
+
+func relativeWorkdirErrors(d *Dockerfile) error {
+	errs := errorList{}
+	for _, f := range checkRelativeWorkdir(d) {
+		errs.add(fieldError(f.Path, fmt.Errorf("%w: %s", ErrRelativeWorkdir, f.Message)))
+	}
+	return errs.err()
+}
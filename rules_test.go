@@ -0,0 +1,206 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestBuiltinRules(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: python:3.11
+    workdir: /src
+    run:
+      - apt-get update && apt-get install -y gcc
+      - cd /src && make
+      - pip install -r requirements.txt flask==2.3.0 ./local
+      - python -m pip install --no-cache-dir requests
+    steps:
+      - run:
+          - cmd: apt-get update && apt-get install -y curl
+            mount: ["type=cache,target=/var/lib/apt/lists"]
+      - run: [sudo make install]
+from: alpine
+add:
+  ./app.tar.gz: /opt/
+  https://example.com/a.txt: /opt/
+  ./config.yml: conf/
+copy:
+  builder:/src/dist: dist
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	findings := Lint(d, builtinRules...)
+
+	lines := make([]string, 0, len(findings))
+	for _, f := range findings {
+		lines = append(lines, f.Rule+" "+f.Path)
+	}
+
+	NewWithT(t).Expect(lines).To(Equal([]string{
+		`add-instead-of-copy add["./config.yml"]`,
+		`missing-workdir add["./config.yml"]`,
+		`missing-workdir copy["builder:/src/dist"]`,
+		`apt-cleanup stages.builder.run[0]`,
+		`apt-unpinned stages.builder.run[0]`,
+		`cd-in-run stages.builder.run[1]`,
+		`pip-unpinned stages.builder.run[3]`,
+		`apt-unpinned stages.builder.steps[0].run[0]`,
+		`sudo stages.builder.steps[1].run[0]`,
+	}))
+}
+
+func TestUnpinnedPackages(t *testing.T) {
+	cases := map[string]string{
+		"apt-get install -y --no-install-recommends curl=7.88.1-10 git":             "pin versions of apt packages git, like git=<version>",
+		"DEBIAN_FRONTEND=noninteractive apt-get -q install -y curl ca-certificates": "pin versions of apt packages curl, ca-certificates, like curl=<version>",
+		"apt-get install -y -t bookworm-backports ./pkg.deb curl=7.88.1-10":         "",
+		"apt-get install -y $PACKAGES":                                              "",
+		"apk add --no-cache --virtual .build-deps gcc musl-dev=1.2.4-r2":            "pin versions of apk packages gcc, like gcc=<version>",
+		"apk add --no-cache curl~8":                                                 "",
+		"apk update":                                                                "",
+		"pip install --no-cache-dir flask requests==2.31.0":                         "pin versions of pip packages flask, like flask==<version>",
+	}
+
+	for command, message := range cases {
+		d := &Dockerfile{}
+		d.From = "alpine:3.18"
+		d.Run = Scripts(command)
+
+		findings := Lint(d, RuleFunc(checkAptUnpinned), RuleFunc(checkApkUnpinned), RuleFunc(checkPipUnpinned))
+
+		if message == "" {
+			NewWithT(t).Expect(findings).To(HaveLen(0), command)
+			continue
+		}
+		NewWithT(t).Expect(findings).To(HaveLen(1), command)
+		NewWithT(t).Expect(findings[0].Message).To(Equal(message), command)
+	}
+}
+
+func TestNonRootUser(t *testing.T) {
+	read := func(spec string) *Dockerfile {
+		d, err := ReadFromYAML(strings.NewReader(spec))
+		NewWithT(t).Expect(err).To(BeNil())
+		return d
+	}
+
+	t.Run("off by default", func(t *testing.T) {
+		d := read("from: alpine\n")
+		NewWithT(t).Expect(Lint(d, RuleFunc(checkNonRootUser))).To(HaveLen(0))
+	})
+
+	t.Run("enabled by lint", func(t *testing.T) {
+		d := read("from: alpine\nuser: root:root\nlint:\n  non-root-user: error\n")
+
+		findings := Lint(d, RuleFunc(checkNonRootUser))
+		NewWithT(t).Expect(findings).To(HaveLen(1))
+		NewWithT(t).Expect(findings[0].String()).To(Equal("2:1: error[non-root-user] user: final stage runs as root, set user to a non-root one"))
+	})
+
+	t.Run("user of stage", func(t *testing.T) {
+		cases := map[string]string{
+			"from: alpine\nuser: nobody\n":                                            "",
+			"from: alpine\nuser: \"0\"\n":                                             "user",
+			"from: alpine\nsteps:\n  - user: app\n  - run: [make]\n":                  "",
+			"from: alpine\nsteps:\n  - user: app\n  - user: root\n":                   "steps[1].user",
+			"stages:\n  base:\n    from: alpine\n    user: app\nfrom: base\n":         "",
+			"stages:\n  base:\n    from: alpine\n    user: app\nextends: base\n":      "",
+			"stages:\n  base:\n    from: alpine\n    user: root\nfrom: base\n":        "stages.base.user",
+			"target: app\nstages:\n  app:\n    from: alpine\nfrom: alpine\nuser: a\n": "stages.app.user",
+		}
+
+		for spec, path := range cases {
+			err := nonRootUserError(read(spec))
+			if path == "" {
+				NewWithT(t).Expect(err).To(BeNil(), spec)
+				continue
+			}
+			NewWithT(t).Expect(errors.Is(err, ErrRootUser)).To(BeTrue(), spec)
+			NewWithT(t).Expect(err.(*FieldError).Path).To(Equal(path), spec)
+		}
+	})
+
+	t.Run("option", func(t *testing.T) {
+		d := read("from: alpine\n")
+
+		err := WriteToDockerfile(bytes.NewBuffer(nil), *d, WithNonRootUser())
+		NewWithT(t).Expect(err).To(MatchError("user: root user: final stage runs as user of base image, which is root by default, set user to a non-root one"))
+
+		d.User = "app"
+		err = WriteToDockerfile(bytes.NewBuffer(nil), *d, WithNonRootUser())
+		NewWithT(t).Expect(err).To(BeNil())
+	})
+}
+
+func TestUntaggedImage(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang:latest
+  tools:
+    from: --platform=$BUILDPLATFORM localhost:5000/tools
+  pinned:
+    from: alpine@sha256:c5b1261d6d3e43071626931fc004f70149baeba2c8ec672bd4f27761f8e1ad6b
+  base:
+    from: ${BASE_IMAGE}
+  app:
+    from: builder
+from: gcr.io/distroless/static:nonroot
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	NewWithT(t).Expect(Lint(d, RuleFunc(checkUntaggedImage))).To(HaveLen(0))
+
+	err = WriteToDockerfile(bytes.NewBuffer(nil), *d, WithTaggedImages())
+	NewWithT(t).Expect(errors.Is(err, ErrUntaggedImage)).To(BeTrue())
+	NewWithT(t).Expect(err.Error()).To(Equal(`2 problems of spec:
+  - 4:5: stages.builder.from: untagged image: image golang:latest of stage builder is of latest tag, set a tag for reproducible builds
+  - 6:5: stages.tools.from: untagged image: image localhost:5000/tools of stage tools has no tag, which is latest, set a tag for reproducible builds`))
+}
+
+func TestRelativeWorkdir(t *testing.T) {
+	read := func(spec string) *Dockerfile {
+		d, err := ReadFromYAML(strings.NewReader(spec))
+		NewWithT(t).Expect(err).To(BeNil())
+		return d
+	}
+
+	cases := map[string]string{
+		"from: alpine\nworkdir: /src\n":                                                     "",
+		"from: alpine\nworkdir: src\n":                                                      "workdir",
+		"from: alpine\nworkdir: $HOME/src\n":                                                "",
+		"from: mcr.microsoft.com/windows/nanoserver\nworkdir: C:\\\\app\n":                  "",
+		"from: alpine\nsteps:\n  - workdir: src\n  - workdir: app\n":                        "steps[0].workdir",
+		"from: alpine\nsteps:\n  - workdir: /src\n  - workdir: app\n":                       "",
+		"stages:\n  base:\n    from: alpine\n    workdir: /src\nfrom: base\nworkdir: app\n": "",
+	}
+
+	for spec, path := range cases {
+		err := relativeWorkdirErrors(read(spec))
+		if path == "" {
+			NewWithT(t).Expect(err).To(BeNil(), spec)
+			continue
+		}
+		NewWithT(t).Expect(errors.Is(err, ErrRelativeWorkdir)).To(BeTrue(), spec)
+		fe := &FieldError{}
+		NewWithT(t).Expect(errors.As(err, &fe)).To(BeTrue(), spec)
+		NewWithT(t).Expect(fe.Path).To(Equal(path), spec)
+	}
+
+	t.Run("normalized", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		err := WriteToDockerfile(buf, Dockerfile{Stage: Stage{
+			From:       "alpine",
+			WorkingDir: "/src//app/",
+			Steps:      []Step{{WorkingDir: "C:\\app\\bin"}, {WorkingDir: "${HOME}/../x"}},
+		}})
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(Equal("FROM alpine\n\nWORKDIR /src/app\n\nWORKDIR C:/app/bin\n\nWORKDIR ${HOME}/../x\n\n"))
+	})
+}
@@ -0,0 +1,168 @@
+package dockerfileyml
+
+import (
+	"strconv"
+	"strings"
+)
+
+// rules of runtime config of final image, complementing rules of build
+
+// checkMissingHealthcheck checks final image has HEALTHCHECK, for orchestrators to detect unhealthy containers
+func checkMissingHealthcheck(d *Dockerfile) (findings []Finding) {
+	found := false
+
+	eachFinalStage(d, func(p string, s *Stage) bool {
+		found = s.Healthcheck != ""
+		return !found
+	})
+
+	if !found {
+		p := "healthcheck"
+		if d.Target != "" {
+			p = yamlPath("stages", d.Target) + ".healthcheck"
+		}
+
+		findings = append(findings, Finding{
+			Rule:     "missing-healthcheck",
+			Severity: SeverityOff,
+			Path:     p,
+			Message:  "final stage has no healthcheck, set one, or NONE to disable healthcheck of base image",
+		})
+	}
+
+	return
+}
+
+// checkPrivilegedPort checks ports exposed by final image are not privileged,
+// which could not be listened by non-root users without NET_BIND_SERVICE.
+func checkPrivilegedPort(d *Dockerfile) (findings []Finding) {
+	check := func(p string, ports []string) {
+		for i, port := range ports {
+			// like 80, 80/tcp or 80-90
+			n, err := strconv.Atoi(strings.FieldsFunc(port, func(r rune) bool { return r == '/' || r == '-' })[0])
+			if err != nil || n <= 0 || n >= 1024 {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Rule:     "privileged-port",
+				Severity: SeverityWarning,
+				Path:     p + indexPath(i),
+				Message:  "port " + port + " is privileged, which needs root or NET_BIND_SERVICE to listen, expose a port from 1024 instead",
+			})
+		}
+	}
+
+	eachFinalStage(d, func(p string, s *Stage) bool {
+		check(fieldPath(p, "expose"), s.Expose)
+
+		for i, step := range s.Steps {
+			check(fieldPath(p, "steps")+indexPath(i)+".expose", step.Expose)
+		}
+
+		return true
+	})
+
+	return
+}
+
+// checkSetuid checks files of final image are not made setuid or setgid,
+// which could be used for privilege escalation.
+func checkSetuid(d *Dockerfile) (findings []Finding) {
+	checkCopy := func(p string, c Copies) {
+		for _, pair := range c.pairs(p) {
+			for _, word := range strings.Fields(pair.key) {
+				if strings.HasPrefix(word, "--chmod=") && isSetuidMode(strings.TrimPrefix(word, "--chmod=")) {
+					findings = append(findings, Finding{
+						Rule:     "setuid",
+						Severity: SeverityWarning,
+						Path:     pair.path,
+						Message:  "files are copied with setuid or setgid bit by " + word + ", remove the bit unless required",
+					})
+				}
+			}
+		}
+	}
+
+	checkRun := func(p string, scripts []Script) {
+		for i, script := range scripts {
+			for _, words := range commandsOf(script) {
+				if words[0] != "chmod" {
+					continue
+				}
+
+				for _, word := range words[1:] {
+					if !strings.HasPrefix(word, "-") && isSetuidMode(word) {
+						findings = append(findings, Finding{
+							Rule:     "setuid",
+							Severity: SeverityWarning,
+							Path:     p + indexPath(i),
+							Message:  "chmod " + word + " sets setuid or setgid bit, remove the bit unless required",
+						})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	eachFinalStage(d, func(p string, s *Stage) bool {
+		checkCopy(fieldPath(p, "copy"), s.Copy)
+		checkCopy(fieldPath(p, "add"), CopyValues(s.Add))
+		checkRun(fieldPath(p, "run"), s.Run)
+
+		for i, step := range s.Steps {
+			stepPath := fieldPath(p, "steps") + indexPath(i)
+			checkCopy(stepPath+".copy", step.Copy)
+			checkCopy(stepPath+".add", CopyValues(step.Add))
+			checkRun(stepPath+".run", step.Run)
+		}
+
+		return true
+	})
+
+	return
+}
+
+// isSetuidMode tells mode of chmod sets setuid or setgid bit, like 4755 or u+s
+func isSetuidMode(mode string) bool {
+	if n, err := strconv.ParseUint(mode, 8, 32); err == nil {
+		return len(mode) >= 4 && n&06000 != 0
+	}
+
+	for _, clause := range strings.Split(mode, ",") {
+		if i := strings.IndexAny(clause, "+="); i >= 0 && strings.Contains(clause[i:], "s") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkShellFormSignals checks command of final image in shell form,
+// which runs as child of /bin/sh -c, so SIGTERM of docker stop is not received by it.
+func checkShellFormSignals(d *Dockerfile) (findings []Finding) {
+	eachFinalStage(d, func(p string, s *Stage) bool {
+		field, command, form := "entrypoint", s.Entrypoint, s.EntrypointForm
+		if len(command) == 0 {
+			field, command, form = "cmd", s.Command, s.CommandForm
+		}
+
+		if len(command) == 0 {
+			return true
+		}
+
+		if form == FormShell && !strings.HasPrefix(strings.TrimSpace(strings.Join(command, " ")), "exec ") {
+			findings = append(findings, Finding{
+				Rule:     "shell-form-signals",
+				Severity: SeverityWarning,
+				Path:     fieldPath(p, field),
+				Message:  "shell form " + strings.ToUpper(field) + " runs under /bin/sh -c, which does not forward signals, use exec form or prefix with exec",
+			})
+		}
+
+		return false
+	})
+
+	return
+}
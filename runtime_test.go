@@ -0,0 +1,88 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRuntimeRules(t *testing.T) {
+	paths := func(spec string, rule RuleFunc) []string {
+		d, err := ReadFromYAML(strings.NewReader(spec))
+		NewWithT(t).Expect(err).To(BeNil())
+
+		list := make([]string, 0)
+		for _, f := range rule(d) {
+			list = append(list, f.Path)
+		}
+		return list
+	}
+
+	t.Run("missing healthcheck", func(t *testing.T) {
+		NewWithT(t).Expect(Lint(&Dockerfile{Stage: Stage{From: "alpine"}}, RuleFunc(checkMissingHealthcheck))).To(HaveLen(0))
+
+		cases := map[string][]string{
+			"from: alpine\n":                    {"healthcheck"},
+			"from: alpine\nhealthcheck: NONE\n": {},
+			"stages:\n  base:\n    from: alpine\n    healthcheck: CMD true\nfrom: base\n": {},
+			"target: app\nstages:\n  app:\n    from: alpine\nfrom: alpine\n":              {"stages.app.healthcheck"},
+		}
+
+		for spec, expected := range cases {
+			NewWithT(t).Expect(paths(spec, checkMissingHealthcheck)).To(Equal(expected), spec)
+		}
+	})
+
+	t.Run("privileged port", func(t *testing.T) {
+		cases := map[string][]string{
+			"from: alpine\nexpose: [\"8080\", \"80/tcp\", \"$PORT\"]\n":              {"expose[1]"},
+			"from: alpine\nsteps:\n  - expose: [\"443\"]\n":                          {"steps[0].expose[0]"},
+			"stages:\n  web:\n    from: nginx\n    expose: [\"80\"]\nfrom: alpine\n": {},
+		}
+
+		for spec, expected := range cases {
+			NewWithT(t).Expect(paths(spec, checkPrivilegedPort)).To(Equal(expected), spec)
+		}
+	})
+
+	t.Run("setuid", func(t *testing.T) {
+		cases := map[string][]string{
+			"from: alpine\ncopy:\n  --chmod=4755 ./app: /usr/bin/app\n":           {`copy["--chmod=4755 ./app"]`},
+			"from: alpine\ncopy:\n  --chmod=755 ./app: /usr/bin/app\n":            {},
+			"from: alpine\nrun:\n  - chmod u+s /usr/bin/app\n  - chmod 0755 /a\n": {"run[0]"},
+			"from: alpine\nsteps:\n  - run: [chmod -R g+s,o-w /data]\n":           {"steps[0].run[0]"},
+		}
+
+		for spec, expected := range cases {
+			NewWithT(t).Expect(paths(spec, checkSetuid)).To(Equal(expected), spec)
+		}
+	})
+
+	t.Run("shell form signals", func(t *testing.T) {
+		cases := map[string][]string{
+			"from: alpine\nentrypoint: [app serve]\nentrypoint-form: shell\n":                       {"entrypoint"},
+			"from: alpine\nentrypoint: [exec app serve]\nentrypoint-form: shell\n":                  {},
+			"from: alpine\ncmd: [app serve]\ncmd-form: shell\n":                                     {"cmd"},
+			"from: alpine\nentrypoint: [app]\ncmd: [serve]\ncmd-form: shell\n":                      {},
+			"stages:\n  base:\n    from: alpine\n    cmd: [app]\n    cmd-form: shell\nfrom: base\n": {"stages.base.cmd"},
+		}
+
+		for spec, expected := range cases {
+			NewWithT(t).Expect(paths(spec, checkShellFormSignals)).To(Equal(expected), spec)
+		}
+	})
+
+	t.Run("healthcheck", func(t *testing.T) {
+		d := &Dockerfile{Stage: Stage{From: "alpine", Healthcheck: "--interval=30s CMD wget -q -O- http://localhost:8080/"}}
+
+		buf := bytes.NewBuffer(nil)
+		NewWithT(t).Expect(WriteToDockerfile(buf, *d)).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(ContainSubstring("HEALTHCHECK --interval=30s CMD wget -q -O- http://localhost:8080/\n"))
+
+		parsed, err := ParseDockerfile(bytes.NewReader(buf.Bytes()))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(parsed.Healthcheck).To(Equal(d.Healthcheck))
+	})
+}
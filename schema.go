@@ -0,0 +1,126 @@
+package dockerfileyml
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Schema returns JSON Schema of dockerfile.yml,
+// for editors and validators to check specs without generating.
+func Schema() []byte {
+	g := &schemaGenerator{definitions: map[string]interface{}{}}
+
+	root := g.object(reflect.TypeOf(Dockerfile{}))
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "dockerfile.yml"
+	root["definitions"] = g.definitions
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+var typeForm = reflect.TypeOf(Form(""))
+
+type schemaGenerator struct {
+	definitions map[string]interface{}
+}
+
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+
+	for name, ft := range yamlFields(t) {
+		properties[name] = g.schemaOf(ft)
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		// x- prefixed keys are for holding anchors
+		"patternProperties": map[string]interface{}{
+			"^x-": map[string]interface{}{},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == typeForm {
+		return map[string]interface{}{
+			"type": "string",
+			"enum": []Form{FormExec, FormShell},
+		}
+	}
+
+	switch t {
+	case typeCopies:
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				g.schemaOf(reflect.TypeOf(Values{})),
+				g.schemaOf(reflect.TypeOf([]CopyEntry{})),
+			},
+		}
+	case typePaths:
+		return map[string]interface{}{
+			"oneOf": []interface{}{g.scalar(), g.schemaOf(reflect.TypeOf([]string{}))},
+		}
+	case typeKeyValues:
+		return g.schemaOf(reflect.TypeOf(Values{}))
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// placeholder for recursive types
+			g.definitions[t.Name()] = map[string]interface{}{}
+			g.definitions[t.Name()] = g.object(t)
+		}
+
+		ref := map[string]interface{}{
+			"$ref": "#/definitions/" + t.Name(),
+		}
+
+		if reflect.PtrTo(t).Implements(typeYAMLUnmarshaler) {
+			// custom types accept scalar short form
+			return map[string]interface{}{
+				"oneOf": []interface{}{g.scalar(), ref},
+			}
+		}
+
+		return ref
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": g.schemaOf(t.Elem()),
+		}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": g.schemaOf(t.Elem()),
+		}
+	case reflect.Bool:
+		return map[string]interface{}{
+			"type": "boolean",
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{
+			"type": "integer",
+		}
+	}
+
+	return g.scalar()
+}
+
+// scalar of yaml is decoded as string, like 8080 for expose
+func (g *schemaGenerator) scalar() map[string]interface{} {
+	return map[string]interface{}{
+		"type": []string{"string", "number", "boolean"},
+	}
+}
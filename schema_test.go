@@ -0,0 +1,25 @@
+package dockerfileyml
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+)
+
+func TestSchema(t *testing.T) {
+	data := Schema()
+
+	v := map[string]interface{}{}
+	NewWithT(t).Expect(json.Unmarshal(data, &v)).To(BeNil())
+	NewWithT(t).Expect(v["properties"]).To(HaveKey("stages"))
+	NewWithT(t).Expect(v["properties"]).To(HaveKey("from"))
+	NewWithT(t).Expect(v["definitions"]).To(HaveKey("Stage"))
+
+	NewWithT(t).Expect(string(data)).To(MatchSnapshot("dockerfile.schema.json"))
+}
+
+func TestCUESchema(t *testing.T) {
+	NewWithT(t).Expect(string(CUESchema())).To(MatchSnapshot("dockerfile.cue"))
+}
@@ -0,0 +1,131 @@
+package dockerfileyml
+
+import (
+	"strings"
+)
+
+func Scripts(args ...string) []Script {
+	scripts := make([]Script, len(args))
+	for i := range args {
+		scripts[i] = Script{Command: args[i]}
+	}
+	return scripts
+}
+
+// Script is one command of RUN,
+// in yaml it could be a plain string or an object with BuildKit flags.
+//
+// Adjacent scripts with same flags are joined by && into one RUN,
+// unless NoJoin is set, which opts out of WithMergedRuns too.
+type Script struct {
+	Command  string   `yaml:"cmd"`
+	Mount    []string `yaml:"mount,omitempty"`
+	Network  string   `yaml:"network,omitempty"`
+	Security string   `yaml:"security,omitempty"`
+	NoJoin   bool     `yaml:"no-join,omitempty"`
+	// Comment is written as # lines before RUN of the script
+	Comment string `yaml:"comment,omitempty"`
+}
+
+func (s *Script) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	cmd := ""
+	if err := unmarshal(&cmd); err == nil {
+		*s = Script{Command: cmd}
+		return nil
+	}
+
+	type script Script
+	return unmarshal((*script)(s))
+}
+
+func (s Script) MarshalYAML() (interface{}, error) {
+	if len(s.flags()) == 0 && !s.NoJoin && s.Comment == "" {
+		return s.Command, nil
+	}
+
+	type script Script
+	return script(s), nil
+}
+
+func (s *Script) flags() (flags []string) {
+	for _, m := range s.Mount {
+		flags = append(flags, "--mount="+m)
+	}
+	if s.Network != "" {
+		flags = append(flags, "--network="+s.Network)
+	}
+	if s.Security != "" {
+		flags = append(flags, "--security="+s.Security)
+	}
+	return
+}
+
+// separators of scripts joined into one RUN
+const (
+	RunJoinAnd       = "&&"
+	RunJoinSemicolon = ";"
+)
+
+type scriptGroup struct {
+	flags    []string
+	commands []string
+	// comments of scripts of the group
+	comments []string
+	// noJoin group is of one script with NoJoin
+	noJoin bool
+}
+
+// values returns flags and command of RUN, with scripts joined by join, && by default,
+// and prelude before them, like set -eux
+func (g *scriptGroup) values(join string, prelude string) []string {
+	return append(append([]string{}, g.flags...), joinScripts(g.commands, join, prelude, " "))
+}
+
+// joinScripts joins commands by join with space or continuation of line after join,
+// prelude is separated from commands by ;
+func joinScripts(commands []string, join string, prelude string, space string) string {
+	if join == "" {
+		join = RunJoinAnd
+	}
+
+	command := strings.Join(commands, " "+join+space)
+	if join == RunJoinSemicolon {
+		command = strings.Join(commands, join+space)
+	}
+
+	if prelude != "" {
+		return strings.TrimSuffix(prelude, ";") + ";" + space + command
+	}
+	return command
+}
+
+// scriptGroups splits scripts into RUN instructions
+func scriptGroups(scripts []Script) (groups []*scriptGroup) {
+	joinable := false
+
+	for i := range scripts {
+		s := scripts[i]
+		flags := s.flags()
+
+		if joinable && !s.NoJoin {
+			last := groups[len(groups)-1]
+
+			if strings.Join(last.flags, " ") == strings.Join(flags, " ") {
+				last.commands = append(last.commands, s.Command)
+				if s.Comment != "" {
+					last.comments = append(last.comments, s.Comment)
+				}
+				continue
+			}
+		}
+
+		group := &scriptGroup{flags: flags, commands: []string{s.Command}, noJoin: s.NoJoin}
+		if s.Comment != "" {
+			group.comments = []string{s.Comment}
+		}
+		groups = append(groups, group)
+		joinable = !s.NoJoin
+	}
+
+	return
+}
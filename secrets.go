@@ -0,0 +1,144 @@
+package dockerfileyml
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// well-known credential patterns, values matched are errors
+var credentialPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"AWS access key id", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b`)},
+	{"credentials in url", regexp.MustCompile(`://[^/\s:@]+:[^/\s@]+@`)},
+}
+
+// names of keys which hold secrets usually, like DB_PASSWORD
+var reSecretKey = regexp.MustCompile(`(?i)(?:password|passwd|secret|token|api_?key|private_?key|credential)`)
+
+// values of secret keys, which are not secrets, like true or references of args
+var reNotSecretValue = regexp.MustCompile(`(?i)^(?:|true|false|yes|no|none|null|changeme|\$.*|/.*)$`)
+
+const (
+	// minimal length and entropy in bits per char of high-entropy strings,
+	// random strings of base64 or hex are above, while words and paths are below.
+	minSecretLength  = 20
+	minSecretEntropy = 4.0
+)
+
+// checkSecretInEnv checks values of env and arg which look like secrets,
+// since they are kept in image layers and history.
+func checkSecretInEnv(d *Dockerfile) (findings []Finding) {
+	check := func(p string, values Values) {
+		for _, key := range sortedValueKeys(values) {
+			value := values[key]
+
+			if name := credentialOf(value); name != "" {
+				findings = append(findings, Finding{
+					Rule:     "secret-in-env",
+					Severity: SeverityError,
+					Path:     yamlPath(p, key),
+					Message:  "value of " + key + " looks like " + name + ", which is kept in image, use secret mount of run instead",
+				})
+				continue
+			}
+
+			if reSecretKey.MatchString(key) && !reNotSecretValue.MatchString(value) {
+				findings = append(findings, Finding{
+					Rule:     "secret-in-env",
+					Severity: SeverityWarning,
+					Path:     yamlPath(p, key),
+					Message:  key + " looks like a secret, which is kept in image, use secret mount of run instead",
+				})
+				continue
+			}
+
+			if isHighEntropy(value) {
+				findings = append(findings, Finding{
+					Rule:     "secret-in-env",
+					Severity: SeverityWarning,
+					Path:     yamlPath(p, key),
+					Message:  "value of " + key + " is a high-entropy string, which looks like a secret kept in image, use secret mount of run instead",
+				})
+			}
+		}
+	}
+
+	eachStage(d, func(p string, s *Stage) {
+		check(fieldPath(p, "env"), s.Env.Values())
+		check(fieldPath(p, "arg"), s.Arg.Values())
+
+		for i, step := range s.Steps {
+			check(fieldPath(p, "steps")+indexPath(i)+".env", step.Env.Values())
+			check(fieldPath(p, "steps")+indexPath(i)+".arg", step.Arg.Values())
+		}
+	})
+
+	return
+}
+
+// credentialOf returns name of well-known credential pattern matched by value
+func credentialOf(value string) string {
+	for _, c := range credentialPatterns {
+		if c.pattern.MatchString(value) {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// isHighEntropy tells value is a long random string, like a token in base64 or hex
+func isHighEntropy(value string) bool {
+	if len(value) < minSecretLength || strings.ContainsAny(value, " $/") {
+		return false
+	}
+
+	// random tokens mix lower, upper and digits, while hex digests and words don't
+	classes := 0
+	for _, class := range []string{"abcdefghijklmnopqrstuvwxyz", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "0123456789"} {
+		if strings.ContainsAny(value, class) {
+			classes++
+		}
+	}
+	if classes < 3 {
+		return false
+	}
+
+	return shannonEntropy(value) >= minSecretEntropy
+}
+
+// shannonEntropy returns entropy in bits per char of s
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func sortedValueKeys(values Values) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
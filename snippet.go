@@ -0,0 +1,239 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Snippet is a reusable group of steps, referred by step with use,
+// params in values are written as {{ name }}, and substituted at render time.
+//
+//	snippets:
+//	  install-node:
+//	    params:
+//	      version: "14"
+//	    steps:
+//	      - run: [curl -fsSL https://deb.nodesource.com/setup_{{ version }}.x | bash -]
+//	stages:
+//	  builder:
+//	    steps:
+//	      - use: install-node
+//	        with:
+//	          version: "15"
+type Snippet struct {
+	// Params with default values, empty default means required
+	Params Values `yaml:"params,omitempty"`
+	Steps  []Step `yaml:"steps,omitempty"`
+}
+
+var reSnippetParam = regexp.MustCompile(`{{\s*([A-Za-z0-9_-]+)\s*}}`)
+
+// expandSnippets replaces steps using snippets by steps of snippets
+func expandSnippets(d *Dockerfile) error {
+	if len(d.Snippets) == 0 {
+		return nil
+	}
+
+	stages := make(map[string]*Stage, len(d.Stages))
+
+	for name := range d.Stages {
+		s := *d.Stages[name]
+
+		steps, err := d.expandSteps(s.Steps, nil)
+		if err != nil {
+			return fmt.Errorf("stage %s: %w", name, err)
+		}
+
+		s.Steps = steps
+		stages[name] = &s
+	}
+
+	steps, err := d.expandSteps(d.Steps, nil)
+	if err != nil {
+		return err
+	}
+
+	d.Stages = stages
+	d.Steps = steps
+
+	return nil
+}
+
+func (d *Dockerfile) expandSteps(steps []Step, chain []string) ([]Step, error) {
+	if steps == nil {
+		return nil, nil
+	}
+
+	expanded := make([]Step, 0, len(steps))
+
+	for i := range steps {
+		step := steps[i]
+
+		if step.Use == "" {
+			if len(step.With) > 0 {
+				return nil, fmt.Errorf("step %d: with is only for use", i)
+			}
+			expanded = append(expanded, step)
+			continue
+		}
+
+		if n := step.instructionCount(); n > 0 {
+			return nil, fmt.Errorf("step %d: use should not be with other instructions", i)
+		}
+
+		if stringIncludes(chain, step.Use) {
+			return nil, fmt.Errorf("circular use of snippets %s", strings.Join(append(chain, step.Use), " -> "))
+		}
+
+		snippet, ok := d.Snippets[step.Use]
+		if !ok {
+			return nil, fmt.Errorf("step %d: missing snippet %s", i, step.Use)
+		}
+
+		params, err := snippet.params(step.With)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: snippet %s: %w", i, step.Use, err)
+		}
+
+		substituted := make([]Step, len(snippet.Steps))
+
+		for j := range snippet.Steps {
+			v, err := substitute(reflect.ValueOf(snippet.Steps[j]), func(s string) (string, error) {
+				return substituteParams(s, params)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("step %d: snippet %s: %w", i, step.Use, err)
+			}
+			substituted[j] = v.Interface().(Step)
+		}
+
+		nested, err := d.expandSteps(substituted, append(chain, step.Use))
+		if err != nil {
+			return nil, err
+		}
+
+		expanded = append(expanded, nested...)
+	}
+
+	return expanded, nil
+}
+
+func (snippet *Snippet) params(with Values) (Values, error) {
+	params := Values{}
+
+	for key, v := range snippet.Params {
+		params[key] = v
+	}
+
+	for key, v := range with {
+		if _, ok := snippet.Params[key]; !ok {
+			return nil, fmt.Errorf("unknown param %s", key)
+		}
+		params[key] = v
+	}
+
+	missing := make([]string, 0)
+	for key, v := range params {
+		if v == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing params %s", strings.Join(missing, ", "))
+	}
+
+	return params, nil
+}
+
+func substituteParams(s string, params Values) (string, error) {
+	var err error
+
+	s = reSnippetParam.ReplaceAllStringFunc(s, func(ref string) string {
+		name := reSnippetParam.FindStringSubmatch(ref)[1]
+
+		v, ok := params[name]
+		if !ok {
+			err = fmt.Errorf("undefined param %s", name)
+			return ref
+		}
+		return v
+	})
+
+	return s, err
+}
+
+// substitute returns a deep copy of v with all strings replaced by fn, including map keys
+func substitute(v reflect.Value, fn func(s string) (string, error)) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := fn(v.String())
+		if err != nil {
+			return v, err
+		}
+		return reflect.ValueOf(s).Convert(v.Type()), nil
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			f, err := substitute(v.Field(i), fn)
+			if err != nil {
+				return v, err
+			}
+			copied.Field(i).Set(f)
+		}
+
+		return copied, nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := substitute(v.Elem(), fn)
+		if err != nil {
+			return v, err
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(elem)
+		return copied, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, err := substitute(v.Index(i), fn)
+			if err != nil {
+				return v, err
+			}
+			copied.Index(i).Set(item)
+		}
+		return copied, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			k, err := substitute(key, fn)
+			if err != nil {
+				return v, err
+			}
+			item, err := substitute(v.MapIndex(key), fn)
+			if err != nil {
+				return v, err
+			}
+			copied.SetMapIndex(k, item)
+		}
+		return copied, nil
+	}
+
+	return v, nil
+}
@@ -0,0 +1,85 @@
+package dockerfileyml
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// SourceMap maps lines of generated Dockerfile to fields of spec, in order of lines,
+// to trace errors of docker build back to spec.
+type SourceMap []SourceMapping
+
+// SourceMapping is instruction of Dockerfile from field of spec
+type SourceMapping struct {
+	// Line of instruction in Dockerfile, from 1
+	Line int `json:"line"`
+	// EndLine is last line of instruction, for instructions in multiple lines
+	EndLine int `json:"endLine"`
+	// Instruction is key of instruction, like RUN
+	Instruction string `json:"instruction"`
+	// Path is yaml path of field, like stages.builder.run[0]
+	Path string `json:"path"`
+	// Position of path in source yaml, when read from yaml
+	Position Position `json:"position"`
+}
+
+// WithSourceMap fills m with source map of written Dockerfile
+func WithSourceMap(m *SourceMap) WriteOption {
+	return func(o *writeOptions) {
+		o.sourceMap = m
+	}
+}
+
+// WriteSourceMap writes m as json
+func WriteSourceMap(w io.Writer, m SourceMap) error {
+	return writeJSON(w, m)
+}
+
+// At returns mapping of instruction at line
+func (m SourceMap) At(line int) (SourceMapping, bool) {
+	i := sort.Search(len(m), func(i int) bool {
+		return m[i].EndLine >= line
+	})
+	if i < len(m) && m[i].Line <= line {
+		return m[i], true
+	}
+	return SourceMapping{}, false
+}
+
+// LinesOf returns lines of instructions from field of path or fields under it
+func (m SourceMap) LinesOf(path string) []int {
+	lines := make([]int, 0)
+	for _, mapping := range m {
+		if mapping.Path == path || strings.HasPrefix(mapping.Path, path+".") || strings.HasPrefix(mapping.Path, path+"[") {
+			lines = append(lines, mapping.Line)
+		}
+	}
+	return lines
+}
+
+// sourceMapOf returns source map of instructions written after offset lines
+func sourceMapOf(list []instruction, offset int, positions Positions) SourceMap {
+	m := make(SourceMap, 0, len(list))
+
+	lines := instructionLines(list)
+
+	for i, ins := range list {
+		if ins.Path == "" {
+			// like hoisted global args
+			continue
+		}
+
+		line := offset + lines[i]
+
+		m = append(m, SourceMapping{
+			Line:        line,
+			EndLine:     line + strings.Count(ins.String(), "\n"),
+			Instruction: ins.Key,
+			Path:        ins.Path,
+			Position:    positions.Of(ins.Path),
+		})
+	}
+
+	return m
+}
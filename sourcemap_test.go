@@ -0,0 +1,71 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSourceMap(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: golang
+    workdir: /go/src
+    steps:
+      - copy:
+          go.mod: ./
+      - run:
+          - go mod download
+          - go build -o app
+from: busybox
+copy:
+  builder:./app: /bin/app
+entrypoint: [app]
+`), WithFilename("dockerfile.yml"))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	m := SourceMap{}
+	buf := bytes.NewBuffer(nil)
+
+	err = WriteToDockerfile(buf, *d, WithSourceMap(&m), WithHeader(Header{}))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	lines := strings.Split(buf.String(), "\n")
+
+	for _, mapping := range m {
+		NewWithT(t).Expect(lines[mapping.Line-1]).To(HavePrefix(mapping.Instruction + " "))
+	}
+
+	paths := make([]string, len(m))
+	for i := range m {
+		paths[i] = m[i].Path
+	}
+
+	NewWithT(t).Expect(paths).To(Equal([]string{
+		"stages.builder.from",
+		"stages.builder.workdir",
+		`stages.builder.steps[0].copy["go.mod"]`,
+		"stages.builder.steps[1].run[0]",
+		"from",
+		`copy["builder:./app"]`,
+		"entrypoint",
+	}))
+
+	mapping, ok := m.At(m[3].Line)
+	NewWithT(t).Expect(ok).To(BeTrue())
+	NewWithT(t).Expect(mapping.Instruction).To(Equal("RUN"))
+	NewWithT(t).Expect(mapping.Position.String()).To(Equal("dockerfile.yml:10:13"))
+
+	_, ok = m.At(m[3].Line + 1)
+	NewWithT(t).Expect(ok).To(BeFalse())
+
+	NewWithT(t).Expect(m.LinesOf("stages.builder")).To(HaveLen(4))
+	NewWithT(t).Expect(m.LinesOf("copy")).To(Equal([]int{m[5].Line}))
+
+	buf.Reset()
+	NewWithT(t).Expect(WriteSourceMap(buf, m[:1])).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring(`"path": "stages.builder.from"`))
+}
@@ -0,0 +1,234 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// StrictError lists all problems found in strict mode
+type StrictError struct {
+	Errors []string
+}
+
+func (e *StrictError) Error() string {
+	return "yaml: strict errors:\n  " + strings.Join(e.Errors, "\n  ")
+}
+
+func checkStrict(v interface{}, target interface{}) error {
+	c := &strictChecker{}
+	c.check("", v, reflect.TypeOf(target))
+
+	if len(c.problems) > 0 {
+		errors := make([]string, len(c.problems))
+		for i, p := range c.problems {
+			path := p.Path
+			if path == "" {
+				path = "."
+			}
+			errors[i] = path + ": " + p.Message
+		}
+		return &StrictError{Errors: errors}
+	}
+	return nil
+}
+
+// strictFindings returns problems of strict mode as warnings, for reading in non-strict mode
+func strictFindings(v interface{}, target interface{}) []Finding {
+	c := &strictChecker{}
+	c.check("", v, reflect.TypeOf(target))
+	return c.problems
+}
+
+var typeYAMLUnmarshaler = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+type strictChecker struct {
+	problems []Finding
+}
+
+func (c *strictChecker) errorf(path string, format string, args ...interface{}) {
+	rule := "invalid-field"
+	if format == "unknown field" {
+		rule = "ignored-field"
+	}
+
+	c.problems = append(c.problems, Finding{
+		Rule:     rule,
+		Severity: SeverityWarning,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *strictChecker) check(path string, v interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if v == nil {
+		return
+	}
+
+	if t == typeCopies {
+		// copies are mapping of sources, or list of entries
+		if _, ok := v.([]interface{}); ok {
+			c.check(path, v, reflect.TypeOf([]CopyEntry{}))
+			return
+		}
+		c.check(path, v, reflect.TypeOf(Values{}))
+		return
+	}
+
+	if t == typeKeyValues {
+		c.check(path, v, reflect.TypeOf(Values{}))
+		return
+	}
+
+	if reflect.PtrTo(t).Implements(typeYAMLUnmarshaler) {
+		// custom types accept scalar short form
+		if _, ok := v.(map[interface{}]interface{}); !ok {
+			if _, ok := v.([]interface{}); !ok {
+				return
+			}
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			c.errorf(path, "should be mapping, but got %s", yamlKind(v))
+			return
+		}
+
+		fields := yamlFields(t)
+		keys, values := sortedEntries(m)
+
+		for _, key := range keys {
+			if isExtensionKey(key) {
+				continue
+			}
+
+			ft, ok := fields[key]
+			if !ok {
+				c.errorf(yamlPath(path, key), "unknown field")
+				continue
+			}
+			c.check(yamlPath(path, key), values[key], ft)
+		}
+	case reflect.Map:
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			c.errorf(path, "should be mapping, but got %s", yamlKind(v))
+			return
+		}
+
+		keys, values := sortedEntries(m)
+
+		for _, key := range keys {
+			c.check(yamlPath(path, key), values[key], t.Elem())
+		}
+	case reflect.Slice:
+		list, ok := v.([]interface{})
+		if !ok {
+			c.errorf(path, "should be sequence, but got %s", yamlKind(v))
+			return
+		}
+
+		for i := range list {
+			c.check(path+"["+strconv.Itoa(i)+"]", list[i], t.Elem())
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			c.errorf(path, "should be bool, but got %s", yamlKind(v))
+		}
+	case reflect.String:
+		switch v.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			c.errorf(path, "should be scalar, but got %s", yamlKind(v))
+		}
+	}
+}
+
+// isExtensionKey tells keys like x-base, which are only for holding anchors
+func isExtensionKey(key string) bool {
+	return strings.HasPrefix(key, "x-")
+}
+
+// sortedEntries returns sorted keys and string keyed values of yaml mapping
+func sortedEntries(m map[interface{}]interface{}) ([]string, map[string]interface{}) {
+	keys := make([]string, 0, len(m))
+	values := make(map[string]interface{}, len(m))
+
+	for k, v := range m {
+		key := fmt.Sprint(k)
+		keys = append(keys, key)
+		values[key] = v
+	}
+
+	sort.Strings(keys)
+
+	return keys, values
+}
+
+func yamlKind(v interface{}) string {
+	switch v.(type) {
+	case map[interface{}]interface{}:
+		return "mapping"
+	case []interface{}:
+		return "sequence"
+	}
+	return "scalar"
+}
+
+// yamlFields collects fields by yaml name, including ones of inline struct
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+
+		if stringIncludes(parts[1:], "inline") {
+			for name, ft := range yamlFields(f.Type) {
+				fields[name] = ft
+			}
+			continue
+		}
+
+		name := parts[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+var reYAMLPlainKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// yamlPath joins key to parent path, as stages.builder.copy["builder:./a.txt"]
+func yamlPath(parent string, key string) string {
+	if !reYAMLPlainKey.MatchString(key) {
+		return parent + "[" + strconv.Quote(key) + "]"
+	}
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
@@ -0,0 +1,239 @@
+package dockerfileyml
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImageUpdate is an available update of base image
+type ImageUpdate struct {
+	// Image without digest, as in spec
+	Image string
+	// Tag is the latest tag in same form of tag of Image, like 1.2.5 of 1.2.3, 1.22 of 1.21, or v3 of v2,
+	// empty when none newer
+	Tag string
+	// Locked is digest pinned in spec or locked in lockfile
+	Locked string
+	// Digest is current digest of tag of Image in registry
+	Digest string
+}
+
+// DigestChanged tells image of same tag is rebuilt since locked
+func (u *ImageUpdate) DigestChanged() bool {
+	return u.Locked != "" && u.Digest != u.Locked
+}
+
+func (u *ImageUpdate) String() string {
+	changes := make([]string, 0)
+	if u.Tag != "" {
+		changes = append(changes, "tag "+u.Tag+" available")
+	}
+	if u.DigestChanged() {
+		changes = append(changes, "digest changed "+u.Locked+" -> "+u.Digest)
+	}
+	return u.Image + ": " + strings.Join(changes, ", ")
+}
+
+// CheckUpdates compares images of documents with registry, and returns images with updates, in order of images.
+//
+// Digests are compared with those pinned in spec or locked in l, which could be nil.
+// Newer tags are checked for tags of version in form of [v]major[.minor[.patch]][-suffix],
+// by the last number of version, like patches of 1.2.3, minors of 1.21, or majors of v2.
+func CheckUpdates(ctx context.Context, list []*Dockerfile, l *Lockfile, r *Resolver) ([]ImageUpdate, error) {
+	locked := Values{}
+
+	for _, d := range list {
+		_, err := mapStages(d, func(s *Stage, stages []string) error {
+			image := imageOfFrom(s.From)
+
+			if image == "" || image == "scratch" || stringIncludes(stages, image) || strings.Contains(image, "$") {
+				return nil
+			}
+
+			parts := strings.SplitN(image, "@", 2)
+			if len(parts) == 2 {
+				locked[parts[0]] = parts[1]
+				return nil
+			}
+
+			if _, ok := locked[image]; !ok {
+				locked[image] = ""
+				if l != nil {
+					locked[image] = l.Images[image]
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	images := make([]string, 0, len(locked))
+	for image := range locked {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	updates := make([]ImageUpdate, 0)
+
+	for _, image := range images {
+		u := ImageUpdate{Image: image, Locked: locked[image]}
+
+		ref, err := parseImageRef(image)
+		if err != nil {
+			return nil, err
+		}
+
+		if v := parseTagVersion(ref.Tag); v != nil {
+			tags, err := r.Tags(ctx, image)
+			if err != nil {
+				return nil, err
+			}
+			u.Tag = latestTag(v, tags)
+		}
+
+		if u.Locked != "" {
+			u.Digest, err = r.Digest(ctx, image)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if u.Tag != "" || u.DigestChanged() {
+			updates = append(updates, u)
+		}
+	}
+
+	return updates, nil
+}
+
+var reNextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Tags lists tags of repository of image, following pagination of registry
+func (r *Resolver) Tags(ctx context.Context, image string) ([]string, error) {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0)
+	path := "/tags/list"
+	// links followed, registries could link a page again
+	visited := map[string]bool{}
+
+	for path != "" && !visited[path] {
+		visited[path] = true
+
+		resp, err := r.do(ctx, http.MethodGet, ref, path)
+		if err != nil {
+			return nil, fmt.Errorf("list tags of %s: %w", image, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("list tags of %s: %s", image, resp.Status)
+		}
+
+		list := struct {
+			Tags []string `json:"tags"`
+		}{}
+
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list tags of %s: %w", image, err)
+		}
+
+		tags = append(tags, list.Tags...)
+
+		path = ""
+		if m := reNextLink.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			path, err = nextTagsPath(resp.Request.URL, m[1], ref)
+			if err != nil {
+				return nil, fmt.Errorf("list tags of %s: %w", image, err)
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+// nextTagsPath resolves link against u of request, like /v2/<name>/tags/list?last=<tag>&n=<n>,
+// or absolute one on same registry, into path under repository of ref
+func nextTagsPath(u *url.URL, link string, ref *imageRef) (string, error) {
+	l, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("invalid link %s: %w", link, err)
+	}
+
+	next := u.ResolveReference(l)
+	prefix := "/v2/" + ref.Path + "/"
+
+	// credentials of registry are not sent to others
+	if next.Scheme != u.Scheme || next.Host != u.Host || !strings.HasPrefix(next.Path, prefix) {
+		return "", fmt.Errorf("link %s out of repository %s", link, ref.Path)
+	}
+
+	return strings.TrimPrefix(next.RequestURI(), prefix[:len(prefix)-1]), nil
+}
+
+// reTagVersion matches tags of version, like 1.2.3, 1.21, v2 or 1.2.3-alpine
+var reTagVersion = regexp.MustCompile(`^(v?)(\d+(?:\.\d+){0,2})(-.+)?$`)
+
+type tagVersion struct {
+	prefix string
+	// numbers of version, like 1, 21 of 1.21
+	numbers []int
+	suffix  string
+}
+
+func parseTagVersion(tag string) *tagVersion {
+	m := reTagVersion.FindStringSubmatch(tag)
+	if m == nil {
+		return nil
+	}
+
+	v := &tagVersion{prefix: m[1], suffix: m[3]}
+	for _, part := range strings.Split(m[2], ".") {
+		n, _ := strconv.Atoi(part)
+		v.numbers = append(v.numbers, n)
+	}
+
+	return v
+}
+
+// latestTag returns the latest tag newer than v, in same form of v, with same prefix and suffix,
+// and only the last number differed, like patches of 1.2.3, minors of 1.21, or majors of v2.
+func latestTag(v *tagVersion, tags []string) string {
+	latest := ""
+	last := len(v.numbers) - 1
+	number := v.numbers[last]
+
+	for _, tag := range tags {
+		t := parseTagVersion(tag)
+
+		if t == nil || t.prefix != v.prefix || t.suffix != v.suffix || len(t.numbers) != len(v.numbers) {
+			continue
+		}
+
+		if !reflect.DeepEqual(t.numbers[:last], v.numbers[:last]) {
+			continue
+		}
+
+		if t.numbers[last] > number {
+			latest, number = tag, t.numbers[last]
+		}
+	}
+
+	return latest
+}
@@ -0,0 +1,109 @@
+package dockerfileyml
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCheckUpdates(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v2/org/app/tags/list":
+			if req.URL.Query().Get("last") == "" {
+				rw.Header().Set("Link", `</v2/org/app/tags/list?last=1.2.4&n=3>; rel="next"`)
+				_, _ = rw.Write([]byte(`{"tags":["1.2.3","1.2.4","1.3.0"]}`))
+				return
+			}
+			_, _ = rw.Write([]byte(`{"tags":["1.2.10-alpine","1.2.5","latest"]}`))
+		case "/v2/org/tool/tags/list":
+			_, _ = rw.Write([]byte(`{"tags":["2.0","2.0.1","2.1","3.0","v2.2"]}`))
+		case "/v2/org/app/manifests/1.2.3", "/v2/org/tool/manifests/2.0":
+			rw.Header().Set("Docker-Content-Digest", testDigest)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	d := &Dockerfile{
+		Stages: map[string]*Stage{
+			"builder": {From: host + "/org/app:1.2.3@sha256:old"},
+			"tools":   {From: host + "/org/tool:2.0"},
+		},
+		Stage: Stage{From: "builder"},
+	}
+
+	updates, err := CheckUpdates(context.Background(), []*Dockerfile{d}, &Lockfile{Images: Values{host + "/org/tool:2.0": testDigest}}, newTestResolver())
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(updates).To(Equal([]ImageUpdate{
+		{Image: host + "/org/app:1.2.3", Tag: "1.2.5", Locked: "sha256:old", Digest: testDigest},
+		{Image: host + "/org/tool:2.0", Tag: "2.1", Locked: testDigest, Digest: testDigest},
+	}))
+	NewWithT(t).Expect(updates[0].String()).To(Equal(host + "/org/app:1.2.3: tag 1.2.5 available, digest changed sha256:old -> " + testDigest))
+}
+
+func TestLatestTag(t *testing.T) {
+	tags := []string{"1.2.3", "1.2.5", "1.21", "1.22", "1.22-alpine", "2.0", "v2", "v3", "v3.1", "20230101", "latest"}
+
+	cases := map[string]string{
+		"1.2.3":       "1.2.5",
+		"1.2.5":       "",
+		"1.21":        "1.22",
+		"1.21-alpine": "1.22-alpine",
+		"v2":          "v3",
+		"20221231":    "20230101",
+	}
+
+	for tag, expected := range cases {
+		NewWithT(t).Expect(latestTag(parseTagVersion(tag), tags)).To(Equal(expected), tag)
+	}
+
+	NewWithT(t).Expect(parseTagVersion("latest")).To(BeNil())
+	NewWithT(t).Expect(parseTagVersion("1.2.3.4")).To(BeNil())
+}
+
+func TestTags(t *testing.T) {
+	var s *httptest.Server
+
+	s = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v2/org/app/tags/list":
+			switch req.URL.Query().Get("last") {
+			case "":
+				// absolute link
+				rw.Header().Set("Link", `<`+s.URL+`/v2/org/app/tags/list?last=1.0.0>; rel="next"`)
+				_, _ = rw.Write([]byte(`{"tags":["1.0.0"]}`))
+			case "1.0.0":
+				// link relative to request
+				rw.Header().Set("Link", `<list?last=2.0.0>; rel="next"`)
+				_, _ = rw.Write([]byte(`{"tags":["2.0.0"]}`))
+			default:
+				// link again
+				rw.Header().Set("Link", `</v2/org/app/tags/list?last=1.0.0>; rel="next"`)
+				_, _ = rw.Write([]byte(`{"tags":["3.0.0"]}`))
+			}
+		case "/v2/org/other/tags/list":
+			rw.Header().Set("Link", `<https://registry.example/v2/org/other/tags/list?last=1.0.0>; rel="next"`)
+			_, _ = rw.Write([]byte(`{"tags":["1.0.0"]}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	tags, err := newTestResolver().Tags(context.Background(), host+"/org/app")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(tags).To(Equal([]string{"1.0.0", "2.0.0", "3.0.0"}))
+
+	_, err = newTestResolver().Tags(context.Background(), host+"/org/other")
+	NewWithT(t).Expect(err).To(MatchError(ContainSubstring("out of repository org/other")))
+}
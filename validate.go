@@ -0,0 +1,84 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WithValidator validates rendered Dockerfile before writing, like by parser of BuildKit,
+// to catch invalid output before building.
+// When validator returns *LineError, the error refers to the stage and instruction of spec.
+func WithValidator(validate func(dockerfile []byte) error) WriteOption {
+	return func(o *writeOptions) {
+		o.validator = validate
+	}
+}
+
+// LineError is error of validator at line of Dockerfile
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+func validateInstructions(list []instruction, validate func(dockerfile []byte) error) error {
+	buf := bytes.NewBuffer(nil)
+	if err := writeInstructions(buf, list); err != nil {
+		return err
+	}
+
+	err := validate(buf.Bytes())
+	if err == nil {
+		return nil
+	}
+
+	lineErr := &LineError{}
+	if !errors.As(err, &lineErr) {
+		return fmt.Errorf("invalid Dockerfile: %w", err)
+	}
+
+	lines := instructionLines(list)
+
+	for i := range list {
+		if lines[i] != lineErr.Line {
+			continue
+		}
+
+		ins := list[i]
+		stage := "main stage"
+		if ins.Stage != "" {
+			stage = "stage " + ins.Stage
+		}
+
+		return fmt.Errorf("invalid Dockerfile: %s of %s: %w", ins.Key, stage, err)
+	}
+
+	return fmt.Errorf("invalid Dockerfile: %w", err)
+}
+
+// instructionLines returns line of each instruction when written
+func instructionLines(list []instruction) []int {
+	lines := make([]int, len(list))
+	line := 1
+
+	for i := range list {
+		line += len(list[i].Comments)
+		lines[i] = line
+
+		line += 1 + strings.Count(list[i].String(), "\n")
+		if !list[i].Attached {
+			line++
+		}
+	}
+
+	return lines
+}
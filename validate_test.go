@@ -0,0 +1,46 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWithValidator(t *testing.T) {
+	d := Dockerfile{}
+	d.Stages = map[string]*Stage{
+		"builder": {From: "golang:1.15", Run: Scripts("go build")},
+	}
+	d.From = "busybox"
+	d.Copy = CopyValues(Values{"builder:/go/bin/app": "/bin/"})
+
+	// fails at first RUN
+	validator := func(dockerfile []byte) error {
+		for i, line := range strings.Split(string(dockerfile), "\n") {
+			if strings.HasPrefix(line, "RUN") {
+				return &LineError{Line: i + 1, Err: errors.New("unknown instruction")}
+			}
+		}
+		return nil
+	}
+
+	err := WriteToDockerfile(bytes.NewBuffer(nil), d, WithValidator(validator))
+	NewWithT(t).Expect(err).To(MatchError("invalid Dockerfile: RUN of stage builder: line 3: unknown instruction"))
+
+	d.Stages["builder"].Run = nil
+	d.Run = Scripts("ls")
+	err = WriteToDockerfile(bytes.NewBuffer(nil), d, WithValidator(validator))
+	NewWithT(t).Expect(err).To(MatchError("invalid Dockerfile: RUN of main stage: line 7: unknown instruction"))
+
+	err = WriteToDockerfile(bytes.NewBuffer(nil), d, WithValidator(func(dockerfile []byte) error {
+		return errors.New("empty")
+	}))
+	NewWithT(t).Expect(err).To(MatchError("invalid Dockerfile: empty"))
+
+	d.Run = nil
+	err = WriteToDockerfile(bytes.NewBuffer(nil), d, WithValidator(validator))
+	NewWithT(t).Expect(err).To(BeNil())
+}
@@ -0,0 +1,70 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ApplyVariant returns a new Dockerfile with variant of name merged,
+// the variant is rendered to its own output, which defaults to output of spec (or Dockerfile) with .<name> suffix.
+//
+//	variants:
+//	  debug:
+//	    from: busybox
+func ApplyVariant(d *Dockerfile, name string) (*Dockerfile, error) {
+	v, ok := d.Variants[name]
+	if !ok {
+		return nil, fmt.Errorf("missing variant %s", name)
+	}
+	if len(v.Variants) > 0 {
+		return nil, fmt.Errorf("variant %s: variants in variant is not supported", name)
+	}
+
+	base := *d
+	base.Variants = nil
+
+	applied := Merge(&base, v)
+
+	if v.Name == "" {
+		applied.Name = name
+		if d.Name != "" {
+			applied.Name = d.Name + "-" + name
+		}
+	}
+
+	if v.Output == "" {
+		output := d.Output
+		if output == "" {
+			output = "Dockerfile"
+		}
+		applied.Output = output + "." + name
+	}
+
+	return applied, nil
+}
+
+// ExpandVariants returns all variants of d in order of names,
+// d itself is returned when without variants.
+func ExpandVariants(d *Dockerfile) ([]*Dockerfile, error) {
+	if len(d.Variants) == 0 {
+		return []*Dockerfile{d}, nil
+	}
+
+	names := make([]string, 0, len(d.Variants))
+	for name := range d.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]*Dockerfile, 0, len(names))
+
+	for _, name := range names {
+		v, err := ApplyVariant(d, name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+
+	return list, nil
+}
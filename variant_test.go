@@ -0,0 +1,47 @@
+package dockerfileyml
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestVariants(t *testing.T) {
+	d, err := ReadFromYAML(strings.NewReader(`
+name: app
+from: python:3.9
+run: [pip install -r requirements.txt]
+variants:
+  slim:
+    from: python:3.9-slim
+  debug:
+    output: debug/Dockerfile
+    run: [pip install debugpy]
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	list, err := ExpandVariants(d)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(list).To(HaveLen(2))
+
+	debug, slim := list[0], list[1]
+
+	NewWithT(t).Expect(debug.Name).To(Equal("app-debug"))
+	NewWithT(t).Expect(debug.Output).To(Equal("debug/Dockerfile"))
+	NewWithT(t).Expect(debug.Run).To(Equal(Scripts("pip install -r requirements.txt", "pip install debugpy")))
+	NewWithT(t).Expect(debug.Variants).To(BeNil())
+
+	NewWithT(t).Expect(slim.Name).To(Equal("app-slim"))
+	NewWithT(t).Expect(slim.Output).To(Equal("Dockerfile.slim"))
+	NewWithT(t).Expect(slim.From).To(Equal("python:3.9-slim"))
+
+	NewWithT(t).Expect(d.From).To(Equal("python:3.9"))
+
+	_, err = ApplyVariant(d, "cuda")
+	NewWithT(t).Expect(err).To(MatchError("missing variant cuda"))
+
+	list, err = ExpandVariants(&Dockerfile{})
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(list).To(HaveLen(1))
+}
@@ -0,0 +1,134 @@
+package dockerfileyml
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// annotations resolved from git repository
+var vcsAnnotations = []string{"revision", "source", "created"}
+
+// WithVCS adds revision, source and created annotations to each document when reading,
+// which are written as org.opencontainers.image.* labels.
+//
+// Values could be provided, like revision: $CI_COMMIT_SHA,
+// the missing ones are resolved from the git repository of spec dir.
+// Annotations of spec take precedence.
+//
+// created is the commit time instead of now, to keep generated Dockerfile stable for same commit.
+func WithVCS(values Values) ReadOption {
+	return func(o *readOptions) {
+		o.vcs = Values{}
+		for k, v := range values {
+			o.vcs[k] = v
+		}
+	}
+}
+
+// ResolveVCS resolves revision, source and created annotations from git repository of dir,
+// source is skipped when without remote origin.
+func ResolveVCS(dir string) (Values, error) {
+	values := Values{}
+
+	revision, err := gitOutput(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	values["revision"] = revision
+
+	committed, err := gitOutput(dir, "show", "-s", "--format=%ct", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	sec, err := strconv.ParseInt(committed, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit time %s", committed)
+	}
+	values["created"] = time.Unix(sec, 0).UTC().Format(time.RFC3339)
+
+	// missing remote exits with 1
+	if origin, err := gitOutput(dir, "config", "--get", "remote.origin.url"); err == nil && origin != "" {
+		values["source"] = sourceURL(origin)
+	}
+
+	return values, nil
+}
+
+// applyVCS sets vcs annotations of d, resolves missing values from git repository of dir
+func applyVCS(d *Dockerfile, values Values, dir string) error {
+	for _, k := range vcsAnnotations {
+		if values[k] != "" {
+			continue
+		}
+
+		resolved, err := ResolveVCS(dir)
+		if err != nil {
+			return err
+		}
+		for k, v := range resolved {
+			if values[k] == "" {
+				values[k] = v
+			}
+		}
+		break
+	}
+
+	annotations := Values{}
+	for k, v := range values {
+		if v != "" {
+			annotations[k] = v
+		}
+	}
+	for k, v := range d.Annotations {
+		annotations[k] = v
+	}
+
+	d.Annotations = annotations
+
+	return nil
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	output, err := cmd.Output()
+	if err != nil {
+		if e, ok := err.(*exec.ExitError); ok && len(e.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(e.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+var reSCPLike = regexp.MustCompile(`^([^@/]+@)?([^:/]+):(.+)$`)
+
+// sourceURL converts remote url to https url without credentials,
+// like git@github.com:org/repo.git to https://github.com/org/repo
+func sourceURL(remote string) string {
+	if !strings.Contains(remote, "://") {
+		if m := reSCPLike.FindStringSubmatch(remote); m != nil {
+			remote = "https://" + m[2] + "/" + strings.TrimPrefix(m[3], "/")
+		}
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil {
+		return remote
+	}
+
+	if u.Scheme == "ssh" || u.Scheme == "git" {
+		u.Scheme = "https"
+		u.Host = u.Hostname()
+	}
+	u.User = nil
+
+	return strings.TrimSuffix(u.String(), ".git")
+}
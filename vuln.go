@@ -0,0 +1,284 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// severities of vulnerabilities, in order from the most severe
+var vulnSeverities = []string{"critical", "high", "medium", "low", "negligible", "unknown"}
+
+// VulnCounts are counts of vulnerabilities by severity in lower case, like critical
+type VulnCounts map[string]int
+
+func (c VulnCounts) String() string {
+	parts := make([]string, 0, len(c))
+	for _, severity := range vulnSeverities {
+		if n := c[severity]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, severity))
+		}
+	}
+	if len(parts) == 0 {
+		return "no vulnerabilities"
+	}
+	return strings.Join(parts, ", ") + " vulnerabilities"
+}
+
+// VulnScanner scans image for vulnerabilities
+type VulnScanner func(ctx context.Context, image string) (VulnCounts, error)
+
+// TrivyScanner scans images by trivy, command is trivy in PATH when empty
+func TrivyScanner(command string) VulnScanner {
+	if command == "" {
+		command = "trivy"
+	}
+	return execScanner(command, func(image string) []string {
+		return []string{"image", "--quiet", "--format", "json", image}
+	})
+}
+
+// GrypeScanner scans images by grype, command is grype in PATH when empty
+func GrypeScanner(command string) VulnScanner {
+	if command == "" {
+		command = "grype"
+	}
+	return execScanner(command, func(image string) []string {
+		return []string{image, "--quiet", "--output", "json"}
+	})
+}
+
+func execScanner(command string, args func(image string) []string) VulnScanner {
+	return func(ctx context.Context, image string) (VulnCounts, error) {
+		stdout := bytes.NewBuffer(nil)
+		stderr := bytes.NewBuffer(nil)
+
+		cmd := exec.CommandContext(ctx, command, args(image)...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, fmt.Errorf("%w: %s", err, msg)
+			}
+			return nil, err
+		}
+
+		_, counts, err := ParseVulnReport(stdout.Bytes())
+		return counts, err
+	}
+}
+
+// ReportScanner returns scanner of JSON reports of trivy or grype, which are scanned before,
+// like in another job of CI. Images are matched by artifact names of reports.
+func ReportScanner(files ...string) (VulnScanner, error) {
+	reports := map[string]VulnCounts{}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		image, counts, err := ParseVulnReport(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		reports[image] = counts
+	}
+
+	return func(ctx context.Context, image string) (VulnCounts, error) {
+		if counts, ok := reports[image]; ok {
+			return counts, nil
+		}
+		// reports of images without digests
+		if counts, ok := reports[strings.SplitN(image, "@", 2)[0]]; ok {
+			return counts, nil
+		}
+		return nil, fmt.Errorf("no report of image %s", image)
+	}, nil
+}
+
+// ParseVulnReport parses JSON report of trivy or grype, returns image scanned and counts of vulnerabilities
+func ParseVulnReport(data []byte) (string, VulnCounts, error) {
+	report := struct {
+		// trivy
+		ArtifactName string `json:"ArtifactName"`
+		Results      []struct {
+			Vulnerabilities []struct {
+				Severity string `json:"Severity"`
+			} `json:"Vulnerabilities"`
+		} `json:"Results"`
+
+		// grype
+		Matches []struct {
+			Vulnerability struct {
+				Severity string `json:"severity"`
+			} `json:"vulnerability"`
+		} `json:"matches"`
+		Source struct {
+			Target json.RawMessage `json:"target"`
+		} `json:"source"`
+	}{}
+
+	if err := json.Unmarshal(data, &report); err != nil {
+		return "", nil, fmt.Errorf("invalid report of trivy or grype: %w", err)
+	}
+
+	counts := VulnCounts{}
+
+	for _, result := range report.Results {
+		for _, v := range result.Vulnerabilities {
+			counts[strings.ToLower(v.Severity)]++
+		}
+	}
+
+	for _, m := range report.Matches {
+		counts[strings.ToLower(m.Vulnerability.Severity)]++
+	}
+
+	image := report.ArtifactName
+
+	if len(report.Source.Target) > 0 {
+		target := struct {
+			UserInput string `json:"userInput"`
+		}{}
+		if err := json.Unmarshal(report.Source.Target, &target); err == nil {
+			image = target.UserInput
+		}
+	}
+
+	return image, counts, nil
+}
+
+// VulnRule is a rule checking vulnerabilities of base images by Scanner,
+// findings are info with counts of vulnerabilities, or error when any count is over threshold.
+type VulnRule struct {
+	Scanner VulnScanner
+	// Thresholds are max counts of vulnerabilities by severity, like critical: 0, others are not limited
+	Thresholds VulnCounts
+	// Resolver resolves digests of images to scan when not nil, so images scanned are the ones built
+	Resolver *Resolver
+
+	// counts of scanned images, images are scanned once
+	scanned map[string]VulnCounts
+}
+
+func (r *VulnRule) Check(d *Dockerfile) (findings []Finding) {
+	stages := make([]string, 0, len(d.Stages))
+	for name := range d.Stages {
+		stages = append(stages, name)
+	}
+
+	isImage := imageRefChecker(d, stages)
+
+	eachStage(d, func(p string, s *Stage) {
+		image := imageOfFrom(s.From)
+		if !isImage(image) {
+			return
+		}
+
+		counts, err := r.scan(image)
+		if err != nil {
+			findings = append(findings, Finding{
+				Rule:     "vulnerabilities",
+				Severity: SeverityError,
+				Path:     fieldPath(p, "from"),
+				Message:  fmt.Sprintf("scan image %s: %s", image, err),
+			})
+			return
+		}
+
+		f := Finding{
+			Rule:     "vulnerabilities",
+			Severity: SeverityInfo,
+			Path:     fieldPath(p, "from"),
+			Message:  "image " + image + " has " + counts.String(),
+		}
+
+		if over := r.overThresholds(counts); len(over) > 0 {
+			f.Severity = SeverityError
+			f.Message += ", over thresholds of " + strings.Join(over, ", ")
+		}
+
+		findings = append(findings, f)
+	})
+
+	return
+}
+
+func (r *VulnRule) scan(image string) (VulnCounts, error) {
+	if counts, ok := r.scanned[image]; ok {
+		return counts, nil
+	}
+
+	ctx := context.Background()
+
+	ref := image
+	if r.Resolver != nil {
+		pinned, err := r.Resolver.Pin(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		ref = pinned
+	}
+
+	counts, err := r.Scanner(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.scanned == nil {
+		r.scanned = map[string]VulnCounts{}
+	}
+	r.scanned[image] = counts
+
+	return counts, nil
+}
+
+// overThresholds returns thresholds exceeded, like critical > 0
+func (r *VulnRule) overThresholds(counts VulnCounts) []string {
+	severities := make([]string, 0, len(r.Thresholds))
+	for severity := range r.Thresholds {
+		severities = append(severities, severity)
+	}
+	sort.Strings(severities)
+
+	over := make([]string, 0)
+	for _, severity := range severities {
+		if max := r.Thresholds[severity]; counts[strings.ToLower(severity)] > max {
+			over = append(over, fmt.Sprintf("%s > %d", strings.ToLower(severity), max))
+		}
+	}
+	return over
+}
+
+// WithVulnScan scans base images by r before writing, and fails when vulnerabilities are over thresholds,
+// counts of vulnerabilities are reported to diagnostics.
+func WithVulnScan(r *VulnRule) WriteOption {
+	return func(o *writeOptions) {
+		o.vulnScan = r
+	}
+}
+
+func vulnErrors(d *Dockerfile, r *VulnRule, report func(f Finding)) error {
+	errs := errorList{}
+
+	for _, f := range Lint(d, r) {
+		if f.Severity != SeverityError {
+			if report != nil {
+				report(f)
+			}
+			continue
+		}
+		errs.add(fieldError(f.Path, fmt.Errorf("%w: %s", ErrVulnerableImage, f.Message)))
+	}
+
+	return errs.err()
+}
@@ -0,0 +1,109 @@
+package dockerfileyml
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const trivyReport = `{
+  "ArtifactName": "alpine:3.18",
+  "Results": [
+    {"Vulnerabilities": [{"VulnerabilityID": "CVE-1", "Severity": "CRITICAL"}, {"VulnerabilityID": "CVE-2", "Severity": "HIGH"}]},
+    {"Vulnerabilities": [{"VulnerabilityID": "CVE-3", "Severity": "HIGH"}]}
+  ]
+}`
+
+const grypeReport = `{
+  "matches": [{"vulnerability": {"id": "CVE-4", "severity": "Medium"}}],
+  "source": {"type": "image", "target": {"userInput": "debian:12"}}
+}`
+
+func TestParseVulnReport(t *testing.T) {
+	image, counts, err := ParseVulnReport([]byte(trivyReport))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(image).To(Equal("alpine:3.18"))
+	NewWithT(t).Expect(counts).To(Equal(VulnCounts{"critical": 1, "high": 2}))
+	NewWithT(t).Expect(counts.String()).To(Equal("1 critical, 2 high vulnerabilities"))
+
+	image, counts, err = ParseVulnReport([]byte(grypeReport))
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(image).To(Equal("debian:12"))
+	NewWithT(t).Expect(counts).To(Equal(VulnCounts{"medium": 1}))
+
+	_, _, err = ParseVulnReport([]byte("oops"))
+	NewWithT(t).Expect(err).NotTo(BeNil())
+}
+
+func TestVulnRule(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vuln")
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "alpine.json"), []byte(trivyReport), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "debian.json"), []byte(grypeReport), 0644)
+
+	scanner, err := ReportScanner(filepath.Join(dir, "alpine.json"), filepath.Join(dir, "debian.json"))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	d, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: debian:12
+from: alpine:3.18@sha256:0000000000000000000000000000000000000000000000000000000000000000
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	t.Run("counts", func(t *testing.T) {
+		findings := Lint(d, &VulnRule{Scanner: scanner})
+		NewWithT(t).Expect(findings).To(HaveLen(2))
+		NewWithT(t).Expect(findings[0].String()).To(HavePrefix("5:1: info[vulnerabilities] from: image alpine:3.18@sha256:"))
+		NewWithT(t).Expect(findings[0].String()).To(HaveSuffix(" has 1 critical, 2 high vulnerabilities"))
+		NewWithT(t).Expect(findings[1].String()).To(Equal("4:5: info[vulnerabilities] stages.builder.from: image debian:12 has 1 medium vulnerabilities"))
+	})
+
+	t.Run("thresholds", func(t *testing.T) {
+		findings := Lint(d, &VulnRule{Scanner: scanner, Thresholds: VulnCounts{"critical": 0, "high": 5}})
+		NewWithT(t).Expect(findings[0].Severity).To(Equal(SeverityError))
+		NewWithT(t).Expect(findings[0].Message).To(HaveSuffix("over thresholds of critical > 0"))
+		NewWithT(t).Expect(findings[1].Severity).To(Equal(SeverityInfo))
+	})
+
+	t.Run("write with scan", func(t *testing.T) {
+		reported := make([]Finding, 0)
+
+		err := WriteToDockerfile(ioutil.Discard, *d, WithWriteDiagnostics(func(f Finding) {
+			reported = append(reported, f)
+		}), WithVulnScan(&VulnRule{Scanner: scanner, Thresholds: VulnCounts{"critical": 0}}))
+		NewWithT(t).Expect(errors.Is(err, ErrVulnerableImage)).To(BeTrue())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("5:1: from: vulnerable image: image alpine:3.18"))
+		NewWithT(t).Expect(reported).To(HaveLen(1))
+		NewWithT(t).Expect(reported[0].Path).To(Equal("stages.builder.from"))
+	})
+
+	t.Run("missing report", func(t *testing.T) {
+		_, err := scanner(context.Background(), "busybox")
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
+
+func TestTrivyScanner(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "trivy")
+	defer os.RemoveAll(dir)
+
+	// fake trivy, prints report of image
+	trivy := filepath.Join(dir, "trivy")
+	_ = ioutil.WriteFile(trivy, []byte("#!/bin/sh\n[ \"$*\" = \"image --quiet --format json alpine:3.18\" ] || exit 1\ncat <<'EOF'\n"+trivyReport+"\nEOF\n"), 0755)
+
+	counts, err := TrivyScanner(trivy)(context.Background(), "alpine:3.18")
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(counts).To(Equal(VulnCounts{"critical": 1, "high": 2}))
+
+	_, err = TrivyScanner(trivy)(context.Background(), "busybox")
+	NewWithT(t).Expect(err).NotTo(BeNil())
+}
@@ -0,0 +1,257 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+type ReadOption func(o *readOptions)
+
+type readOptions struct {
+	strict bool
+	// dir to resolve relative includes from
+	dir string
+	// absolute paths of files including current one
+	includes []string
+	// profiles to apply
+	profiles []string
+	// vcs annotations to add, nil when disabled
+	vcs Values
+	// reports non-fatal problems
+	diagnostics func(f Finding)
+	// filename of yaml for positions
+	filename string
+	// called with each local file included
+	included func(filename string)
+}
+
+// WithStrict makes reading fail on unknown fields and mismatched types,
+// with yaml path of each in error
+func WithStrict() ReadOption {
+	return func(o *readOptions) {
+		o.strict = true
+	}
+}
+
+// WithDir sets dir to resolve relative includes from,
+// ParseFile and ParseFileAll use dir of the file by default.
+func WithDir(dir string) ReadOption {
+	return func(o *readOptions) {
+		o.dir = dir
+	}
+}
+
+// ReadFromYAML decodes Dockerfile from content of dockerfile.yml.
+//
+// Anchors and merge keys are resolved before validation,
+// so fragments could be shared between stages,
+// and keys prefixed with x- are ignored for holding them:
+//
+//	x-go: &go
+//	  from: golang:1.15
+//	  workdir: /go/src
+//	stages:
+//	  builder:
+//	    <<: *go
+//	    run: [go build]
+//
+// Scalars are resolved by YAML 1.2, so y, yes and on are strings, booleans are true or false only.
+func ReadFromYAML(r io.Reader, opts ...ReadOption) (*Dockerfile, error) {
+	list, err := ReadAllFromYAML(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(list) {
+	case 0:
+		return &Dockerfile{}, nil
+	case 1:
+		return list[0], nil
+	}
+
+	return nil, fmt.Errorf("yaml contains %d documents, use ReadAllFromYAML instead", len(list))
+}
+
+// ReadAllFromYAML decodes each document of yaml stream as a Dockerfile.
+// When more than one, each should be named by name or output, and unique.
+func ReadAllFromYAML(r io.Reader, opts ...ReadOption) ([]*Dockerfile, error) {
+	o := &readOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// positions are optional, decoding reports errors of syntax
+	positions, _ := PositionsOf(data, o.filename)
+	comments, _ := commentsOf(data)
+
+	list := make([]*Dockerfile, 0)
+	// decoded by yaml.v3 as positions and comments, so keys of them are same,
+	// scalars are resolved by YAML 1.2, like y, yes and on are strings instead of true.
+	decoder := yamlv3.NewDecoder(bytes.NewReader(data))
+
+	for i := 0; ; i++ {
+		var v interface{}
+
+		if err := decoder.Decode(&v); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		v = yamlv2Value(v)
+
+		if v == nil {
+			continue
+		}
+
+		var p Positions
+		if i < len(positions) {
+			p = positions[i]
+		}
+
+		d, err := decodeDocument(v, o, p)
+		if err != nil {
+			if i > 0 {
+				return nil, fmt.Errorf("document %d: %w", i, err)
+			}
+			return nil, err
+		}
+
+		if i < len(comments) {
+			d.comments = comments[i]
+		}
+
+		list = append(list, d)
+	}
+
+	if len(list) > 1 {
+		names := map[string]bool{}
+
+		for i, d := range list {
+			name := d.documentName()
+			if name == "" {
+				return nil, fmt.Errorf("document %d: name or output is required in multi-document yaml", i)
+			}
+			if names[name] {
+				return nil, fmt.Errorf("document %d: duplicated %s", i, name)
+			}
+			names[name] = true
+		}
+	}
+
+	return list, nil
+}
+
+// yamlv2Value converts maps of v decoded by yaml.v3 into maps of yaml.v2,
+// which are decoded into Dockerfile after merges and migrations.
+func yamlv2Value(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(x))
+		for key, value := range x {
+			m[key] = yamlv2Value(value)
+		}
+		return m
+	case map[interface{}]interface{}:
+		for key, value := range x {
+			x[key] = yamlv2Value(value)
+		}
+		return x
+	case []interface{}:
+		for i := range x {
+			x[i] = yamlv2Value(x[i])
+		}
+		return x
+	}
+	return v
+}
+
+func decodeDocument(v interface{}, o *readOptions, positions Positions) (*Dockerfile, error) {
+	d := &Dockerfile{}
+
+	v, err := migrate(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.strict {
+		// yaml.UnmarshalStrict treats keys overridden after merge as duplicated,
+		// so checking on the merged result instead.
+		if err := checkStrict(v, d); err != nil {
+			return nil, err
+		}
+	} else if o.diagnostics != nil {
+		for _, f := range strictFindings(v, d) {
+			f.Position = positions.Of(f.Path)
+			o.diagnostics(f)
+		}
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+
+	orderKeyValues(reflect.ValueOf(d), "", positions)
+
+	if err := resolveIncludes(d, o, o.includes); err != nil {
+		return nil, err
+	}
+
+	if len(o.profiles) > 0 {
+		d, err = ApplyProfiles(d, o.profiles...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.vcs != nil {
+		if err := applyVCS(d, o.vcs, o.dir); err != nil {
+			return nil, err
+		}
+	}
+
+	d.positions = positions
+
+	return d, nil
+}
+
+// ParseFile decodes Dockerfile from dockerfile.yml file
+func ParseFile(filename string, opts ...ReadOption) (*Dockerfile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadFromYAML(f, append([]ReadOption{WithDir(filepath.Dir(filename)), WithFilename(filename)}, opts...)...)
+}
+
+// ParseFileAll decodes all Dockerfile from multi-document dockerfile.yml file
+func ParseFileAll(filename string, opts ...ReadOption) ([]*Dockerfile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadAllFromYAML(f, append([]ReadOption{WithDir(filepath.Dir(filename)), WithFilename(filename)}, opts...)...)
+}
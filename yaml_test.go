@@ -0,0 +1,222 @@
+package dockerfileyml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/go-courier/snapshotmacther"
+	. "github.com/onsi/gomega"
+)
+
+func TestReadFromYAML(t *testing.T) {
+	t.Run("parse file", func(t *testing.T) {
+		d, err := ParseFile("testdata/multistage.yml")
+		NewWithT(t).Expect(err).To(BeNil())
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("multistage.Dockerfile"))
+	})
+
+	t.Run("include", func(t *testing.T) {
+		d, err := ParseFile("testdata/include/dockerfile.yml")
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Stages).To(HaveKey("gobase"))
+		NewWithT(t).Expect(d.Snippets).To(HaveKey("build"))
+
+		buf := bytes.NewBuffer(nil)
+		err = WriteToDockerfile(buf, *d)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(buf.String()).To(MatchSnapshot("include.Dockerfile"))
+
+		included := make([]string, 0)
+		_, err = ParseFile("testdata/include/dockerfile.yml", WithIncludedFiles(func(filename string) {
+			included = append(included, filename)
+		}))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(included).To(HaveLen(1))
+		NewWithT(t).Expect(included[0]).To(HaveSuffix("testdata/include/base/go.yml"))
+
+		_, err = ReadFromYAML(strings.NewReader("include: [dockerfile.yml]\n"), WithDir("testdata/include"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+
+		_, err = ReadFromYAML(strings.NewReader("include: [missing.yml]\n"), WithDir("testdata/include"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+
+		_, err = ReadFromYAML(strings.NewReader("include: [../dockerfile.yml]\nfrom: busybox\n"), WithDir("testdata/include/base"))
+		NewWithT(t).Expect(err).To(MatchError(ContainSubstring("only stages and snippets could be included")))
+	})
+
+	t.Run("scalars of yaml 1.2", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader("from: alpine\nenv:\n  Z: 1\n  Y: y\n  A: on\n"))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Env).To(Equal(KeyValues{{Key: "Z", Value: "1"}, {Key: "Y", Value: "y"}, {Key: "A", Value: "on"}}))
+		NewWithT(t).Expect(d.positions.Of("env.Y")).To(Equal(Position{Line: 4, Column: 3}))
+
+		findings := make([]Finding, 0)
+		_, err = ReadFromYAML(strings.NewReader("from: alpine\nY: yes\n"), WithReadDiagnostics(func(f Finding) {
+			findings = append(findings, f)
+		}))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(findings).To(HaveLen(1))
+		NewWithT(t).Expect(findings[0].Path).To(Equal("Y"))
+		NewWithT(t).Expect(findings[0].Position.Line).To(Equal(2))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader(""))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d).To(Equal(&Dockerfile{}))
+	})
+
+	t.Run("version", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader("from: busybox\n"))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Version).To(Equal(CurrentVersion))
+
+		_, err = ReadFromYAML(strings.NewReader("version: 99\nfrom: busybox\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ReadFromYAML(strings.NewReader("run: {"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := ReadFromYAML(strings.NewReader(`
+stages:
+  builder:
+    from: busybox
+    comand: [sh]
+    run:
+      - cmd: make
+        no-join: yes please
+from: busybox
+copy: [./]
+`), WithStrict())
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(Equal(`yaml: strict errors:
+  copy[0]: should be mapping, but got scalar
+  stages.builder.comand: unknown field
+  stages.builder.run[0].no-join: should be bool, but got scalar`))
+	})
+
+	t.Run("strict valid", func(t *testing.T) {
+		_, err := ParseFile("testdata/multistage.yml", WithStrict())
+		NewWithT(t).Expect(err).To(BeNil())
+	})
+
+	t.Run("anchors and merge keys", func(t *testing.T) {
+		d, err := ReadFromYAML(strings.NewReader(`
+x-go: &go
+  from: golang:1.15
+  workdir: /go/src
+  env: &go-env
+    CGO_ENABLED: "0"
+stages:
+  builder:
+    <<: *go
+    env:
+      <<: *go-env
+      GOOS: linux
+    run: [go build -o app]
+  tester:
+    <<: *go
+    workdir: /go/test
+    run: [go test ./...]
+from: busybox
+workdir: /todo
+copy:
+  builder:./app: ./
+`), WithStrict())
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(d.Stages["builder"].Env.Values()).To(Equal(Values{"CGO_ENABLED": "0", "GOOS": "linux"}))
+		NewWithT(t).Expect(d.Stages["tester"].WorkingDir).To(Equal("/go/test"))
+		NewWithT(t).Expect(d.Stages["tester"].From).To(Equal("golang:1.15"))
+	})
+
+	t.Run("strict errors of merged result", func(t *testing.T) {
+		_, err := ReadFromYAML(strings.NewReader(`
+x-go: &go
+  from: golang:1.15
+  wrokdir: /go/src
+stages:
+  builder:
+    <<: *go
+`), WithStrict())
+		NewWithT(t).Expect(err).NotTo(BeNil())
+		NewWithT(t).Expect(err.Error()).To(ContainSubstring("stages.builder.wrokdir: unknown field"))
+	})
+
+	t.Run("multi documents", func(t *testing.T) {
+		list, err := ReadAllFromYAML(strings.NewReader(`
+name: api
+output: cmd/api/Dockerfile
+from: busybox
+cmd: [api]
+---
+name: worker
+output: cmd/worker/Dockerfile
+from: busybox
+cmd: [worker]
+`))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(list).To(HaveLen(2))
+		NewWithT(t).Expect(list[1].Output).To(Equal("cmd/worker/Dockerfile"))
+		NewWithT(t).Expect(list[1].Command).To(Equal([]string{"worker"}))
+
+		_, err = ReadFromYAML(strings.NewReader("name: a\n---\nname: b\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("multi documents without name", func(t *testing.T) {
+		_, err := ReadAllFromYAML(strings.NewReader("from: busybox\n---\nfrom: alpine\n"))
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
+
+func TestOrderOfValues(t *testing.T) {
+	d, err := ReadFromYAML(bytes.NewBufferString(`
+from: busybox
+env:
+  PREFIX: /opt/app
+  BIN: $PREFIX/bin
+  PATH: ${BIN}:$PATH
+  DEBUG: "false"
+label:
+  z: "1"
+  a: "2"
+stages:
+  builder:
+    from: golang
+    arg:
+      VERSION: "1.0"
+      LDFLAGS: -X main.version=$VERSION
+`))
+	NewWithT(t).Expect(err).To(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(WriteToDockerfile(buf, *d)).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`FROM golang AS builder
+
+ARG VERSION=1.0
+
+ARG LDFLAGS="-X main.version=$VERSION"
+
+FROM busybox
+
+LABEL z=1
+
+LABEL a=2
+
+ENV PREFIX=/opt/app
+
+ENV BIN=$PREFIX/bin
+
+ENV PATH=${BIN}:$PATH DEBUG=false
+
+`))
+}